@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/agent"
+	"github.com/cryptonextsecurity/network-sniffer/internal/collector"
+	"github.com/cryptonextsecurity/network-sniffer/internal/config"
+	"github.com/cryptonextsecurity/network-sniffer/internal/health"
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// runAgent captures packets and forwards them to the collector instead of
+// storing them, until interrupted. Only the metrics and the health probes
+// are served over HTTP.
+func runAgent(cfg *config.Config, logger *slog.Logger, shutdownTracing func(context.Context) error) {
+	hostname, _ := os.Hostname()
+	id := cfg.Agent.ID
+	if id == "" {
+		id = hostname
+	}
+	if err := collector.ValidateID(id); err != nil {
+		fatal("Invalid agent ID, set agent.id", err)
+	}
+
+	// Connect to the collector over TLS unless told otherwise; connections
+	// are opened lazily and re-established by the agent
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if cfg.Agent.Insecure {
+		creds = insecure.NewCredentials()
+	} else if cfg.Agent.CAFile != "" {
+		var err error
+		if creds, err = credentials.NewClientTLSFromFile(cfg.Agent.CAFile, ""); err != nil {
+			fatal("Failed to load collector CA certificate", err)
+		}
+	}
+	conn, err := grpc.Dial(cfg.Agent.CollectorAddress, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		fatal("Invalid collector address", err)
+	}
+	if cfg.Agent.APIKey != "" && cfg.Agent.Insecure {
+		logger.Warn("Sending the agent API key over an unencrypted connection")
+	}
+
+	// Buffer batches on disk until the collector acknowledges them
+	buffer, err := agent.OpenBuffer(cfg.Agent.BufferDir, int64(cfg.Agent.BufferMaxMB)<<20)
+	if err != nil {
+		fatal("Failed to open agent buffer", err)
+	}
+	agentConfig := agent.DefaultConfig()
+	agentConfig.ID = id
+	agentConfig.Hostname = hostname
+	agentConfig.APIKey = cfg.Agent.APIKey
+	agentConfig.BatchSize = cfg.Agent.BatchSize
+	agentConfig.FlushInterval = cfg.Agent.FlushInterval
+	forwarder := agent.New(agentConfig, buffer, conn, logger)
+	sniffer := newSniffer(cfg, forwarder, logger)
+
+	// Readiness fails while the collector is unreachable, though packets
+	// keep being captured and buffered
+	liveness := health.NewChecker(cfg.Health.CheckTimeout)
+	readiness := health.NewChecker(cfg.Health.CheckTimeout).
+		Register("sniffer", health.SnifferCheck(sniffer)).
+		Register("collector", health.ForwarderCheck(forwarder)).
+		Register("disk", health.DiskCheck([]string{filepath.Join(cfg.Agent.BufferDir, "buffer.json")}, uint64(cfg.Health.MinFreeDiskMB)<<20))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", probe(liveness))
+	mux.HandleFunc("/readyz", probe(readiness))
+	server := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: mux,
+	}
+
+	ctx := context.Background()
+	forwarder.Start()
+	if err := sniffer.Start(ctx); err != nil {
+		logger.Error("Failed to start packet sniffing", "error", err.Error())
+	}
+	batches, _ := buffer.Len()
+	logger.Info("Forwarding packets to the collector",
+		"agent_id", id,
+		"collector", cfg.Agent.CollectorAddress,
+		"buffer_dir", cfg.Agent.BufferDir,
+		"buffered_batches", batches,
+	)
+
+	go func() {
+		logger.Info("Server starting", "port", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("Failed to start server", err)
+		}
+	}()
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	logger.Info("Shutting down agent")
+	sniffer.Stop(ctx)
+
+	// Keep the packets not forwarded yet for the next run
+	if err := forwarder.Stop(); err != nil {
+		logger.Error("Failed to buffer pending packets", "error", err.Error())
+	}
+	batches, _ = buffer.Len()
+	logger.Info("Agent stopped", "buffered_batches", batches)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	conn.Close()
+
+	// Flush pending spans
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("Failed to flush traces", "error", err.Error())
+	}
+}
+
+// probe serves the report of checker, with a 503 status when a component
+// is degraded
+func probe(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Run(r.Context())
+		status := http.StatusOK
+		if report.Status != models.HealthOK {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -2,78 +2,439 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	_ "github.com/cryptonextsecurity/network-sniffer/docs" // Swagger docs
+	"github.com/cryptonextsecurity/network-sniffer/internal/aggregate"
+	"github.com/cryptonextsecurity/network-sniffer/internal/alerting"
 	"github.com/cryptonextsecurity/network-sniffer/internal/api"
+	"github.com/cryptonextsecurity/network-sniffer/internal/audit"
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/collector"
 	"github.com/cryptonextsecurity/network-sniffer/internal/config"
+	"github.com/cryptonextsecurity/network-sniffer/internal/detection"
+	"github.com/cryptonextsecurity/network-sniffer/internal/grpcapi"
+	"github.com/cryptonextsecurity/network-sniffer/internal/health"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ingest"
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/netflow"
+	"github.com/cryptonextsecurity/network-sniffer/internal/notify"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ratelimit"
 	"github.com/cryptonextsecurity/network-sniffer/internal/services"
 	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/internal/tracing"
 	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+// @title Network Sniffer API
+// @version 1.0
+// @description Simulated network packet capture, analytics and detection service.
+// @BasePath /api/v1
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key created through /auth/keys or configured with AUTH_ADMIN_KEY
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT signed with AUTH_JWT_SECRET, sent as "Bearer <token>"
 func main() {
-	log.Println("Starting Network Sniffing Service...")
+	configFile := flag.String("config", "", "YAML configuration file (default $CONFIG_FILE)")
+	flag.Parse()
 
-	// Load configuration from environment variables
-	cfg := config.Load()
+	// Load configuration from the YAML file and environment variables
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+
+	// Log structured records to stdout; the standard log package writes
+	// through the same logger. The level can be changed by a reload.
+	logLevel := new(slog.LevelVar)
+	logger, err := logging.New(os.Stdout, logging.Config{
+		Level:    cfg.Logging.Level,
+		Format:   cfg.Logging.Format,
+		LevelVar: logLevel,
+	})
+	if err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+	slog.SetDefault(logger)
 
-	log.Printf("Configuration: Storage Max Size=%d, Sniffing Interval=%v, Server Port=%s, Shutdown Timeout=%v",
-		cfg.StorageMaxSize, cfg.SniffingInterval, cfg.ServerPort, cfg.ShutdownTimeout)
+	logger.Info("Starting Network Sniffing Service",
+		"mode", cfg.Mode,
+		"config_file", cfg.File,
+		"storage_max_size", cfg.Storage.MaxSize,
+		"sniffing_interval", cfg.Capture.Interval.String(),
+		"server_port", cfg.Server.Port,
+		"shutdown_timeout", cfg.Server.ShutdownTimeout.String(),
+	)
+
+	// Export traces of API requests, service calls and storage operations
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     cfg.Tracing.Exporter,
+		ServiceName:  cfg.Tracing.ServiceName,
+		File:         cfg.Tracing.File,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		logger.Info("Exporting traces", "exporter", cfg.Tracing.Exporter)
+	}
+
+	// Agents forward the packets they capture instead of storing them
+	if cfg.Mode == config.ModeAgent {
+		runAgent(cfg, logger, shutdownTracing)
+		return
+	}
 
 	// Create storage
-	storage := storage.NewInMemoryStorage(cfg.StorageMaxSize)
+	storage := storage.NewInMemoryStorage(cfg.Storage.MaxSize).WithSessionCapacity(cfg.Upload.MaxPackets)
+
+	// Maintain rolling aggregates as packets are stored
+	aggregator := aggregate.New(aggregate.DefaultConfig())
+	storage.AddObserver(aggregator)
+
+	// Evaluate alert rules against stored packets
+	alertEngine := alerting.NewEngine(cfg.Alerting.HistorySize)
+	if cfg.Alerting.RulesFile != "" {
+		if err := alertEngine.LoadRules(cfg.Alerting.RulesFile); err != nil {
+			fatal("Failed to load alert rules", err)
+		}
+		logger.Info("Loaded alert rules", "count", len(alertEngine.Rules()), "file", cfg.Alerting.RulesFile)
+	}
+	storage.AddObserver(alertEngine)
+
+	// Detect port scans and host sweeps
+	findings := detection.NewFindingStore(cfg.Detection.FindingsHistorySize)
+	scanDetector := detection.NewScanDetector(detection.ScanConfig{
+		Window:              cfg.Detection.Scan.Window,
+		VerticalThreshold:   cfg.Detection.Scan.VerticalThreshold,
+		HorizontalThreshold: cfg.Detection.Scan.HorizontalThreshold,
+		HalfOpenThreshold:   cfg.Detection.Scan.HalfOpenThreshold,
+		Cooldown:            cfg.Detection.Scan.Cooldown,
+	}, findings)
+	storage.AddObserver(scanDetector)
+
+	// Learn traffic baselines and flag deviations
+	anomalyDetector := detection.NewAnomalyDetector(detection.AnomalyConfig{
+		Interval:   cfg.Detection.Anomaly.Interval,
+		Alpha:      cfg.Detection.Anomaly.Alpha,
+		ZThreshold: cfg.Detection.Anomaly.ZThreshold,
+		MinSamples: cfg.Detection.Anomaly.MinSamples,
+		Cooldown:   cfg.Detection.Anomaly.Cooldown,
+	}, findings)
+	storage.AddObserver(anomalyDetector)
+
+	// Export the flows of live traffic to NetFlow v9 or IPFIX collectors
+	var flowExporter *netflow.Exporter
+	if len(cfg.FlowExport.Collectors) > 0 {
+		flowExporter, err = netflow.New(netflow.Config{
+			Protocol:         cfg.FlowExport.Protocol,
+			Collectors:       cfg.FlowExport.Collectors,
+			ActiveTimeout:    cfg.FlowExport.ActiveTimeout,
+			InactiveTimeout:  cfg.FlowExport.InactiveTimeout,
+			TemplateInterval: cfg.FlowExport.TemplateInterval,
+			MaxFlows:         cfg.FlowExport.MaxFlows,
+			DomainID:         uint32(cfg.FlowExport.DomainID),
+		}, logger)
+		if err != nil {
+			fatal("Failed to set up flow export", err)
+		}
+		storage.AddObserver(flowExporter)
+		flowExporter.Start()
+		logger.Info("Exporting flows", "protocol", cfg.FlowExport.Protocol, "collectors", len(cfg.FlowExport.Collectors))
+	}
+
+	// Deliver alerts and findings to the configured webhooks
+	deadLetters, err := notify.OpenDeadLetterQueue(cfg.Webhooks.DeadLetterFile, cfg.Webhooks.DeadLetterMaxSize)
+	if err != nil {
+		fatal("Failed to open webhook dead-letter queue", err)
+	}
+	endpoints := make([]notify.Endpoint, 0, len(cfg.Webhooks.URLs))
+	for _, url := range cfg.Webhooks.URLs {
+		endpoints = append(endpoints, notify.Endpoint{URL: url, Secret: cfg.Webhooks.Secret})
+	}
+	notifier := notify.NewDispatcher(notify.Config{
+		Endpoints:      endpoints,
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		InitialBackoff: cfg.Webhooks.InitialBackoff,
+		MaxBackoff:     cfg.Webhooks.MaxBackoff,
+		Timeout:        cfg.Webhooks.Timeout,
+		QueueSize:      cfg.Webhooks.QueueSize,
+	}, deadLetters)
+	alertEngine.OnAlert(notifier.NotifyAlert)
+	findings.OnFinding(notifier.NotifyFinding)
+	notifier.Start()
+	if len(endpoints) > 0 {
+		logger.Info("Delivering notifications to webhooks", "endpoints", len(endpoints))
+	}
 
 	// Create sniffer
-	sniffer := sniffing.NewPacketSniffer(storage, cfg.SniffingInterval)
+	sniffer := newSniffer(cfg, storage, logger)
 
 	// Create service
-	packetService := services.NewPacketService(storage, sniffer, nil)
+	packetService := services.NewPacketService(storage, sniffer, logger).
+		WithAggregator(aggregator).
+		WithSnifferConfigFile(cfg.Capture.ConfigFile)
+
+	// Decode uploaded captures into sessions of their own
+	if cfg.Upload.Dir != "" {
+		if err := os.MkdirAll(cfg.Upload.Dir, 0o700); err != nil {
+			fatal("Failed to create upload directory", err)
+		}
+	}
+	ingestJobs := ingest.NewJobs(ingest.Config{
+		MaxSize:     int64(cfg.Upload.MaxSizeMB) << 20,
+		Dir:         cfg.Upload.Dir,
+		HistorySize: cfg.Upload.HistorySize,
+	}, storage, logger)
+	ingestJobs.Start()
+
+	// Authenticate API clients with API keys and JWTs
+	keys, err := auth.OpenKeyStore(cfg.Auth.KeysFile)
+	if err != nil {
+		fatal("Failed to open API key store", err)
+	}
+	if cfg.Auth.AdminKey != "" {
+		keys.AddStatic("admin", cfg.Auth.AdminKey, auth.RoleAdmin)
+	}
+	if cfg.Auth.Enabled && len(keys.List()) == 0 && cfg.Auth.JWTSecret == "" {
+		fatal("Authentication is enabled but no API key or JWT secret is configured",
+			errors.New("set AUTH_ADMIN_KEY, AUTH_KEYS_FILE or AUTH_JWT_SECRET, or AUTH_ENABLED=false to serve every request as admin"))
+	}
+
+	// Record mutating API operations
+	auditLog, err := audit.Open(cfg.Audit.LogFile, cfg.Audit.HistorySize)
+	if err != nil {
+		fatal("Failed to open audit log", err)
+	}
+
+	// Liveness only checks that storage is not wedged; readiness also
+	// checks capture, notification delivery and the disk of persistent files
+	liveness := health.NewChecker(cfg.Health.CheckTimeout).
+		Register("storage", health.StorageCheck(storage))
+	readiness := health.NewChecker(cfg.Health.CheckTimeout).
+		Register("storage", health.StorageCheck(storage)).
+		Register("sniffer", health.SnifferCheck(sniffer)).
+		Register("notifier", health.NotifierCheck(notifier, cfg.Health.NotificationBacklogRatio))
+	persistentFiles := []string{cfg.Audit.LogFile, cfg.Auth.KeysFile, cfg.Webhooks.DeadLetterFile}
+	if cfg.Tracing.Exporter == tracing.ExporterFile {
+		persistentFiles = append(persistentFiles, cfg.Tracing.File)
+	}
+	for _, path := range persistentFiles {
+		if path != "" {
+			readiness.Register("disk", health.DiskCheck(persistentFiles, uint64(cfg.Health.MinFreeDiskMB)<<20))
+			break
+		}
+	}
+
+	// Collectors track the capture agents forwarding packets to them
+	var agents *collector.Registry
+	if cfg.Mode == config.ModeCollector {
+		agents = collector.NewRegistry(cfg.Collector.MaxAgents)
+	}
 
 	// Create handler and router
-	handler := api.NewHandler(packetService, nil)
-	router := api.NewRouter(handler, nil)
+	handler := api.NewHandler(packetService, logger).
+		WithAlerting(alertEngine).
+		WithDetections(findings, anomalyDetector).
+		WithNotifications(notifier).
+		WithAPIKeys(keys).
+		WithIngestJobs(ingestJobs).
+		WithAudit(auditLog).
+		WithAgents(agents).
+		WithHealth(liveness, readiness)
+	router := api.NewRouter(handler, logger).
+		WithCORS(cfg.Server.CORSAllowedOrigins).
+		WithTrustedProxies(cfg.Server.TrustedProxies)
+	if cfg.RateLimit.Enabled {
+		router.WithRateLimits(rateLimits(cfg))
+	}
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled {
+		authenticator = auth.NewAuthenticator(keys, &auth.JWTConfig{
+			Secret:   cfg.Auth.JWTSecret,
+			Issuer:   cfg.Auth.JWTIssuer,
+			Audience: cfg.Auth.JWTAudience,
+		})
+		router.WithAuth(authenticator)
+	} else {
+		logger.Warn("Authentication is disabled, every request is served with the admin role")
+	}
 	ginRouter := router.Setup()
 
+	// Serve the same operations over gRPC, streaming live packets to
+	// subscribers
+	feed := grpcapi.NewFeed(cfg.GRPC.SubscriberBuffer)
+	storage.AddObserver(feed)
+	grpcServer := grpcapi.NewServer(packetService, feed, logger).WithAudit(auditLog)
+	if cfg.RateLimit.Enabled {
+		limits := rateLimits(cfg)
+		grpcServer.WithRateLimits(limits.Standard, limits.Expensive)
+	}
+	if authenticator != nil {
+		grpcServer.WithAuth(authenticator)
+	}
+	if agents != nil {
+		grpcServer.WithCollector(agents)
+		logger.Info("Receiving packets from capture agents", "port", cfg.GRPC.Port)
+	}
+	if cfg.GRPC.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.GRPC.TLSCertFile, cfg.GRPC.TLSKeyFile)
+		if err != nil {
+			fatal("Failed to load gRPC TLS certificate", err)
+		}
+		grpcServer.WithOptions(grpc.Creds(creds))
+	}
+	rpcServer := grpcServer.Setup()
+
 	// Auto-start sniffing on startup
 	ctx := context.Background()
-	log.Println("Starting packet sniffing...")
 	if err := packetService.StartSniffing(ctx); err != nil {
-		log.Printf("Failed to start packet sniffing: %v", err)
+		logger.Error("Failed to start packet sniffing", "error", err.Error())
 	}
 
+	// Apply the settings that can change at runtime when the configuration
+	// file changes or on SIGHUP
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	watcher := config.NewWatcher(cfg, config.DefaultWatchInterval, logger).
+		OnReload(func(previous, next *config.Config) {
+			level, _ := logging.ParseLevel(next.Logging.Level)
+			logLevel.Set(level)
+			limits := rateLimits(next)
+			router.UpdateRateLimits(limits)
+			grpcServer.UpdateRateLimits(limits.Standard, limits.Expensive)
+
+			var patch models.SnifferConfigPatch
+			if next.Capture.Interval != previous.Capture.Interval {
+				interval := models.Duration(next.Capture.Interval)
+				patch.Interval = &interval
+			}
+			if next.Capture.ScanProbability != previous.Capture.ScanProbability {
+				probability := next.Capture.ScanProbability
+				patch.ScanProbability = &probability
+			}
+			if patch.Interval != nil || patch.ScanProbability != nil {
+				if _, err := packetService.UpdateSnifferConfig(watchCtx, patch); err != nil {
+					logger.Error("Failed to apply capture configuration", "error", err.Error())
+				}
+			}
+		})
+	go watcher.Run(watchCtx)
+
 	// Setup server
 	server := &http.Server{
-		Addr:    ":" + cfg.ServerPort,
+		Addr:    ":" + cfg.Server.Port,
 		Handler: ginRouter,
 	}
 
 	// Start server
 	go func() {
-		log.Printf("Server starting on port %s", cfg.ServerPort)
+		logger.Info("Server starting", "port", cfg.Server.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", err)
 		}
 	}()
 
+	if cfg.GRPC.Enabled {
+		listener, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+		if err != nil {
+			fatal("Failed to start gRPC server", err)
+		}
+		go func() {
+			logger.Info("gRPC server starting", "port", cfg.GRPC.Port)
+			if err := rpcServer.Serve(listener); err != nil {
+				fatal("Failed to serve gRPC", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	logger.Info("Shutting down server")
+	stopWatching()
 
 	// Stop sniffing
 	packetService.StopSniffing(ctx)
-	log.Println("Packet sniffing stopped")
+
+	// Interrupt capture decoding
+	ingestJobs.Stop()
+
+	// Export the flows in progress
+	if flowExporter != nil {
+		flowExporter.Stop()
+	}
+
+	// Persist undelivered notifications
+	notifier.Stop()
+	logger.Info("Notification dispatcher stopped", "dead_letters", notifier.DeadLetterCount())
 
 	// Shutdown server
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	server.Shutdown(shutdownCtx)
-	log.Println("Server stopped")
+	grpcapi.Shutdown(shutdownCtx, rpcServer, feed)
+	logger.Info("Server stopped")
+
+	// Flush pending spans
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("Failed to flush traces", "error", err.Error())
+	}
+}
+
+// newSniffer creates the sniffer capturing into storage. Capture parameters
+// changed through the API take precedence over the environment.
+func newSniffer(cfg *config.Config, storage sniffing.Storage, logger *slog.Logger) *sniffing.PacketSniffer {
+	sniffer := sniffing.NewPacketSniffer(storage, cfg.Capture.Interval)
+	sniffer.SetScanProbability(cfg.Capture.ScanProbability)
+	sniffer.SetLogger(logger)
+
+	if cfg.Capture.ConfigFile != "" {
+		saved, err := sniffing.LoadConfig(cfg.Capture.ConfigFile)
+		if err != nil {
+			fatal("Failed to load sniffer configuration", err)
+		}
+		if saved != nil {
+			if err := sniffer.Configure(*saved); err != nil {
+				fatal("Failed to apply sniffer configuration", err)
+			}
+			logger.Info("Loaded sniffer configuration", "file", cfg.Capture.ConfigFile)
+		}
+	}
+	return sniffer
+}
+
+// rateLimits returns the per-client budgets of cfg
+func rateLimits(cfg *config.Config) api.RateLimits {
+	return api.RateLimits{
+		Standard:  ratelimit.Limit{Rate: cfg.RateLimit.Rate, Burst: cfg.RateLimit.Burst},
+		Expensive: ratelimit.Limit{Rate: cfg.RateLimit.ExpensiveRate, Burst: cfg.RateLimit.ExpensiveBurst},
+	}
+}
+
+// fatal logs an error that prevents the service from running and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err.Error())
+	os.Exit(1)
 }
@@ -0,0 +1,197 @@
+// Command sniffer runs the packet processing of the network sniffer service
+// as batch jobs, without the HTTP server.
+//
+// Usage:
+//
+//	sniffer analyze [flags] FILE...
+//
+// analyze loads pcap, pcapng and exported packet dumps, optionally gzip
+// compressed, and prints their protocol mix, top talkers, flows, crypto
+// inventory and detections as text or as a JSON report.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/analysis"
+	"github.com/cryptonextsecurity/network-sniffer/internal/config"
+	"github.com/cryptonextsecurity/network-sniffer/internal/detection"
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+)
+
+// Exit statuses
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command line args and returns the exit status
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return exitOK
+	}
+	if args[0] != "analyze" {
+		fmt.Fprintf(stderr, "sniffer: unknown command %q\n\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+	return analyze(ctx, args[1:], stdin, stdout, stderr)
+}
+
+// usage lists the commands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: sniffer <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  analyze FILE...  Summarise pcap, pcapng and packet dump files; - reads standard input")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "sniffer <command> -h" for the flags of a command.`)
+}
+
+// analyze loads files into an in-process analyzer and prints its report
+func analyze(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("sniffer analyze", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "text", "report format: text or json")
+	output := fs.String("o", "", "write the report to this file instead of standard output")
+	top := fs.Int("top", 10, "number of top talkers and flows, 0 for all")
+	maxPackets := fs.Int("max-packets", analysis.DefaultConfig().MaxPackets, "maximum number of packets held for the report")
+	configFile := fs.String("config", "", "YAML configuration file of the detection thresholds (default $CONFIG_FILE)")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: sniffer analyze [flags] FILE...\n\n")
+		fmt.Fprint(stderr, "Load pcap, pcapng, JSON and NDJSON packet files, optionally gzip compressed, in\n")
+		fmt.Fprint(stderr, "chronological order and report on their packets. - reads standard input.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "sniffer analyze: expected at least one file")
+		fs.Usage()
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "sniffer analyze: unknown format %q, expected text or json\n", *format)
+		return exitUsage
+	}
+	if *top < 0 || *maxPackets <= 0 {
+		fmt.Fprintln(stderr, "sniffer analyze: -top must not be negative and -max-packets must be positive")
+		return exitUsage
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "sniffer analyze: %v\n", err)
+		return exitUsage
+	}
+	// Standard output holds the report, so logs go to standard error
+	logger, err := logging.New(stderr, logging.Config{Level: cfg.Logging.Level, Format: cfg.Logging.Format})
+	if err != nil {
+		fmt.Fprintf(stderr, "sniffer analyze: %v\n", err)
+		return exitUsage
+	}
+
+	analyzer := analysis.New(analysis.Config{
+		MaxPackets: *maxPackets,
+		Top:        *top,
+		Scan: detection.ScanConfig{
+			Window:              cfg.Detection.Scan.Window,
+			VerticalThreshold:   cfg.Detection.Scan.VerticalThreshold,
+			HorizontalThreshold: cfg.Detection.Scan.HorizontalThreshold,
+			HalfOpenThreshold:   cfg.Detection.Scan.HalfOpenThreshold,
+			Cooldown:            cfg.Detection.Scan.Cooldown,
+		},
+		Anomaly: detection.AnomalyConfig{
+			Interval:   cfg.Detection.Anomaly.Interval,
+			Alpha:      cfg.Detection.Anomaly.Alpha,
+			ZThreshold: cfg.Detection.Anomaly.ZThreshold,
+			MinSamples: cfg.Detection.Anomaly.MinSamples,
+			Cooldown:   cfg.Detection.Anomaly.Cooldown,
+		},
+		FindingsHistorySize: cfg.Detection.FindingsHistorySize,
+	}, logger)
+
+	for _, name := range fs.Args() {
+		if ctx.Err() != nil {
+			fmt.Fprintln(stderr, "sniffer analyze: interrupted")
+			return exitError
+		}
+		if err := load(ctx, analyzer, name, stdin); err != nil {
+			fmt.Fprintf(stderr, "sniffer analyze: %v\n", err)
+			return exitError
+		}
+	}
+
+	report, err := analyzer.Report(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "sniffer analyze: %v\n", err)
+		return exitError
+	}
+
+	if err := writeOutput(*output, stdout, func(w io.Writer) error {
+		if *format == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		}
+		return writeReport(w, report)
+	}); err != nil {
+		fmt.Fprintf(stderr, "sniffer analyze: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// writeOutput calls write with the file at path, or stdout when path is
+// empty
+func writeOutput(path string, stdout io.Writer, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(stdout)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// load feeds a file, or standard input for "-", to the analyzer
+func load(ctx context.Context, analyzer *analysis.Analyzer, name string, stdin io.Reader) error {
+	if name == "-" {
+		_, err := analyzer.Load(ctx, "stdin", stdin)
+		return err
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = analyzer.Load(ctx, name, file)
+	return err
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dump = `{"packets":[
+	{"id":"packet_1","timestamp":"2024-01-02T03:04:05Z","protocol":"TCP","source_ip":"10.0.0.1","destination_ip":"10.0.0.2","port":443,"size":1200,"flags":"SYN"},
+	{"id":"packet_2","timestamp":"2024-01-02T03:04:06Z","protocol":"TCP","source_ip":"10.0.0.3","destination_ip":"10.0.0.4","port":23,"size":80}
+],"total":2,"timestamp":"2024-01-02T03:04:07Z"}`
+
+// analyzeFiles runs sniffer analyze and returns its exit status and output
+func analyzeFiles(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append([]string{"analyze"}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestAnalyze_Text(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.json")
+	require.NoError(t, os.WriteFile(path, []byte(dump), 0o600))
+
+	code, stdout, stderr := analyzeFiles(t, "", path)
+	require.Equal(t, exitOK, code, stderr)
+	for _, section := range []string{"SOURCES", "SUMMARY", "PROTOCOLS", "TOP TALKERS", "FLOWS", "CRYPTO INVENTORY", "DETECTIONS"} {
+		assert.Contains(t, "\n"+stdout, "\n"+section+"\n")
+	}
+	assert.Regexp(t, `dump\.json\s+json\s+2\s+0`, stdout)
+	assert.Regexp(t, `Bytes:\s+1280`, stdout)
+	assert.Regexp(t, `10\.0\.0\.4\s+23\s+Telnet\s+no\s+port\s+.*cleartext protocol`, stdout)
+}
+
+func TestAnalyze_JSONFromStdin(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "report.json")
+	code, stdout, stderr := analyzeFiles(t, dump, "-format", "json", "-o", output, "-")
+	require.Equal(t, exitOK, code, stderr)
+	assert.Empty(t, stdout)
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	var report models.AnalysisReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, 2, report.Packets)
+	assert.Equal(t, "stdin", report.Sources[0].Name)
+	assert.Len(t, report.Crypto, 2)
+}
+
+func TestAnalyze_Errors(t *testing.T) {
+	code, _, stderr := analyzeFiles(t, "")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "expected at least one file")
+
+	code, _, stderr = analyzeFiles(t, "", filepath.Join(t.TempDir(), "missing.pcap"))
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, "no such file or directory")
+
+	code, _, stderr = analyzeFiles(t, "not a capture", "-")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, "stdin: unknown input format")
+
+	var stdout, stderrBuf bytes.Buffer
+	assert.Equal(t, exitUsage, run(context.Background(), []string{"capture"}, nil, &stdout, &stderrBuf))
+	assert.Contains(t, stderrBuf.String(), `unknown command "capture"`)
+}
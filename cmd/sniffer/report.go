@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// timeLayout formats the timestamps of the text report
+const timeLayout = "2006-01-02 15:04:05.000"
+
+// writeReport writes an analysis report as aligned text sections
+func writeReport(w io.Writer, report *models.AnalysisReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	section(tw, "SOURCES")
+	fmt.Fprintln(tw, "NAME\tFORMAT\tPACKETS\tSKIPPED")
+	for _, source := range report.Sources {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", source.Name, source.Format, source.Packets, skipped(source.Skipped))
+	}
+
+	section(tw, "SUMMARY")
+	fmt.Fprintf(tw, "Packets:\t%d\n", report.Packets)
+	fmt.Fprintf(tw, "Bytes:\t%d\n", report.Bytes)
+	if report.Dropped > 0 {
+		fmt.Fprintf(tw, "Dropped:\t%d (raise -max-packets to keep every packet)\n", report.Dropped)
+	}
+	if report.From != nil && report.To != nil {
+		fmt.Fprintf(tw, "From:\t%s\n", report.From.UTC().Format(timeLayout))
+		fmt.Fprintf(tw, "To:\t%s\n", report.To.UTC().Format(timeLayout))
+		fmt.Fprintf(tw, "Duration:\t%s\n", report.To.Sub(*report.From).Round(time.Millisecond))
+	}
+
+	section(tw, "PROTOCOLS")
+	writeEntries(tw, "PROTOCOL", report.Protocols)
+
+	section(tw, "TOP TALKERS")
+	writeEntries(tw, "SOURCE", report.TopTalkers)
+
+	section(tw, "FLOWS")
+	fmt.Fprintln(tw, "PROTOCOL\tSOURCE\tDESTINATION\tPORT\tPACKETS\tBYTES\tFLAGS\tFIRST SEEN\tLAST SEEN")
+	for _, flow := range report.Flows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			flow.Protocol, flow.SourceIP, flow.DestinationIP, flow.Port, flow.Packets, flow.Bytes,
+			list(flow.Flags), flow.FirstSeen.UTC().Format(timeLayout), flow.LastSeen.UTC().Format(timeLayout))
+	}
+	none(tw, len(report.Flows))
+
+	section(tw, "CRYPTO INVENTORY")
+	fmt.Fprintln(tw, "ADDRESS\tPORT\tPROTOCOL\tENCRYPTED\tEVIDENCE\tVERSIONS\tCIPHER SUITES\tSERVER NAMES\tCLIENTS\tPACKETS\tWEAKNESSES")
+	for _, endpoint := range report.Crypto {
+		encrypted := "no"
+		if endpoint.Encrypted {
+			encrypted = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			endpoint.Address, endpoint.Port, endpoint.Protocol, encrypted, endpoint.Evidence,
+			list(endpoint.Versions), list(endpoint.CipherSuites), list(endpoint.ServerNames),
+			endpoint.Clients, endpoint.Packets, list(endpoint.Weaknesses))
+	}
+	none(tw, len(report.Crypto))
+
+	section(tw, "DETECTIONS")
+	fmt.Fprintln(tw, "SEVERITY\tDETECTOR\tTYPE\tCOUNT\tFIRST SEEN\tLAST SEEN\tSUMMARY")
+	for _, finding := range report.Findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			finding.Severity, finding.Detector, finding.Type, finding.Count,
+			finding.FirstSeen.UTC().Format(timeLayout), finding.LastSeen.UTC().Format(timeLayout), finding.Summary)
+	}
+	none(tw, len(report.Findings))
+
+	return tw.Flush()
+}
+
+// section starts a section of the report
+func section(w io.Writer, title string) {
+	if title != "SOURCES" {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, title)
+}
+
+// writeEntries writes a ranking
+func writeEntries(w io.Writer, header string, entries []models.TopNEntry) {
+	fmt.Fprintf(w, "%s\tPACKETS\tBYTES\tSHARE\n", header)
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\n", entry.Key, entry.Packets, entry.Bytes, entry.Share*100)
+	}
+	none(w, len(entries))
+}
+
+// none marks an empty table
+func none(w io.Writer, n int) {
+	if n == 0 {
+		fmt.Fprintln(w, "(none)")
+	}
+}
+
+// list joins the values of a cell
+func list(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
+
+// skipped formats the skipped packets of a source by reason
+func skipped(reasons map[string]int) string {
+	if len(reasons) == 0 {
+		return "0"
+	}
+	keys := make([]string, 0, len(reasons))
+	total := 0
+	for reason, n := range reasons {
+		keys = append(keys, fmt.Sprintf("%d %s", n, reason))
+		total += n
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("%d (%s)", total, strings.Join(keys, "; "))
+}
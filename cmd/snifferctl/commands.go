@@ -0,0 +1,680 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/client"
+)
+
+// commands lists every command, in the order of the usage message
+var commands = []command{
+	{path: "packets list", summary: "List stored packets, oldest first; -watch keeps printing new ones", setup: packetsList},
+	{path: "packets get", args: "ID", nargs: 1, summary: "Show a packet", setup: get("/api/v1/packets/{}", view{})},
+	{path: "packets delete", args: "ID", nargs: 1, summary: "Delete a packet", setup: send(http.MethodDelete, "/api/v1/packets/{}", "Packet deleted")},
+	{path: "packets export", args: "FILE", nargs: 1, summary: "Download packets as a CSV, NDJSON, Parquet or pcapng file; - writes to standard output", setup: packetsExport},
+	{path: "packets clear", summary: "Delete every stored packet", setup: packetsClear},
+
+	{path: "ingest upload", args: "FILE", nargs: 1, summary: "Upload a pcap or pcapng capture to decode into a new session; -wait follows its job", setup: ingestUpload},
+	{path: "ingest jobs list", summary: "List the jobs decoding uploaded captures", setup: get("/api/v1/ingest/jobs", jobsView)},
+	{path: "ingest jobs get", args: "ID", nargs: 1, summary: "Show the progress and decode errors of a capture ingestion job", setup: get("/api/v1/ingest/jobs/{}", view{})},
+
+	{path: "sniffing start", summary: "Start capturing packets", setup: send(http.MethodPost, "/api/v1/sniffing/start", "Sniffing started")},
+	{path: "sniffing stop", summary: "Stop capturing packets", setup: send(http.MethodPost, "/api/v1/sniffing/stop", "Sniffing stopped")},
+	{path: "sniffing status", summary: "Show whether packets are being captured", setup: get("/api/v1/sniffing/status", view{})},
+	{path: "sniffing config", summary: "Show the capture parameters", setup: get("/api/v1/sniffing/config", view{})},
+	{path: "sniffing config set", summary: "Change capture parameters without restarting", setup: snifferConfigSet},
+
+	{path: "stats", summary: "Show storage usage and traffic rates", setup: get("/api/v1/stats", statsView)},
+	{path: "top", summary: "Watch live traffic: packets, top talkers, protocols and throughput", setup: topCommand},
+	{path: "analytics top", args: "DIMENSION", nargs: 1, summary: "Rank source_ip, destination_ip, host_pair, port or protocol values", setup: get("/api/v1/analytics/top/{}", topView,
+		param{"by", "ranking metric: packets or bytes"},
+		param{"n", "number of entries, 0 for all"},
+		param{"protocol", "only packets of this protocol"},
+		param{"source_ip", "only packets from this address"},
+		param{"destination_ip", "only packets to this address"},
+		param{"window", "only packets within this duration before now, e.g. 5m"},
+		param{"from", "only packets at or after this RFC3339 timestamp"},
+		param{"to", "only packets at or before this RFC3339 timestamp"},
+		param{"session", "only packets of this session, such as an uploaded capture, instead of live traffic"},
+		param{"agent", "only packets captured by this agent"},
+	)},
+
+	{path: "alerts list", summary: "List raised alerts", setup: get("/api/v1/alerts", alertsView,
+		param{"rule_id", "only alerts of this rule"},
+		param{"severity", "only alerts of this severity"},
+		param{"since", "only alerts seen at or after this RFC3339 timestamp"},
+		param{"limit", "maximum number of alerts"},
+	)},
+	{path: "alerts rules list", summary: "List alert rules", setup: get("/api/v1/alerts/rules", rulesView)},
+	{path: "alerts rules get", args: "ID", nargs: 1, summary: "Show an alert rule", setup: get("/api/v1/alerts/rules/{}", view{})},
+	{path: "alerts rules create", summary: "Create an alert rule from a JSON file", setup: sendFile(http.MethodPost, "/api/v1/alerts/rules")},
+	{path: "alerts rules update", args: "ID", nargs: 1, summary: "Replace an alert rule with a JSON file", setup: sendFile(http.MethodPut, "/api/v1/alerts/rules/{}")},
+	{path: "alerts rules delete", args: "ID", nargs: 1, summary: "Delete an alert rule", setup: send(http.MethodDelete, "/api/v1/alerts/rules/{}", "Rule deleted")},
+
+	{path: "detections list", summary: "List port scans, host sweeps and traffic anomalies", setup: get("/api/v1/detections", findingsView,
+		param{"detector", "only findings of this detector"},
+		param{"type", "only findings of this type"},
+		param{"source_ip", "only findings about this source"},
+		param{"since", "only findings seen at or after this RFC3339 timestamp"},
+		param{"limit", "maximum number of findings"},
+	)},
+	{path: "detections get", args: "ID", nargs: 1, summary: "Show a finding", setup: get("/api/v1/detections/{}", view{})},
+	{path: "detections baselines", summary: "Show the learned traffic baselines", setup: get("/api/v1/detections/baselines", baselinesView,
+		param{"entity", "only the baselines of this host or port"},
+	)},
+
+	{path: "notifications status", summary: "Show webhook delivery status", setup: get("/api/v1/notifications", endpointsView)},
+	{path: "notifications dead-letters", summary: "List undelivered notifications", setup: get("/api/v1/notifications/dead-letters", deadLettersView)},
+	{path: "notifications replay", summary: "Redeliver undelivered notifications", setup: send(http.MethodPost, "/api/v1/notifications/dead-letters/replay", "")},
+
+	{path: "auth whoami", summary: "Show the identity and role of the credentials", setup: get("/api/v1/auth/whoami", view{})},
+	{path: "auth keys list", summary: "List API keys", setup: get("/api/v1/auth/keys", keysView)},
+	{path: "auth keys create", summary: "Create an API key; its secret is only shown once", setup: keysCreate},
+	{path: "auth keys revoke", args: "ID", nargs: 1, summary: "Revoke an API key", setup: send(http.MethodDelete, "/api/v1/auth/keys/{}", "API key revoked")},
+
+	{path: "audit list", summary: "List audit log entries", setup: get("/api/v1/audit", auditView,
+		param{"actor", "only entries of this actor"},
+		param{"action", "only entries of this action, e.g. packets.clear"},
+		param{"outcome", "only entries with this outcome: success, denied or failure"},
+		param{"since", "only entries at or after this RFC3339 timestamp"},
+		param{"until", "only entries at or before this RFC3339 timestamp"},
+		param{"limit", "maximum number of entries"},
+	)},
+	{path: "audit verify", summary: "Verify the hash chain of the audit log", setup: get("/api/v1/audit/verify", view{})},
+
+	{path: "agents list", summary: "List the capture agents registered with a collector", setup: get("/api/v1/agents", agentsView)},
+
+	{path: "health", summary: "Show service health; -probe checks liveness or readiness", setup: healthCheck},
+}
+
+// Table layouts of the responses
+var (
+	packetsView = view{items: "packets", columns: []column{
+		{"ID", "id", 31},
+		{"TIME", "timestamp", 19},
+		{"PROTOCOL", "protocol", 8},
+		{"SOURCE", "source_ip", 15},
+		{"DESTINATION", "destination_ip", 15},
+		{"PORT", "port", 5},
+		{"SIZE", "size", 5},
+		{"FLAGS", "flags", 5},
+	}}
+	jobsView = view{items: "jobs", columns: []column{
+		{header: "ID", path: "id"},
+		{header: "FILE", path: "file_name"},
+		{header: "SESSION", path: "session"},
+		{header: "STATUS", path: "status"},
+		{header: "PROGRESS", path: "progress"},
+		{header: "STORED", path: "packets_stored"},
+		{header: "SKIPPED", path: "packets_skipped"},
+		{header: "CREATED", path: "created_at"},
+		{header: "ERROR", path: "error"},
+	}}
+	agentsView = view{items: "agents", columns: []column{
+		{header: "ID", path: "id"},
+		{header: "HOSTNAME", path: "hostname"},
+		{header: "ADDRESS", path: "address"},
+		{header: "CONNECTED", path: "connected"},
+		{header: "LAST SEEN", path: "last_seen_at"},
+		{header: "BATCHES", path: "batches"},
+		{header: "PACKETS", path: "packets"},
+		{header: "REJECTED", path: "rejected"},
+	}}
+	statsView = view{
+		items: "windows",
+		summary: []column{
+			{header: "Packets", path: "total_packets"},
+			{header: "Capacity", path: "capacity"},
+			{header: "Oldest", path: "oldest_at"},
+			{header: "Newest", path: "newest_at"},
+		},
+		columns: []column{
+			{header: "WINDOW", path: "window"},
+			{header: "PACKETS", path: "packets"},
+			{header: "BYTES", path: "bytes"},
+			{header: "PACKETS/S", path: "packets_per_second"},
+			{header: "BYTES/S", path: "bytes_per_second"},
+			{header: "SOURCES", path: "distinct_sources"},
+			{header: "DESTINATIONS", path: "distinct_destinations"},
+		},
+	}
+	topView = view{
+		items: "entries",
+		summary: []column{
+			{header: "Dimension", path: "dimension"},
+			{header: "Metric", path: "metric"},
+			{header: "Packets", path: "total_packets"},
+			{header: "Bytes", path: "total_bytes"},
+		},
+		columns: []column{
+			{header: "KEY", path: "key"},
+			{header: "PACKETS", path: "packets"},
+			{header: "BYTES", path: "bytes"},
+			{header: "SHARE", path: "share"},
+		},
+	}
+	alertsView = view{items: "alerts", columns: []column{
+		{header: "ID", path: "id"},
+		{header: "RULE", path: "rule_name"},
+		{header: "SEVERITY", path: "severity"},
+		{header: "VALUE", path: "value"},
+		{header: "THRESHOLD", path: "threshold"},
+		{header: "OCCURRENCES", path: "occurrences"},
+		{header: "LAST SEEN", path: "last_seen"},
+		{header: "MESSAGE", path: "message"},
+	}}
+	rulesView = view{columns: []column{
+		{header: "ID", path: "id"},
+		{header: "NAME", path: "name"},
+		{header: "ENABLED", path: "enabled"},
+		{header: "SEVERITY", path: "severity"},
+		{header: "AGGREGATION", path: "aggregation.function"},
+		{header: "FIELD", path: "aggregation.field"},
+		{header: "THRESHOLD", path: "threshold"},
+		{header: "WINDOW", path: "window"},
+	}}
+	findingsView = view{items: "findings", columns: []column{
+		{header: "ID", path: "id"},
+		{header: "DETECTOR", path: "detector"},
+		{header: "TYPE", path: "type"},
+		{header: "SEVERITY", path: "severity"},
+		{header: "SOURCE", path: "source_ip"},
+		{header: "COUNT", path: "count"},
+		{header: "LAST SEEN", path: "last_seen"},
+		{header: "SUMMARY", path: "summary"},
+	}}
+	baselinesView = view{columns: []column{
+		{header: "ENTITY", path: "entity"},
+		{header: "LAST SEEN", path: "last_seen"},
+		{header: "METRICS", path: "metrics"},
+	}}
+	endpointsView = view{
+		items:   "endpoints",
+		summary: []column{{header: "Dead letters", path: "dead_letters"}},
+		columns: []column{
+			{header: "NAME", path: "name"},
+			{header: "URL", path: "url"},
+			{header: "QUEUED", path: "queued"},
+			{header: "DELIVERED", path: "delivered"},
+			{header: "RETRIES", path: "retries"},
+			{header: "DEAD LETTERED", path: "dead_lettered"},
+			{header: "LAST STATUS", path: "last_status_code"},
+			{header: "LAST ERROR", path: "last_error"},
+		},
+	}
+	deadLettersView = view{items: "dead_letters", columns: []column{
+		{header: "NOTIFICATION", path: "notification.id"},
+		{header: "EVENT", path: "notification.event"},
+		{header: "ENDPOINT", path: "endpoint"},
+		{header: "ATTEMPTS", path: "attempts"},
+		{header: "FAILED AT", path: "failed_at"},
+		{header: "LAST ERROR", path: "last_error"},
+	}}
+	keysView = view{columns: []column{
+		{header: "ID", path: "id"},
+		{header: "NAME", path: "name"},
+		{header: "ROLE", path: "role"},
+		{header: "PREFIX", path: "prefix"},
+		{header: "CREATED", path: "created_at"},
+		{header: "EXPIRES", path: "expires_at"},
+		{header: "LAST USED", path: "last_used_at"},
+	}}
+	auditView = view{items: "entries", columns: []column{
+		{header: "SEQ", path: "sequence"},
+		{header: "TIME", path: "timestamp"},
+		{header: "ACTOR", path: "actor"},
+		{header: "ACTION", path: "action"},
+		{header: "STATUS", path: "status"},
+		{header: "OUTCOME", path: "outcome"},
+		{header: "CLIENT", path: "client_ip"},
+	}}
+)
+
+// param is a query parameter set through the flag of the same name, with
+// dashes instead of underscores
+type param struct {
+	name  string
+	usage string
+}
+
+// params holds the values of parameter flags
+type params map[string]*string
+
+// register adds a flag per parameter
+func register(fs *flag.FlagSet, list []param) params {
+	values := make(params, len(list))
+	for _, p := range list {
+		values[p.name] = fs.String(strings.ReplaceAll(p.name, "_", "-"), "", p.usage)
+	}
+	return values
+}
+
+// query returns the parameters that were set
+func (p params) query() url.Values {
+	query := url.Values{}
+	for name, value := range p {
+		if *value != "" {
+			query.Set(name, *value)
+		}
+	}
+	return query
+}
+
+// route replaces the {} placeholders of path with the escaped arguments
+func route(path string, args []string) string {
+	for _, arg := range args {
+		path = strings.Replace(path, "{}", url.PathEscape(arg), 1)
+	}
+	return path
+}
+
+// get prints the response of a GET route
+func get(path string, v view, list ...param) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		values := register(fs, list)
+		return func(ctx context.Context, cli *cli, args []string) error {
+			return cli.show(ctx, http.MethodGet, route(path, args), values.query(), nil, v, "")
+		}
+	}
+}
+
+// send calls a route without a body and prints message when it answers
+// without one
+func send(method, path, message string) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		return func(ctx context.Context, cli *cli, args []string) error {
+			return cli.show(ctx, method, route(path, args), nil, nil, view{}, message)
+		}
+	}
+}
+
+// sendFile calls a route with the JSON document of the -f flag as body
+func sendFile(method, path string) func(fs *flag.FlagSet) runFunc {
+	return func(fs *flag.FlagSet) runFunc {
+		file := fs.String("f", "", "JSON file of the request body, - for standard input (required)")
+		return func(ctx context.Context, cli *cli, args []string) error {
+			if *file == "" {
+				return errors.New("-f is required")
+			}
+
+			var data []byte
+			var err error
+			if *file == "-" {
+				data, err = io.ReadAll(cli.stdin)
+			} else {
+				data, err = os.ReadFile(*file)
+			}
+			if err != nil {
+				return err
+			}
+			if !json.Valid(data) {
+				return fmt.Errorf("%s is not a JSON document", *file)
+			}
+			return cli.show(ctx, method, route(path, args), nil, json.RawMessage(data), view{}, "")
+		}
+	}
+}
+
+// packetParams are the filters of the packet listings
+var packetParams = []param{
+	{"protocol", "only packets of this protocol"},
+	{"source_ip", "only packets from this address"},
+	{"destination_ip", "only packets to this address"},
+	{"window", "only packets within this duration before now, e.g. 5m"},
+	{"from", "only packets at or after this RFC3339 timestamp"},
+	{"to", "only packets at or before this RFC3339 timestamp"},
+	{"limit", "maximum number of packets"},
+	{"offset", "number of matching packets to skip"},
+	{"session", "only packets of this session, such as an uploaded capture, instead of live traffic"},
+	{"agent", "only packets captured by this agent"},
+}
+
+// packetsList lists packets once or, with -watch, until interrupted
+func packetsList(fs *flag.FlagSet) runFunc {
+	values := register(fs, packetParams)
+	watch := fs.Bool("watch", false, "keep printing new packets until interrupted; without -from or -window only packets stored from now on")
+	interval := fs.Duration("interval", 2*time.Second, "polling interval of -watch")
+
+	return func(ctx context.Context, cli *cli, args []string) error {
+		query := values.query()
+		if !*watch {
+			return cli.show(ctx, http.MethodGet, "/api/v1/packets", query, nil, packetsView, "")
+		}
+		if *interval <= 0 {
+			return errors.New("-interval must be positive")
+		}
+		return watchPackets(ctx, cli, query, *interval)
+	}
+}
+
+// watchPackets polls for packets stored after the newest one seen and
+// prints them as they arrive
+func watchPackets(ctx context.Context, cli *cli, query url.Values, interval time.Duration) error {
+	filter, err := packetFilter(query, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Without a starting point, skip the packets already stored
+	tail := cli.client.Tail(filter)
+	if filter.FromTimestamp.IsZero() {
+		if err := tail.Skip(ctx); err != nil {
+			return err
+		}
+	}
+
+	header := true
+	for {
+		packets, err := tail.Next(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(packets) > 0 {
+			rows, err := decodeRows(packets)
+			if err != nil {
+				return err
+			}
+			if err := cli.out.stream(rows, packetsView, header); err != nil {
+				return err
+			}
+			header = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// packetFilter converts the query of a packet listing into a filter, with
+// the window resolved against now
+func packetFilter(query url.Values, now time.Time) (models.PacketFilter, error) {
+	filter := models.PacketFilter{
+		Protocol:      query.Get("protocol"),
+		SourceIP:      query.Get("source_ip"),
+		DestinationIP: query.Get("destination_ip"),
+		Session:       query.Get("session"),
+		Agent:         query.Get("agent"),
+	}
+	for name, bound := range map[string]*time.Time{"from": &filter.FromTimestamp, "to": &filter.ToTimestamp} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid -%s %q, expected an RFC3339 timestamp", name, value)
+			}
+			*bound = t
+		}
+	}
+	if value := query.Get("window"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			return filter, fmt.Errorf("invalid -window %q, expected a positive duration", value)
+		}
+		if from := now.Add(-window); from.After(filter.FromTimestamp) {
+			filter.FromTimestamp = from
+		}
+	}
+	return filter, nil
+}
+
+// decodeRows converts typed values into decoded JSON rows for the printer
+func decodeRows(values interface{}) ([]interface{}, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var rows []interface{}
+	err = decoder.Decode(&rows)
+	return rows, err
+}
+
+// packetsExport downloads the packets matching the filters to a file, in
+// the format named by its extension unless -format is set
+func packetsExport(fs *flag.FlagSet) runFunc {
+	values := register(fs, packetParams)
+	format := fs.String("format", "", "file format: csv, ndjson, parquet or pcapng (default from the file extension)")
+
+	return func(ctx context.Context, cli *cli, args []string) error {
+		name := args[0]
+		if *format == "" && name != "-" {
+			*format = strings.TrimPrefix(filepath.Ext(name), ".")
+		}
+		if *format == "" {
+			return errors.New("-format is required without a file extension")
+		}
+
+		query := values.query()
+		filter, err := packetFilter(query, time.Now())
+		if err != nil {
+			return err
+		}
+		for flagName, value := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+			if text := query.Get(flagName); text != "" {
+				if *value, err = strconv.Atoi(text); err != nil || *value < 0 {
+					return fmt.Errorf("invalid -%s %q, expected a non-negative integer", flagName, text)
+				}
+			}
+		}
+
+		if name == "-" {
+			_, err := cli.client.Export(ctx, *format, filter, cli.stdout)
+			return err
+		}
+		file, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		n, err := cli.client.Export(ctx, *format, filter, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			// Do not leave a truncated file behind
+			os.Remove(name)
+			return err
+		}
+		if cli.out.format == outputTable {
+			fmt.Fprintf(cli.stdout, "Exported %d bytes to %s\n", n, name)
+		}
+		return nil
+	}
+}
+
+// ingestUpload uploads a capture file and prints its job, once finished
+// with -wait
+func ingestUpload(fs *flag.FlagSet) runFunc {
+	wait := fs.Bool("wait", false, "wait until the capture is decoded; fails when its job fails")
+	interval := fs.Duration("interval", time.Second, "polling interval of -wait")
+
+	return func(ctx context.Context, cli *cli, args []string) error {
+		if *interval <= 0 {
+			return errors.New("-interval must be positive")
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		job, err := cli.client.Upload(ctx, filepath.Base(args[0]), file)
+		if err != nil {
+			return err
+		}
+		for *wait && job.FinishedAt == nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(*interval):
+			}
+			if job, err = cli.client.IngestJob(ctx, job.ID); err != nil {
+				return err
+			}
+		}
+
+		rows, err := decodeRows([]*models.IngestJob{job})
+		if err != nil {
+			return err
+		}
+		if err := cli.out.print(rows[0], view{}); err != nil {
+			return err
+		}
+		if *wait && job.Status == models.IngestJobFailed {
+			return fmt.Errorf("ingestion of %s failed: %s", args[0], job.Error)
+		}
+		return nil
+	}
+}
+
+// packetsClear deletes every packet once confirmed with -yes
+func packetsClear(fs *flag.FlagSet) runFunc {
+	yes := fs.Bool("yes", false, "confirm the deletion of every stored packet")
+	return func(ctx context.Context, cli *cli, args []string) error {
+		if !*yes {
+			return errors.New("refusing to delete every stored packet without -yes")
+		}
+		return cli.show(ctx, http.MethodDelete, "/api/v1/packets", nil, nil, view{}, "Packets cleared")
+	}
+}
+
+// snifferConfigSet patches the capture parameters given as flags
+func snifferConfigSet(fs *flag.FlagSet) runFunc {
+	interval := fs.String("interval", "", "capture interval, between 10ms and 1h")
+	profile := fs.String("rate-profile", "", "rate profile: constant, bursty or diurnal")
+	probability := fs.Float64("scan-probability", 0, "chance per tick of simulating a scan burst")
+	weights := fs.String("protocol-weights", "", "relative protocol weights, e.g. TCP=5,UDP=2,HTTPS=1")
+	addresses := fs.String("addresses", "", "comma-separated pool of at least two distinct IP addresses")
+	ports := fs.String("ports", "", "comma-separated pool of ports")
+
+	return func(ctx context.Context, cli *cli, args []string) error {
+		patch := make(map[string]interface{})
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "interval":
+				patch["interval"] = *interval
+			case "rate-profile":
+				patch["rate_profile"] = *profile
+			case "scan-probability":
+				patch["scan_probability"] = *probability
+			case "addresses":
+				patch["addresses"] = splitList(*addresses)
+			case "ports":
+				var list []int
+				for _, item := range splitList(*ports) {
+					port, parseErr := strconv.Atoi(item)
+					if parseErr != nil {
+						err = fmt.Errorf("invalid port %q", item)
+						return
+					}
+					list = append(list, port)
+				}
+				patch["ports"] = list
+			case "protocol-weights":
+				mix := make(map[string]float64)
+				for _, item := range splitList(*weights) {
+					protocol, value, ok := strings.Cut(item, "=")
+					weight, parseErr := strconv.ParseFloat(value, 64)
+					if !ok || parseErr != nil {
+						err = fmt.Errorf("invalid protocol weight %q, expected PROTOCOL=WEIGHT", item)
+						return
+					}
+					mix[strings.ToUpper(protocol)] = weight
+				}
+				patch["protocol_weights"] = mix
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if len(patch) == 0 {
+			return errors.New("no capture parameter given")
+		}
+		return cli.show(ctx, http.MethodPatch, "/api/v1/sniffing/config", nil, patch, view{}, "")
+	}
+}
+
+// keysCreate creates an API key
+func keysCreate(fs *flag.FlagSet) runFunc {
+	name := fs.String("name", "", "name of the key (required)")
+	role := fs.String("role", "", "role of the key: viewer, analyst or admin (required)")
+	expiresIn := fs.String("expires-in", "", "lifetime of the key, e.g. 720h; never expires when empty")
+
+	return func(ctx context.Context, cli *cli, args []string) error {
+		if *name == "" || *role == "" {
+			return errors.New("-name and -role are required")
+		}
+		body := map[string]string{"name": *name, "role": *role}
+		if *expiresIn != "" {
+			body["expires_in"] = *expiresIn
+		}
+		return cli.show(ctx, http.MethodPost, "/api/v1/auth/keys", nil, body, view{}, "")
+	}
+}
+
+// healthCheck prints the health summary or the report of a probe. A
+// degraded probe report is printed and fails the command.
+func healthCheck(fs *flag.FlagSet) runFunc {
+	probe := fs.String("probe", "", "probe to run: live or ready; the API health summary when empty")
+
+	return func(ctx context.Context, cli *cli, args []string) error {
+		path := "/api/v1/health"
+		switch *probe {
+		case "":
+		case "live":
+			path = "/healthz"
+		case "ready":
+			path = "/readyz"
+		default:
+			return fmt.Errorf("unknown probe %q, expected live or ready", *probe)
+		}
+
+		data, err := cli.request(ctx, http.MethodGet, path, nil, nil)
+		var apiErr *client.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusServiceUnavailable && json.Valid(apiErr.Body) {
+			var report interface{}
+			if json.Unmarshal(apiErr.Body, &report) == nil {
+				if printErr := cli.out.print(report, view{}); printErr != nil {
+					return printErr
+				}
+			}
+			return errors.New("service is degraded")
+		}
+		if err != nil {
+			return err
+		}
+		return cli.out.print(data, view{})
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
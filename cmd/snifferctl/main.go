@@ -0,0 +1,251 @@
+// Command snifferctl manages a network sniffer service through its HTTP
+// API.
+//
+// Usage:
+//
+//	snifferctl <command> [flags] [arguments]
+//
+// The server and credentials are taken from flags, then from the
+// SNIFFERCTL_SERVER, SNIFFERCTL_API_KEY and SNIFFERCTL_TOKEN variables,
+// then from the selected profile of the profile file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/pkg/client"
+)
+
+// Exit statuses
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// runFunc runs a command with its positional arguments
+type runFunc func(ctx context.Context, cli *cli, args []string) error
+
+// command is a snifferctl subcommand, such as "packets list"
+type command struct {
+	path    string
+	args    string
+	nargs   int
+	summary string
+
+	// setup registers the flags of the command and returns its runner
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+// options are the connection and output flags of every command
+type options struct {
+	server      string
+	apiKey      string
+	token       string
+	profile     string
+	profileFile string
+	output      string
+	timeout     time.Duration
+}
+
+// register adds the options to the flags of a command
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.server, "server", "", "API base URL (default $SNIFFERCTL_SERVER, the profile or "+defaultServer+")")
+	fs.StringVar(&o.apiKey, "api-key", "", "API key (default $SNIFFERCTL_API_KEY or the profile)")
+	fs.StringVar(&o.token, "token", "", "JWT bearer token (default $SNIFFERCTL_TOKEN or the profile)")
+	fs.StringVar(&o.profile, "profile", "", "profile to use instead of the current one of the profile file")
+	fs.StringVar(&o.profileFile, "profile-file", "", "profile file (default $SNIFFERCTL_CONFIG or ~/.config/snifferctl/config.yaml)")
+	fs.StringVar(&o.output, "output", "", "output format: table, json or ndjson")
+	fs.StringVar(&o.output, "o", "", "shorthand for -output")
+	fs.DurationVar(&o.timeout, "timeout", 30*time.Second, "timeout of each request")
+}
+
+// cli is the state shared by the commands
+type cli struct {
+	client *client.Client
+	out    *printer
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command line args and returns the exit status
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		if len(args) > 0 && args[0] != "help" && args[0] != "-h" && args[0] != "--help" {
+			fmt.Fprintf(stderr, "snifferctl: unknown command %q\n\n", strings.Join(args, " "))
+			usage(stderr)
+			return exitUsage
+		}
+		usage(stdout)
+		return exitOK
+	}
+
+	fs := flag.NewFlagSet("snifferctl "+cmd.path, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts options
+	opts.register(fs)
+	runCmd := cmd.setup(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: snifferctl %s [flags] %s\n\n%s\n\nFlags:\n", cmd.path, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseFlags(fs, rest)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != cmd.nargs {
+		fmt.Fprintf(stderr, "snifferctl %s: expected %d argument(s), got %d\n", cmd.path, cmd.nargs, len(positional))
+		fs.Usage()
+		return exitUsage
+	}
+
+	c, output, err := opts.connect()
+	if err != nil {
+		fmt.Fprintf(stderr, "snifferctl: %v\n", err)
+		return exitUsage
+	}
+
+	state := &cli{
+		client: c,
+		out:    &printer{format: output, w: stdout},
+		stdin:  stdin,
+		stdout: stdout,
+	}
+	if err := runCmd(ctx, state, positional); err != nil {
+		fmt.Fprintf(stderr, "snifferctl: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// findCommand returns the command named by the longest prefix of args and
+// the remaining arguments
+func findCommand(args []string) (*command, []string) {
+	for n := min(len(args), 3); n > 0; n-- {
+		path := strings.Join(args[:n], " ")
+		for i := range commands {
+			if commands[i].path == path {
+				return &commands[i], args[n:]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// parseFlags parses flags placed before, between or after the positional
+// arguments, which it returns
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// usage lists the commands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: snifferctl <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.path, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "snifferctl <command> -h" for the flags of a command.`)
+}
+
+// connect resolves the connection settings and creates the API client. It
+// returns the selected output format.
+func (o *options) connect() (*client.Client, string, error) {
+	path, explicit := o.profileFile, o.profileFile != ""
+	if !explicit {
+		path = defaultProfilePath()
+	}
+	profile, err := loadProfile(path, o.profile, explicit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	output := firstSet(o.output, os.Getenv("SNIFFERCTL_OUTPUT"), profile.Output, outputTable)
+	if output != outputTable && output != outputJSON && output != outputNDJSON {
+		return nil, "", fmt.Errorf("unknown output format %q, expected table, json or ndjson", output)
+	}
+
+	c, err := client.New(
+		firstSet(o.server, os.Getenv("SNIFFERCTL_SERVER"), profile.Server, defaultServer),
+		client.WithAPIKey(firstSet(o.apiKey, os.Getenv("SNIFFERCTL_API_KEY"), profile.APIKey)),
+		client.WithToken(firstSet(o.token, os.Getenv("SNIFFERCTL_TOKEN"), profile.Token)),
+		client.WithHTTPClient(&http.Client{Timeout: o.timeout}),
+	)
+	return c, output, err
+}
+
+// request calls the API and decodes the response, if any, keeping numbers
+// exact
+func (c *cli) request(ctx context.Context, method, path string, query url.Values, body interface{}) (interface{}, error) {
+	var raw json.RawMessage
+	if err := c.client.Do(ctx, method, path, query, body, &raw); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// show calls the API and prints the response. message is printed in
+// table format when the response has no body.
+func (c *cli) show(ctx context.Context, method, path string, query url.Values, body interface{}, v view, message string) error {
+	data, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		if c.out.format == outputTable && message != "" {
+			fmt.Fprintln(c.stdout, message)
+		}
+		return nil
+	}
+	return c.out.print(data, v)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const packetsBody = `{"packets":[
+	{"id":"packet_1","timestamp":"2024-01-02T03:04:05Z","protocol":"TCP","source_ip":"10.0.0.1","destination_ip":"10.0.0.2","port":443,"size":1200,"flags":"SYN"},
+	{"id":"packet_2","timestamp":"2024-01-02T03:04:06Z","protocol":"UDP","source_ip":"10.0.0.3","destination_ip":"10.0.0.4","port":53,"size":80}
+],"total":2,"timestamp":"2024-01-02T03:04:07Z"}`
+
+// execute runs snifferctl against handler and returns its exit status and
+// output
+func execute(t *testing.T, handler http.HandlerFunc, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("SNIFFERCTL_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	args = append(args, "-server", server.URL)
+	code := run(context.Background(), args, strings.NewReader(""), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_PacketsListTable(t *testing.T) {
+	code, stdout, _ := execute(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/packets", r.URL.Path)
+		assert.Equal(t, "TCP", r.URL.Query().Get("protocol"))
+		assert.Equal(t, "10.0.0.1", r.URL.Query().Get("source_ip"))
+		w.Write([]byte(packetsBody))
+	}, "packets", "list", "-protocol", "TCP", "-source-ip", "10.0.0.1")
+
+	require.Equal(t, exitOK, code)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^ID\s+TIME\s+PROTOCOL\s+SOURCE\s+DESTINATION\s+PORT\s+SIZE\s+FLAGS$`, lines[0])
+	assert.Regexp(t, `^packet_1\s+\S+ \S+\s+TCP\s+10\.0\.0\.1\s+10\.0\.0\.2\s+443\s+1200\s+SYN$`, lines[1])
+	assert.Regexp(t, `^packet_2\s.*\s80\s+-$`, lines[2])
+}
+
+func TestRun_PacketsListNDJSON(t *testing.T) {
+	code, stdout, _ := execute(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(packetsBody))
+	}, "packets", "list", "-o", "ndjson")
+
+	require.Equal(t, exitOK, code)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	require.Len(t, lines, 2)
+	var packet map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &packet))
+	assert.Equal(t, "packet_2", packet["id"])
+}
+
+func TestRun_PacketGetArguments(t *testing.T) {
+	code, stdout, _ := execute(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/packets/packet_1", r.URL.Path)
+		w.Write([]byte(`{"id":"packet_1","port":443}`))
+	}, "packets", "get", "packet_1", "-o", "json")
+	require.Equal(t, exitOK, code)
+	assert.JSONEq(t, `{"id":"packet_1","port":443}`, stdout)
+
+	// Missing arguments are a usage error
+	code, _, stderr := execute(t, nil, "packets", "get")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "expected 1 argument(s), got 0")
+}
+
+func TestRun_SnifferConfigSet(t *testing.T) {
+	code, _, _ := execute(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"interval":"2s","ports":[80,443],"protocol_weights":{"TCP":3,"UDP":1}}`, string(body))
+		w.Write([]byte(`{"interval":"2s"}`))
+	}, "sniffing", "config", "set", "-interval", "2s", "-ports", "80, 443", "-protocol-weights", "tcp=3,UDP=1")
+	assert.Equal(t, exitOK, code)
+
+	code, _, stderr := execute(t, nil, "sniffing", "config", "set", "-ports", "http")
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, `invalid port "http"`)
+}
+
+func TestRun_NoContentAndErrors(t *testing.T) {
+	code, stdout, _ := execute(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/sniffing/stop", r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}, "sniffing", "stop")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "Sniffing stopped\n", stdout)
+
+	code, _, stderr := execute(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"Forbidden","message":"Requires the admin role"}`))
+	}, "packets", "clear", "-yes")
+	assert.Equal(t, exitError, code)
+	assert.Equal(t, "snifferctl: 403 Forbidden: Requires the admin role\n", stderr)
+}
+
+func TestRun_PacketsExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+	code, stdout, stderr := execute(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/packets/export", r.URL.Path)
+		assert.Equal(t, "pcapng", r.URL.Query().Get("format"))
+		assert.Equal(t, "TCP", r.URL.Query().Get("protocol"))
+		assert.Equal(t, "10", r.URL.Query().Get("limit"))
+		w.Write([]byte("capture"))
+	}, "packets", "export", "-protocol", "TCP", "-limit", "10", path)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Equal(t, "Exported 7 bytes to "+path+"\n", stdout)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "capture", string(data))
+
+	code, stdout, _ = execute(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "csv", r.URL.Query().Get("format"))
+		w.Write([]byte("id\n"))
+	}, "packets", "export", "-format", "csv", "-")
+	require.Equal(t, exitOK, code)
+	assert.Equal(t, "id\n", stdout)
+
+	// A failed export leaves no file behind
+	path = filepath.Join(t.TempDir(), "packets.xml")
+	code, _, stderr = execute(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Bad Request","message":"unknown export format \"xml\""}`))
+	}, "packets", "export", path)
+	assert.Equal(t, exitError, code)
+	assert.Contains(t, stderr, `unknown export format "xml"`)
+	assert.NoFileExists(t, path)
+}
+
+func TestRun_IngestUpload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+	require.NoError(t, os.WriteFile(path, []byte("pcapng"), 0o600))
+
+	polls := 0
+	code, stdout, stderr := execute(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			_, header, err := r.FormFile("file")
+			require.NoError(t, err)
+			assert.Equal(t, "capture.pcapng", header.Filename)
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"id":"job_1","status":"queued"}`))
+		default:
+			assert.Equal(t, "/api/v1/ingest/jobs/job_1", r.URL.Path)
+			polls++
+			if polls == 1 {
+				w.Write([]byte(`{"id":"job_1","status":"running"}`))
+				return
+			}
+			w.Write([]byte(`{"id":"job_1","status":"failed","error":"truncated","finished_at":"2024-01-02T03:04:05Z"}`))
+		}
+	}, "ingest", "upload", "-wait", "-interval", "1ms", "-o", "json", path)
+	assert.Equal(t, exitError, code)
+	assert.Equal(t, 2, polls)
+	assert.Contains(t, stdout, `"status": "failed"`)
+	assert.Contains(t, stderr, "ingestion of "+path+" failed: truncated")
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, exitUsage, run(context.Background(), []string{"packets", "purge"}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown command "packets purge"`)
+
+	assert.Equal(t, exitOK, run(context.Background(), nil, nil, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "packets list")
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+current: local
+profiles:
+  local:
+    server: http://localhost:8080
+  prod:
+    server: https://sniffer.example.com
+    api_key: nsk_prod
+    output: json
+`), 0o600))
+
+	profile, err := loadProfile(path, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", profile.Server)
+
+	profile, err = loadProfile(path, "prod", false)
+	require.NoError(t, err)
+	assert.Equal(t, Profile{Server: "https://sniffer.example.com", APIKey: "nsk_prod", Output: "json"}, profile)
+
+	_, err = loadProfile(path, "staging", false)
+	assert.ErrorContains(t, err, `profile "staging" not found`)
+
+	// A missing default file is not an error, a missing explicit one is
+	missing := filepath.Join(t.TempDir(), "config.yaml")
+	_, err = loadProfile(missing, "", false)
+	assert.NoError(t, err)
+	_, err = loadProfile(missing, "", true)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
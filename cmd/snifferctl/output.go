@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats
+const (
+	outputTable  = "table"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+// view describes how a response is printed as a table
+type view struct {
+	// items is the key of the list of rows in the response object. When
+	// empty, a list response is printed one row per element and an object
+	// response as key/value pairs.
+	items string
+
+	// summary lists the fields of the response object printed as
+	// "header: value" lines above the rows
+	summary []column
+
+	// columns of the rows. When empty, rows are printed as key/value pairs.
+	columns []column
+}
+
+// column is a table column holding the value at a dotted JSON path
+type column struct {
+	header string
+	path   string
+	width  int
+}
+
+// printer writes responses in the selected format
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+// print writes a decoded JSON response
+func (p *printer) print(data interface{}, v view) error {
+	switch p.format {
+	case outputJSON:
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case outputNDJSON:
+		encoder := json.NewEncoder(p.w)
+		rows, ok := p.rows(data, v)
+		if !ok {
+			return encoder.Encode(data)
+		}
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return p.table(data, v)
+	}
+}
+
+// rows returns the list of rows of a response, if it holds one
+func (p *printer) rows(data interface{}, v view) ([]interface{}, bool) {
+	if v.items != "" {
+		if object, ok := data.(map[string]interface{}); ok {
+			rows, ok := object[v.items].([]interface{})
+			return rows, ok || object[v.items] == nil
+		}
+	}
+	rows, ok := data.([]interface{})
+	return rows, ok
+}
+
+// table writes a response as aligned columns
+func (p *printer) table(data interface{}, v view) error {
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+
+	if object, ok := data.(map[string]interface{}); ok {
+		for _, c := range v.summary {
+			fmt.Fprintf(w, "%s:\t%s\n", c.header, format(lookup(object, c.path)))
+		}
+		if len(v.summary) > 0 {
+			fmt.Fprintln(w)
+		}
+	}
+
+	rows, ok := p.rows(data, v)
+	if !ok {
+		writeFields(w, data)
+		return w.Flush()
+	}
+	if len(v.columns) == 0 {
+		for i, row := range rows {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			writeFields(w, row)
+		}
+		return w.Flush()
+	}
+
+	headers := make([]string, len(v.columns))
+	for i, c := range v.columns {
+		headers[i] = c.header
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(cells(row, v.columns), "\t"))
+	}
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "(none)")
+	}
+	return w.Flush()
+}
+
+// stream writes rows as they arrive. Columns have their fixed width so
+// that successive batches line up; the header is written when header is
+// set. JSON rows are written one per line, since the stream has no end.
+func (p *printer) stream(rows []interface{}, v view, header bool) error {
+	if p.format != outputTable {
+		encoder := json.NewEncoder(p.w)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var line bytes.Buffer
+	writeLine := func(values []string) {
+		line.Reset()
+		for i, value := range values {
+			if i < len(values)-1 {
+				fmt.Fprintf(&line, "%-*s  ", v.columns[i].width, value)
+			} else {
+				line.WriteString(value)
+			}
+		}
+		fmt.Fprintln(p.w, strings.TrimRight(line.String(), " "))
+	}
+
+	if header {
+		headers := make([]string, len(v.columns))
+		for i, c := range v.columns {
+			headers[i] = c.header
+		}
+		writeLine(headers)
+	}
+	for _, row := range rows {
+		writeLine(cells(row, v.columns))
+	}
+	return nil
+}
+
+// cells returns the formatted values of the columns of a row
+func cells(row interface{}, columns []column) []string {
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = format(lookup(row, c.path))
+	}
+	return values
+}
+
+// writeFields writes the fields of an object as sorted key/value pairs
+func writeFields(w io.Writer, data interface{}) {
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		fmt.Fprintln(w, format(data))
+		return
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s:\t%s\n", key, format(object[key]))
+	}
+}
+
+// lookup returns the value at a dotted path of a decoded JSON object
+func lookup(data interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		data = object[key]
+	}
+	return data
+}
+
+// format renders a JSON value in a table cell. Timestamps are shown in
+// local time to the second, and composite values as compact JSON.
+func format(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		if value == "" {
+			return "-"
+		}
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t.Local().Format("2006-01-02 15:04:05")
+		}
+		return value
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return value.String()
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return value.String()
+		}
+		return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+	case bool:
+		if value {
+			return "yes"
+		}
+		return "no"
+	default:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultServer is the server used when neither a flag, the environment
+// nor a profile selects one
+const defaultServer = "http://localhost:8080"
+
+// Profile holds the connection settings of one service instance
+type Profile struct {
+	Server string `yaml:"server"`
+	APIKey string `yaml:"api_key"`
+	Token  string `yaml:"token"`
+	Output string `yaml:"output"`
+}
+
+// profileFile is the YAML file of named profiles
+type profileFile struct {
+	Current  string             `yaml:"current"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// defaultProfilePath returns $SNIFFERCTL_CONFIG, or config.yaml in the
+// snifferctl directory of the user configuration directory
+func defaultProfilePath() string {
+	if path := os.Getenv("SNIFFERCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "snifferctl", "config.yaml")
+}
+
+// loadProfile returns the profile called name in the file at path, or its
+// current profile when name is empty. A missing file is only an error when
+// explicit is set or a profile is named.
+func loadProfile(path, name string, explicit bool) (Profile, error) {
+	if path == "" {
+		return Profile{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit && name == "" {
+		return Profile{}, nil
+	}
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var file profileFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return Profile{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if name == "" {
+		name = file.Current
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	profile, ok := file.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found in %s", name, path)
+	}
+	return profile, nil
+}
+
+// firstSet returns the first non-empty value
+func firstSet(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,758 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/client"
+)
+
+const (
+	// topMaxPackets bounds the packets kept for the packet list
+	topMaxPackets = 500
+
+	// topMaxSamples bounds the throughput history of the sparkline
+	topMaxSamples = 240
+
+	// topEntries is the number of top talkers and protocols shown
+	topEntries = 5
+
+	// topRankingInterval is the minimum interval between refreshes of the
+	// rankings, whose routes are rate limited as expensive
+	topRankingInterval = 5 * time.Second
+)
+
+// ANSI escape sequences
+const (
+	ansiBold       = "\x1b[1m"
+	ansiReverse    = "\x1b[7m"
+	ansiReset      = "\x1b[0m"
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearBelow = "\x1b[J"
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l"
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// sparklineLevels are the bars of the sparkline, lowest first
+const sparklineLevels = "▁▂▃▄▅▆▇█"
+
+// key is a key press: a printable character or the name of a special key
+type key string
+
+// Special keys
+const (
+	keyUp        key = "up"
+	keyDown      key = "down"
+	keyPageUp    key = "pgup"
+	keyPageDown  key = "pgdown"
+	keyEnter     key = "enter"
+	keyEscape    key = "esc"
+	keyBackspace key = "backspace"
+	keyInterrupt key = "ctrl+c"
+)
+
+// topCommand shows a live dashboard of the traffic until q is pressed
+func topCommand(fs *flag.FlagSet) runFunc {
+	interval := fs.Duration("interval", 2*time.Second, "refresh interval")
+	window := fs.Duration("window", 5*time.Minute, "window of the top talkers and protocol breakdown")
+
+	return func(ctx context.Context, cli *cli, args []string) error {
+		if *interval <= 0 {
+			return errors.New("-interval must be positive")
+		}
+		if *window <= 0 {
+			return errors.New("-window must be positive")
+		}
+		in, inOK := cli.stdin.(*os.File)
+		out, outOK := cli.stdout.(*os.File)
+		if !inOK || !outOK || !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+			return errors.New("top needs an interactive terminal; use \"packets list -watch\" to follow packets from a script")
+		}
+
+		source := &topSource{client: cli.client, window: *window}
+		model := newTopModel(cli.client.Server(), *window)
+		return runTop(ctx, in, out, source, model, *interval)
+	}
+}
+
+// runTop drives the dashboard: it puts the terminal in raw mode, redraws
+// the model after every key press and refresh, and restores the terminal
+// on exit
+func runTop(ctx context.Context, in, out *os.File, source *topSource, model *topModel, interval time.Duration) error {
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	fmt.Fprint(out, ansiAltScreen)
+	defer fmt.Fprint(out, ansiMainScreen)
+
+	keys := make(chan []key)
+	go readKeys(in, keys)
+
+	// A single refresh runs at a time so that slow responses never pile up
+	results := make(chan topSnapshot, 1)
+	fetching := false
+	refresh := func() {
+		if fetching || model.paused {
+			return
+		}
+		fetching = true
+		go func() { results <- source.fetch(ctx) }()
+	}
+	refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		draw(out, model.render(width, height))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case pressed, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			paused := model.paused
+			for _, k := range pressed {
+				if model.handle(k) {
+					return nil
+				}
+			}
+			if paused && !model.paused {
+				refresh()
+			}
+		case snapshot := <-results:
+			fetching = false
+			model.apply(snapshot)
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
+
+// draw writes a frame over the previous one
+func draw(w io.Writer, lines []string) {
+	var frame strings.Builder
+	frame.WriteString(ansiHome)
+	for i, line := range lines {
+		if i > 0 {
+			frame.WriteString("\r\n")
+		}
+		frame.WriteString(line)
+		frame.WriteString(ansiClearLine)
+	}
+	frame.WriteString(ansiClearBelow)
+	io.WriteString(w, frame.String())
+}
+
+// readKeys sends the keys read from r until it fails, then closes keys
+func readKeys(r io.Reader, keys chan<- []key) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			keys <- parseKeys(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseKeys decodes the bytes of a terminal in raw mode into keys
+func parseKeys(data []byte) []key {
+	sequences := map[string]key{
+		"\x1b[A": keyUp, "\x1bOA": keyUp,
+		"\x1b[B": keyDown, "\x1bOB": keyDown,
+		"\x1b[5~": keyPageUp,
+		"\x1b[6~": keyPageDown,
+	}
+
+	var keys []key
+	for len(data) > 0 {
+		if data[0] == 0x1b && len(data) > 1 {
+			matched := false
+			for sequence, k := range sequences {
+				if strings.HasPrefix(string(data), sequence) {
+					keys = append(keys, k)
+					data = data[len(sequence):]
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+		}
+
+		switch c := data[0]; {
+		case c == 0x1b:
+			keys = append(keys, keyEscape)
+		case c == '\r' || c == '\n':
+			keys = append(keys, keyEnter)
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, keyBackspace)
+		case c == 0x03:
+			keys = append(keys, keyInterrupt)
+		case c >= 0x20 && c < 0x7f:
+			keys = append(keys, key(string(rune(c))))
+		}
+		data = data[1:]
+	}
+	return keys
+}
+
+// topSnapshot is the result of a refresh
+type topSnapshot struct {
+	at        time.Time
+	elapsed   time.Duration
+	packets   []models.Packet
+	stats     *models.Stats
+	running   bool
+	talkers   *models.TopNResponse
+	protocols *models.TopNResponse
+	err       error
+}
+
+// topSource fetches the data of the dashboard
+type topSource struct {
+	client *client.Client
+	window time.Duration
+	tail   *client.Tail
+	last   time.Time
+	ranked time.Time
+}
+
+// fetch returns the current statistics, the rankings when they are due
+// and the packets stored since the previous call. The packets are fetched
+// last, so that none is lost when another request fails.
+func (s *topSource) fetch(ctx context.Context) topSnapshot {
+	snapshot := topSnapshot{at: time.Now()}
+
+	snapshot.stats, snapshot.err = s.client.Stats(ctx)
+	if snapshot.err != nil {
+		return snapshot
+	}
+
+	var status struct {
+		Running bool `json:"running"`
+	}
+	if snapshot.err = s.client.Do(ctx, http.MethodGet, "/api/v1/sniffing/status", nil, nil, &status); snapshot.err != nil {
+		return snapshot
+	}
+	snapshot.running = status.Running
+
+	if snapshot.at.Sub(s.ranked) >= topRankingInterval {
+		query := url.Values{
+			"window": {s.window.String()},
+			"n":      {strconv.Itoa(topEntries)},
+		}
+		query.Set("by", "bytes")
+		if snapshot.talkers, snapshot.err = s.client.TopN(ctx, "source_ip", query); snapshot.err != nil {
+			return snapshot
+		}
+		query.Set("by", "packets")
+		if snapshot.protocols, snapshot.err = s.client.TopN(ctx, "protocol", query); snapshot.err != nil {
+			return snapshot
+		}
+		s.ranked = snapshot.at
+	}
+
+	// Start with the last minute of traffic, measured on the server's clock
+	if s.tail == nil {
+		var filter models.PacketFilter
+		if snapshot.stats.NewestAt != nil {
+			filter.FromTimestamp = snapshot.stats.NewestAt.Add(-time.Minute)
+		}
+		s.tail = s.client.Tail(filter)
+	} else {
+		snapshot.elapsed = snapshot.at.Sub(s.last)
+	}
+	if snapshot.packets, snapshot.err = s.tail.Next(ctx); snapshot.err != nil {
+		return snapshot
+	}
+	s.last = snapshot.at
+	return snapshot
+}
+
+// topModel is the state of the dashboard, independent of the terminal
+type topModel struct {
+	server string
+	window time.Duration
+
+	packets   []models.Packet // newest first
+	samples   []float64       // packets per second, oldest first
+	stats     *models.Stats
+	running   bool
+	talkers   *models.TopNResponse
+	protocols *models.TopNResponse
+	updated   time.Time
+	err       error
+
+	filter   string
+	editing  bool
+	draft    string
+	selected string // ID of the selected packet, empty to follow the newest
+	detail   *models.Packet
+	paused   bool
+}
+
+// newTopModel returns an empty dashboard of server
+func newTopModel(server string, window time.Duration) *topModel {
+	return &topModel{server: server, window: window}
+}
+
+// apply merges a refresh into the model
+func (m *topModel) apply(s topSnapshot) {
+	m.err = s.err
+	if s.err != nil {
+		return
+	}
+	m.updated = s.at
+	m.stats = s.stats
+	m.running = s.running
+	if s.talkers != nil {
+		m.talkers = s.talkers
+		m.protocols = s.protocols
+	}
+
+	if s.elapsed > 0 {
+		m.samples = append(m.samples, float64(len(s.packets))/s.elapsed.Seconds())
+		if len(m.samples) > topMaxSamples {
+			m.samples = m.samples[len(m.samples)-topMaxSamples:]
+		}
+	}
+
+	fresh := make([]models.Packet, 0, len(s.packets)+len(m.packets))
+	for i := len(s.packets) - 1; i >= 0; i-- {
+		fresh = append(fresh, s.packets[i])
+	}
+	m.packets = append(fresh, m.packets...)
+	if len(m.packets) > topMaxPackets {
+		m.packets = m.packets[:topMaxPackets]
+	}
+}
+
+// visible returns the packets matching the filter, newest first
+func (m *topModel) visible() []models.Packet {
+	if m.filter == "" {
+		return m.packets
+	}
+	var packets []models.Packet
+	for _, packet := range m.packets {
+		if matchPacket(packet, m.filter) {
+			packets = append(packets, packet)
+		}
+	}
+	return packets
+}
+
+// cursor returns the index of the selected packet among the visible ones
+func (m *topModel) cursor(packets []models.Packet) int {
+	for i, packet := range packets {
+		if packet.ID == m.selected {
+			return i
+		}
+	}
+	return 0
+}
+
+// move selects the packet delta rows away from the selected one
+func (m *topModel) move(delta int) {
+	packets := m.visible()
+	if len(packets) == 0 {
+		return
+	}
+	i := min(max(m.cursor(packets)+delta, 0), len(packets)-1)
+	m.selected = ""
+	if i > 0 {
+		m.selected = packets[i].ID
+	}
+}
+
+// handle applies a key press and reports whether the dashboard should exit
+func (m *topModel) handle(k key) bool {
+	if k == keyInterrupt {
+		return true
+	}
+
+	if m.editing {
+		switch k {
+		case keyEnter:
+			m.filter = strings.TrimSpace(m.draft)
+			m.editing = false
+			m.selected = ""
+		case keyEscape:
+			m.editing = false
+		case keyBackspace:
+			if n := len(m.draft); n > 0 {
+				m.draft = m.draft[:n-1]
+			}
+		default:
+			if len(k) == 1 {
+				m.draft += string(k)
+			}
+		}
+		return false
+	}
+
+	if m.detail != nil {
+		switch k {
+		case keyEscape, keyEnter, keyBackspace:
+			m.detail = nil
+		case "q":
+			return true
+		}
+		return false
+	}
+
+	switch k {
+	case "q":
+		return true
+	case "/":
+		m.editing = true
+		m.draft = m.filter
+	case keyEscape:
+		m.filter = ""
+		m.selected = ""
+	case "p", " ":
+		m.paused = !m.paused
+	case keyUp, "k":
+		m.move(-1)
+	case keyDown, "j":
+		m.move(1)
+	case keyPageUp:
+		m.move(-10)
+	case keyPageDown:
+		m.move(10)
+	case "g":
+		m.selected = ""
+	case keyEnter:
+		if packets := m.visible(); len(packets) > 0 {
+			packet := packets[m.cursor(packets)]
+			m.detail = &packet
+		}
+	}
+	return false
+}
+
+// matchPacket reports whether packet matches every term of filter. A term
+// is either field:value, with field one of proto, src, dst, host, port
+// and flags, or a text searched in every field. Matching ignores case.
+func matchPacket(packet models.Packet, filter string) bool {
+	port := strconv.Itoa(packet.Port)
+	for _, term := range strings.Fields(strings.ToLower(filter)) {
+		field, value, found := strings.Cut(term, ":")
+		if !found || value == "" {
+			field, value = "", term
+		}
+
+		var ok bool
+		switch field {
+		case "proto", "protocol":
+			ok = strings.EqualFold(packet.Protocol, value)
+		case "src":
+			ok = packet.SourceIP == value
+		case "dst":
+			ok = packet.DestinationIP == value
+		case "host":
+			ok = packet.SourceIP == value || packet.DestinationIP == value
+		case "port":
+			ok = port == value
+		case "flags":
+			ok = strings.Contains(strings.ToLower(packet.Flags), value)
+		default:
+			ok = false
+			for _, text := range []string{packet.ID, packet.Protocol, packet.SourceIP, packet.DestinationIP, port, packet.Flags} {
+				if strings.Contains(strings.ToLower(text), term) {
+					ok = true
+					break
+				}
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// render returns the lines of the screen
+func (m *topModel) render(width, height int) []string {
+	width = max(width, 40)
+	height = max(height, 10)
+
+	lines := []string{m.header(width)}
+	if m.detail != nil {
+		lines = append(lines, m.renderDetail(width, height-2)...)
+	} else {
+		lines = append(lines, m.renderDashboard(width, height-2)...)
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	return append(lines[:height-1], m.footer(width))
+}
+
+// header returns the title line
+func (m *topModel) header(width int) string {
+	state := "stopped"
+	if m.running {
+		state = "running"
+	}
+	title := fmt.Sprintf("snifferctl top - %s   capture %s", m.server, state)
+	if !m.updated.IsZero() {
+		title += "   " + m.updated.Local().Format("15:04:05")
+	}
+	if m.paused {
+		title += "   [PAUSED]"
+	}
+	return ansiBold + fit(title, width) + ansiReset
+}
+
+// footer returns the filter prompt, the last error or the key help
+func (m *topModel) footer(width int) string {
+	switch {
+	case m.editing:
+		return fit("/"+m.draft+"_   (enter apply, esc cancel; e.g. proto:tcp src:10.0.0.1 port:443)", width)
+	case m.err != nil:
+		return ansiReverse + fit("error: "+m.err.Error(), width) + ansiReset
+	case m.detail != nil:
+		return fit("esc back  q quit", width)
+	default:
+		help := "/ filter  esc clear  up/down select  enter details  p pause  q quit"
+		if m.filter != "" {
+			help = "filter: " + m.filter + "   " + help
+		}
+		return fit(help, width)
+	}
+}
+
+// renderDashboard returns the summary, rankings and packet list
+func (m *topModel) renderDashboard(width, height int) []string {
+	var lines []string
+
+	summary := "Packets: -"
+	if m.stats != nil {
+		summary = fmt.Sprintf("Packets: %d/%d", m.stats.TotalPackets, m.stats.Capacity)
+		for _, w := range m.stats.Windows {
+			if w.Window == "1m" {
+				summary += fmt.Sprintf("   1m: %.1f pkt/s  %s/s  %d sources", w.PacketsPerSecond, humanBytes(w.BytesPerSecond), w.DistinctSources)
+			}
+		}
+	}
+	lines = append(lines, fit(summary, width))
+
+	const label = "Throughput "
+	rate := "-"
+	if n := len(m.samples); n > 0 {
+		rate = fmt.Sprintf("%.1f pkt/s", m.samples[n-1])
+	}
+	spark := sparkline(m.samples, max(width-len(label)-len(rate)-1, 0))
+	lines = append(lines, fit(label+spark+" "+rate, width), "")
+
+	half := (width - 2) / 2
+	window := shortDuration(m.window)
+	left := rankLines("TOP TALKERS ("+window+")", m.talkers, half, true)
+	right := rankLines("PROTOCOLS ("+window+")", m.protocols, half, false)
+	for i := range left {
+		line := fit(left[i], half) + "  " + fit(right[i], half)
+		if i == 0 {
+			line = ansiBold + line + ansiReset
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "")
+
+	packets := m.visible()
+	title := fmt.Sprintf("PACKETS (%d)", len(packets))
+	if m.filter != "" {
+		title = fmt.Sprintf("PACKETS (%d of %d matching %q)", len(packets), len(m.packets), m.filter)
+	}
+	lines = append(lines, fit(title, width))
+	lines = append(lines, ansiBold+fit(packetLine("TIME", "PROTO", "SOURCE", "DESTINATION", "PORT", "SIZE", "FLAGS"), width)+ansiReset)
+
+	rows := height - len(lines)
+	if rows <= 0 {
+		return lines
+	}
+	if len(packets) == 0 {
+		return append(lines, "(none)")
+	}
+	cursor := m.cursor(packets)
+	start := max(cursor-rows+1, 0)
+	for i := start; i < len(packets) && i < start+rows; i++ {
+		packet := packets[i]
+		line := fit(packetLine(
+			packet.Timestamp.Local().Format("15:04:05"),
+			packet.Protocol,
+			packet.SourceIP,
+			packet.DestinationIP,
+			strconv.Itoa(packet.Port),
+			strconv.Itoa(packet.Size),
+			packet.Flags,
+		), width)
+		if i == cursor {
+			line = ansiReverse + line + ansiReset
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// renderDetail returns the fields of the selected packet
+func (m *topModel) renderDetail(width, height int) []string {
+	p := m.detail
+	fields := [][2]string{
+		{"ID", p.ID},
+		{"Timestamp", p.Timestamp.Local().Format("2006-01-02 15:04:05.000")},
+		{"Protocol", p.Protocol},
+		{"Source", p.SourceIP},
+		{"Destination", p.DestinationIP},
+		{"Port", strconv.Itoa(p.Port)},
+		{"Size", strconv.Itoa(p.Size)},
+		{"TTL", strconv.Itoa(p.TTL)},
+		{"Flags", p.Flags},
+	}
+	lines := []string{ansiBold + fit("PACKET", width) + ansiReset}
+	for _, field := range fields {
+		value := field[1]
+		if value == "" || value == "0" && field[0] == "TTL" {
+			value = "-"
+		}
+		lines = append(lines, fit(fmt.Sprintf("%-12s %s", field[0]+":", value), width))
+	}
+
+	lines = append(lines, "", ansiBold+fit("PAYLOAD", width)+ansiReset)
+	if p.Payload == "" {
+		return append(lines, "-")
+	}
+	payload := []rune(p.Payload)
+	for len(payload) > 0 && len(lines) < height {
+		n := min(width, len(payload))
+		lines = append(lines, printable(string(payload[:n])))
+		payload = payload[n:]
+	}
+	return lines
+}
+
+// rankLines returns the title and entries of a ranking, padded to
+// topEntries rows
+func rankLines(title string, ranking *models.TopNResponse, width int, bytes bool) []string {
+	lines := []string{title}
+	if ranking != nil {
+		for _, entry := range ranking.Entries {
+			value := strconv.Itoa(entry.Packets)
+			if bytes {
+				value = humanBytes(float64(entry.Bytes))
+			}
+			text := fmt.Sprintf("%-18s %9s %5.1f%% ", fit(entry.Key, 18), value, entry.Share*100)
+			bar := max(width-len(text), 0)
+			lines = append(lines, text+strings.Repeat("#", int(math.Round(entry.Share*float64(bar)))))
+		}
+	}
+	if len(lines) == 1 {
+		lines = append(lines, "(none)")
+	}
+	for len(lines) <= topEntries {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// packetLine lays out the columns of the packet list
+func packetLine(timestamp, protocol, source, destination, port, size, flags string) string {
+	return fmt.Sprintf("%-8s  %-5s  %-15s  %-15s  %5s  %6s  %s", timestamp, protocol, source, destination, port, size, flags)
+}
+
+// sparkline draws the last width samples scaled to the largest one
+func sparkline(samples []float64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+	peak := 0.0
+	for _, sample := range samples {
+		peak = math.Max(peak, sample)
+	}
+
+	levels := []rune(sparklineLevels)
+	line := []rune(strings.Repeat(" ", width-len(samples)))
+	for _, sample := range samples {
+		if peak == 0 || sample <= 0 {
+			line = append(line, ' ')
+			continue
+		}
+		line = append(line, levels[int(math.Ceil(sample/peak*float64(len(levels))))-1])
+	}
+	return string(line)
+}
+
+// fit truncates or pads s to width columns
+func fit(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+// printable replaces the control characters of s, which would garble the
+// screen
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '.'
+		}
+		return r
+	}, s)
+}
+
+// humanBytes formats a byte count with a binary unit
+func humanBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
+// shortDuration formats d without its zero trailing units, e.g. 5m
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
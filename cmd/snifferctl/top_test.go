@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// topPackets returns n packets one second apart, oldest first
+func topPackets(n int) []models.Packet {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	packets := make([]models.Packet, n)
+	for i := range packets {
+		protocol, port := "TCP", 443
+		if i%2 == 1 {
+			protocol, port = "UDP", 53
+		}
+		packets[i] = models.Packet{
+			ID:            fmt.Sprintf("packet_%d", i+1),
+			Timestamp:     base.Add(time.Duration(i) * time.Second),
+			Protocol:      protocol,
+			SourceIP:      fmt.Sprintf("10.0.0.%d", i+1),
+			DestinationIP: "10.0.1.1",
+			Port:          port,
+			Size:          100,
+		}
+	}
+	return packets
+}
+
+// press sends keys to the model and fails if one of them quits
+func press(t *testing.T, m *topModel, keys ...key) {
+	t.Helper()
+	for _, k := range keys {
+		require.False(t, m.handle(k), "key %q quit", k)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	assert.Equal(t,
+		[]key{"/", "t", keyUp, keyDown, keyPageDown, keyBackspace, keyEnter, keyEscape, keyInterrupt},
+		parseKeys([]byte("/t\x1b[A\x1bOB\x1b[6~\x7f\r\x1b\x03")),
+	)
+}
+
+func TestMatchPacket(t *testing.T) {
+	packet := models.Packet{ID: "packet_1", Protocol: "TCP", SourceIP: "10.0.0.1", DestinationIP: "192.168.1.5", Port: 443, Flags: "SYN,ACK"}
+	for filter, want := range map[string]bool{
+		"":                      true,
+		"tcp":                   true,
+		"proto:udp":             false,
+		"src:10.0.0.1 port:443": true,
+		"src:192.168.1.5":       false,
+		"host:192.168.1.5":      true,
+		"flags:ack 192.168":     true,
+		"port:44":               false,
+		"PACKET_1":              true,
+		"10.0.0.1 missing":      false,
+	} {
+		assert.Equal(t, want, matchPacket(packet, filter), filter)
+	}
+}
+
+func TestTopModel_Apply(t *testing.T) {
+	m := newTopModel("http://localhost:8080", 5*time.Minute)
+	packets := topPackets(4)
+
+	m.apply(topSnapshot{packets: packets[:2]})
+	m.apply(topSnapshot{packets: packets[2:], elapsed: 2 * time.Second})
+	require.Len(t, m.packets, 4)
+	assert.Equal(t, "packet_4", m.packets[0].ID, "newest first")
+	assert.Equal(t, []float64{1}, m.samples, "only refreshes after the first are sampled")
+
+	// Errors are reported without discarding the current data
+	m.apply(topSnapshot{err: fmt.Errorf("connection refused")})
+	assert.Len(t, m.packets, 4)
+	assert.Contains(t, m.footer(80), "error: connection refused")
+
+	m.apply(topSnapshot{packets: topPackets(topMaxPackets)})
+	assert.Len(t, m.packets, topMaxPackets)
+}
+
+func TestTopModel_FilterAndDetail(t *testing.T) {
+	m := newTopModel("http://localhost:8080", 5*time.Minute)
+	m.apply(topSnapshot{packets: topPackets(6)})
+
+	press(t, m, "/", "u", "d", "p", keyEnter)
+	assert.Equal(t, "udp", m.filter)
+	require.Len(t, m.visible(), 3)
+
+	// The selection follows the packet when new ones arrive
+	press(t, m, keyDown)
+	assert.Equal(t, "packet_4", m.selected)
+	m.apply(topSnapshot{packets: []models.Packet{{ID: "packet_7", Protocol: "UDP"}}})
+	assert.Equal(t, 2, m.cursor(m.visible()))
+
+	press(t, m, keyEnter)
+	require.NotNil(t, m.detail)
+	assert.Equal(t, "packet_4", m.detail.ID)
+	screen := strings.Join(m.render(100, 30), "\n")
+	assert.Contains(t, screen, "packet_4")
+	assert.Contains(t, screen, "esc back")
+
+	press(t, m, keyEscape, keyEscape)
+	assert.Nil(t, m.detail)
+	assert.Empty(t, m.filter)
+	assert.True(t, m.handle("q"))
+}
+
+func TestTopModel_RenderDashboard(t *testing.T) {
+	m := newTopModel("http://localhost:8080", 5*time.Minute)
+	m.apply(topSnapshot{
+		packets: topPackets(30),
+		stats:   &models.Stats{TotalPackets: 30, Capacity: 1000},
+		running: true,
+		talkers: &models.TopNResponse{Entries: []models.TopNEntry{
+			{Key: "10.0.0.1", Packets: 3, Bytes: 3072, Share: 0.5},
+		}},
+		protocols: &models.TopNResponse{Entries: []models.TopNEntry{
+			{Key: "TCP", Packets: 15, Share: 0.5},
+			{Key: "UDP", Packets: 15, Share: 0.5},
+		}},
+	})
+
+	lines := m.render(100, 24)
+	require.Len(t, lines, 24)
+	screen := strings.Join(lines, "\n")
+	assert.Contains(t, lines[0], "capture running")
+	assert.Contains(t, screen, "Packets: 30/1000")
+	assert.Regexp(t, `10\.0\.0\.1\s+3\.0 KiB\s+50\.0% #+`, screen)
+	assert.Regexp(t, `UDP\s+15\s+50\.0%`, screen)
+	assert.Contains(t, screen, "PACKETS (30)")
+
+	// The newest packet is selected and the list is cut to the screen
+	assert.True(t, strings.HasPrefix(lines[13], ansiReverse))
+	assert.Contains(t, lines[13], "10.0.0.30")
+	assert.Contains(t, lines[22], "10.0.0.21")
+	assert.Contains(t, lines[23], "q quit")
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "  ▁▄█", sparkline([]float64{0.5, 4, 8}, 5))
+	assert.Equal(t, "▄█", sparkline([]float64{0, 4, 8}, 2), "only the latest samples fit")
+	assert.Equal(t, "   ", sparkline([]float64{0, 0}, 3))
+	assert.Empty(t, sparkline([]float64{1}, 0))
+}
@@ -0,0 +1,284 @@
+// Package agent runs a capture agent: packets captured locally are batched,
+// buffered on disk and forwarded to a collector over the CollectorService
+// of the gRPC API.
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	snifferv1 "github.com/cryptonextsecurity/network-sniffer/proto/sniffer/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Config configures an agent
+type Config struct {
+	// ID identifies the agent to the collector, which tags its packets
+	// with it
+	ID string
+
+	// Hostname is reported to the collector
+	Hostname string
+
+	// APIKey authenticates the agent to the collector
+	APIKey string
+
+	// BatchSize is the number of packets of a full batch
+	BatchSize int
+
+	// FlushInterval bounds the time a packet waits for its batch to fill
+	FlushInterval time.Duration
+
+	// MinBackoff and MaxBackoff bound the delay before reconnecting to an
+	// unreachable collector, doubled after each failed attempt
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultConfig returns the default agent configuration
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:     500,
+		FlushInterval: 5 * time.Second,
+		MinBackoff:    time.Second,
+		MaxBackoff:    30 * time.Second,
+	}
+}
+
+// Agent forwards captured packets to a collector. It implements the
+// storage of a sniffer: stored packets are batched, and every batch is
+// written to the buffer before it is forwarded, then removed once the
+// collector acknowledges it.
+type Agent struct {
+	config Config
+	buffer *Buffer
+	client snifferv1.CollectorServiceClient
+	logger *slog.Logger
+
+	mutex   sync.Mutex
+	pending []models.Packet
+
+	flushed   chan struct{}
+	connected atomic.Bool
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// New creates an agent forwarding the batches of buffer over conn. A nil
+// logger selects the default logger.
+func New(config Config, buffer *Buffer, conn grpc.ClientConnInterface, logger *slog.Logger) *Agent {
+	return &Agent{
+		config:  config,
+		buffer:  buffer,
+		client:  snifferv1.NewCollectorServiceClient(conn),
+		logger:  logging.OrDefault(logger),
+		flushed: make(chan struct{}, 1),
+	}
+}
+
+// Store queues a packet for the next batch, which is flushed once full
+func (a *Agent) Store(_ context.Context, packet *models.Packet) error {
+	a.mutex.Lock()
+	a.pending = append(a.pending, *packet)
+	full := len(a.pending) >= a.config.BatchSize
+	a.mutex.Unlock()
+
+	if full {
+		return a.Flush()
+	}
+	return nil
+}
+
+// Flush writes the queued packets to the buffer as a batch
+func (a *Agent) Flush() error {
+	a.mutex.Lock()
+	packets := a.pending
+	a.pending = nil
+	a.mutex.Unlock()
+	if len(packets) == 0 {
+		return nil
+	}
+
+	dropped, err := a.buffer.Append(packets)
+	if err != nil {
+		metrics.AgentDroppedPackets.Add(float64(len(packets)))
+		return fmt.Errorf("failed to buffer %d packets: %w", len(packets), err)
+	}
+	if dropped > 0 {
+		a.logger.Warn("Agent buffer full, discarded the oldest packets", "dropped", dropped)
+	}
+	select {
+	case a.flushed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start flushes batches periodically and forwards them until Stop
+func (a *Agent) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.wg.Add(2)
+	go a.flushLoop(ctx)
+	go a.forwardLoop(ctx)
+}
+
+// Stop stops forwarding and flushes the queued packets to the buffer, to
+// be forwarded by the next run
+func (a *Agent) Stop() error {
+	if a.cancel != nil {
+		a.cancel()
+		a.wg.Wait()
+	}
+	return a.Flush()
+}
+
+// Connected reports whether the agent has a forwarding stream open
+func (a *Agent) Connected() bool {
+	return a.connected.Load()
+}
+
+// Buffered returns the number of batches waiting to be forwarded and their
+// size in bytes
+func (a *Agent) Buffered() (batches int, bytes int64) {
+	return a.buffer.Len()
+}
+
+// flushLoop flushes the queued packets every FlushInterval
+func (a *Agent) flushLoop(ctx context.Context) {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Flush(); err != nil {
+				a.logger.Error("Failed to flush packets", "error", err.Error())
+			}
+		}
+	}
+}
+
+// forwardLoop keeps a forwarding stream open, reconnecting with an
+// exponential backoff while the collector is unreachable
+func (a *Agent) forwardLoop(ctx context.Context) {
+	defer a.wg.Done()
+	backoff := a.config.MinBackoff
+	for {
+		connected, err := a.forward(ctx)
+		a.connected.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = a.config.MinBackoff
+		}
+		batches, _ := a.buffer.Len()
+		a.logger.Warn("Collector unreachable, buffering packets", "error", err.Error(), "retry_in", backoff.String(), "buffered_batches", batches)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, a.config.MaxBackoff)
+	}
+}
+
+// forward registers the agent, then sends the buffered batches oldest
+// first, one at a time, until the stream breaks or ctx is done. connected
+// reports whether the stream was opened.
+func (a *Agent) forward(ctx context.Context) (connected bool, err error) {
+	if a.config.APIKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", a.config.APIKey)
+	}
+	if _, err := a.client.RegisterAgent(ctx, &snifferv1.RegisterAgentRequest{AgentId: a.config.ID, Hostname: a.config.Hostname}); err != nil {
+		return false, err
+	}
+	stream, err := a.client.ForwardPackets(ctx)
+	if err != nil {
+		return false, err
+	}
+	a.connected.Store(true)
+	a.logger.Info("Connected to collector", "agent_id", a.config.ID)
+
+	for {
+		batch, err := a.buffer.Oldest()
+		if errors.Is(err, ErrCorruptBatch) {
+			a.logger.Error("Discarded buffered batch", "error", err.Error())
+			continue
+		}
+		if err != nil {
+			return true, err
+		}
+		if batch == nil {
+			select {
+			case <-ctx.Done():
+				return true, stream.CloseSend()
+			case <-a.flushed:
+				continue
+			}
+		}
+
+		request := &snifferv1.ForwardPacketsRequest{
+			AgentId:  a.config.ID,
+			BufferId: a.buffer.ID(),
+			Sequence: batch.Sequence,
+			Packets:  make([]*snifferv1.Packet, len(batch.Packets)),
+		}
+		for i := range batch.Packets {
+			request.Packets[i] = toPacket(&batch.Packets[i])
+		}
+		if err := stream.Send(request); err != nil {
+			// The status of a stream ended by the collector is returned by
+			// Recv
+			if errors.Is(err, io.EOF) {
+				_, err = stream.Recv()
+			}
+			return true, err
+		}
+		ack, err := stream.Recv()
+		if err != nil {
+			return true, err
+		}
+		if ack.Sequence != batch.Sequence {
+			return true, fmt.Errorf("collector acknowledged batch %d instead of %d", ack.Sequence, batch.Sequence)
+		}
+		if err := a.buffer.Remove(batch.Sequence); err != nil {
+			return true, err
+		}
+		metrics.AgentForwardedPackets.Add(float64(ack.Accepted))
+		if ack.Rejected > 0 {
+			a.logger.Warn("Collector rejected invalid packets", "sequence", batch.Sequence, "rejected", ack.Rejected)
+		}
+	}
+}
+
+// toPacket converts a packet to its protobuf message
+func toPacket(packet *models.Packet) *snifferv1.Packet {
+	return &snifferv1.Packet{
+		Id:            packet.ID,
+		SourceIp:      packet.SourceIP,
+		DestinationIp: packet.DestinationIP,
+		Protocol:      packet.Protocol,
+		Port:          int32(packet.Port),
+		Size:          int32(packet.Size),
+		Timestamp:     timestamppb.New(packet.Timestamp),
+		Ttl:           int32(packet.TTL),
+		Flags:         packet.Flags,
+		Payload:       packet.Payload,
+	}
+}
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/collector"
+	"github.com/cryptonextsecurity/network-sniffer/internal/grpcapi"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/services"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// collectorFixture is a collector serving over an in-memory connection and
+// accepting the API key of an analyst
+type collectorFixture struct {
+	storage  *storage.InMemoryStorage
+	registry *collector.Registry
+	conn     *grpc.ClientConn
+}
+
+func newCollector(t *testing.T) *collectorFixture {
+	t.Helper()
+	store := storage.NewInMemoryStorage(100)
+	packetService := services.NewPacketService(store, sniffing.NewPacketSniffer(store, time.Hour), nil)
+	keys, err := auth.OpenKeyStore("")
+	require.NoError(t, err)
+	keys.AddStatic("edge", "nsk_agent", auth.RoleAnalyst)
+	registry := collector.NewRegistry(10)
+	feed := grpcapi.NewFeed(10)
+
+	listener := bufconn.Listen(1 << 20)
+	rpcServer := grpcapi.NewServer(packetService, feed, nil).
+		WithAuth(auth.NewAuthenticator(keys, nil)).
+		WithCollector(registry).
+		Setup()
+	go rpcServer.Serve(listener)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		grpcapi.Shutdown(ctx, rpcServer, feed)
+	})
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &collectorFixture{storage: store, registry: registry, conn: conn}
+}
+
+// testConfig flushes and reconnects quickly
+func testConfig() Config {
+	config := DefaultConfig()
+	config.ID = "edge-1"
+	config.Hostname = "edge-host"
+	config.APIKey = "nsk_agent"
+	config.BatchSize = 3
+	config.FlushInterval = 10 * time.Millisecond
+	config.MinBackoff = 10 * time.Millisecond
+	config.MaxBackoff = 10 * time.Millisecond
+	return config
+}
+
+// storedPackets returns the live packets stored by the collector
+func (f *collectorFixture) storedPackets(t *testing.T) []models.Packet {
+	response, err := f.storage.Get(context.Background(), &models.PacketFilter{})
+	require.NoError(t, err)
+	return response.Packets
+}
+
+func TestAgent_Forward(t *testing.T) {
+	f := newCollector(t)
+	buffer, err := OpenBuffer(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	agent := New(testConfig(), buffer, f.conn, nil)
+	agent.Start()
+	defer agent.Stop()
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, agent.Store(ctx, models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 60)))
+	}
+	invalid := models.NewPacket("10.0.0.1", "not an address", "TCP", 443, 60)
+	require.NoError(t, agent.Store(ctx, invalid))
+
+	// Full batches and the remainder are forwarded, tagged with the agent
+	require.Eventually(t, func() bool { return len(f.storedPackets(t)) == 4 }, 5*time.Second, 10*time.Millisecond)
+	for _, packet := range f.storedPackets(t) {
+		assert.Equal(t, "edge-1", packet.Agent)
+	}
+	require.Eventually(t, func() bool { batches, _ := agent.Buffered(); return batches == 0 }, time.Second, 10*time.Millisecond)
+	assert.True(t, agent.Connected())
+
+	agents := f.registry.List()
+	require.Len(t, agents, 1)
+	assert.Equal(t, "edge-host", agents[0].Hostname)
+	assert.True(t, agents[0].Connected)
+	assert.Equal(t, uint64(4), agents[0].Packets)
+	assert.Equal(t, uint64(1), agents[0].Rejected)
+}
+
+func TestAgent_Unreachable(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir, 1<<20)
+	require.NoError(t, err)
+
+	// Nothing listens on the address of a closed listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener.Close()
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	offline := New(testConfig(), buffer, conn, nil)
+	offline.Start()
+	require.NoError(t, offline.Store(context.Background(), models.NewPacket("10.0.0.1", "10.0.0.2", "UDP", 53, 80)))
+	require.NoError(t, offline.Stop())
+	assert.False(t, offline.Connected())
+	batches, _ := offline.Buffered()
+	assert.Equal(t, 1, batches)
+
+	// The buffered batch is forwarded once a collector is reachable
+	f := newCollector(t)
+	buffer, err = OpenBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	online := New(testConfig(), buffer, f.conn, nil)
+	online.Start()
+	defer online.Stop()
+	require.Eventually(t, func() bool { return len(f.storedPackets(t)) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "UDP", f.storedPackets(t)[0].Protocol)
+}
+
+func TestAgent_Unauthenticated(t *testing.T) {
+	f := newCollector(t)
+	buffer, err := OpenBuffer(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	config := testConfig()
+	config.APIKey = "nsk_unknown"
+	agent := New(config, buffer, f.conn, nil)
+	agent.Start()
+	require.NoError(t, agent.Store(context.Background(), models.NewPacket("10.0.0.1", "10.0.0.2", "UDP", 53, 80)))
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, agent.Stop())
+	assert.Empty(t, f.registry.List())
+	batches, _ := agent.Buffered()
+	assert.Equal(t, 1, batches)
+}
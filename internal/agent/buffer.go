@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// ErrCorruptBatch is returned for a buffered batch that cannot be decoded.
+// The batch is discarded.
+var ErrCorruptBatch = errors.New("corrupt buffered batch")
+
+// Files of a buffer directory
+const (
+	stateFile   = "buffer.json"
+	batchSuffix = ".batch"
+)
+
+// Batch is a set of packets forwarded and acknowledged together
+type Batch struct {
+	Sequence uint64          `json:"sequence"`
+	Packets  []models.Packet `json:"packets"`
+}
+
+// Buffer holds batches on disk until the collector acknowledges them, so
+// that captured packets outlive collector outages and agent restarts. Each
+// batch is a file named after its sequence number and packet count. Once
+// the buffer exceeds its maximum size the oldest batches are discarded.
+type Buffer struct {
+	dir      string
+	maxBytes int64
+
+	mutex   sync.Mutex
+	id      string
+	next    uint64
+	batches []bufferedBatch
+	bytes   int64
+}
+
+// bufferedBatch is a batch file, in sequence order
+type bufferedBatch struct {
+	sequence uint64
+	packets  int
+	size     int64
+}
+
+// bufferState is persisted so that sequence numbers keep increasing across
+// restarts, even once every batch was acknowledged
+type bufferState struct {
+	ID           string `json:"id"`
+	NextSequence uint64 `json:"next_sequence"`
+}
+
+// OpenBuffer opens the buffer in dir, creating it when needed, and loads
+// the batches left by a previous run
+func OpenBuffer(dir string, maxBytes int64) (*Buffer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	b := &Buffer{dir: dir, maxBytes: maxBytes, next: 1}
+
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	switch {
+	case err == nil:
+		var state bufferState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("%s: %w", stateFile, err)
+		}
+		b.id, b.next = state.ID, max(state.NextSequence, 1)
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+	if b.id == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		b.id = "buf_" + hex.EncodeToString(id)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		var batch bufferedBatch
+		name := entry.Name()
+		if !strings.HasSuffix(name, batchSuffix) {
+			continue
+		}
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, batchSuffix), "%d-%d", &batch.sequence, &batch.packets); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		batch.size = info.Size()
+		b.batches = append(b.batches, batch)
+		b.bytes += batch.size
+		b.next = max(b.next, batch.sequence+1)
+	}
+	sort.Slice(b.batches, func(i, j int) bool { return b.batches[i].sequence < b.batches[j].sequence })
+
+	if err := b.saveState(); err != nil {
+		return nil, err
+	}
+	b.updateMetrics()
+	return b, nil
+}
+
+// ID identifies the buffer to the collector, which detects resent batches
+// by their sequence number within a buffer
+func (b *Buffer) ID() string {
+	return b.id
+}
+
+// Append writes packets as the newest batch and returns the number of
+// packets discarded with the oldest batches to stay within the maximum
+// size. The newest batch is always kept.
+func (b *Buffer) Append(packets []models.Packet) (dropped int, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	batch := bufferedBatch{sequence: b.next, packets: len(packets)}
+	data, err := json.Marshal(Batch{Sequence: batch.sequence, Packets: packets})
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFile(b.path(batch), data); err != nil {
+		return 0, err
+	}
+	b.next++
+	if err := b.saveState(); err != nil {
+		return 0, err
+	}
+
+	batch.size = int64(len(data))
+	b.batches = append(b.batches, batch)
+	b.bytes += batch.size
+	for b.bytes > b.maxBytes && len(b.batches) > 1 {
+		oldest := b.batches[0]
+		if err := b.remove(oldest); err != nil {
+			return dropped, err
+		}
+		dropped += oldest.packets
+	}
+	metrics.AgentDroppedPackets.Add(float64(dropped))
+	b.updateMetrics()
+	return dropped, nil
+}
+
+// Oldest returns the oldest batch, or nil when the buffer is empty. A
+// batch that cannot be decoded is discarded and reported with an error
+// wrapping ErrCorruptBatch.
+func (b *Buffer) Oldest() (*Batch, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(b.batches) == 0 {
+		return nil, nil
+	}
+	oldest := b.batches[0]
+	data, err := os.ReadFile(b.path(oldest))
+	if err != nil {
+		return nil, err
+	}
+	var batch Batch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		if removeErr := b.remove(oldest); removeErr != nil {
+			return nil, removeErr
+		}
+		metrics.AgentDroppedPackets.Add(float64(oldest.packets))
+		b.updateMetrics()
+		return nil, fmt.Errorf("%w %d: %v", ErrCorruptBatch, oldest.sequence, err)
+	}
+	batch.Sequence = oldest.sequence
+	return &batch, nil
+}
+
+// Remove deletes an acknowledged batch. Removing a batch that is not
+// buffered, such as one discarded meanwhile, does nothing.
+func (b *Buffer) Remove(sequence uint64) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, batch := range b.batches {
+		if batch.sequence == sequence {
+			if err := b.remove(batch); err != nil {
+				return err
+			}
+			b.updateMetrics()
+			return nil
+		}
+	}
+	return nil
+}
+
+// Len returns the number of buffered batches and their size in bytes
+func (b *Buffer) Len() (batches int, bytes int64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.batches), b.bytes
+}
+
+// remove deletes the file of a batch and forgets it
+func (b *Buffer) remove(batch bufferedBatch) error {
+	if err := os.Remove(b.path(batch)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := range b.batches {
+		if b.batches[i].sequence == batch.sequence {
+			b.batches = append(b.batches[:i], b.batches[i+1:]...)
+			b.bytes -= batch.size
+			break
+		}
+	}
+	return nil
+}
+
+// path returns the file of a batch
+func (b *Buffer) path(batch bufferedBatch) string {
+	return filepath.Join(b.dir, fmt.Sprintf("%020d-%d%s", batch.sequence, batch.packets, batchSuffix))
+}
+
+// saveState persists the buffer ID and the next sequence number
+func (b *Buffer) saveState() error {
+	data, err := json.Marshal(bufferState{ID: b.id, NextSequence: b.next})
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(b.dir, stateFile), data)
+}
+
+// updateMetrics publishes the occupancy of the buffer
+func (b *Buffer) updateMetrics() {
+	metrics.AgentBufferedBatches.Set(float64(len(b.batches)))
+	metrics.AgentBufferedBytes.Set(float64(b.bytes))
+}
+
+// writeFile replaces the file at path atomically, so that a crash never
+// leaves a partial batch or state behind
+func writeFile(path string, data []byte) error {
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// packets returns n packets
+func packets(n int) []models.Packet {
+	result := make([]models.Packet, n)
+	for i := range result {
+		result[i] = *models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 60)
+	}
+	return result
+}
+
+func TestBuffer_AppendRemove(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	assert.NotEmpty(t, buffer.ID())
+
+	batch, err := buffer.Oldest()
+	require.NoError(t, err)
+	assert.Nil(t, batch)
+
+	for _, n := range []int{2, 3} {
+		dropped, err := buffer.Append(packets(n))
+		require.NoError(t, err)
+		assert.Zero(t, dropped)
+	}
+	batches, bytes := buffer.Len()
+	assert.Equal(t, 2, batches)
+	assert.Positive(t, bytes)
+
+	batch, err = buffer.Oldest()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), batch.Sequence)
+	assert.Len(t, batch.Packets, 2)
+
+	require.NoError(t, buffer.Remove(1))
+	batch, err = buffer.Oldest()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), batch.Sequence)
+}
+
+func TestBuffer_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	_, err = buffer.Append(packets(1))
+	require.NoError(t, err)
+	_, err = buffer.Append(packets(1))
+	require.NoError(t, err)
+	require.NoError(t, buffer.Remove(1))
+
+	// Batches, the buffer ID and sequence numbers survive a restart
+	reopened, err := OpenBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, buffer.ID(), reopened.ID())
+	batch, err := reopened.Oldest()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), batch.Sequence)
+
+	require.NoError(t, reopened.Remove(2))
+	_, err = reopened.Append(packets(1))
+	require.NoError(t, err)
+	reopened, err = OpenBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	batch, err = reopened.Oldest()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), batch.Sequence)
+}
+
+func TestBuffer_Overflow(t *testing.T) {
+	buffer, err := OpenBuffer(t.TempDir(), 1)
+	require.NoError(t, err)
+
+	_, err = buffer.Append(packets(2))
+	require.NoError(t, err)
+	dropped, err := buffer.Append(packets(3))
+	require.NoError(t, err)
+
+	// The oldest batch is discarded, the newest kept
+	assert.Equal(t, 2, dropped)
+	batches, _ := buffer.Len()
+	assert.Equal(t, 1, batches)
+	batch, err := buffer.Oldest()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), batch.Sequence)
+}
+
+func TestBuffer_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir, 1<<20)
+	require.NoError(t, err)
+	_, err = buffer.Append(packets(1))
+	require.NoError(t, err)
+	_, err = buffer.Append(packets(1))
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+batchSuffix))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.NoError(t, os.WriteFile(files[0], []byte("{"), 0o600))
+
+	_, err = buffer.Oldest()
+	assert.ErrorIs(t, err, ErrCorruptBatch)
+	batch, err := buffer.Oldest()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), batch.Sequence)
+}
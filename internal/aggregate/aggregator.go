@@ -0,0 +1,390 @@
+// Package aggregate maintains rolling traffic aggregates that are updated as
+// packets are stored, so statistics over recent windows can be answered
+// without scanning storage.
+package aggregate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/analytics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sketch"
+)
+
+// WindowSpec describes a sliding window made of fixed-size buckets
+type WindowSpec struct {
+	Name       string
+	Length     time.Duration
+	Resolution time.Duration
+}
+
+// Config controls the windows and the memory used per bucket
+type Config struct {
+	Windows      []WindowSpec
+	TopK         int
+	CMSWidth     int
+	CMSDepth     int
+	HLLPrecision uint8
+}
+
+// DefaultConfig returns 1m, 5m and 1h sliding windows
+func DefaultConfig() Config {
+	return Config{
+		Windows: []WindowSpec{
+			{Name: "1m", Length: time.Minute, Resolution: 5 * time.Second},
+			{Name: "5m", Length: 5 * time.Minute, Resolution: 30 * time.Second},
+			{Name: "1h", Length: time.Hour, Resolution: 5 * time.Minute},
+		},
+		TopK:         64,
+		CMSWidth:     1024,
+		CMSDepth:     4,
+		HLLPrecision: 12,
+	}
+}
+
+// Aggregator maintains counters, heavy-hitter sketches and distinct host
+// estimates for each configured window. Memory and query cost depend only on
+// the configuration, never on the number of stored packets.
+type Aggregator struct {
+	mutex   sync.Mutex
+	config  Config
+	windows []*window
+	now     func() time.Time
+}
+
+// New creates an aggregator for the given configuration
+func New(config Config) *Aggregator {
+	a := &Aggregator{
+		config: config,
+		now:    time.Now,
+	}
+	for _, spec := range config.Windows {
+		a.windows = append(a.windows, newWindow(spec, config))
+	}
+	return a
+}
+
+// OnStore records a stored packet in every window
+func (a *Aggregator) OnStore(packet *models.Packet) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, w := range a.windows {
+		w.add(packet)
+	}
+}
+
+// Reset drops every aggregate
+func (a *Aggregator) Reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for i, w := range a.windows {
+		a.windows[i] = newWindow(w.spec, a.config)
+	}
+}
+
+// Supports reports whether length matches one of the configured windows
+func (a *Aggregator) Supports(length time.Duration) bool {
+	return a.window(length) != nil
+}
+
+// Stats returns the summary of every configured window
+func (a *Aggregator) Stats() []models.WindowStats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	stats := make([]models.WindowStats, 0, len(a.windows))
+	for _, w := range a.windows {
+		stats = append(stats, w.stats(now, a.config.HLLPrecision))
+	}
+	return stats
+}
+
+// TopN ranks keys of a dimension over the window of the given length.
+// It returns false when no such window is configured.
+func (a *Aggregator) TopN(length time.Duration, dimension analytics.Dimension, metric analytics.Metric, n int) (*models.TopNResponse, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	w := a.window(length)
+	if w == nil {
+		return nil, false
+	}
+
+	now := a.now()
+	from := now.Add(-length)
+	response := &models.TopNResponse{
+		Dimension:   string(dimension),
+		Metric:      string(metric),
+		From:        &from,
+		To:          &now,
+		Approximate: dimension != analytics.DimensionProtocol,
+		Timestamp:   now,
+	}
+
+	live := w.live(now)
+	var entries []models.TopNEntry
+	if dimension == analytics.DimensionProtocol {
+		entries = protocolEntries(live)
+	} else {
+		entries = sketchEntries(live, dimension, metric, n)
+	}
+
+	for _, b := range live {
+		response.TotalPackets += int(b.packets)
+		response.TotalBytes += int(b.bytes)
+	}
+	response.Entries = analytics.Rank(entries, metric, n, response.TotalPackets, response.TotalBytes)
+	return response, true
+}
+
+// window returns the window with the given length, or nil
+func (a *Aggregator) window(length time.Duration) *window {
+	for _, w := range a.windows {
+		if w.spec.Length == length {
+			return w
+		}
+	}
+	return nil
+}
+
+// protocolEntries returns exact per-protocol totals
+func protocolEntries(buckets []*bucket) []models.TopNEntry {
+	totals := make(map[string]*models.TopNEntry)
+	for _, b := range buckets {
+		for protocol, c := range b.protocols {
+			entry, ok := totals[protocol]
+			if !ok {
+				entry = &models.TopNEntry{Key: protocol}
+				totals[protocol] = entry
+			}
+			entry.Packets += int(c.packets)
+			entry.Bytes += int(c.bytes)
+		}
+	}
+
+	entries := make([]models.TopNEntry, 0, len(totals))
+	for _, entry := range totals {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+// sketchEntries merges the heavy hitters of each bucket and completes the
+// secondary metric from the Count-Min estimates
+func sketchEntries(buckets []*bucket, dimension analytics.Dimension, metric analytics.Metric, n int) []models.TopNEntry {
+	merged := make(map[string]uint64)
+	for _, b := range buckets {
+		top := b.topPackets[dimension]
+		if metric == analytics.MetricBytes {
+			top = b.topBytes[dimension]
+		}
+		for _, c := range top.Top(0) {
+			merged[c.Key] += c.Count
+		}
+	}
+
+	candidates := make([]sketch.Counter, 0, len(merged))
+	for key, count := range merged {
+		candidates = append(candidates, sketch.Counter{Key: key, Count: count})
+	}
+	sketch.SortCounters(candidates)
+	if n > 0 && len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	entries := make([]models.TopNEntry, 0, len(candidates))
+	for _, c := range candidates {
+		entry := models.TopNEntry{Key: c.Key}
+		cmsKey := countKey(dimension, c.Key)
+		for _, b := range buckets {
+			if metric == analytics.MetricBytes {
+				entry.Packets += int(b.packetCounts.Estimate(cmsKey))
+			} else {
+				entry.Bytes += int(b.byteCounts.Estimate(cmsKey))
+			}
+		}
+		if metric == analytics.MetricBytes {
+			entry.Bytes = int(c.Count)
+		} else {
+			entry.Packets = int(c.Count)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// countKey namespaces a key by dimension inside the shared Count-Min sketches
+func countKey(dimension analytics.Dimension, key string) string {
+	return string(dimension) + "\x00" + key
+}
+
+// sketchDimensions are the dimensions tracked with heavy-hitter sketches
+var sketchDimensions = []analytics.Dimension{
+	analytics.DimensionSourceIP,
+	analytics.DimensionDestinationIP,
+	analytics.DimensionHostPair,
+	analytics.DimensionPort,
+}
+
+// window is a ring of buckets covering spec.Length
+type window struct {
+	spec    WindowSpec
+	buckets []*bucket
+}
+
+func newWindow(spec WindowSpec, config Config) *window {
+	count := int(spec.Length / spec.Resolution)
+	if count < 1 {
+		count = 1
+	}
+	w := &window{spec: spec, buckets: make([]*bucket, count)}
+	for i := range w.buckets {
+		w.buckets[i] = newBucket(config)
+	}
+	return w
+}
+
+// slot returns the bucket sequence number containing t
+func (w *window) slot(t time.Time) int64 {
+	return t.UnixNano() / int64(w.spec.Resolution)
+}
+
+// add records a packet in the bucket of its timestamp. Packets older than
+// the bucket currently occupying their ring position are ignored.
+func (w *window) add(packet *models.Packet) {
+	slot := w.slot(packet.Timestamp)
+	b := w.buckets[int(slot%int64(len(w.buckets)))]
+	if slot < b.slot {
+		return
+	}
+	if slot > b.slot {
+		b.reset(slot)
+	}
+	b.add(packet)
+}
+
+// live returns the buckets that fall inside the window ending at now
+func (w *window) live(now time.Time) []*bucket {
+	current := w.slot(now)
+	oldest := current - int64(len(w.buckets)) + 1
+
+	live := make([]*bucket, 0, len(w.buckets))
+	for _, b := range w.buckets {
+		if b.packets > 0 && b.slot >= oldest && b.slot <= current {
+			live = append(live, b)
+		}
+	}
+	return live
+}
+
+// stats summarises the live buckets of the window
+func (w *window) stats(now time.Time, precision uint8) models.WindowStats {
+	stats := models.WindowStats{
+		Window:    w.spec.Name,
+		Protocols: make(map[string]uint64),
+	}
+	sources := sketch.NewHyperLogLog(precision)
+	destinations := sketch.NewHyperLogLog(precision)
+
+	for _, b := range w.live(now) {
+		stats.Packets += b.packets
+		stats.Bytes += b.bytes
+		for protocol, c := range b.protocols {
+			stats.Protocols[protocol] += c.packets
+		}
+		_ = sources.Merge(b.sources)
+		_ = destinations.Merge(b.destinations)
+	}
+
+	seconds := w.spec.Length.Seconds()
+	stats.PacketsPerSecond = float64(stats.Packets) / seconds
+	stats.BytesPerSecond = float64(stats.Bytes) / seconds
+	stats.DistinctSources = sources.Count()
+	stats.DistinctDestinations = destinations.Count()
+	return stats
+}
+
+// protocolCount holds exact totals for one protocol
+type protocolCount struct {
+	packets uint64
+	bytes   uint64
+}
+
+// bucket aggregates the packets of one resolution interval
+type bucket struct {
+	slot         int64
+	packets      uint64
+	bytes        uint64
+	protocols    map[string]*protocolCount
+	sources      *sketch.HyperLogLog
+	destinations *sketch.HyperLogLog
+	topPackets   map[analytics.Dimension]*sketch.SpaceSaving
+	topBytes     map[analytics.Dimension]*sketch.SpaceSaving
+	packetCounts *sketch.CountMin
+	byteCounts   *sketch.CountMin
+}
+
+func newBucket(config Config) *bucket {
+	b := &bucket{
+		slot:         -1,
+		protocols:    make(map[string]*protocolCount),
+		sources:      sketch.NewHyperLogLog(config.HLLPrecision),
+		destinations: sketch.NewHyperLogLog(config.HLLPrecision),
+		topPackets:   make(map[analytics.Dimension]*sketch.SpaceSaving),
+		topBytes:     make(map[analytics.Dimension]*sketch.SpaceSaving),
+		packetCounts: sketch.NewCountMin(config.CMSWidth, config.CMSDepth),
+		byteCounts:   sketch.NewCountMin(config.CMSWidth, config.CMSDepth),
+	}
+	for _, d := range sketchDimensions {
+		b.topPackets[d] = sketch.NewSpaceSaving(config.TopK)
+		b.topBytes[d] = sketch.NewSpaceSaving(config.TopK)
+	}
+	return b
+}
+
+// reset empties the bucket and assigns it a new slot, reusing its memory
+func (b *bucket) reset(slot int64) {
+	b.slot = slot
+	b.packets = 0
+	b.bytes = 0
+	b.protocols = make(map[string]*protocolCount)
+	b.sources.Reset()
+	b.destinations.Reset()
+	for _, d := range sketchDimensions {
+		b.topPackets[d].Reset()
+		b.topBytes[d].Reset()
+	}
+	b.packetCounts.Reset()
+	b.byteCounts.Reset()
+}
+
+// add records a packet in the bucket
+func (b *bucket) add(packet *models.Packet) {
+	size := uint64(packet.Size)
+
+	b.packets++
+	b.bytes += size
+
+	c, ok := b.protocols[packet.Protocol]
+	if !ok {
+		c = &protocolCount{}
+		b.protocols[packet.Protocol] = c
+	}
+	c.packets++
+	c.bytes += size
+
+	b.sources.Add(packet.SourceIP)
+	b.destinations.Add(packet.DestinationIP)
+
+	for _, d := range sketchDimensions {
+		key := analytics.Key(packet, d)
+		b.topPackets[d].Add(key, 1)
+		b.topBytes[d].Add(key, size)
+		b.packetCounts.Add(countKey(d, key), 1)
+		b.byteCounts.Add(countKey(d, key), size)
+	}
+}
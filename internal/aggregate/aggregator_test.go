@@ -0,0 +1,124 @@
+package aggregate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/analytics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAggregator(now time.Time) *Aggregator {
+	a := New(DefaultConfig())
+	a.now = func() time.Time { return now }
+	return a
+}
+
+func packetAt(src, dst, protocol string, port, size int, ts time.Time) *models.Packet {
+	p := models.NewPacket(src, dst, protocol, port, size)
+	p.Timestamp = ts
+	return p
+}
+
+func TestAggregator_WindowStats(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := newTestAggregator(now)
+
+	a.OnStore(packetAt("10.0.0.1", "8.8.8.8", "UDP", 53, 100, now.Add(-10*time.Second)))
+	a.OnStore(packetAt("10.0.0.2", "8.8.8.8", "TCP", 443, 300, now.Add(-2*time.Minute)))
+	a.OnStore(packetAt("10.0.0.3", "1.1.1.1", "TCP", 443, 600, now.Add(-30*time.Minute)))
+
+	stats := a.Stats()
+	require.Len(t, stats, 3)
+
+	byName := make(map[string]models.WindowStats)
+	for _, s := range stats {
+		byName[s.Window] = s
+	}
+
+	assert.Equal(t, uint64(1), byName["1m"].Packets)
+	assert.Equal(t, uint64(2), byName["5m"].Packets)
+	assert.Equal(t, uint64(400), byName["5m"].Bytes)
+	assert.Equal(t, uint64(3), byName["1h"].Packets)
+	assert.Equal(t, uint64(3), byName["1h"].DistinctSources)
+	assert.Equal(t, uint64(2), byName["1h"].DistinctDestinations)
+	assert.Equal(t, uint64(2), byName["1h"].Protocols["TCP"])
+	assert.InDelta(t, 1000.0/3600.0, byName["1h"].BytesPerSecond, 1e-9)
+}
+
+func TestAggregator_BucketsExpire(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	a := New(DefaultConfig())
+	a.now = func() time.Time { return now }
+
+	a.OnStore(packetAt("10.0.0.1", "8.8.8.8", "UDP", 53, 100, start))
+
+	now = start.Add(2 * time.Minute)
+	stats := a.Stats()
+	assert.Equal(t, uint64(0), stats[0].Packets, "1m window should have expired")
+	assert.Equal(t, uint64(1), stats[1].Packets, "5m window should still count the packet")
+
+	// A new packet reusing the same ring position replaces the stale bucket
+	a.OnStore(packetAt("10.0.0.2", "8.8.8.8", "UDP", 53, 100, start.Add(time.Hour)))
+	now = start.Add(time.Hour)
+	stats = a.Stats()
+	assert.Equal(t, uint64(1), stats[2].Packets)
+	assert.Equal(t, uint64(1), stats[2].DistinctSources)
+}
+
+func TestAggregator_TopNMatchesExactScan(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := newTestAggregator(now)
+
+	var packets []models.Packet
+	for i := 0; i < 3000; i++ {
+		src := fmt.Sprintf("10.0.%d.%d", i%3, i%200)
+		if i%5 == 0 {
+			src = "192.168.1.100"
+		}
+		p := packetAt(src, "8.8.8.8", "TCP", 443, 64+i%1000, now.Add(-time.Duration(i)*time.Millisecond*50))
+		a.OnStore(p)
+		packets = append(packets, *p)
+	}
+
+	for _, metric := range []analytics.Metric{analytics.MetricPackets, analytics.MetricBytes} {
+		approx, ok := a.TopN(5*time.Minute, analytics.DimensionSourceIP, metric, 1)
+		require.True(t, ok)
+		exact := analytics.TopN(packets, analytics.DimensionSourceIP, metric, 1)
+
+		require.Len(t, approx.Entries, 1)
+		assert.True(t, approx.Approximate)
+		assert.Equal(t, exact.Entries[0].Key, approx.Entries[0].Key)
+		assert.Equal(t, exact.TotalPackets, approx.TotalPackets)
+		assert.Equal(t, exact.TotalBytes, approx.TotalBytes)
+		// Sketch counts may only over-estimate
+		assert.GreaterOrEqual(t, approx.Entries[0].Packets, exact.Entries[0].Packets)
+		assert.GreaterOrEqual(t, approx.Entries[0].Bytes, exact.Entries[0].Bytes)
+	}
+}
+
+func TestAggregator_ProtocolsAreExact(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := newTestAggregator(now)
+
+	a.OnStore(packetAt("10.0.0.1", "8.8.8.8", "UDP", 53, 100, now))
+	a.OnStore(packetAt("10.0.0.1", "8.8.8.8", "TCP", 443, 900, now))
+	a.OnStore(packetAt("10.0.0.1", "8.8.8.8", "TCP", 443, 100, now))
+
+	response, ok := a.TopN(time.Minute, analytics.DimensionProtocol, analytics.MetricBytes, 0)
+	require.True(t, ok)
+	assert.False(t, response.Approximate)
+	require.Len(t, response.Entries, 2)
+	assert.Equal(t, models.TopNEntry{Key: "TCP", Packets: 2, Bytes: 1000, Share: 1000.0 / 1100.0}, response.Entries[0])
+
+	_, ok = a.TopN(10*time.Minute, analytics.DimensionProtocol, analytics.MetricBytes, 0)
+	assert.False(t, ok)
+	assert.True(t, a.Supports(time.Hour))
+
+	a.Reset()
+	assert.Equal(t, uint64(0), a.Stats()[0].Packets)
+}
@@ -0,0 +1,435 @@
+// Package alerting evaluates user-defined rules against stored packets and
+// keeps a history of the alerts they raise.
+package alerting
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+const (
+	// DefaultHistorySize is the number of alerts kept when none is configured
+	DefaultHistorySize = 1000
+
+	// maxAlertPacketIDs caps the packet references kept per alert
+	maxAlertPacketIDs = 20
+
+	// sweepInterval is the number of evaluated packets between state cleanups
+	sweepInterval = 1024
+)
+
+// Engine evaluates alert rules against packets as they are stored.
+// It implements storage.Observer.
+type Engine struct {
+	mutex       sync.RWMutex
+	rules       map[string]*compiledRule
+	states      map[string]map[string]*groupState
+	history     []*models.Alert
+	historySize int
+	evaluated   int
+	listeners   []func(models.Alert)
+	now         func() time.Time
+}
+
+// NewEngine creates an alerting engine keeping at most historySize alerts
+func NewEngine(historySize int) *Engine {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Engine{
+		rules:       make(map[string]*compiledRule),
+		states:      make(map[string]map[string]*groupState),
+		historySize: historySize,
+		now:         time.Now,
+	}
+}
+
+// LoadRules creates the rules defined in a JSON file holding an array of
+// rules. Rules without an explicit "enabled" field are enabled.
+func (e *Engine) LoadRules(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	for i, item := range raw {
+		rule := models.AlertRule{Enabled: true}
+		if err := json.Unmarshal(item, &rule); err != nil {
+			return fmt.Errorf("parse %s: rule %d: %w", path, i, err)
+		}
+		if _, err := e.CreateRule(rule); err != nil {
+			return fmt.Errorf("load %s: rule %d: %w", path, i, err)
+		}
+	}
+	return nil
+}
+
+// CreateRule validates and adds a rule, assigning it a new ID
+func (e *Engine) CreateRule(rule models.AlertRule) (*models.AlertRule, error) {
+	now := e.now()
+	rule.ID = newID("rule")
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.rules[rule.ID] = compiled
+	result := compiled.rule
+	return &result, nil
+}
+
+// UpdateRule replaces an existing rule and resets its evaluation state
+func (e *Engine) UpdateRule(id string, rule models.AlertRule) (*models.AlertRule, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	existing, ok := e.rules[id]
+	if !ok {
+		return nil, ErrRuleNotFound
+	}
+
+	rule.ID = id
+	rule.CreatedAt = existing.rule.CreatedAt
+	rule.UpdatedAt = e.now()
+
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	e.rules[id] = compiled
+	delete(e.states, id)
+	result := compiled.rule
+	return &result, nil
+}
+
+// DeleteRule removes a rule. Alerts it raised stay in the history.
+func (e *Engine) DeleteRule(id string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, ok := e.rules[id]; !ok {
+		return ErrRuleNotFound
+	}
+	delete(e.rules, id)
+	delete(e.states, id)
+	return nil
+}
+
+// GetRule returns a rule by ID, or nil if it does not exist
+func (e *Engine) GetRule(id string) *models.AlertRule {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if compiled, ok := e.rules[id]; ok {
+		rule := compiled.rule
+		return &rule
+	}
+	return nil
+}
+
+// Rules returns every rule ordered by creation time
+func (e *Engine) Rules() []models.AlertRule {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	rules := make([]models.AlertRule, 0, len(e.rules))
+	for _, compiled := range e.rules {
+		rules = append(rules, compiled.rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// Alerts returns the alert history matching the filter, newest first
+func (e *Engine) Alerts(filter *models.AlertFilter) []models.Alert {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	alerts := make([]models.Alert, 0)
+	for i := len(e.history) - 1; i >= 0; i-- {
+		alert := e.history[i]
+		if filter != nil {
+			if filter.RuleID != "" && alert.RuleID != filter.RuleID {
+				continue
+			}
+			if filter.Severity != "" && alert.Severity != filter.Severity {
+				continue
+			}
+			if !filter.Since.IsZero() && alert.LastSeen.Before(filter.Since) {
+				continue
+			}
+		}
+
+		alerts = append(alerts, copyAlert(alert))
+
+		if filter != nil && filter.Limit > 0 && len(alerts) >= filter.Limit {
+			break
+		}
+	}
+	return alerts
+}
+
+// OnAlert registers a function called with every newly raised alert.
+// Alerts folded into an earlier one during its cooldown are not reported.
+// Listeners are called outside the engine lock and must not block.
+func (e *Engine) OnAlert(fn func(models.Alert)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.listeners = append(e.listeners, fn)
+}
+
+// OnStore evaluates every enabled rule against a stored packet
+func (e *Engine) OnStore(packet *models.Packet) {
+	var raised []models.Alert
+
+	e.mutex.Lock()
+	for _, rule := range e.rules {
+		if rule.rule.Enabled && rule.matches(packet) {
+			if alert := e.evaluate(rule, packet); alert != nil {
+				raised = append(raised, copyAlert(alert))
+			}
+		}
+	}
+
+	e.evaluated++
+	if e.evaluated%sweepInterval == 0 {
+		e.sweep(packet.Timestamp)
+	}
+	listeners := e.listeners
+	e.mutex.Unlock()
+
+	for _, alert := range raised {
+		for _, fn := range listeners {
+			fn(alert)
+		}
+	}
+}
+
+// evaluate updates the window of the packet's group and raises or folds
+// an alert when the threshold is exceeded. It returns the alert when a new
+// one is raised.
+func (e *Engine) evaluate(rule *compiledRule, packet *models.Packet) *models.Alert {
+	key, group := rule.groupKey(packet)
+
+	states, ok := e.states[rule.rule.ID]
+	if !ok {
+		states = make(map[string]*groupState)
+		e.states[rule.rule.ID] = states
+	}
+	state, ok := states[key]
+	if !ok {
+		state = newGroupState()
+		states[key] = state
+	}
+
+	window := time.Duration(rule.rule.Window)
+	state.add(rule.rule.Aggregation, packet)
+	state.expire(packet.Timestamp, window)
+
+	value := state.value(rule.rule.Aggregation.Function)
+	if value <= rule.rule.Threshold {
+		return nil
+	}
+
+	if state.alert != nil && packet.Timestamp.Before(state.cooldownUntil) {
+		// Deduplicate into the alert raised earlier in the cooldown
+		state.alert.Occurrences++
+		state.alert.LastSeen = packet.Timestamp
+		if value > state.alert.Value {
+			state.alert.Value = value
+		}
+		if len(state.alert.PacketIDs) < maxAlertPacketIDs {
+			state.alert.PacketIDs = append(state.alert.PacketIDs, packet.ID)
+		}
+		return nil
+	}
+
+	alert := &models.Alert{
+		ID:          newID("alert"),
+		RuleID:      rule.rule.ID,
+		RuleName:    rule.rule.Name,
+		Severity:    rule.rule.Severity,
+		Group:       group,
+		Value:       value,
+		Threshold:   rule.rule.Threshold,
+		Message:     describe(rule.rule, key, value),
+		PacketIDs:   state.packetIDs(maxAlertPacketIDs),
+		Occurrences: 1,
+		FirstSeen:   state.firstSeen(packet.Timestamp),
+		LastSeen:    packet.Timestamp,
+	}
+	state.alert = alert
+	state.cooldownUntil = packet.Timestamp.Add(time.Duration(rule.rule.Cooldown))
+
+	e.history = append(e.history, alert)
+	if len(e.history) > e.historySize {
+		e.history = append(e.history[:0], e.history[len(e.history)-e.historySize:]...)
+	}
+	return alert
+}
+
+// sweep drops group states that hold no events and are out of cooldown
+func (e *Engine) sweep(now time.Time) {
+	for ruleID, states := range e.states {
+		rule, ok := e.rules[ruleID]
+		if !ok {
+			delete(e.states, ruleID)
+			continue
+		}
+		for key, state := range states {
+			state.expire(now, time.Duration(rule.rule.Window))
+			if len(state.events) == 0 && !now.Before(state.cooldownUntil) {
+				delete(states, key)
+			}
+		}
+	}
+}
+
+// copyAlert returns a copy of an alert that does not share its slices
+func copyAlert(alert *models.Alert) models.Alert {
+	a := *alert
+	a.PacketIDs = append([]string(nil), alert.PacketIDs...)
+	return a
+}
+
+// describe builds the human readable message of an alert
+func describe(rule models.AlertRule, groupKey string, value float64) string {
+	expr := rule.Aggregation.Function
+	if rule.Aggregation.Field != "" {
+		expr += "(" + rule.Aggregation.Field + ")"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s=%g exceeds %g", rule.Name, expr, value, rule.Threshold)
+	if rule.Window > 0 {
+		fmt.Fprintf(&b, " within %s", time.Duration(rule.Window))
+	}
+	if groupKey != "" {
+		fmt.Fprintf(&b, " for %s", groupKey)
+	}
+	return b.String()
+}
+
+// newID returns a random identifier with the given prefix
+func newID(prefix string) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return prefix + "_" + hex.EncodeToString(b)
+}
+
+// event is a matching packet kept inside a rule window
+type event struct {
+	at       time.Time
+	packetID string
+	distinct bool
+	value    string
+	amount   float64
+}
+
+// groupState holds the window of one rule group
+type groupState struct {
+	events        []event
+	distinct      map[string]int
+	sum           float64
+	alert         *models.Alert
+	cooldownUntil time.Time
+}
+
+func newGroupState() *groupState {
+	return &groupState{distinct: make(map[string]int)}
+}
+
+// add appends a packet to the window
+func (s *groupState) add(aggregation models.AlertAggregation, packet *models.Packet) {
+	ev := event{at: packet.Timestamp, packetID: packet.ID}
+	switch aggregation.Function {
+	case FunctionDistinct:
+		ev.distinct = true
+		ev.value = fieldValue(packet, aggregation.Field)
+		s.distinct[ev.value]++
+	case FunctionSum:
+		ev.amount = float64(numericFields[aggregation.Field](packet))
+		s.sum += ev.amount
+	}
+	s.events = append(s.events, ev)
+}
+
+// expire drops events older than window before now. A zero window only
+// keeps the latest event, so each packet is evaluated on its own.
+func (s *groupState) expire(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	n := 0
+	for n < len(s.events) && (s.events[n].at.Before(cutoff) || window == 0 && n < len(s.events)-1) {
+		s.drop(s.events[n])
+		n++
+	}
+	s.events = s.events[n:]
+}
+
+// drop removes the contribution of an event from the aggregates
+func (s *groupState) drop(ev event) {
+	if ev.distinct {
+		if s.distinct[ev.value]--; s.distinct[ev.value] <= 0 {
+			delete(s.distinct, ev.value)
+		}
+	}
+	s.sum -= ev.amount
+}
+
+// value computes the aggregation over the current window
+func (s *groupState) value(function string) float64 {
+	switch function {
+	case FunctionDistinct:
+		return float64(len(s.distinct))
+	case FunctionSum:
+		return s.sum
+	}
+	return float64(len(s.events))
+}
+
+// packetIDs returns up to n of the most recent packet IDs in the window
+func (s *groupState) packetIDs(n int) []string {
+	events := s.events
+	if len(events) > n {
+		events = events[len(events)-n:]
+	}
+	ids := make([]string, len(events))
+	for i, ev := range events {
+		ids[i] = ev.packetID
+	}
+	return ids
+}
+
+// firstSeen returns the timestamp of the oldest event in the window
+func (s *groupState) firstSeen(fallback time.Time) time.Time {
+	if len(s.events) > 0 {
+		return s.events[0].at
+	}
+	return fallback
+}
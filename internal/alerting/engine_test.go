@@ -0,0 +1,195 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseRule(t *testing.T, data string) models.AlertRule {
+	t.Helper()
+	rule := models.AlertRule{Enabled: true}
+	require.NoError(t, json.Unmarshal([]byte(data), &rule))
+	return rule
+}
+
+func packetAt(src, dst string, port int, ts time.Time) *models.Packet {
+	p := models.NewPacket(src, dst, "TCP", port, 100)
+	p.Timestamp = ts
+	return p
+}
+
+func TestEngine_DistinctPortsPerSource(t *testing.T) {
+	engine := NewEngine(10)
+	_, err := engine.CreateRule(parseRule(t, `{
+		"name": "Port scan",
+		"severity": "high",
+		"group_by": ["source_ip"],
+		"aggregation": {"function": "distinct", "field": "port"},
+		"threshold": 100,
+		"window": "60s"
+	}`))
+	require.NoError(t, err)
+
+	start := time.Now()
+	// 100 distinct ports from the scanner do not exceed the threshold
+	for port := 1; port <= 100; port++ {
+		engine.OnStore(packetAt("10.0.0.66", "10.0.0.1", port, start.Add(time.Duration(port)*100*time.Millisecond)))
+	}
+	// Another source hitting the same ports is tracked separately
+	engine.OnStore(packetAt("10.0.0.2", "10.0.0.1", 80, start.Add(11*time.Second)))
+	assert.Empty(t, engine.Alerts(nil))
+
+	// The 101st distinct port raises the alert
+	engine.OnStore(packetAt("10.0.0.66", "10.0.0.1", 101, start.Add(12*time.Second)))
+	alerts := engine.Alerts(nil)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "high", alerts[0].Severity)
+	assert.Equal(t, map[string]string{"source_ip": "10.0.0.66"}, alerts[0].Group)
+	assert.Equal(t, 101.0, alerts[0].Value)
+	assert.Len(t, alerts[0].PacketIDs, maxAlertPacketIDs)
+	assert.Contains(t, alerts[0].Message, "distinct(port)=101 exceeds 100 within 1m0s for source_ip=10.0.0.66")
+}
+
+func TestEngine_WindowExpiry(t *testing.T) {
+	engine := NewEngine(10)
+	_, err := engine.CreateRule(parseRule(t, `{
+		"name": "Chatty host",
+		"group_by": ["source_ip"],
+		"threshold": 2,
+		"window": "10s"
+	}`))
+	require.NoError(t, err)
+
+	start := time.Now()
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 53, start))
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 53, start.Add(5*time.Second)))
+	// The first packet has left the window by now
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 53, start.Add(12*time.Second)))
+	assert.Empty(t, engine.Alerts(nil))
+
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 53, start.Add(13*time.Second)))
+	assert.Len(t, engine.Alerts(nil), 1)
+}
+
+func TestEngine_PerPacketRuleWithCooldown(t *testing.T) {
+	engine := NewEngine(10)
+	rule, err := engine.CreateRule(parseRule(t, `{
+		"name": "Non-DNS traffic to Google DNS",
+		"conditions": [
+			{"field": "destination_ip", "op": "eq", "value": "8.8.8.8"},
+			{"field": "port", "op": "neq", "value": 53}
+		],
+		"threshold": 0,
+		"cooldown": "1m"
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, FunctionCount, rule.Aggregation.Function)
+
+	start := time.Now()
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 53, start))
+	engine.OnStore(packetAt("10.0.0.1", "1.1.1.1", 443, start))
+	assert.Empty(t, engine.Alerts(nil))
+
+	// Repeated triggers inside the cooldown are deduplicated
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 443, start.Add(time.Second)))
+	engine.OnStore(packetAt("10.0.0.2", "8.8.8.8", 80, start.Add(2*time.Second)))
+	alerts := engine.Alerts(nil)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 2, alerts[0].Occurrences)
+	assert.Len(t, alerts[0].PacketIDs, 2)
+
+	// After the cooldown a new alert is raised
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 443, start.Add(2*time.Minute)))
+	alerts = engine.Alerts(&models.AlertFilter{RuleID: rule.ID})
+	require.Len(t, alerts, 2)
+	assert.Equal(t, 1, alerts[0].Occurrences, "newest alert is listed first")
+}
+
+func TestEngine_RuleCRUD(t *testing.T) {
+	engine := NewEngine(10)
+
+	_, err := engine.CreateRule(models.AlertRule{})
+	assert.True(t, errors.Is(err, ErrInvalidRule))
+
+	_, err = engine.CreateRule(parseRule(t, `{"name": "x", "aggregation": {"function": "sum", "field": "protocol"}}`))
+	assert.True(t, errors.Is(err, ErrInvalidRule))
+
+	_, err = engine.CreateRule(parseRule(t, `{"name": "x", "conditions": [{"field": "port", "op": "cidr", "value": "10.0.0.0/8"}]}`))
+	assert.True(t, errors.Is(err, ErrInvalidRule))
+
+	created, err := engine.CreateRule(parseRule(t, `{"name": "Large packets", "conditions": [{"field": "size", "op": "gt", "value": 1400}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, models.Duration(DefaultCooldown), created.Cooldown)
+	assert.Equal(t, "medium", created.Severity)
+
+	update := *created
+	update.Name = "Jumbo packets"
+	updated, err := engine.UpdateRule(created.ID, update)
+	require.NoError(t, err)
+	assert.Equal(t, "Jumbo packets", engine.GetRule(created.ID).Name)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+	assert.Len(t, engine.Rules(), 1)
+
+	require.NoError(t, engine.DeleteRule(created.ID))
+	assert.Nil(t, engine.GetRule(created.ID))
+	assert.Equal(t, ErrRuleNotFound, engine.DeleteRule(created.ID))
+	_, err = engine.UpdateRule(created.ID, update)
+	assert.Equal(t, ErrRuleNotFound, err)
+}
+
+func TestEngine_LoadRulesAndHistoryLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"name": "Internal sources", "conditions": [{"field": "source_ip", "op": "cidr", "value": "10.0.0.0/8"}], "cooldown": "1ns"},
+		{"name": "Disabled", "enabled": false}
+	]`), 0o644))
+
+	engine := NewEngine(3)
+	require.NoError(t, engine.LoadRules(path))
+	rules := engine.Rules()
+	require.Len(t, rules, 2)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		engine.OnStore(packetAt("10.1.2.3", "8.8.8.8", 53, start.Add(time.Duration(i)*time.Second)))
+	}
+	engine.OnStore(packetAt("192.168.1.1", "8.8.8.8", 53, start.Add(10*time.Second)))
+
+	alerts := engine.Alerts(nil)
+	assert.Len(t, alerts, 3, "history is capped")
+	assert.Len(t, engine.Alerts(&models.AlertFilter{Limit: 1}), 1)
+	assert.Empty(t, engine.Alerts(&models.AlertFilter{Severity: "critical"}))
+}
+
+func TestEngine_OnAlert(t *testing.T) {
+	engine := NewEngine(10)
+	_, err := engine.CreateRule(parseRule(t, `{
+		"name": "DNS bypass",
+		"conditions": [{"field": "destination_ip", "op": "eq", "value": "8.8.8.8"}],
+		"threshold": 0,
+		"window": "60s",
+		"cooldown": "60s"
+	}`))
+	require.NoError(t, err)
+
+	var raised []models.Alert
+	engine.OnAlert(func(alert models.Alert) { raised = append(raised, alert) })
+
+	start := time.Now()
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 443, start))
+	// Folded into the first alert during the cooldown
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 443, start.Add(time.Second)))
+	require.Len(t, raised, 1)
+	assert.Equal(t, "DNS bypass", raised[0].RuleName)
+
+	engine.OnStore(packetAt("10.0.0.1", "8.8.8.8", 443, start.Add(2*time.Minute)))
+	assert.Len(t, raised, 2)
+}
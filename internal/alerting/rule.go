@@ -0,0 +1,274 @@
+package alerting
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// Aggregation functions supported by rules
+const (
+	FunctionCount    = "count"
+	FunctionDistinct = "distinct"
+	FunctionSum      = "sum"
+)
+
+// DefaultCooldown is applied to rules that do not set a cooldown
+const DefaultCooldown = 5 * time.Minute
+
+var (
+	// ErrInvalidRule is wrapped by every rule validation error
+	ErrInvalidRule = errors.New("invalid alert rule")
+
+	// ErrRuleNotFound is returned when a rule ID does not exist
+	ErrRuleNotFound = errors.New("alert rule not found")
+)
+
+// Severities lists the accepted rule severities, lowest first
+var Severities = []string{"info", "low", "medium", "high", "critical"}
+
+// numericFields are packet fields compared as numbers
+var numericFields = map[string]func(*models.Packet) int{
+	"port": func(p *models.Packet) int { return p.Port },
+	"size": func(p *models.Packet) int { return p.Size },
+	"ttl":  func(p *models.Packet) int { return p.TTL },
+}
+
+// stringFields are packet fields compared as strings
+var stringFields = map[string]func(*models.Packet) string{
+	"source_ip":      func(p *models.Packet) string { return p.SourceIP },
+	"destination_ip": func(p *models.Packet) string { return p.DestinationIP },
+	"protocol":       func(p *models.Packet) string { return p.Protocol },
+	"flags":          func(p *models.Packet) string { return p.Flags },
+	"payload":        func(p *models.Packet) string { return p.Payload },
+}
+
+// fieldValue returns the string form of a packet field
+func fieldValue(packet *models.Packet, field string) string {
+	if get, ok := stringFields[field]; ok {
+		return get(packet)
+	}
+	if get, ok := numericFields[field]; ok {
+		return strconv.Itoa(get(packet))
+	}
+	return ""
+}
+
+// isField reports whether field names a packet field
+func isField(field string) bool {
+	_, str := stringFields[field]
+	_, num := numericFields[field]
+	return str || num
+}
+
+// predicate tests a single packet
+type predicate func(*models.Packet) bool
+
+// compiledRule is a validated rule ready for evaluation
+type compiledRule struct {
+	rule       models.AlertRule
+	predicates []predicate
+}
+
+// matches reports whether a packet satisfies every condition of the rule
+func (r *compiledRule) matches(packet *models.Packet) bool {
+	for _, p := range r.predicates {
+		if !p(packet) {
+			return false
+		}
+	}
+	return true
+}
+
+// groupKey returns the group values of a packet and their canonical key
+func (r *compiledRule) groupKey(packet *models.Packet) (string, map[string]string) {
+	if len(r.rule.GroupBy) == 0 {
+		return "", nil
+	}
+
+	group := make(map[string]string, len(r.rule.GroupBy))
+	parts := make([]string, len(r.rule.GroupBy))
+	for i, field := range r.rule.GroupBy {
+		value := fieldValue(packet, field)
+		group[field] = value
+		parts[i] = field + "=" + value
+	}
+	return strings.Join(parts, ","), group
+}
+
+// compileRule validates a rule, fills in defaults and compiles its conditions
+func compileRule(rule models.AlertRule) (*compiledRule, error) {
+	if strings.TrimSpace(rule.Name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+
+	if rule.Severity == "" {
+		rule.Severity = "medium"
+	}
+	if !contains(Severities, rule.Severity) {
+		return nil, fmt.Errorf("%w: severity must be one of %s", ErrInvalidRule, strings.Join(Severities, ", "))
+	}
+
+	if rule.Window < 0 || rule.Cooldown < 0 {
+		return nil, fmt.Errorf("%w: window and cooldown must not be negative", ErrInvalidRule)
+	}
+	if rule.Cooldown == 0 {
+		rule.Cooldown = models.Duration(DefaultCooldown)
+	}
+	if rule.Threshold < 0 {
+		return nil, fmt.Errorf("%w: threshold must not be negative", ErrInvalidRule)
+	}
+
+	switch rule.Aggregation.Function {
+	case "":
+		rule.Aggregation.Function = FunctionCount
+	case FunctionCount:
+	case FunctionDistinct:
+		if !isField(rule.Aggregation.Field) {
+			return nil, fmt.Errorf("%w: distinct requires a packet field, got %q", ErrInvalidRule, rule.Aggregation.Field)
+		}
+	case FunctionSum:
+		if _, ok := numericFields[rule.Aggregation.Field]; !ok {
+			return nil, fmt.Errorf("%w: sum requires a numeric field, got %q", ErrInvalidRule, rule.Aggregation.Field)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported aggregation %q", ErrInvalidRule, rule.Aggregation.Function)
+	}
+	if rule.Aggregation.Function == FunctionCount {
+		rule.Aggregation.Field = ""
+	}
+
+	for _, field := range rule.GroupBy {
+		if !isField(field) {
+			return nil, fmt.Errorf("%w: unknown group_by field %q", ErrInvalidRule, field)
+		}
+	}
+
+	compiled := &compiledRule{rule: rule}
+	for i, condition := range rule.Conditions {
+		p, err := compileCondition(condition)
+		if err != nil {
+			return nil, fmt.Errorf("%w: condition %d: %v", ErrInvalidRule, i, err)
+		}
+		compiled.predicates = append(compiled.predicates, p)
+	}
+
+	return compiled, nil
+}
+
+// compileCondition turns a condition into a packet predicate
+func compileCondition(condition models.AlertCondition) (predicate, error) {
+	field := condition.Field
+	if !isField(field) {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+
+	switch condition.Operator {
+	case "eq", "neq":
+		want, err := scalar(condition.Value)
+		if err != nil {
+			return nil, err
+		}
+		negate := condition.Operator == "neq"
+		return func(p *models.Packet) bool {
+			return (fieldValue(p, field) == want) != negate
+		}, nil
+
+	case "in", "not_in":
+		list, ok := condition.Value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a list value", condition.Operator)
+		}
+		set := make(map[string]bool, len(list))
+		for _, item := range list {
+			v, err := scalar(item)
+			if err != nil {
+				return nil, err
+			}
+			set[v] = true
+		}
+		negate := condition.Operator == "not_in"
+		return func(p *models.Packet) bool {
+			return set[fieldValue(p, field)] != negate
+		}, nil
+
+	case "gt", "gte", "lt", "lte":
+		get, ok := numericFields[field]
+		if !ok {
+			return nil, fmt.Errorf("%s requires a numeric field", condition.Operator)
+		}
+		limit, ok := condition.Value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a numeric value", condition.Operator)
+		}
+		op := condition.Operator
+		return func(p *models.Packet) bool {
+			v := float64(get(p))
+			switch op {
+			case "gt":
+				return v > limit
+			case "gte":
+				return v >= limit
+			case "lt":
+				return v < limit
+			}
+			return v <= limit
+		}, nil
+
+	case "cidr", "not_cidr":
+		if field != "source_ip" && field != "destination_ip" {
+			return nil, fmt.Errorf("%s requires an IP field", condition.Operator)
+		}
+		value, _ := condition.Value.(string)
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", value)
+		}
+		negate := condition.Operator == "not_cidr"
+		return func(p *models.Packet) bool {
+			ip := net.ParseIP(fieldValue(p, field))
+			return (ip != nil && network.Contains(ip)) != negate
+		}, nil
+
+	case "contains":
+		value, ok := condition.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("contains requires a string value")
+		}
+		return func(p *models.Packet) bool {
+			return strings.Contains(fieldValue(p, field), value)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %q", condition.Operator)
+}
+
+// scalar converts a JSON scalar into the string form used for comparisons
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+// Package analysis runs the storage, analytics and detection layers of the
+// service in-process over capture files and packet dumps, for batch jobs
+// that do not need the HTTP server.
+package analysis
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/analytics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/detection"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/services"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+)
+
+// Config controls an analysis
+type Config struct {
+	// MaxPackets is the capacity of the storage. Packets beyond it evict
+	// the oldest ones, which are then missing from the report.
+	MaxPackets int
+
+	// Top is the number of top talkers and flows reported, 0 for all
+	Top int
+
+	Scan                detection.ScanConfig
+	Anomaly             detection.AnomalyConfig
+	FindingsHistorySize int
+}
+
+// DefaultConfig returns the default detection thresholds, a storage of a
+// million packets and the top 10 talkers and flows
+func DefaultConfig() Config {
+	return Config{
+		MaxPackets: 1000000,
+		Top:        10,
+		Scan:       detection.DefaultScanConfig(),
+		Anomaly:    detection.DefaultAnomalyConfig(),
+	}
+}
+
+// Analyzer loads packets into storage and reports on them. The detectors
+// observe the packets as they are loaded, so files should be loaded in
+// chronological order.
+type Analyzer struct {
+	config   Config
+	storage  *storage.InMemoryStorage
+	service  *services.PacketService
+	findings *detection.FindingStore
+	sources  []models.AnalysisSource
+	loaded   int
+}
+
+// New creates an analyzer with empty storage. A nil logger selects the
+// default logger.
+func New(config Config, logger *slog.Logger) *Analyzer {
+	if config.MaxPackets <= 0 {
+		config.MaxPackets = DefaultConfig().MaxPackets
+	}
+	store := storage.NewInMemoryStorage(config.MaxPackets)
+	findings := detection.NewFindingStore(config.FindingsHistorySize)
+	store.AddObserver(detection.NewScanDetector(config.Scan, findings))
+	store.AddObserver(detection.NewAnomalyDetector(config.Anomaly, findings))
+
+	return &Analyzer{
+		config:  config,
+		storage: store,
+		// Nothing is captured, so the service has no sniffer
+		service:  services.NewPacketService(store, nil, logger),
+		findings: findings,
+	}
+}
+
+// Report summarises the packets loaded so far
+func (a *Analyzer) Report(ctx context.Context) (*models.AnalysisReport, error) {
+	stored, err := a.service.GetPackets(ctx, &models.PacketFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.AnalysisReport{
+		Sources:   a.sources,
+		Packets:   len(stored.Packets),
+		Dropped:   a.loaded - len(stored.Packets),
+		Findings:  a.findings.List(&models.FindingFilter{}),
+		Timestamp: time.Now(),
+	}
+	if n := len(stored.Packets); n > 0 {
+		report.From = &stored.Packets[0].Timestamp
+		report.To = &stored.Packets[n-1].Timestamp
+	}
+
+	protocols, err := a.service.TopN(ctx, &analytics.Query{Dimension: analytics.DimensionProtocol, Metric: analytics.MetricPackets})
+	if err != nil {
+		return nil, err
+	}
+	report.Protocols = protocols.Entries
+	report.Bytes = protocols.TotalBytes
+
+	talkers, err := a.service.TopN(ctx, &analytics.Query{Dimension: analytics.DimensionSourceIP, Metric: analytics.MetricBytes, N: a.config.Top})
+	if err != nil {
+		return nil, err
+	}
+	report.TopTalkers = talkers.Entries
+
+	crypto := newInventory()
+	for i := range stored.Packets {
+		crypto.add(&stored.Packets[i])
+	}
+	report.Crypto = crypto.list()
+	report.Flows = flows(stored.Packets, a.config.Top)
+	return report, nil
+}
+
+// flows groups packets by protocol, source, destination and port and
+// returns the n largest flows by bytes, or all of them when n is 0
+func flows(packets []models.Packet, n int) []models.Flow {
+	type flowState struct {
+		models.Flow
+		flags map[string]bool
+	}
+
+	index := make(map[string]*flowState)
+	var list []*flowState
+	for i := range packets {
+		packet := &packets[i]
+		key := strings.Join([]string{packet.Protocol, packet.SourceIP, packet.DestinationIP, strconv.Itoa(packet.Port)}, "|")
+		flow, ok := index[key]
+		if !ok {
+			flow = &flowState{
+				Flow: models.Flow{
+					Protocol:      packet.Protocol,
+					SourceIP:      packet.SourceIP,
+					DestinationIP: packet.DestinationIP,
+					Port:          packet.Port,
+					FirstSeen:     packet.Timestamp,
+				},
+				flags: make(map[string]bool),
+			}
+			index[key] = flow
+			list = append(list, flow)
+		}
+
+		// Packets are ordered by timestamp
+		flow.Packets++
+		flow.Bytes += packet.Size
+		flow.LastSeen = packet.Timestamp
+		for _, flag := range strings.FieldsFunc(packet.Flags, func(r rune) bool { return r == ',' || r == '|' || r == ' ' }) {
+			flow.flags[flag] = true
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Bytes != list[j].Bytes {
+			return list[i].Bytes > list[j].Bytes
+		}
+		return list[i].Packets > list[j].Packets
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+
+	result := make([]models.Flow, len(list))
+	for i, flow := range list {
+		result[i] = flow.Flow
+		result[i].Flags = sortedKeys(flow.flags)
+	}
+	return result
+}
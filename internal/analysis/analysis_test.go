@@ -0,0 +1,218 @@
+package analysis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/detection"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// packet returns a packet sent at base plus offset
+func packet(id, source, destination string, port int, offset time.Duration) models.Packet {
+	return models.Packet{
+		ID:            id,
+		SourceIP:      source,
+		DestinationIP: destination,
+		Protocol:      "TCP",
+		Port:          port,
+		Size:          100,
+		Flags:         "SYN",
+		Timestamp:     base.Add(offset),
+	}
+}
+
+// tlsHello returns a TLS record holding a ClientHello or a ServerHello
+func tlsHello(server bool, version, suite uint16, extensions []byte) string {
+	body := binary.BigEndian.AppendUint16(nil, 0x0303)
+	body = append(body, make([]byte, 32)...) // random
+	body = append(body, 0)                   // session ID
+	if server {
+		body = binary.BigEndian.AppendUint16(body, suite)
+		body = append(body, 0)
+	} else {
+		body = append(body, 0, 2)
+		body = binary.BigEndian.AppendUint16(body, suite)
+		body = append(body, 1, 0)
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(extensions)))
+	body = append(body, extensions...)
+
+	messageType := byte(1)
+	if server {
+		messageType = 2
+	}
+	message := append([]byte{messageType, 0}, binary.BigEndian.AppendUint16(nil, uint16(len(body)))...)
+	message = append(message, body...)
+	record := []byte{0x16, 0x03, byte(version)}
+	record = binary.BigEndian.AppendUint16(record, uint16(len(message)))
+	return string(append(record, message...))
+}
+
+// extension encodes a TLS extension
+func extension(code uint16, body []byte) []byte {
+	data := binary.BigEndian.AppendUint16(nil, code)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(body)))
+	return append(data, body...)
+}
+
+func TestParseHandshake(t *testing.T) {
+	name := "example.com"
+	sni := []byte{0, byte(3 + len(name)), 0, 0, byte(len(name))}
+	sni = append(sni, name...)
+	versions := []byte{4, 0x03, 0x04, 0x03, 0x03}
+	client := parseHandshake(tlsHello(false, 0x01, 0x1301, append(extension(0, sni), extension(43, versions)...)))
+	require.NotNil(t, client)
+	assert.Equal(t, handshake{protocol: "TLS", version: "TLS 1.3", name: "example.com"}, *client)
+
+	server := parseHandshake(tlsHello(true, 0x03, 0x1302, extension(43, []byte{0x03, 0x04})))
+	require.NotNil(t, server)
+	assert.Equal(t, handshake{protocol: "TLS", server: true, version: "TLS 1.3", cipher: "TLS_AES_256_GCM_SHA384"}, *server)
+
+	legacy := parseHandshake(tlsHello(true, 0x01, 0x0005, nil))
+	require.NotNil(t, legacy)
+	assert.Equal(t, "TLS 1.2", legacy.version)
+	assert.Equal(t, "TLS_RSA_WITH_RC4_128_SHA", legacy.cipher)
+
+	ssh := parseHandshake("SSH-2.0-OpenSSH_9.6\r\n")
+	require.NotNil(t, ssh)
+	assert.Equal(t, "SSH 2.0", ssh.version)
+
+	assert.Nil(t, parseHandshake("GET / HTTP/1.1"))
+	assert.Nil(t, parseHandshake("\x16\x03\x01"))
+}
+
+func TestInventory(t *testing.T) {
+	inv := newInventory()
+	add := func(p models.Packet, payload string) {
+		p.Payload = payload
+		inv.add(&p)
+	}
+
+	// A TLS server seen through its ServerHello and two clients
+	add(packet("p1", "10.0.0.1", "10.0.0.9", 443, 0), "")
+	add(packet("p2", "10.0.0.9", "10.0.0.1", 443, time.Second), tlsHello(true, 0x03, 0xc02f, nil))
+	add(packet("p3", "10.0.0.2", "10.0.0.9", 443, 2*time.Second), "")
+	// A cleartext web server and an unknown service
+	add(packet("p4", "10.0.0.1", "10.0.0.8", 80, 3*time.Second), "GET / HTTP/1.1")
+	add(packet("p5", "10.0.0.1", "10.0.0.7", 7000, 4*time.Second), "")
+
+	endpoints := inv.list()
+	require.Len(t, endpoints, 2, "the client side of the TLS connection is not a server")
+
+	assert.Equal(t, "10.0.0.8", endpoints[0].Address)
+	assert.Equal(t, "HTTP", endpoints[0].Protocol)
+	assert.False(t, endpoints[0].Encrypted)
+	assert.Equal(t, []string{"cleartext protocol"}, endpoints[0].Weaknesses)
+
+	tls := endpoints[1]
+	assert.Equal(t, "10.0.0.9", tls.Address)
+	assert.Equal(t, EvidenceHandshake, tls.Evidence)
+	assert.Equal(t, []string{"TLS 1.2"}, tls.Versions)
+	assert.Equal(t, []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}, tls.CipherSuites)
+	assert.Equal(t, 2, tls.Clients)
+	assert.Equal(t, 3, tls.Packets)
+	assert.Empty(t, tls.Weaknesses)
+}
+
+func TestAnalyzer_LoadDumps(t *testing.T) {
+	analyzer := New(DefaultConfig(), nil)
+	ctx := context.Background()
+
+	// A response of the packets API
+	response, err := json.Marshal(models.PacketResponse{Packets: []models.Packet{
+		packet("p1", "10.0.0.1", "10.0.0.2", 443, 0),
+		packet("p2", "10.0.0.1", "10.0.0.2", 443, time.Second),
+	}})
+	require.NoError(t, err)
+	source, err := analyzer.Load(ctx, "response.json", bytes.NewReader(response))
+	require.NoError(t, err)
+	assert.Equal(t, &models.AnalysisSource{Name: "response.json", Format: FormatJSON, Packets: 2}, source)
+
+	// Gzip compressed NDJSON, with a packet lacking its timestamp
+	var ndjson bytes.Buffer
+	gz := gzip.NewWriter(&ndjson)
+	encoder := json.NewEncoder(gz)
+	encoder.Encode(packet("p3", "10.0.0.3", "10.0.0.2", 80, 2*time.Second))
+	encoder.Encode(models.Packet{ID: "p4"})
+	require.NoError(t, gz.Close())
+	source, err = analyzer.Load(ctx, "dump.ndjson.gz", &ndjson)
+	require.NoError(t, err)
+	assert.Equal(t, 1, source.Packets)
+	assert.Equal(t, map[string]int{"missing id or timestamp": 1}, source.Skipped)
+
+	_, err = analyzer.Load(ctx, "notes.txt", strings.NewReader("hello"))
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	report, err := analyzer.Report(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Packets)
+	assert.Equal(t, 300, report.Bytes)
+	assert.Len(t, report.Sources, 3)
+	assert.Equal(t, base, *report.From)
+	assert.Equal(t, base.Add(2*time.Second), *report.To)
+	require.Len(t, report.Protocols, 1)
+	assert.Equal(t, "TCP", report.Protocols[0].Key)
+	require.Len(t, report.TopTalkers, 2)
+	assert.Equal(t, "10.0.0.1", report.TopTalkers[0].Key)
+
+	require.Len(t, report.Flows, 2)
+	assert.Equal(t, models.Flow{
+		Protocol: "TCP", SourceIP: "10.0.0.1", DestinationIP: "10.0.0.2", Port: 443,
+		Packets: 2, Bytes: 200, Flags: []string{"SYN"},
+		FirstSeen: base, LastSeen: base.Add(time.Second),
+	}, report.Flows[0])
+}
+
+func TestAnalyzer_Detections(t *testing.T) {
+	config := DefaultConfig()
+	config.Scan = detection.ScanConfig{Window: time.Minute, VerticalThreshold: 10, HorizontalThreshold: 100, HalfOpenThreshold: 100, Cooldown: time.Minute}
+	config.Top = 3
+	analyzer := New(config, nil)
+
+	var dump bytes.Buffer
+	encoder := json.NewEncoder(&dump)
+	for port := 1; port <= 20; port++ {
+		encoder.Encode(packet(fmt.Sprintf("p%d", port), "10.0.0.66", "10.0.0.2", port, time.Duration(port)*time.Millisecond))
+	}
+	_, err := analyzer.Load(context.Background(), "scan.ndjson", &dump)
+	require.NoError(t, err)
+
+	report, err := analyzer.Report(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, report.Flows, 3)
+	require.NotEmpty(t, report.Findings)
+	assert.Equal(t, detection.FindingVerticalScan, report.Findings[0].Type)
+	assert.Equal(t, "10.0.0.66", report.Findings[0].SourceIP)
+}
+
+func TestAnalyzer_Dropped(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxPackets = 2
+	analyzer := New(config, nil)
+
+	var dump bytes.Buffer
+	encoder := json.NewEncoder(&dump)
+	for i := 0; i < 3; i++ {
+		encoder.Encode(packet(fmt.Sprintf("p%d", i), "10.0.0.1", "10.0.0.2", 443, time.Duration(i)*time.Second))
+	}
+	_, err := analyzer.Load(context.Background(), "dump.ndjson", &dump)
+	require.NoError(t, err)
+
+	report, err := analyzer.Report(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Packets)
+	assert.Equal(t, 1, report.Dropped)
+	assert.Equal(t, base.Add(time.Second), *report.From)
+}
@@ -0,0 +1,413 @@
+package analysis
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// Evidence of the protocol of a crypto inventory endpoint
+const (
+	EvidenceHandshake = "handshake"
+	EvidencePort      = "port"
+)
+
+// service is the protocol conventionally spoken on a port
+type service struct {
+	protocol  string
+	encrypted bool
+}
+
+// wellKnownServices maps ports to their protocol, for endpoints whose
+// handshake was not captured
+var wellKnownServices = map[string]service{
+	"TCP/22":    {"SSH", true},
+	"TCP/443":   {"TLS", true},
+	"TCP/465":   {"TLS", true},
+	"TCP/636":   {"TLS", true},
+	"TCP/853":   {"TLS", true},
+	"TCP/989":   {"TLS", true},
+	"TCP/990":   {"TLS", true},
+	"TCP/993":   {"TLS", true},
+	"TCP/995":   {"TLS", true},
+	"TCP/5061":  {"TLS", true},
+	"TCP/8443":  {"TLS", true},
+	"UDP/443":   {"QUIC", true},
+	"UDP/500":   {"IKE", true},
+	"UDP/4500":  {"IKE", true},
+	"UDP/1194":  {"OpenVPN", true},
+	"UDP/51820": {"WireGuard", true},
+	"TCP/21":    {"FTP", false},
+	"TCP/23":    {"Telnet", false},
+	"TCP/25":    {"SMTP", false},
+	"TCP/80":    {"HTTP", false},
+	"TCP/110":   {"POP3", false},
+	"TCP/143":   {"IMAP", false},
+	"TCP/389":   {"LDAP", false},
+	"TCP/8080":  {"HTTP", false},
+	"UDP/53":    {"DNS", false},
+	"UDP/161":   {"SNMP", false},
+}
+
+// tlsVersions names the TLS protocol versions
+var tlsVersions = map[uint16]string{
+	0x0300: "SSL 3.0",
+	0x0301: "TLS 1.0",
+	0x0302: "TLS 1.1",
+	0x0303: "TLS 1.2",
+	0x0304: "TLS 1.3",
+}
+
+// cipherSuites names the common TLS cipher suites
+var cipherSuites = map[uint16]string{
+	0x0004: "TLS_RSA_WITH_RC4_128_MD5",
+	0x0005: "TLS_RSA_WITH_RC4_128_SHA",
+	0x000a: "TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	0x002f: "TLS_RSA_WITH_AES_128_CBC_SHA",
+	0x0035: "TLS_RSA_WITH_AES_256_CBC_SHA",
+	0x009c: "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	0x009d: "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	0x1301: "TLS_AES_128_GCM_SHA256",
+	0x1302: "TLS_AES_256_GCM_SHA384",
+	0x1303: "TLS_CHACHA20_POLY1305_SHA256",
+	0xc009: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	0xc00a: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	0xc013: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	0xc014: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	0xc02b: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	0xc02c: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	0xc02f: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	0xc030: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	0xcca8: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	0xcca9: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+}
+
+// weakCipherParts are the parts of the names of deprecated cipher suites
+var weakCipherParts = []string{"_NULL_", "_EXPORT_", "_RC4_", "_3DES_", "_DES_", "_MD5"}
+
+// handshake is what a captured handshake message reveals about a service
+type handshake struct {
+	protocol string
+	server   bool // sent by the server
+	version  string
+	cipher   string
+	name     string
+}
+
+// inventory accumulates the endpoints of the crypto inventory
+type inventory struct {
+	endpoints map[string]*endpointState
+}
+
+// endpointState is an endpoint with the sets behind its lists
+type endpointState struct {
+	models.CryptoEndpoint
+	versions map[string]bool
+	ciphers  map[string]bool
+	names    map[string]bool
+	clients  map[string]bool
+}
+
+func newInventory() *inventory {
+	return &inventory{endpoints: make(map[string]*endpointState)}
+}
+
+// add accounts a packet to the endpoint it reaches. Handshakes identify
+// the server side; other packets are attributed to their destination.
+func (inv *inventory) add(packet *models.Packet) {
+	hello := parseHandshake(packet.Payload)
+	address, client := packet.DestinationIP, packet.SourceIP
+	if hello != nil && hello.server {
+		address, client = packet.SourceIP, packet.DestinationIP
+	}
+
+	protocol, encrypted, evidence := "", false, EvidencePort
+	if hello != nil {
+		protocol, encrypted, evidence = hello.protocol, true, EvidenceHandshake
+	} else if known, ok := wellKnownServices[transport(packet.Protocol)+"/"+strconv.Itoa(packet.Port)]; ok {
+		protocol, encrypted = known.protocol, known.encrypted
+	} else if packet.Protocol == "HTTPS" {
+		protocol, encrypted = "TLS", true
+	} else if packet.Protocol == "HTTP" {
+		protocol = "HTTP"
+	}
+
+	key := address + "|" + strconv.Itoa(packet.Port)
+	state, ok := inv.endpoints[key]
+	if !ok {
+		if protocol == "" {
+			return
+		}
+		state = &endpointState{
+			CryptoEndpoint: models.CryptoEndpoint{
+				Address:   address,
+				Port:      packet.Port,
+				FirstSeen: packet.Timestamp,
+				LastSeen:  packet.Timestamp,
+			},
+			versions: make(map[string]bool),
+			ciphers:  make(map[string]bool),
+			names:    make(map[string]bool),
+			clients:  make(map[string]bool),
+		}
+		inv.endpoints[key] = state
+	}
+
+	// A handshake outweighs the port convention
+	if protocol != "" && (state.Evidence != EvidenceHandshake || evidence == EvidenceHandshake) {
+		state.Protocol, state.Encrypted, state.Evidence = protocol, encrypted, evidence
+	}
+	if hello != nil {
+		addValue(state.versions, hello.version)
+		addValue(state.ciphers, hello.cipher)
+		addValue(state.names, hello.name)
+	}
+	state.clients[client] = true
+	state.Packets++
+	if packet.Timestamp.Before(state.FirstSeen) {
+		state.FirstSeen = packet.Timestamp
+	}
+	if packet.Timestamp.After(state.LastSeen) {
+		state.LastSeen = packet.Timestamp
+	}
+}
+
+// list returns the endpoints, cleartext ones first, then by address and
+// port
+func (inv *inventory) list() []models.CryptoEndpoint {
+	endpoints := make([]models.CryptoEndpoint, 0, len(inv.endpoints))
+	for _, state := range inv.endpoints {
+		if inv.isReply(state) {
+			continue
+		}
+		endpoint := state.CryptoEndpoint
+		endpoint.Versions = sortedKeys(state.versions)
+		endpoint.CipherSuites = sortedKeys(state.ciphers)
+		endpoint.ServerNames = sortedKeys(state.names)
+		endpoint.Clients = len(state.clients)
+		endpoint.Weaknesses = weaknesses(&endpoint)
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		a, b := endpoints[i], endpoints[j]
+		if a.Encrypted != b.Encrypted {
+			return !a.Encrypted
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.Port < b.Port
+	})
+	return endpoints
+}
+
+// isReply reports whether an endpoint known only by its port is the client
+// side of a connection, whose replies carry the service port too. It is
+// when one of its peers serves it on the same port and is the likelier
+// server: proven by a handshake or serving more clients.
+func (inv *inventory) isReply(state *endpointState) bool {
+	if state.Evidence == EvidenceHandshake {
+		return false
+	}
+	for client := range state.clients {
+		peer, ok := inv.endpoints[client+"|"+strconv.Itoa(state.Port)]
+		if !ok || !peer.clients[state.Address] {
+			continue
+		}
+		if peer.Evidence == EvidenceHandshake || len(peer.clients) > len(state.clients) {
+			return true
+		}
+	}
+	return false
+}
+
+// weaknesses lists the problems of an endpoint's cryptography
+func weaknesses(endpoint *models.CryptoEndpoint) []string {
+	var problems []string
+	if !endpoint.Encrypted {
+		problems = append(problems, "cleartext protocol")
+	}
+	for _, version := range endpoint.Versions {
+		switch version {
+		case "SSL 3.0", "TLS 1.0", "TLS 1.1", "SSH 1.5", "SSH 1.0":
+			problems = append(problems, "deprecated version "+version)
+		}
+	}
+	for _, cipher := range endpoint.CipherSuites {
+		for _, part := range weakCipherParts {
+			if strings.Contains(cipher, part) {
+				problems = append(problems, "weak cipher suite "+cipher)
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// transport returns the transport protocol of a packet protocol
+func transport(protocol string) string {
+	if protocol == "HTTP" || protocol == "HTTPS" {
+		return "TCP"
+	}
+	return protocol
+}
+
+// parseHandshake recognises the start of a TLS or SSH handshake in a
+// payload
+func parseHandshake(payload string) *handshake {
+	if strings.HasPrefix(payload, "SSH-") {
+		banner, _, _ := strings.Cut(payload, "\n")
+		fields := strings.SplitN(strings.TrimRight(banner, "\r"), "-", 3)
+		if len(fields) < 3 {
+			return nil
+		}
+		return &handshake{protocol: "SSH", version: "SSH " + fields[1]}
+	}
+	return parseTLS([]byte(payload))
+}
+
+// parseTLS decodes a TLS record holding a ClientHello or a ServerHello. A
+// ClientHello yields the server name and highest offered version, a
+// ServerHello the negotiated version and cipher suite.
+func parseTLS(data []byte) *handshake {
+	// Record header: handshake content type and a 3.x version
+	if len(data) < 9 || data[0] != 0x16 || data[1] != 0x03 {
+		return nil
+	}
+	messageType := data[5]
+	if messageType != 1 && messageType != 2 {
+		return nil
+	}
+	r := reader(data[9:])
+
+	hello := &handshake{protocol: "TLS", server: messageType == 2}
+	version, ok := r.uint16()
+	if !ok || !r.skip(32) {
+		return nil
+	}
+	if !r.skip(int(r.uint8())) {
+		return nil
+	}
+
+	if hello.server {
+		suite, ok := r.uint16()
+		if !ok || !r.skip(1) {
+			return nil
+		}
+		hello.cipher = cipherName(suite)
+	} else {
+		if !r.skip(int(r.uint16OrZero())) || !r.skip(int(r.uint8())) {
+			return nil
+		}
+	}
+
+	// Extensions are optional before TLS 1.3, and may be cut off by the
+	// capture, so parsing stops quietly at the first truncated one
+	extensions := reader(r.bytes(int(r.uint16OrZero())))
+	for len(extensions) >= 4 {
+		extension, _ := extensions.uint16()
+		body := reader(extensions.bytes(int(extensions.uint16OrZero())))
+		switch extension {
+		case 0: // server_name
+			body.skip(2)
+			if body.uint8() == 0 {
+				hello.name = string(body.bytes(int(body.uint16OrZero())))
+			}
+		case 43: // supported_versions
+			if hello.server {
+				version, _ = body.uint16()
+				continue
+			}
+			list := reader(body.bytes(int(body.uint8())))
+			for len(list) >= 2 {
+				offered, _ := list.uint16()
+				if _, known := tlsVersions[offered]; known && offered > version {
+					version = offered
+				}
+			}
+		}
+	}
+
+	if name, ok := tlsVersions[version]; ok {
+		hello.version = name
+	}
+	return hello
+}
+
+// cipherName names a cipher suite, or formats its code
+func cipherName(suite uint16) string {
+	if name, ok := cipherSuites[suite]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", suite)
+}
+
+// reader consumes big-endian fields of a handshake message
+type reader []byte
+
+func (r *reader) uint8() uint8 {
+	if len(*r) < 1 {
+		*r = nil
+		return 0
+	}
+	v := (*r)[0]
+	*r = (*r)[1:]
+	return v
+}
+
+func (r *reader) uint16() (uint16, bool) {
+	if len(*r) < 2 {
+		*r = nil
+		return 0, false
+	}
+	v := binary.BigEndian.Uint16(*r)
+	*r = (*r)[2:]
+	return v, true
+}
+
+func (r *reader) uint16OrZero() uint16 {
+	v, _ := r.uint16()
+	return v
+}
+
+// skip drops n bytes and reports whether they were available
+func (r *reader) skip(n int) bool {
+	if len(*r) < n {
+		*r = nil
+		return false
+	}
+	*r = (*r)[n:]
+	return true
+}
+
+// bytes consumes up to n bytes
+func (r *reader) bytes(n int) []byte {
+	n = min(n, len(*r))
+	v := (*r)[:n]
+	*r = (*r)[n:]
+	return v
+}
+
+// addValue adds value to set unless it is empty
+func addValue(set map[string]bool, value string) {
+	if value != "" {
+		set[value] = true
+	}
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
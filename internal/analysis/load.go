@@ -0,0 +1,165 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/pcap"
+)
+
+// Input formats
+const (
+	FormatPcap = "pcap"
+	FormatJSON = "json"
+)
+
+// ErrUnknownFormat is returned for input that is neither a capture file nor
+// a packet dump
+var ErrUnknownFormat = errors.New("unknown input format, expected pcap, pcapng, JSON or NDJSON")
+
+// Load stores the packets of a capture file or packet dump read from r,
+// which may be gzip compressed. Packet dumps are the JSON responses of the
+// packets API, JSON arrays of packets or NDJSON streams of packets.
+// Packets that cannot be decoded are counted by reason and skipped.
+func (a *Analyzer) Load(ctx context.Context, name string, r io.Reader) (*models.AnalysisSource, error) {
+	input := bufio.NewReader(r)
+	if header, _ := input.Peek(2); bytes.Equal(header, []byte{0x1f, 0x8b}) {
+		decompressed, err := gzip.NewReader(input)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		defer decompressed.Close()
+		input = bufio.NewReader(decompressed)
+	}
+
+	source := &models.AnalysisSource{Name: name, Skipped: make(map[string]int)}
+	var err error
+	header, _ := input.Peek(4)
+	switch {
+	case pcap.IsCapture(header):
+		source.Format = FormatPcap
+		err = a.loadCapture(ctx, input, source)
+	case isJSON(input):
+		source.Format = FormatJSON
+		err = a.loadDump(ctx, input, source)
+	default:
+		err = ErrUnknownFormat
+	}
+	if len(source.Skipped) == 0 {
+		source.Skipped = nil
+	}
+	a.sources = append(a.sources, *source)
+	if err != nil {
+		return source, fmt.Errorf("%s: %w", name, err)
+	}
+	return source, nil
+}
+
+// loadCapture stores the packets of a pcap or pcapng file
+func (a *Analyzer) loadCapture(ctx context.Context, r io.Reader, source *models.AnalysisSource) error {
+	reader, err := pcap.NewReader(r)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		packet, err := pcap.Decode(record)
+		if err != nil {
+			source.Skipped[err.Error()]++
+			continue
+		}
+		if err := a.store(ctx, packet, source); err != nil {
+			return err
+		}
+	}
+}
+
+// loadDump stores the packets of a JSON packet dump
+func (a *Analyzer) loadDump(ctx context.Context, r io.Reader, source *models.AnalysisSource) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var value json.RawMessage
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var packets []models.Packet
+		switch value[0] {
+		case '[':
+			err = json.Unmarshal(value, &packets)
+		case '{':
+			var response struct {
+				Packets *[]models.Packet `json:"packets"`
+			}
+			if err = json.Unmarshal(value, &response); err == nil && response.Packets != nil {
+				packets = *response.Packets
+				break
+			}
+			var packet models.Packet
+			err = json.Unmarshal(value, &packet)
+			packets = []models.Packet{packet}
+		default:
+			err = ErrUnknownFormat
+		}
+		if err != nil {
+			return err
+		}
+
+		for i := range packets {
+			if packets[i].ID == "" || packets[i].Timestamp.IsZero() {
+				source.Skipped["missing id or timestamp"]++
+				continue
+			}
+			if err := a.store(ctx, &packets[i], source); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// store adds a packet to storage and the per-packet aggregates
+func (a *Analyzer) store(ctx context.Context, packet *models.Packet, source *models.AnalysisSource) error {
+	if err := a.storage.Store(ctx, packet); err != nil {
+		return err
+	}
+	source.Packets++
+	a.loaded++
+	return nil
+}
+
+// isJSON reports whether the next non-blank byte of r starts a JSON
+// object or array
+func isJSON(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		data, err := r.Peek(n)
+		if err != nil || len(data) < n {
+			return false
+		}
+		switch data[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{', '[':
+			return true
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// Dimension identifies the packet attribute used to group an aggregation
+type Dimension string
+
+// Supported aggregation dimensions
+const (
+	DimensionSourceIP      Dimension = "source_ip"
+	DimensionDestinationIP Dimension = "destination_ip"
+	DimensionHostPair      Dimension = "host_pair"
+	DimensionPort          Dimension = "port"
+	DimensionProtocol      Dimension = "protocol"
+)
+
+// Metric identifies the quantity used to rank aggregated keys
+type Metric string
+
+// Supported ranking metrics
+const (
+	MetricPackets Metric = "packets"
+	MetricBytes   Metric = "bytes"
+)
+
+// Dimensions lists every supported aggregation dimension
+var Dimensions = []Dimension{
+	DimensionSourceIP,
+	DimensionDestinationIP,
+	DimensionHostPair,
+	DimensionPort,
+	DimensionProtocol,
+}
+
+// ParseDimension validates a dimension name
+func ParseDimension(value string) (Dimension, error) {
+	for _, d := range Dimensions {
+		if string(d) == value {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported dimension %q", value)
+}
+
+// ParseMetric validates a metric name, defaulting to packets when empty
+func ParseMetric(value string) (Metric, error) {
+	switch Metric(value) {
+	case "", MetricPackets:
+		return MetricPackets, nil
+	case MetricBytes:
+		return MetricBytes, nil
+	}
+	return "", fmt.Errorf("unsupported metric %q", value)
+}
+
+// Key returns the aggregation key of a packet for the given dimension
+func Key(packet *models.Packet, dimension Dimension) string {
+	switch dimension {
+	case DimensionSourceIP:
+		return packet.SourceIP
+	case DimensionDestinationIP:
+		return packet.DestinationIP
+	case DimensionHostPair:
+		return HostPairKey(packet.SourceIP, packet.DestinationIP)
+	case DimensionPort:
+		return strconv.Itoa(packet.Port)
+	case DimensionProtocol:
+		return packet.Protocol
+	}
+	return ""
+}
+
+// HostPairKey builds the directional key used for host pair aggregations
+func HostPairKey(sourceIP, destinationIP string) string {
+	return sourceIP + " -> " + destinationIP
+}
+
+// TopN groups packets by dimension and returns the n highest ranked keys.
+// A non-positive n returns every key.
+func TopN(packets []models.Packet, dimension Dimension, metric Metric, n int) *models.TopNResponse {
+	counters := make(map[string]*models.TopNEntry)
+	response := &models.TopNResponse{
+		Dimension: string(dimension),
+		Metric:    string(metric),
+	}
+
+	for i := range packets {
+		packet := &packets[i]
+		key := Key(packet, dimension)
+
+		entry, ok := counters[key]
+		if !ok {
+			entry = &models.TopNEntry{Key: key}
+			counters[key] = entry
+		}
+		entry.Packets++
+		entry.Bytes += packet.Size
+
+		response.TotalPackets++
+		response.TotalBytes += packet.Size
+	}
+
+	entries := make([]models.TopNEntry, 0, len(counters))
+	for _, entry := range counters {
+		entries = append(entries, *entry)
+	}
+
+	response.Entries = Rank(entries, metric, n, response.TotalPackets, response.TotalBytes)
+	return response
+}
+
+// Rank sorts entries by metric, truncates them to n and fills in each
+// entry's share of the given totals
+func Rank(entries []models.TopNEntry, metric Metric, n int, totalPackets, totalBytes int) []models.TopNEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := value(entries[i], metric), value(entries[j], metric)
+		if a != b {
+			return a > b
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+
+	total := totalPackets
+	if metric == MetricBytes {
+		total = totalBytes
+	}
+	for i := range entries {
+		if total > 0 {
+			entries[i].Share = float64(value(entries[i], metric)) / float64(total)
+		}
+	}
+
+	return entries
+}
+
+// value returns the ranked quantity of an entry
+func value(entry models.TopNEntry, metric Metric) int {
+	if metric == MetricBytes {
+		return entry.Bytes
+	}
+	return entry.Packets
+}
+
+// Query describes a top-N aggregation request
+type Query struct {
+	Filter    models.PacketFilter
+	Window    time.Duration
+	Dimension Dimension
+	Metric    Metric
+	N         int
+}
+
+// WindowOnly reports whether the query is restricted by nothing but a
+// sliding window ending now, which rolling aggregates can answer directly
+func (q *Query) WindowOnly() bool {
+	f := q.Filter
+	return q.Window > 0 && f.Protocol == "" && f.SourceIP == "" && f.DestinationIP == "" && f.Session == "" && f.Agent == "" && f.ToTimestamp.IsZero()
+}
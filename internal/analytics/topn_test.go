@@ -0,0 +1,78 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPackets() []models.Packet {
+	return []models.Packet{
+		*models.NewPacket("10.0.0.1", "8.8.8.8", "UDP", 53, 100),
+		*models.NewPacket("10.0.0.1", "8.8.8.8", "UDP", 53, 100),
+		*models.NewPacket("10.0.0.1", "1.1.1.1", "TCP", 443, 100),
+		*models.NewPacket("10.0.0.2", "1.1.1.1", "TCP", 443, 1400),
+	}
+}
+
+func TestTopN_ByPackets(t *testing.T) {
+	response := TopN(testPackets(), DimensionSourceIP, MetricPackets, 10)
+
+	assert.Equal(t, 4, response.TotalPackets)
+	assert.Equal(t, 1700, response.TotalBytes)
+	require.Len(t, response.Entries, 2)
+	assert.Equal(t, "10.0.0.1", response.Entries[0].Key)
+	assert.Equal(t, 3, response.Entries[0].Packets)
+	assert.InDelta(t, 0.75, response.Entries[0].Share, 1e-9)
+}
+
+func TestTopN_ByBytes(t *testing.T) {
+	response := TopN(testPackets(), DimensionSourceIP, MetricBytes, 10)
+
+	require.Len(t, response.Entries, 2)
+	assert.Equal(t, "10.0.0.2", response.Entries[0].Key)
+	assert.Equal(t, 1400, response.Entries[0].Bytes)
+}
+
+func TestTopN_Dimensions(t *testing.T) {
+	packets := testPackets()
+
+	pairs := TopN(packets, DimensionHostPair, MetricPackets, 1)
+	require.Len(t, pairs.Entries, 1)
+	assert.Equal(t, HostPairKey("10.0.0.1", "8.8.8.8"), pairs.Entries[0].Key)
+
+	ports := TopN(packets, DimensionPort, MetricPackets, 0)
+	require.Len(t, ports.Entries, 2)
+	// Ties are broken by key so results are stable
+	assert.Equal(t, "443", ports.Entries[0].Key)
+	assert.Equal(t, "53", ports.Entries[1].Key)
+
+	protocols := TopN(packets, DimensionProtocol, MetricBytes, 0)
+	require.Len(t, protocols.Entries, 2)
+	assert.Equal(t, "TCP", protocols.Entries[0].Key)
+}
+
+func TestTopN_Empty(t *testing.T) {
+	response := TopN(nil, DimensionDestinationIP, MetricPackets, 10)
+
+	assert.Equal(t, 0, response.TotalPackets)
+	assert.Empty(t, response.Entries)
+}
+
+func TestParseDimensionAndMetric(t *testing.T) {
+	_, err := ParseDimension("mac")
+	assert.Error(t, err)
+
+	d, err := ParseDimension("host_pair")
+	require.NoError(t, err)
+	assert.Equal(t, DimensionHostPair, d)
+
+	m, err := ParseMetric("")
+	require.NoError(t, err)
+	assert.Equal(t, MetricPackets, m)
+
+	_, err = ParseMetric("flows")
+	assert.Error(t, err)
+}
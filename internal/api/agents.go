@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAgents handles GET /agents
+// @Summary List agents
+// @Description List the remote capture agents registered with the collector, by ID, with the time they were last seen, whether they are connected and the packets they forwarded. The list is empty unless the service runs in collector mode.
+// @Tags agents
+// @Produce json
+// @Success 200 {object} models.AgentsResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /agents [get]
+func (h *Handler) GetAgents(c *gin.Context) {
+	agents := []models.Agent{}
+	if h.agents != nil {
+		agents = h.agents.List()
+	}
+	c.JSON(http.StatusOK, models.AgentsResponse{Agents: agents, Total: len(agents)})
+}
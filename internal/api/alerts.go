@@ -0,0 +1,183 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/alerting"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAlerts handles GET /alerts
+// @Summary Alert history
+// @Description List alerts raised by alert rules, newest first
+// @Tags alerts
+// @Produce json
+// @Param rule_id query string false "Filter by rule ID"
+// @Param severity query string false "Filter by severity" Enums(info, low, medium, high, critical)
+// @Param since query string false "Only alerts seen at or after this RFC3339 timestamp"
+// @Param limit query int false "Limit number of results (default: no limit)"
+// @Success 200 {object} models.AlertResponse
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /alerts [get]
+func (h *Handler) GetAlerts(c *gin.Context) {
+	filter := &models.AlertFilter{
+		RuleID:   c.Query("rule_id"),
+		Severity: c.Query("severity"),
+	}
+
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: "invalid since timestamp"})
+			return
+		}
+		filter.Since = since
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+	}
+
+	alerts := h.alerts.Alerts(filter)
+	c.JSON(http.StatusOK, models.AlertResponse{
+		Alerts:    alerts,
+		Total:     len(alerts),
+		Timestamp: time.Now(),
+	})
+}
+
+// GetAlertRules handles GET /alerts/rules
+// @Summary List alert rules
+// @Description List every alert rule
+// @Tags alerts
+// @Produce json
+// @Success 200 {array} models.AlertRule
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /alerts/rules [get]
+func (h *Handler) GetAlertRules(c *gin.Context) {
+	c.JSON(http.StatusOK, h.alerts.Rules())
+}
+
+// GetAlertRule handles GET /alerts/rules/:id
+// @Summary Get alert rule
+// @Description Retrieve a single alert rule by ID
+// @Tags alerts
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} models.AlertRule
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Rule not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /alerts/rules/{id} [get]
+func (h *Handler) GetAlertRule(c *gin.Context) {
+	rule := h.alerts.GetRule(c.Param("id"))
+	if rule == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not Found", Message: "Alert rule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateAlertRule handles POST /alerts/rules
+// @Summary Create alert rule
+// @Description Create an alert rule. Rules are enabled unless "enabled" is false.
+// @Description Supported operators: eq, neq, in, not_in, gt, gte, lt, lte, cidr, not_cidr, contains.
+// @Description Aggregations: count, distinct(field), sum(numeric field); a zero window evaluates each packet alone.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param rule body models.AlertRule true "Alert rule"
+// @Success 201 {object} models.AlertRule
+// @Failure 400 {object} ErrorResponse "Invalid rule"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /alerts/rules [post]
+func (h *Handler) CreateAlertRule(c *gin.Context) {
+	rule := models.AlertRule{Enabled: true}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+
+	created, err := h.alerts.CreateRule(rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateAlertRule handles PUT /alerts/rules/:id
+// @Summary Update alert rule
+// @Description Replace an alert rule and reset its evaluation state
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Param rule body models.AlertRule true "Alert rule"
+// @Success 200 {object} models.AlertRule
+// @Failure 400 {object} ErrorResponse "Invalid rule"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Rule not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /alerts/rules/{id} [put]
+func (h *Handler) UpdateAlertRule(c *gin.Context) {
+	rule := models.AlertRule{Enabled: true}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+
+	updated, err := h.alerts.UpdateRule(c.Param("id"), rule)
+	if errors.Is(err, alerting.ErrRuleNotFound) {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not Found", Message: "Alert rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteAlertRule handles DELETE /alerts/rules/:id
+// @Summary Delete alert rule
+// @Description Delete an alert rule; alerts it raised remain in the history
+// @Tags alerts
+// @Param id path string true "Rule ID"
+// @Success 204 "Deleted"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Rule not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /alerts/rules/{id} [delete]
+func (h *Handler) DeleteAlertRule(c *gin.Context) {
+	if err := h.alerts.DeleteRule(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not Found", Message: "Alert rule not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/analytics"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTopN = 10
+	maxTopN     = 1000
+)
+
+// TopN handles GET /analytics/top/:dimension
+// @Summary Top-N aggregation
+// @Description Rank source IPs, destination IPs, host pairs, destination ports or protocols by packets or bytes.
+// @Description Requests limited to a 1m, 5m or 1h window are answered from rolling aggregates and flagged approximate.
+// @Tags analytics
+// @Produce json
+// @Param dimension path string true "Aggregation dimension" Enums(source_ip, destination_ip, host_pair, port, protocol)
+// @Param by query string false "Ranking metric (default: packets)" Enums(packets, bytes)
+// @Param n query int false "Number of entries to return (default: 10, 0 for all)"
+// @Param protocol query string false "Filter by protocol (TCP, UDP, HTTP, HTTPS)"
+// @Param source_ip query string false "Filter by source IP address"
+// @Param destination_ip query string false "Filter by destination IP address"
+// @Param from query string false "Only packets at or after this RFC3339 timestamp"
+// @Param to query string false "Only packets at or before this RFC3339 timestamp"
+// @Param session query string false "Only packets of this session, such as an uploaded capture (default: live traffic)"
+// @Param agent query string false "Only packets captured by this agent"
+// @Param window query string false "Only packets within this duration before now (e.g. 5m), instead of from"
+// @Success 200 {object} models.TopNResponse
+// @Failure 400 {object} ErrorResponse "Invalid parameters"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /analytics/top/{dimension} [get]
+func (h *Handler) TopN(c *gin.Context) {
+	dimension, err := analytics.ParseDimension(c.Param("dimension"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+
+	metric, err := analytics.ParseMetric(c.Query("by"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+
+	n := defaultTopN
+	if nStr := c.Query("n"); nStr != "" {
+		n, err = strconv.Atoi(nStr)
+		if err != nil || n < 0 || n > maxTopN {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: "n must be between 0 and 1000"})
+			return
+		}
+	}
+
+	filter, err := parsePacketFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+
+	query := &analytics.Query{
+		Filter:    *filter,
+		Dimension: dimension,
+		Metric:    metric,
+		N:         n,
+	}
+	if c.Query("from") == "" {
+		// Already validated by parsePacketFilter
+		query.Window, _ = time.ParseDuration(c.Query("window"))
+	}
+
+	response, err := h.packetService.TopN(c.Request.Context(), query)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to aggregate packets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
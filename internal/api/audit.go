@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuditBody is the largest request body recorded in audit parameters
+const maxAuditBody = 8 * 1024
+
+// auditActions names the mutating routes in the audit log
+var auditActions = map[string]string{
+	"DELETE /api/v1/packets":                         "packets.clear",
+	"DELETE /api/v1/packets/:id":                     "packets.delete",
+	"POST /api/v1/packets":                           "packets.ingest",
+	"POST /api/v1/packets/batch":                     "packets.ingest_batch",
+	"POST /api/v1/ingest/jobs":                       "ingest_jobs.create",
+	"POST /api/v1/sniffing/start":                    "sniffing.start",
+	"POST /api/v1/sniffing/stop":                     "sniffing.stop",
+	"PATCH /api/v1/sniffing/config":                  "sniffing.configure",
+	"POST /api/v1/alerts/rules":                      "alert_rules.create",
+	"PUT /api/v1/alerts/rules/:id":                   "alert_rules.update",
+	"DELETE /api/v1/alerts/rules/:id":                "alert_rules.delete",
+	"POST /api/v1/notifications/dead-letters/replay": "notifications.replay",
+	"POST /api/v1/auth/keys":                         "api_keys.create",
+	"DELETE /api/v1/auth/keys/:id":                   "api_keys.revoke",
+}
+
+// audit records every mutating request once it has been handled,
+// including the ones rejected by authentication or authorization
+func (r *Router) audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r.handler.auditLog == nil {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		parameters := auditParameters(c)
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := models.AuditEntry{
+			Actor:      "unauthenticated",
+			Action:     auditAction(c),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Parameters: parameters,
+			ClientIP:   c.ClientIP(),
+			Status:     status,
+			Outcome:    auditOutcome(status),
+		}
+		if peer := c.RemoteIP(); peer != entry.ClientIP {
+			entry.PeerIP = peer
+		}
+		if principal := principalFrom(c); principal != nil {
+			entry.Actor = principal.Subject
+			entry.Role = string(principal.Role)
+			entry.AuthMethod = principal.Method
+		}
+		if _, err := r.handler.auditLog.Record(entry); err != nil {
+			r.logger.ErrorContext(c.Request.Context(), "Failed to record audit entry", "action", entry.Action, "error", err.Error())
+		}
+	}
+}
+
+// auditAction returns the audit name of the matched route
+func auditAction(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	key := c.Request.Method + " " + route
+	if action, ok := auditActions[key]; ok {
+		return action
+	}
+	return key
+}
+
+// auditOutcome classifies a response status
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return models.AuditDenied
+	case status >= 400:
+		return models.AuditFailure
+	default:
+		return models.AuditSuccess
+	}
+}
+
+// auditParameters collects the path and query parameters of a request and
+// its JSON body when it is small enough. The body is restored for the
+// handler.
+func auditParameters(c *gin.Context) map[string]any {
+	parameters := make(map[string]any)
+	for _, p := range c.Params {
+		parameters[p.Key] = p.Value
+	}
+	for key, values := range c.Request.URL.Query() {
+		parameters[key] = strings.Join(values, ",")
+	}
+
+	if c.Request.Body != nil && strings.HasPrefix(c.ContentType(), "application/json") &&
+		c.Request.ContentLength > 0 && c.Request.ContentLength <= maxAuditBody {
+		data, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		var body any
+		if err == nil && json.Unmarshal(data, &body) == nil {
+			parameters["body"] = body
+		}
+	}
+
+	if len(parameters) == 0 {
+		return nil
+	}
+	return parameters
+}
+
+// GetAudit handles GET /audit
+// @Summary Audit log
+// @Description List the recorded mutating operations, newest first. Every entry carries the hash of the previous one.
+// @Tags audit
+// @Produce json
+// @Param actor query string false "Filter by actor"
+// @Param action query string false "Filter by action (e.g. packets.clear, sniffing.stop)"
+// @Param outcome query string false "Filter by outcome" Enums(success, denied, failure)
+// @Param since query string false "Only entries at or after this RFC3339 timestamp"
+// @Param until query string false "Only entries at or before this RFC3339 timestamp"
+// @Param limit query int false "Limit number of results (default: no limit)"
+// @Success 200 {object} models.AuditResponse
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /audit [get]
+func (h *Handler) GetAudit(c *gin.Context) {
+	filter := &models.AuditFilter{
+		Actor:   c.Query("actor"),
+		Action:  c.Query("action"),
+		Outcome: c.Query("outcome"),
+	}
+
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: "invalid " + name + " timestamp"})
+				return
+			}
+			*target = parsed
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+	}
+
+	entries := h.auditLog.List(filter)
+	c.JSON(http.StatusOK, models.AuditResponse{
+		Entries:   entries,
+		Total:     len(entries),
+		Timestamp: time.Now(),
+	})
+}
+
+// VerifyAudit handles GET /audit/verify
+// @Summary Verify the audit chain
+// @Description Recompute the hash chain of the audit log and report the first entry that was altered, removed or reordered
+// @Tags audit
+// @Produce json
+// @Success 200 {object} models.AuditVerification
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /audit/verify [get]
+func (h *Handler) VerifyAudit(c *gin.Context) {
+	result, err := h.auditLog.Verify()
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to verify audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
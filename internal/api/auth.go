@@ -0,0 +1,176 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin context key of the authenticated principal
+const principalKey = "principal"
+
+// authenticate resolves the principal of every request. Without an
+// authenticator every request is served as the anonymous admin. Failed
+// attempts are charged to the standard budget of the client IP, so
+// credential guessing is throttled like anonymous traffic.
+func (r *Router) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r.authenticator == nil {
+			c.Set(principalKey, auth.Anonymous)
+			c.Next()
+			return
+		}
+
+		principal, err := r.authenticator.Authenticate(c.Request)
+		if err != nil {
+			if r.standardLimiter != nil {
+				if decision := r.standardLimiter.Allow("ip:" + c.ClientIP()); !decision.Allowed {
+					abortRateLimited(c, decision)
+					return
+				}
+			}
+			message := "Invalid credentials"
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				message = "Authentication required: provide an X-API-Key header or a bearer token"
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="network-sniffer"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized", Message: message})
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// authorize rejects principals whose role does not include role
+func authorize(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := principalFrom(c)
+		if principal == nil || !principal.Role.Allows(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Error:   "Forbidden",
+				Message: "This operation requires the " + string(role) + " role",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// principalFrom returns the principal of a request, or nil before
+// authentication
+func principalFrom(c *gin.Context) *auth.Principal {
+	if value, ok := c.Get(principalKey); ok {
+		if principal, ok := value.(*auth.Principal); ok {
+			return principal
+		}
+	}
+	return nil
+}
+
+// WhoAmI handles GET /auth/whoami
+// @Summary Current principal
+// @Description Return the identity and role the request is authenticated as
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.Principal
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/whoami [get]
+func (h *Handler) WhoAmI(c *gin.Context) {
+	c.JSON(http.StatusOK, principalFrom(c))
+}
+
+// GetAPIKeys handles GET /auth/keys
+// @Summary List API keys
+// @Description List the API keys. Secrets are never returned, only their prefix.
+// @Tags auth
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/keys [get]
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, h.keys.List())
+}
+
+// CreateAPIKey handles POST /auth/keys
+// @Summary Create an API key
+// @Description Create an API key for a role. The secret is only returned in this response.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param key body models.APIKeyRequest true "Key to create"
+// @Success 201 {object} models.APIKeyCreated
+// @Failure 400 {object} ErrorResponse "Invalid key request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/keys [post]
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+	if req.ExpiresIn < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: "expires_in must not be negative"})
+		return
+	}
+
+	secret, key, err := h.keys.Create(req.Name, role, time.Duration(req.ExpiresIn))
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to create API key"})
+		return
+	}
+	h.logger.InfoContext(c.Request.Context(), "API key created", "key_id", key.ID, "name", key.Name, "role", key.Role)
+	c.JSON(http.StatusCreated, models.APIKeyCreated{APIKey: *key, Key: secret})
+}
+
+// RevokeAPIKey handles DELETE /auth/keys/:id
+// @Summary Revoke an API key
+// @Description Revoke an API key created through the API
+// @Tags auth
+// @Param id path string true "Key ID"
+// @Success 204 "Revoked"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Key not found"
+// @Failure 409 {object} ErrorResponse "Key defined in the configuration"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /auth/keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	err := h.keys.Revoke(c.Param("id"))
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not Found", Message: "API key not found"})
+	case errors.Is(err, auth.ErrStaticKey):
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Conflict", Message: err.Error()})
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to revoke API key"})
+	default:
+		h.logger.InfoContext(c.Request.Context(), "API key revoked", "key_id", c.Param("id"))
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetDetections handles GET /detections
+// @Summary List findings
+// @Description List findings raised by the built-in detectors, newest first.
+// @Description Anomaly findings carry the observed value and the baseline it deviates from.
+// @Tags detections
+// @Produce json
+// @Param detector query string false "Filter by detector" Enums(portscan, anomaly)
+// @Param type query string false "Filter by finding type (vertical_scan, horizontal_sweep, syn_scan, packet_rate, packet_size, new_peer_rate)"
+// @Param source_ip query string false "Filter by source IP address"
+// @Param since query string false "Only findings seen at or after this RFC3339 timestamp"
+// @Param limit query int false "Limit number of results (default: no limit)"
+// @Success 200 {object} models.FindingResponse
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /detections [get]
+func (h *Handler) GetDetections(c *gin.Context) {
+	filter := &models.FindingFilter{
+		Detector: c.Query("detector"),
+		Type:     c.Query("type"),
+		SourceIP: c.Query("source_ip"),
+	}
+
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: "invalid since timestamp"})
+			return
+		}
+		filter.Since = since
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+	}
+
+	findings := h.findings.List(filter)
+	c.JSON(http.StatusOK, models.FindingResponse{
+		Findings:  findings,
+		Total:     len(findings),
+		Timestamp: time.Now(),
+	})
+}
+
+// GetDetection handles GET /detections/:id
+// @Summary Get finding
+// @Description Retrieve a single finding by ID
+// @Tags detections
+// @Produce json
+// @Param id path string true "Finding ID"
+// @Success 200 {object} models.Finding
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Finding not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /detections/{id} [get]
+func (h *Handler) GetDetection(c *gin.Context) {
+	finding := h.findings.Get(c.Param("id"))
+	if finding == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not Found", Message: "Finding not found"})
+		return
+	}
+	c.JSON(http.StatusOK, finding)
+}
+
+// GetBaselines handles GET /detections/baselines
+// @Summary Learned traffic baselines
+// @Description List the per-host and per-port baselines learned by the anomaly detector
+// @Tags detections
+// @Produce json
+// @Param entity query string false "Restrict to one entity, e.g. host:10.0.0.1 or port:443"
+// @Success 200 {array} models.EntityBaseline
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /detections/baselines [get]
+func (h *Handler) GetBaselines(c *gin.Context) {
+	c.JSON(http.StatusOK, h.anomalies.Baselines(c.Query("entity")))
+}
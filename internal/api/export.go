@@ -0,0 +1,126 @@
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/export"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportPackets handles GET /packets/export
+// @Summary Export packets
+// @Description Download the packets matching a filter, oldest first, as CSV, NDJSON, Parquet or pcapng. The file is streamed while packets are read from storage, so exports of any size use bounded memory.
+// @Description pcapng files open in Wireshark: each packet is written as an Ethernet frame synthesized from its addresses, port, TTL, flags, size and payload, with its ID and session as frame comments. Frames of packets larger than their payload are marked as truncated captures.
+// @Description CSV, NDJSON and pcapng are gzip compressed when the request accepts it; Parquet data pages are always gzip compressed.
+// @Description An error after the first bytes ends the response early, leaving a truncated file.
+// @Tags packets
+// @Produce text/csv,application/x-ndjson,application/vnd.apache.parquet,application/x-pcapng
+// @Param format query string false "File format (default: ndjson)" Enums(csv, ndjson, parquet, pcapng)
+// @Param protocol query string false "Filter by protocol (TCP, UDP, HTTP, HTTPS)"
+// @Param source_ip query string false "Filter by source IP address"
+// @Param destination_ip query string false "Filter by destination IP address"
+// @Param from query string false "Only packets at or after this RFC3339 timestamp"
+// @Param to query string false "Only packets at or before this RFC3339 timestamp"
+// @Param session query string false "Only packets of this session, such as an uploaded capture (default: live traffic)"
+// @Param agent query string false "Only packets captured by this agent"
+// @Param window query string false "Only packets within this duration before now (e.g. 5m), instead of from"
+// @Param limit query int false "Limit number of packets (default: no limit)"
+// @Param offset query int false "Offset of the first packet (default: 0)"
+// @Success 200 {file} file "Packet file"
+// @Failure 400 {object} ErrorResponse "Invalid format or filter"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /packets/export [get]
+func (h *Handler) ExportPackets(c *gin.Context) {
+	format, err := export.ParseFormat(c.DefaultQuery("format", string(export.FormatNDJSON)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+	filter, err := parsePacketFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", format.ContentType())
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="packets-%s.%s"`,
+		time.Now().UTC().Format("20060102T150405Z"), format.Extension()))
+
+	var body io.Writer = c.Writer
+	var gz *gzip.Writer
+	if format.Compressible() {
+		header.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			header.Set("Content-Encoding", "gzip")
+			gz = gzip.NewWriter(c.Writer)
+			body = gz
+		}
+	}
+
+	writer, err := export.NewWriter(format, body)
+	if err == nil {
+		err = h.packetService.ExportPackets(c.Request.Context(), filter, func(packets []models.Packet) error {
+			if err := writer.Write(packets); err != nil {
+				return err
+			}
+			// Send each batch as it is encoded
+			if gz != nil {
+				if err := gz.Flush(); err != nil {
+					return err
+				}
+			}
+			c.Writer.Flush()
+			return nil
+		})
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	c.Error(err)
+	if !c.Writer.Written() {
+		header.Del("Content-Type")
+		header.Del("Content-Disposition")
+		header.Del("Content-Encoding")
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to export packets"})
+	}
+	// Otherwise the response ends without the end of the file: the gzip
+	// trailer or Parquet footer is missing and the client sees a
+	// truncated download
+}
+
+// acceptsGzip reports whether an Accept-Encoding header accepts gzip
+func acceptsGzip(accept string) bool {
+	for _, coding := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.TrimSpace(name)
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		quality, err := strconv.ParseFloat(q, 64)
+		return err == nil && quality > 0
+	}
+	return false
+}
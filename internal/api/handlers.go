@@ -1,17 +1,42 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/cryptonextsecurity/network-sniffer/internal/alerting"
+	"github.com/cryptonextsecurity/network-sniffer/internal/audit"
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/collector"
+	"github.com/cryptonextsecurity/network-sniffer/internal/detection"
+	"github.com/cryptonextsecurity/network-sniffer/internal/health"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ingest"
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
 	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/notify"
 	"github.com/cryptonextsecurity/network-sniffer/internal/services"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
 	"github.com/gin-gonic/gin"
 )
 
 // Handler handles HTTP requests
 type Handler struct {
 	packetService *services.PacketService
+	alerts        *alerting.Engine
+	findings      *detection.FindingStore
+	anomalies     *detection.AnomalyDetector
+	notifier      *notify.Dispatcher
+	keys          *auth.KeyStore
+	jobs          *ingest.Jobs
+	agents        *collector.Registry
+	auditLog      *audit.Log
+	liveness      *health.Checker
+	readiness     *health.Checker
+	logger        *slog.Logger
 }
 
 // PacketService returns the packet service instance
@@ -19,13 +44,60 @@ func (h *Handler) PacketService() *services.PacketService {
 	return h.packetService
 }
 
-// NewHandler creates a new handler instance
-func NewHandler(packetService *services.PacketService, logger interface{}) *Handler {
+// NewHandler creates a new handler instance. A nil logger selects the
+// default logger.
+func NewHandler(packetService *services.PacketService, logger *slog.Logger) *Handler {
 	return &Handler{
 		packetService: packetService,
+		logger:        logging.OrDefault(logger),
 	}
 }
 
+// WithAlerting exposes the alert rules and history of an alerting engine
+func (h *Handler) WithAlerting(engine *alerting.Engine) *Handler {
+	h.alerts = engine
+	return h
+}
+
+// WithDetections exposes the findings raised by the built-in detectors and
+// the baselines learned by the anomaly detector
+func (h *Handler) WithDetections(findings *detection.FindingStore, anomalies *detection.AnomalyDetector) *Handler {
+	h.findings = findings
+	h.anomalies = anomalies
+	return h
+}
+
+// WithIngestJobs accepts capture file uploads and exposes the jobs
+// decoding them
+func (h *Handler) WithIngestJobs(jobs *ingest.Jobs) *Handler {
+	h.jobs = jobs
+	return h
+}
+
+// WithAgents exposes the capture agents registered with a collector
+func (h *Handler) WithAgents(registry *collector.Registry) *Handler {
+	h.agents = registry
+	return h
+}
+
+// WithAPIKeys exposes the management of the API keys of a key store
+func (h *Handler) WithAPIKeys(keys *auth.KeyStore) *Handler {
+	h.keys = keys
+	return h
+}
+
+// WithAudit records the mutating requests in an audit log and exposes it
+func (h *Handler) WithAudit(auditLog *audit.Log) *Handler {
+	h.auditLog = auditLog
+	return h
+}
+
+// WithNotifications exposes the delivery state of the webhook dispatcher
+func (h *Handler) WithNotifications(notifier *notify.Dispatcher) *Handler {
+	h.notifier = notifier
+	return h
+}
+
 // GetPackets handles GET /packets requests
 // @Summary Get all packets
 // @Description Retrieve all sniffed packets with optional filtering
@@ -35,42 +107,33 @@ func NewHandler(packetService *services.PacketService, logger interface{}) *Hand
 // @Param protocol query string false "Filter by protocol (TCP, UDP, HTTP, HTTPS)"
 // @Param source_ip query string false "Filter by source IP address"
 // @Param destination_ip query string false "Filter by destination IP address"
+// @Param from query string false "Only packets at or after this RFC3339 timestamp"
+// @Param to query string false "Only packets at or before this RFC3339 timestamp"
+// @Param session query string false "Only packets of this session, such as an uploaded capture (default: live traffic)"
+// @Param agent query string false "Only packets captured by this agent"
+// @Param window query string false "Only packets within this duration before now (e.g. 5m), instead of from"
 // @Param limit query int false "Limit number of results (default: no limit)"
 // @Param offset query int false "Offset for pagination (default: 0)"
 // @Success 200 {object} models.PacketResponse "List of packets"
+// @Failure 400 {object} ErrorResponse "Invalid filter"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /packets [get]
 func (h *Handler) GetPackets(c *gin.Context) {
 	// Parse query parameters
-	filter := &models.PacketFilter{}
-
-	if protocol := c.Query("protocol"); protocol != "" {
-		filter.Protocol = protocol
-	}
-
-	if sourceIP := c.Query("source_ip"); sourceIP != "" {
-		filter.SourceIP = sourceIP
-	}
-
-	if destIP := c.Query("destination_ip"); destIP != "" {
-		filter.DestinationIP = destIP
-	}
-
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
-			filter.Limit = limit
-		}
-	}
-
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
-			filter.Offset = offset
-		}
+	filter, err := parsePacketFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
 	}
 
 	// Get packets from service
 	response, err := h.packetService.GetPackets(c.Request.Context(), filter)
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Internal server error",
 			Message: "Failed to retrieve packets",
@@ -89,13 +152,18 @@ func (h *Handler) GetPackets(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Packet ID"
 // @Success 200 {object} models.Packet
+// @Failure 401 {object} ErrorResponse "Unauthorized"
 // @Failure 404 {object} ErrorResponse "Packet not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /packets/{id} [get]
 func (h *Handler) GetPacketByID(c *gin.Context) {
 	id := c.Param("id")
 	packet, err := h.packetService.GetPacketByID(c.Request.Context(), id)
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to retrieve packet"})
 		return
 	}
@@ -112,11 +180,17 @@ func (h *Handler) GetPacketByID(c *gin.Context) {
 // @Tags packets
 // @Param id path string true "Packet ID"
 // @Success 204 "Deleted"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /packets/{id} [delete]
 func (h *Handler) DeletePacketByID(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.packetService.DeletePacketByID(c.Request.Context(), id); err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to delete packet"})
 		return
 	}
@@ -128,10 +202,16 @@ func (h *Handler) DeletePacketByID(c *gin.Context) {
 // @Description Remove all packets from storage
 // @Tags packets
 // @Success 204 "Cleared"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /packets [delete]
 func (h *Handler) ClearPackets(c *gin.Context) {
 	if err := h.packetService.ClearPackets(c.Request.Context()); err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to clear packets"})
 		return
 	}
@@ -140,10 +220,11 @@ func (h *Handler) ClearPackets(c *gin.Context) {
 
 // Health handles GET /health
 // @Summary Health check
-// @Description Service health status
+// @Description Service health status. Always ok while the server answers; probes should use /healthz and /readyz, served outside the API base path.
 // @Tags system
 // @Produce json
 // @Success 200 {object} map[string]interface{}
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Router /health [get]
 func (h *Handler) Health(c *gin.Context) {
 	status := map[string]interface{}{
@@ -155,15 +236,20 @@ func (h *Handler) Health(c *gin.Context) {
 
 // Stats handles GET /stats
 // @Summary Storage statistics
-// @Description Get current storage statistics
+// @Description Get current storage statistics and rolling 1m/5m/1h traffic summaries
 // @Tags system
 // @Produce json
 // @Success 200 {object} models.Stats
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /stats [get]
 func (h *Handler) Stats(c *gin.Context) {
 	stats, err := h.packetService.StorageStats(c.Request.Context())
 	if err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to get stats"})
 		return
 	}
@@ -178,10 +264,16 @@ func (h *Handler) Stats(c *gin.Context) {
 // @Description Start the packet sniffing process
 // @Tags sniffing
 // @Success 204 "Started"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /sniffing/start [post]
 func (h *Handler) StartSniffing(c *gin.Context) {
 	if err := h.packetService.StartSniffing(c.Request.Context()); err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to start sniffing"})
 		return
 	}
@@ -193,10 +285,16 @@ func (h *Handler) StartSniffing(c *gin.Context) {
 // @Description Stop the packet sniffing process
 // @Tags sniffing
 // @Success 204 "Stopped"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /sniffing/stop [post]
 func (h *Handler) StopSniffing(c *gin.Context) {
 	if err := h.packetService.StopSniffing(c.Request.Context()); err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to stop sniffing"})
 		return
 	}
@@ -209,11 +307,139 @@ func (h *Handler) StopSniffing(c *gin.Context) {
 // @Tags sniffing
 // @Produce json
 // @Success 200 {object} map[string]bool
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
 // @Router /sniffing/status [get]
 func (h *Handler) SniffingStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, map[string]bool{"running": h.packetService.IsSniffingRunning()})
 }
 
+// GetSnifferConfig handles GET /sniffing/config
+// @Summary Sniffer configuration
+// @Description Get the capture parameters of the running sniffer
+// @Tags sniffing
+// @Produce json
+// @Success 200 {object} models.SnifferConfig
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 501 {object} ErrorResponse "Sniffer cannot be reconfigured"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /sniffing/config [get]
+func (h *Handler) GetSnifferConfig(c *gin.Context) {
+	config, err := h.packetService.SnifferConfig()
+	if err != nil {
+		c.JSON(http.StatusNotImplemented, ErrorResponse{Error: "Not Implemented", Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, config)
+}
+
+// UpdateSnifferConfig handles PATCH /sniffing/config
+// @Summary Reconfigure the sniffer
+// @Description Change the interval, rate profile, protocol weights, address pool or port pool of the sniffer without restarting it. Omitted fields are unchanged. The result is persisted for the next boot when SNIFFING_CONFIG_FILE is set.
+// @Tags sniffing
+// @Accept json
+// @Produce json
+// @Param config body models.SnifferConfigPatch true "Fields to change"
+// @Success 200 {object} models.SnifferConfig
+// @Failure 400 {object} ErrorResponse "Invalid configuration"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 501 {object} ErrorResponse "Sniffer cannot be reconfigured"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /sniffing/config [patch]
+func (h *Handler) UpdateSnifferConfig(c *gin.Context) {
+	var patch models.SnifferConfigPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+
+	config, err := h.packetService.UpdateSnifferConfig(c.Request.Context(), patch)
+	switch {
+	case errors.Is(err, sniffing.ErrInvalidConfig):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+	case errors.Is(err, services.ErrNotConfigurable):
+		c.JSON(http.StatusNotImplemented, ErrorResponse{Error: "Not Implemented", Message: err.Error()})
+	case err != nil:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to reconfigure the sniffer"})
+	default:
+		c.JSON(http.StatusOK, config)
+	}
+}
+
+// parsePacketFilter builds a packet filter from the request query string
+func parsePacketFilter(c *gin.Context) (*models.PacketFilter, error) {
+	filter := &models.PacketFilter{}
+
+	if protocol := c.Query("protocol"); protocol != "" {
+		filter.Protocol = protocol
+	}
+
+	if sourceIP := c.Query("source_ip"); sourceIP != "" {
+		filter.SourceIP = sourceIP
+	}
+
+	if destIP := c.Query("destination_ip"); destIP != "" {
+		filter.DestinationIP = destIP
+	}
+
+	filter.Session = c.Query("session")
+	filter.Agent = c.Query("agent")
+
+	if windowStr := c.Query("window"); windowStr != "" {
+		window, err := time.ParseDuration(windowStr)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid window %q", windowStr)
+		}
+		filter.FromTimestamp = time.Now().Add(-window)
+	}
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		if c.Query("window") != "" {
+			return nil, errors.New("window and from cannot both be set")
+		}
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from timestamp %q", fromStr)
+		}
+		filter.FromTimestamp = from
+	}
+
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to timestamp %q", toStr)
+		}
+		filter.ToTimestamp = to
+	}
+
+	if !filter.FromTimestamp.IsZero() && !filter.ToTimestamp.IsZero() && filter.FromTimestamp.After(filter.ToTimestamp) {
+		return nil, fmt.Errorf("from %s is after to %s", filter.FromTimestamp.Format(time.RFC3339), filter.ToTimestamp.Format(time.RFC3339))
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			filter.Offset = offset
+		}
+	}
+
+	return filter, nil
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
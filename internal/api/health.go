@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/health"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// WithHealth serves the liveness and readiness probes from the checks of
+// two checkers. Without it both probes report ok without checking
+// anything.
+func (h *Handler) WithHealth(liveness, readiness *health.Checker) *Handler {
+	h.liveness = liveness
+	h.readiness = readiness
+	return h
+}
+
+// Liveness handles GET /healthz. It fails only when the process should be
+// restarted.
+func (h *Handler) Liveness(c *gin.Context) {
+	h.probe(c, h.liveness)
+}
+
+// Readiness handles GET /readyz. It fails while any component is degraded
+// and traffic should be routed elsewhere.
+func (h *Handler) Readiness(c *gin.Context) {
+	h.probe(c, h.readiness)
+}
+
+// probe runs the checks of checker and answers 503 when one is degraded
+func (h *Handler) probe(c *gin.Context, checker *health.Checker) {
+	report := models.HealthReport{Status: models.HealthOK, Components: map[string]models.ComponentHealth{}}
+	if checker != nil {
+		report = checker.Run(c.Request.Context())
+	}
+
+	status := http.StatusOK
+	if report.Status != models.HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/ingest"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of a batch pushed to IngestPackets
+const (
+	maxBatchPackets = 10000
+	maxBatchSize    = 64 << 20
+)
+
+// IngestPacket handles POST /packets
+// @Summary Ingest packet
+// @Description Store a packet captured outside the service, such as by a remote agent or a test harness. It is validated like the packets of the sniffer, given a new ID, which replaces any ID of the request, and evaluated by the alert rules and detectors. Its timestamp must lie within the last 24 hours, or at most 5 minutes ahead; the session and agent of the request are ignored.
+// @Tags packets
+// @Accept json
+// @Produce json
+// @Param packet body models.Packet true "Packet"
+// @Success 201 {object} models.Packet "Stored packet"
+// @Failure 400 {object} ErrorResponse "Invalid packet"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /packets [post]
+func (h *Handler) IngestPacket(c *gin.Context) {
+	var packet models.Packet
+	if err := c.ShouldBindJSON(&packet); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+	// Ingested packets are live traffic of the service itself
+	packet.Session = ""
+	packet.Agent = ""
+
+	if err := h.packetService.IngestPacket(c.Request.Context(), &packet); err != nil {
+		if errors.Is(err, ingest.ErrInvalidPacket) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+			return
+		}
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to store packet"})
+		return
+	}
+	c.JSON(http.StatusCreated, packet)
+}
+
+// IngestPackets handles POST /packets/batch
+// @Summary Ingest packets
+// @Description Store a batch of packets captured outside the service, one JSON packet per line (NDJSON). Each line is validated and stored on its own under a new ID: the response reports the ID or the error of every non-blank line, and invalid lines do not prevent the others from being stored.
+// @Description Packets are validated like those of POST /packets, and their session and agent are ignored.
+// @Description A batch holds at most 10000 packets in 64 MiB, with lines of at most 1 MiB; larger batches are refused as a whole.
+// @Tags packets
+// @Accept application/x-ndjson
+// @Produce json
+// @Param packets body string true "Packets, one JSON object per line"
+// @Success 200 {object} models.IngestResponse "Outcome of every line"
+// @Failure 400 {object} ErrorResponse "Unreadable body"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 413 {object} ErrorResponse "Batch too large"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /packets/batch [post]
+func (h *Handler) IngestPackets(c *gin.Context) {
+	lines, err := ingest.ReadBatch(http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchSize), maxBatchPackets)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) || errors.Is(err, ingest.ErrTooManyPackets) || errors.Is(err, ingest.ErrLineTooLong) {
+			c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: "Request Entity Too Large", Message: err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+		return
+	}
+
+	// Store the packets that decoded, then report every line in order
+	var packets []*models.Packet
+	for _, line := range lines {
+		if line.Packet != nil {
+			line.Packet.Session = ""
+			line.Packet.Agent = ""
+			packets = append(packets, line.Packet)
+		}
+	}
+	errs := h.packetService.IngestPackets(c.Request.Context(), packets)
+
+	response := models.IngestResponse{Results: make([]models.IngestResult, len(lines))}
+	for i, line := range lines {
+		result := models.IngestResult{Line: line.Number}
+		err := line.Err
+		if line.Packet != nil {
+			err, errs = errs[0], errs[1:]
+		}
+		switch {
+		case err == nil:
+			result.ID = line.Packet.ID
+			response.Accepted++
+		case errors.Is(err, ingest.ErrInvalidPacket):
+			result.Error = err.Error()
+			response.Rejected++
+		default:
+			c.Error(err)
+			result.Error = "failed to store packet"
+			response.Rejected++
+		}
+		response.Results[i] = result
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// maxMultipartOverhead bounds the multipart framing and form fields sent
+// along with an uploaded capture file
+const maxMultipartOverhead = 1 << 20
+
+// CreateIngestJob handles POST /ingest/jobs
+// @Summary Upload capture
+// @Description Upload a pcap or pcapng file, optionally gzip compressed, in the "file" field of a multipart form. The file is decoded in the background into a new session: follow the returned job until it completes, then query its packets with the session parameter of the packet listing, export and analytics routes.
+// @Description Packets of a session are kept apart from live traffic: they are not evaluated by alert rules and detectors, and only returned when their session is requested. They share the storage capacity with live packets.
+// @Tags ingest
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "pcap or pcapng file"
+// @Success 202 {object} models.IngestJob "Queued job"
+// @Failure 400 {object} ErrorResponse "Missing or invalid file"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 413 {object} ErrorResponse "File too large"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} ErrorResponse "Internal server error"
+// @Failure 503 {object} ErrorResponse "Too many uploads waiting"
+// @Header 202 {string} Location "URL of the job"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /ingest/jobs [post]
+func (h *Handler) CreateIngestJob(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.jobs.MaxSize()+maxMultipartOverhead)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: "expected a multipart/form-data body"})
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: `missing "file" field`})
+			return
+		}
+		if err != nil {
+			uploadError(c, err)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		job, err := h.jobs.Upload(part.FileName(), part)
+		if err != nil {
+			uploadError(c, err)
+			return
+		}
+		h.logger.InfoContext(c.Request.Context(), "Capture uploaded", "job_id", job.ID, "session", job.Session, "file", job.FileName, "size", job.Size)
+		c.Header("Location", "/api/v1/ingest/jobs/"+job.ID)
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+}
+
+// uploadError responds to a refused or failed upload
+func uploadError(c *gin.Context, err error) {
+	var maxBytesError *http.MaxBytesError
+	switch {
+	case errors.Is(err, ingest.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: "Request Entity Too Large", Message: err.Error()})
+	case errors.As(err, &maxBytesError):
+		c.JSON(http.StatusRequestEntityTooLarge, ErrorResponse{Error: "Request Entity Too Large", Message: ingest.ErrTooLarge.Error()})
+	case errors.Is(err, ingest.ErrNotCapture):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+	case errors.Is(err, ingest.ErrQueueFull), errors.Is(err, ingest.ErrStopped):
+		c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "Service Unavailable", Message: err.Error()})
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, multipart.ErrMessageTooLarge):
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Bad Request", Message: err.Error()})
+	default:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Internal server error", Message: "Failed to store the upload"})
+	}
+}
+
+// GetIngestJobs handles GET /ingest/jobs
+// @Summary List ingestion jobs
+// @Description List the jobs decoding uploaded captures, newest first, with their progress, packet counts and decode errors
+// @Tags ingest
+// @Produce json
+// @Success 200 {object} models.IngestJobsResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /ingest/jobs [get]
+func (h *Handler) GetIngestJobs(c *gin.Context) {
+	jobs := h.jobs.List()
+	c.JSON(http.StatusOK, models.IngestJobsResponse{Jobs: jobs, Total: len(jobs)})
+}
+
+// GetIngestJob handles GET /ingest/jobs/:id
+// @Summary Get ingestion job
+// @Description Show the progress, packet counts and decode errors of the decoding of an uploaded capture
+// @Tags ingest
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.IngestJob
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Job not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /ingest/jobs/{id} [get]
+func (h *Handler) GetIngestJob(c *gin.Context) {
+	job, ok := h.jobs.Job(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not Found", Message: "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
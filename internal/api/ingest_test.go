@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/alerting"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/services"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// post serves a POST request with a JSON body
+func post(t *testing.T, handler http.Handler, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// packetJSON returns the JSON of a packet to 8.8.8.8 at ts
+func packetJSON(ts time.Time, extra string) string {
+	return `{"source_ip":"10.0.0.1","destination_ip":"8.8.8.8","protocol":"TCP","port":443,"size":60,` +
+		extra + `"timestamp":"` + ts.UTC().Format(time.RFC3339Nano) + `"}`
+}
+
+func TestIngest_IgnoresSessionAndAgent(t *testing.T) {
+	store := storage.NewInMemoryStorage(10)
+	packetService := services.NewPacketService(store, sniffing.NewPacketSniffer(store, time.Hour), nil)
+	engine := NewRouter(NewHandler(packetService, nil), nil).Setup()
+
+	recorder := post(t, engine, "/api/v1/packets", packetJSON(time.Now(), `"session":"upload_1","agent":"edge-1",`))
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	var packet models.Packet
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &packet))
+	assert.Empty(t, packet.Session)
+	assert.Empty(t, packet.Agent)
+
+	recorder = post(t, engine, "/api/v1/packets/batch", packetJSON(time.Now(), `"session":"upload_1","agent":"edge-1",`))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// Both packets are live traffic of the service
+	live, err := store.Get(context.Background(), &models.PacketFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, live.Total)
+	for _, stored := range live.Packets {
+		assert.Empty(t, stored.Agent)
+	}
+}
+
+func TestIngest_FutureTimestampKeepsAlertsFiring(t *testing.T) {
+	store := storage.NewInMemoryStorage(100)
+	alerts := alerting.NewEngine(10)
+	store.AddObserver(alerts)
+	_, err := alerts.CreateRule(models.AlertRule{
+		Name:       "Traffic to Google DNS",
+		Enabled:    true,
+		Conditions: []models.AlertCondition{{Field: "destination_ip", Operator: "eq", Value: "8.8.8.8"}},
+		Cooldown:   models.Duration(time.Minute),
+	})
+	require.NoError(t, err)
+	packetService := services.NewPacketService(store, sniffing.NewPacketSniffer(store, time.Hour), nil)
+	engine := NewRouter(NewHandler(packetService, nil).WithAlerting(alerts), nil).Setup()
+
+	// A packet dated far ahead is rejected, so it cannot hold the
+	// cooldown of its group
+	recorder := post(t, engine, "/api/v1/packets", packetJSON(time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), ""))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "timestamp is more than 5m0s in the future")
+	recorder = post(t, engine, "/api/v1/packets/batch", packetJSON(time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), ""))
+	var response models.IngestResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Rejected)
+	assert.Empty(t, alerts.Alerts(nil))
+
+	// Later matches still raise new alerts once the cooldown is over
+	start := time.Now().Add(-10 * time.Minute)
+	for _, ts := range []time.Time{start, start.Add(2 * time.Minute)} {
+		require.Equal(t, http.StatusCreated, post(t, engine, "/api/v1/packets", packetJSON(ts, "")).Code)
+	}
+	assert.Len(t, alerts.Alerts(nil), 2)
+}
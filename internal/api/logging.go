@@ -0,0 +1,113 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderRequestID carries the ID correlating a request with its log lines
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// quietRoutes are probed continuously, their successful requests are only
+// logged at debug level
+var quietRoutes = map[string]bool{
+	"/api/v1/health": true,
+	"/metrics":       true,
+	"/healthz":       true,
+	"/readyz":        true,
+}
+
+// requestID assigns every request an ID, reusing the one sent by the
+// client when it is printable and reasonably short. The ID is echoed in
+// the response and attached to the request context so every log line
+// written while serving the request carries it.
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(HeaderRequestID, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// accessLog writes one line per request once it has been served. Server
+// errors are logged at error level, client errors at warn level.
+func (r *Router) accessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		route := c.FullPath()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case quietRoutes[route]:
+			level = slog.LevelDebug
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if principal := principalFrom(c); principal != nil {
+			attrs = append(attrs, slog.String("principal", principal.Subject))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		r.logger.LogAttrs(c.Request.Context(), level, "HTTP request", attrs...)
+	}
+}
+
+// recovery turns a panic into a 500 response and logs it
+func (r *Router) recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		r.logger.ErrorContext(c.Request.Context(), "Recovered from panic", "panic", err, "path", c.Request.URL.Path)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Message: "An unexpected error occurred",
+		})
+	})
+}
+
+// validRequestID reports whether a client supplied request ID can be
+// reused as is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "req_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// instrument counts every request and observes its latency by route.
+// Requests that match no route are reported under the "unmatched" route
+// to keep the label cardinality bounded.
+func instrument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
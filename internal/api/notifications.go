@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications handles GET /notifications
+// @Summary Webhook delivery status
+// @Description Report the delivery state of every configured webhook endpoint and the size of the dead-letter queue
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} models.NotificationStatusResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /notifications [get]
+func (h *Handler) GetNotifications(c *gin.Context) {
+	c.JSON(http.StatusOK, models.NotificationStatusResponse{
+		Endpoints:   h.notifier.Status(),
+		DeadLetters: h.notifier.DeadLetterCount(),
+		Timestamp:   time.Now(),
+	})
+}
+
+// GetDeadLetters handles GET /notifications/dead-letters
+// @Summary List undelivered notifications
+// @Description List the notifications that exhausted their delivery attempts, oldest first
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} models.DeadLetterResponse
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /notifications/dead-letters [get]
+func (h *Handler) GetDeadLetters(c *gin.Context) {
+	letters := h.notifier.DeadLetters()
+	c.JSON(http.StatusOK, models.DeadLetterResponse{
+		DeadLetters: letters,
+		Total:       len(letters),
+		Timestamp:   time.Now(),
+	})
+}
+
+// ReplayDeadLetters handles POST /notifications/dead-letters/replay
+// @Summary Replay undelivered notifications
+// @Description Queue the dead letters again for delivery to their endpoint
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /notifications/dead-letters/replay [post]
+func (h *Handler) ReplayDeadLetters(c *gin.Context) {
+	replayed := h.notifier.Replay()
+	h.logger.InfoContext(c.Request.Context(), "Dead-lettered notifications replayed", "count", replayed)
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"replayed":  replayed,
+		"timestamp": time.Now(),
+	})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimits holds the budgets applied per client. Expensive routes scan
+// or aggregate stored packets; every other route uses the standard budget.
+type RateLimits struct {
+	Standard  ratelimit.Limit
+	Expensive ratelimit.Limit
+}
+
+// expensiveRoutes are the routes charged to the expensive budget
+var expensiveRoutes = map[string]bool{
+	"GET /api/v1/packets":                    true,
+	"GET /api/v1/packets/export":             true,
+	"POST /api/v1/packets/batch":             true,
+	"POST /api/v1/ingest/jobs":               true,
+	"GET /api/v1/analytics/top/:dimension":   true,
+	"GET /api/v1/audit":                      true,
+	"GET /api/v1/audit/verify":               true,
+	"GET /api/v1/detections":                 true,
+	"GET /api/v1/alerts":                     true,
+	"GET /api/v1/notifications/dead-letters": true,
+}
+
+// rateLimit charges every request to the budget of its client and rejects
+// it with 429 when the budget is exhausted. Clients are identified by
+// their API key or token subject, or by their IP when anonymous.
+func (r *Router) rateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r.standardLimiter == nil {
+			c.Next()
+			return
+		}
+
+		limiter := r.standardLimiter
+		if expensiveRoutes[c.Request.Method+" "+c.FullPath()] {
+			limiter = r.expensiveLimiter
+		}
+
+		decision := limiter.Allow(rateLimitKey(c))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		if !decision.Allowed {
+			abortRateLimited(c, decision)
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortRateLimited rejects a request whose client exhausted its budget
+func abortRateLimited(c *gin.Context, decision ratelimit.Decision) {
+	seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, ErrorResponse{
+		Error:   "Too Many Requests",
+		Message: "Rate limit exceeded, retry in " + strconv.Itoa(seconds) + "s",
+	})
+}
+
+// rateLimitKey identifies the client of a request
+func rateLimitKey(c *gin.Context) string {
+	principal := principalFrom(c)
+	switch {
+	case principal == nil || principal.Method == auth.MethodAnonymous:
+		return "ip:" + c.ClientIP()
+	case principal.KeyID != "":
+		return "key:" + principal.KeyID
+	default:
+		return principal.Method + ":" + principal.Subject
+	}
+}
@@ -1,6 +1,13 @@
 package api
 
 import (
+	"log/slog"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ratelimit"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -9,14 +16,65 @@ import (
 
 // Router sets up the HTTP router with all routes and middleware
 type Router struct {
-	handler *Handler
+	handler        *Handler
+	authenticator  *auth.Authenticator
+	corsOrigins    []string
+	trustedProxies []string
+	logger         *slog.Logger
+
+	standardLimiter  *ratelimit.Limiter
+	expensiveLimiter *ratelimit.Limiter
 }
 
-// NewRouter creates a new router instance
-func NewRouter(handler *Handler, logger interface{}) *Router {
+// NewRouter creates a new router instance. A nil logger selects the
+// default logger.
+func NewRouter(handler *Handler, logger *slog.Logger) *Router {
 	return &Router{
 		handler: handler,
+		logger:  logging.OrDefault(logger),
+	}
+}
+
+// WithAuth requires every API request except the health check to be
+// authenticated and authorizes it by role. Without it every request is
+// served as the anonymous admin.
+func (r *Router) WithAuth(authenticator *auth.Authenticator) *Router {
+	r.authenticator = authenticator
+	return r
+}
+
+// WithCORS allows cross-origin requests from the given origins. A "*"
+// entry allows any origin. Without it cross-origin requests are refused.
+func (r *Router) WithCORS(origins []string) *Router {
+	r.corsOrigins = origins
+	return r
+}
+
+// WithTrustedProxies trusts the X-Forwarded-For and X-Real-IP headers of
+// requests coming from the given addresses or CIDR ranges. Without it the
+// client IP used by rate limiting, logs and the audit log is the address
+// of the peer, so clients cannot choose it.
+func (r *Router) WithTrustedProxies(proxies []string) *Router {
+	r.trustedProxies = proxies
+	return r
+}
+
+// WithRateLimits limits the request rate of every client. Without it
+// requests are not limited.
+func (r *Router) WithRateLimits(limits RateLimits) *Router {
+	r.standardLimiter = ratelimit.NewLimiter(limits.Standard)
+	r.expensiveLimiter = ratelimit.NewLimiter(limits.Expensive)
+	return r
+}
+
+// UpdateRateLimits changes the budgets of a router set up with
+// WithRateLimits. It does nothing when requests are not limited.
+func (r *Router) UpdateRateLimits(limits RateLimits) {
+	if r.standardLimiter == nil {
+		return
 	}
+	r.standardLimiter.SetLimit(limits.Standard)
+	r.expensiveLimiter.SetLimit(limits.Expensive)
 }
 
 // Setup configures the router with all routes and middleware
@@ -24,36 +82,135 @@ func (r *Router) Setup() *gin.Engine {
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
 
-	// Create router
+	// Create router. Forwarding headers are only trusted from the
+	// configured proxies.
 	router := gin.New()
+	if err := router.SetTrustedProxies(r.trustedProxies); err != nil {
+		r.logger.Error("Invalid trusted proxies, trusting none", "error", err.Error())
+		router.SetTrustedProxies(nil)
+	}
 
 	// Add middleware
-	router.Use(gin.Recovery())
-	router.Use(cors.Default())
+	router.Use(requestID())
+	router.Use(r.accessLog())
+	router.Use(r.recovery())
+	router.Use(instrument())
+	router.Use(traceRequests())
+	if len(r.corsOrigins) > 0 {
+		router.Use(r.cors())
+	}
+
+	// Public routes
+	router.GET("/api/v1/health", r.rateLimit(), r.handler.Health)
+	router.GET("/healthz", r.handler.Liveness)
+	router.GET("/readyz", r.handler.Readiness)
 
-	// API routes
-	api := router.Group("/api/v1")
+	// Metrics are scraped with a viewer key when authentication is enabled
+	router.GET("/metrics", r.authenticate(), authorize(auth.RoleViewer), gin.WrapH(metrics.Handler()))
+
+	// API routes. Viewers may read everything but API keys and the audit
+	// log, analysts may also ingest packets and captures and control
+	// sniffing and alerting, admins may also delete packets, manage API keys and read
+	// the audit log. Mutating requests are audited, including rejected
+	// ones.
+	api := router.Group("/api/v1", r.audit(), r.authenticate(), r.rateLimit())
+	viewer := api.Group("", authorize(auth.RoleViewer))
+	analyst := api.Group("", authorize(auth.RoleAnalyst))
+	admin := api.Group("", authorize(auth.RoleAdmin))
 	{
 		// Packet routes
-		packets := api.Group("/packets")
+		packets := viewer.Group("/packets")
 		{
 			packets.GET("", r.handler.GetPackets)
+			packets.GET("/export", r.handler.ExportPackets)
 			packets.GET(":id", r.handler.GetPacketByID)
-			packets.DELETE(":id", r.handler.DeletePacketByID)
-			packets.DELETE("", r.handler.ClearPackets)
 		}
+		packetAdmin := admin.Group("/packets")
+		{
+			packetAdmin.DELETE(":id", r.handler.DeletePacketByID)
+			packetAdmin.DELETE("", r.handler.ClearPackets)
+		}
+		packetIngest := analyst.Group("/packets")
+		{
+			packetIngest.POST("", r.handler.IngestPacket)
+			packetIngest.POST("/batch", r.handler.IngestPackets)
+		}
+
+		// Ingestion routes
+		jobs := viewer.Group("/ingest/jobs")
+		{
+			jobs.GET("", r.handler.GetIngestJobs)
+			jobs.GET("/:id", r.handler.GetIngestJob)
+		}
+		analyst.POST("/ingest/jobs", r.handler.CreateIngestJob)
+
+		// Agent routes
+		viewer.GET("/agents", r.handler.GetAgents)
 
 		// Sniffing control routes
-		sniffing := api.Group("/sniffing")
+		sniffing := analyst.Group("/sniffing")
 		{
 			sniffing.POST("/start", r.handler.StartSniffing)
 			sniffing.POST("/stop", r.handler.StopSniffing)
-			sniffing.GET("/status", r.handler.SniffingStatus)
 		}
+		viewer.GET("/sniffing/status", r.handler.SniffingStatus)
+		viewer.GET("/sniffing/config", r.handler.GetSnifferConfig)
+		analyst.PATCH("/sniffing/config", r.handler.UpdateSnifferConfig)
 
-		// Health and stats
-		api.GET("/health", r.handler.Health)
-		api.GET("/stats", r.handler.Stats)
+		// Analytics routes
+		analytics := viewer.Group("/analytics")
+		{
+			analytics.GET("/top/:dimension", r.handler.TopN)
+		}
+
+		// Alerting routes
+		alerts := viewer.Group("/alerts")
+		{
+			alerts.GET("", r.handler.GetAlerts)
+			alerts.GET("/rules", r.handler.GetAlertRules)
+			alerts.GET("/rules/:id", r.handler.GetAlertRule)
+		}
+		alertRules := analyst.Group("/alerts/rules")
+		{
+			alertRules.POST("", r.handler.CreateAlertRule)
+			alertRules.PUT("/:id", r.handler.UpdateAlertRule)
+			alertRules.DELETE("/:id", r.handler.DeleteAlertRule)
+		}
+
+		// Detection routes
+		detections := viewer.Group("/detections")
+		{
+			detections.GET("", r.handler.GetDetections)
+			detections.GET("/baselines", r.handler.GetBaselines)
+			detections.GET("/:id", r.handler.GetDetection)
+		}
+
+		// Notification routes
+		notifications := viewer.Group("/notifications")
+		{
+			notifications.GET("", r.handler.GetNotifications)
+			notifications.GET("/dead-letters", r.handler.GetDeadLetters)
+		}
+		analyst.POST("/notifications/dead-letters/replay", r.handler.ReplayDeadLetters)
+
+		// Authentication routes
+		viewer.GET("/auth/whoami", r.handler.WhoAmI)
+		keys := admin.Group("/auth/keys")
+		{
+			keys.GET("", r.handler.GetAPIKeys)
+			keys.POST("", r.handler.CreateAPIKey)
+			keys.DELETE("/:id", r.handler.RevokeAPIKey)
+		}
+
+		// Audit routes
+		auditLog := admin.Group("/audit")
+		{
+			auditLog.GET("", r.handler.GetAudit)
+			auditLog.GET("/verify", r.handler.VerifyAudit)
+		}
+
+		// Stats
+		viewer.GET("/stats", r.handler.Stats)
 	}
 
 	// Swagger documentation
@@ -61,3 +218,21 @@ func (r *Router) Setup() *gin.Engine {
 
 	return router
 }
+
+// cors allows cross-origin requests from the configured origins
+func (r *Router) cors() gin.HandlerFunc {
+	config := cors.Config{
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization", auth.HeaderAPIKey, HeaderRequestID, "traceparent", "tracestate"},
+		ExposeHeaders: []string{"Content-Length", "Content-Disposition", "Location", "Retry-After", HeaderRequestID},
+		MaxAge:        12 * time.Hour,
+	}
+	for _, origin := range r.corsOrigins {
+		if origin == "*" {
+			config.AllowAllOrigins = true
+			return cors.New(config)
+		}
+	}
+	config.AllowOrigins = r.corsOrigins
+	return cors.New(config)
+}
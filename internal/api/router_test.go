@@ -0,0 +1,160 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/audit"
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ratelimit"
+	"github.com/cryptonextsecurity/network-sniffer/internal/services"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLimits allow two requests per client
+var testLimits = RateLimits{
+	Standard:  ratelimit.Limit{Rate: 0.001, Burst: 2},
+	Expensive: ratelimit.Limit{Rate: 0.001, Burst: 2},
+}
+
+// newTestRouter returns the engine of a router over empty storage
+func newTestRouter(configure func(r *Router)) *gin.Engine {
+	store := storage.NewInMemoryStorage(10)
+	packetService := services.NewPacketService(store, sniffing.NewPacketSniffer(store, time.Hour), nil)
+	router := NewRouter(NewHandler(packetService, nil), nil)
+	configure(router)
+	return router.Setup()
+}
+
+// request serves a GET request from remoteAddr, forwarded for forwardedFor
+// when it is set
+func request(engine *gin.Engine, path, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRouter_RateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	engine := newTestRouter(func(r *Router) { r.WithRateLimits(testLimits) })
+
+	// Rotating X-Forwarded-For does not give a client a fresh bucket
+	for _, forwardedFor := range []string{"203.0.113.1", "203.0.113.2"} {
+		assert.Equal(t, http.StatusOK, request(engine, "/api/v1/stats", "192.0.2.10:4000", forwardedFor).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request(engine, "/api/v1/stats", "192.0.2.10:4001", "203.0.113.3").Code)
+
+	// Other clients keep their own budget
+	assert.Equal(t, http.StatusOK, request(engine, "/api/v1/stats", "192.0.2.11:4000", "").Code)
+}
+
+func TestRouter_TrustedProxies(t *testing.T) {
+	engine := newTestRouter(func(r *Router) {
+		r.WithRateLimits(testLimits).WithTrustedProxies([]string{"192.0.2.0/24"})
+	})
+
+	// Clients behind a trusted proxy are identified by the forwarded IP
+	for _, forwardedFor := range []string{"203.0.113.1", "203.0.113.1", "203.0.113.2"} {
+		assert.Equal(t, http.StatusOK, request(engine, "/api/v1/stats", "192.0.2.10:4000", forwardedFor).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request(engine, "/api/v1/stats", "192.0.2.10:4000", "203.0.113.1").Code)
+}
+
+func TestRouter_RateLimitFailedAuthentication(t *testing.T) {
+	keys, err := auth.OpenKeyStore("")
+	require.NoError(t, err)
+	keys.AddStatic("ops", "nsk_admin", auth.RoleAdmin)
+	engine := newTestRouter(func(r *Router) {
+		r.WithRateLimits(testLimits).WithAuth(auth.NewAuthenticator(keys, nil))
+	})
+	withKey := func(remoteAddr, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-API-Key", key)
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// Guessed keys are charged to the client IP until it is throttled
+	assert.Equal(t, http.StatusUnauthorized, withKey("192.0.2.10:4000", "nsk_guess1"))
+	assert.Equal(t, http.StatusUnauthorized, withKey("192.0.2.10:4000", "nsk_guess2"))
+	assert.Equal(t, http.StatusTooManyRequests, withKey("192.0.2.10:4000", "nsk_guess3"))
+	assert.Equal(t, http.StatusTooManyRequests, request(engine, "/api/v1/stats", "192.0.2.10:4000", "").Code)
+
+	// Valid keys keep their own budget
+	assert.Equal(t, http.StatusOK, withKey("192.0.2.10:4000", "nsk_admin"))
+}
+
+func TestRouter_AuditClientIP(t *testing.T) {
+	auditLog, err := audit.Open("", 10)
+	require.NoError(t, err)
+	engine := newTestRouter(func(r *Router) {
+		r.handler.WithAudit(auditLog)
+		r.WithTrustedProxies([]string{"192.0.2.0/24"})
+	})
+	clearPackets := func(remoteAddr, forwardedFor string) {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/packets", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Forwarding headers of untrusted clients are not recorded
+	clearPackets("198.51.100.7:4000", "203.0.113.1")
+	// Behind a trusted proxy, the proxy is recorded next to the client
+	clearPackets("192.0.2.10:4000", "203.0.113.2")
+
+	entries := auditLog.List(&models.AuditFilter{})
+	require.Len(t, entries, 2)
+	assert.Equal(t, "203.0.113.2", entries[0].ClientIP)
+	assert.Equal(t, "192.0.2.10", entries[0].PeerIP)
+	assert.Equal(t, "198.51.100.7", entries[1].ClientIP)
+	assert.Empty(t, entries[1].PeerIP)
+}
+
+func TestRouter_MetricsRequireViewer(t *testing.T) {
+	assert.Equal(t, http.StatusOK, request(newTestRouter(func(*Router) {}), "/metrics", "192.0.2.10:4000", "").Code)
+
+	keys, err := auth.OpenKeyStore("")
+	require.NoError(t, err)
+	keys.AddStatic("prometheus", "nsk_viewer", auth.RoleViewer)
+	engine := newTestRouter(func(r *Router) { r.WithAuth(auth.NewAuthenticator(keys, nil)) })
+	assert.Equal(t, http.StatusUnauthorized, request(engine, "/metrics", "192.0.2.10:4000", "").Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer nsk_viewer")
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "sniffer_")
+}
+
+func TestRouter_PacketFilterTimeRange(t *testing.T) {
+	engine := newTestRouter(func(r *Router) {})
+
+	for query, expected := range map[string]int{
+		"window=5m": http.StatusOK,
+		"from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z": http.StatusOK,
+		"window=5m&to=2099-01-01T00:00:00Z":                 http.StatusOK,
+		"window=5m&from=2024-01-01T00:00:00Z":               http.StatusBadRequest,
+		"from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z": http.StatusBadRequest,
+		"window=1h&to=2024-01-01T00:00:00Z":                 http.StatusBadRequest,
+	} {
+		for _, path := range []string{"/api/v1/packets", "/api/v1/packets/export", "/api/v1/analytics/top/source_ip"} {
+			recorder := request(engine, path+"?"+query, "192.0.2.1:1234", "")
+			assert.Equal(t, expected, recorder.Code, "%s?%s: %s", path, query, recorder.Body.String())
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Package audit keeps a tamper-evident record of the mutating operations
+// performed through the API.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// DefaultHistorySize is the number of entries kept in memory when none is
+// configured
+const DefaultHistorySize = 10000
+
+// genesisHash is the previous hash of the first entry
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Log is an append-only, hash-chained audit log. Entries are appended to a
+// JSON lines file when one is configured; the most recent ones are also
+// kept in memory to serve queries.
+type Log struct {
+	mutex    sync.RWMutex
+	path     string
+	entries  []models.AuditEntry
+	size     int
+	sequence uint64
+	lastHash string
+	now      func() time.Time
+}
+
+// Open opens the audit log persisted at path, resuming its chain. An empty
+// path keeps the log in memory only.
+func Open(path string, size int) (*Log, error) {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	l := &Log{path: path, size: size, lastHash: genesisHash, now: time.Now}
+	if path == "" {
+		return l, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	err = readEntries(file, func(entry models.AuditEntry) error {
+		l.remember(entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Record completes an entry with its sequence number, timestamp and
+// hashes, and appends it to the log
+func (l *Log) Record(entry models.AuditEntry) (models.AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entry.Sequence = l.sequence + 1
+	entry.Timestamp = l.now().UTC()
+	entry.PrevHash = l.lastHash
+	hash, err := hashEntry(entry)
+	if err != nil {
+		return entry, err
+	}
+	entry.Hash = hash
+
+	if l.path != "" {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return entry, err
+		}
+		file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return entry, err
+		}
+		if _, err := file.Write(append(data, '\n')); err != nil {
+			file.Close()
+			return entry, err
+		}
+		if err := file.Close(); err != nil {
+			return entry, err
+		}
+	}
+
+	l.remember(entry)
+	return entry, nil
+}
+
+// List returns the entries in memory matching the filter, newest first
+func (l *Log) List(filter *models.AuditFilter) []models.AuditEntry {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	entries := make([]models.AuditEntry, 0)
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		e := l.entries[i]
+		if filter != nil {
+			if filter.Actor != "" && e.Actor != filter.Actor {
+				continue
+			}
+			if filter.Action != "" && e.Action != filter.Action {
+				continue
+			}
+			if filter.Outcome != "" && e.Outcome != filter.Outcome {
+				continue
+			}
+			if !filter.Since.IsZero() && e.Timestamp.Before(filter.Since) {
+				continue
+			}
+			if !filter.Until.IsZero() && e.Timestamp.After(filter.Until) {
+				continue
+			}
+		}
+
+		entries = append(entries, e)
+		if filter != nil && filter.Limit > 0 && len(entries) >= filter.Limit {
+			break
+		}
+	}
+	return entries
+}
+
+// Verify walks the chain and reports the first entry whose hash or link
+// does not match. A file-backed log is verified from the file, so entries
+// altered on disk are detected.
+func (l *Log) Verify() (*models.AuditVerification, error) {
+	result := &models.AuditVerification{Valid: true, Timestamp: time.Now()}
+
+	prev := genesisHash
+	var expected uint64 = 1
+	check := func(entry models.AuditEntry) error {
+		result.Entries++
+		reason := ""
+		hash, err := hashEntry(entry)
+		switch {
+		case err != nil:
+			return err
+		case entry.Sequence != expected:
+			reason = fmt.Sprintf("expected sequence %d, found %d", expected, entry.Sequence)
+		case entry.PrevHash != prev:
+			reason = "previous hash does not match the preceding entry"
+		case entry.Hash != hash:
+			reason = "entry hash does not match its content"
+		}
+		if reason != "" && result.Valid {
+			sequence := entry.Sequence
+			result.Valid = false
+			result.BrokenAt = &sequence
+			result.Reason = reason
+		}
+		prev = entry.Hash
+		expected = entry.Sequence + 1
+		return nil
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.path == "" {
+		if len(l.entries) > 0 {
+			// Older entries have been dropped from memory: verify from the
+			// oldest one kept
+			prev = l.entries[0].PrevHash
+			expected = l.entries[0].Sequence
+		}
+		for _, entry := range l.entries {
+			if err := check(entry); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := readEntries(file, check); err != nil {
+		return nil, err
+	}
+	if result.Valid && expected-1 != l.sequence {
+		// Entries were removed from the end of the file
+		result.Valid = false
+		result.BrokenAt = &expected
+		result.Reason = fmt.Sprintf("log ends at sequence %d, expected %d", expected-1, l.sequence)
+	}
+	return result, nil
+}
+
+// remember keeps an entry in memory and advances the chain
+func (l *Log) remember(entry models.AuditEntry) {
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > l.size {
+		l.entries = append(l.entries[:0], l.entries[len(l.entries)-l.size:]...)
+	}
+	l.sequence = entry.Sequence
+	l.lastHash = entry.Hash
+}
+
+// readEntries decodes a JSON lines audit file
+func readEntries(r io.Reader, fn func(models.AuditEntry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// hashEntry returns the hex SHA-256 of an entry's JSON encoding without its
+// own hash. The encoding includes the previous hash, which chains entries.
+func hashEntry(entry models.AuditEntry) (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
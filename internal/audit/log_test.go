@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func record(t *testing.T, l *Log, actor, action, outcome string) models.AuditEntry {
+	t.Helper()
+	entry, err := l.Record(models.AuditEntry{
+		Actor:      actor,
+		Action:     action,
+		Method:     "DELETE",
+		Path:       "/api/v1/packets",
+		Parameters: map[string]any{"id": "packet_1", "body": map[string]any{"threshold": 100.0}},
+		ClientIP:   "10.0.0.1",
+		Status:     204,
+		Outcome:    outcome,
+	})
+	require.NoError(t, err)
+	return entry
+}
+
+func TestLog_RecordAndList(t *testing.T) {
+	l, err := Open("", 0)
+	require.NoError(t, err)
+
+	first := record(t, l, "alice", "packets.clear", models.AuditSuccess)
+	second := record(t, l, "bob", "sniffing.stop", models.AuditDenied)
+	record(t, l, "alice", "sniffing.stop", models.AuditSuccess)
+
+	assert.EqualValues(t, 1, first.Sequence)
+	assert.Equal(t, genesisHash, first.PrevHash)
+	assert.Equal(t, first.Hash, second.PrevHash)
+	assert.NotEqual(t, first.Hash, second.Hash)
+
+	entries := l.List(nil)
+	require.Len(t, entries, 3)
+	assert.EqualValues(t, 3, entries[0].Sequence)
+
+	assert.Len(t, l.List(&models.AuditFilter{Actor: "alice"}), 2)
+	assert.Len(t, l.List(&models.AuditFilter{Action: "sniffing.stop", Outcome: models.AuditSuccess}), 1)
+	assert.Len(t, l.List(&models.AuditFilter{Limit: 1}), 1)
+	assert.Empty(t, l.List(&models.AuditFilter{Until: first.Timestamp.Add(-time.Second)}))
+
+	result, err := l.Verify()
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.EqualValues(t, 3, result.Entries)
+}
+
+func TestLog_ResumesChainFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path, 0)
+	require.NoError(t, err)
+	last := record(t, l, "alice", "packets.clear", models.AuditSuccess)
+
+	l, err = Open(path, 0)
+	require.NoError(t, err)
+	next := record(t, l, "alice", "sniffing.start", models.AuditSuccess)
+	assert.EqualValues(t, 2, next.Sequence)
+	assert.Equal(t, last.Hash, next.PrevHash)
+
+	result, err := l.Verify()
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.EqualValues(t, 2, result.Entries)
+}
+
+func TestLog_DetectsTampering(t *testing.T) {
+	setup := func(t *testing.T) (*Log, string, []string) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		l, err := Open(path, 0)
+		require.NoError(t, err)
+		for _, actor := range []string{"alice", "bob", "carol", "dave"} {
+			record(t, l, actor, "packets.clear", models.AuditSuccess)
+		}
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return l, path, strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+	write := func(t *testing.T, path string, lines []string) {
+		require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+	}
+
+	tests := map[string]struct {
+		tamper   func([]string) []string
+		brokenAt uint64
+	}{
+		"altered entry": {
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"actor":"bob"`, `"actor":"mallory"`, 1)
+				return lines
+			},
+			brokenAt: 2,
+		},
+		"removed entry": {
+			tamper: func(lines []string) []string {
+				return append(lines[:1:1], lines[2:]...)
+			},
+			brokenAt: 3,
+		},
+		"truncated log": {
+			tamper: func(lines []string) []string {
+				return lines[:3]
+			},
+			brokenAt: 4,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			l, path, lines := setup(t)
+			write(t, path, tt.tamper(lines))
+
+			result, err := l.Verify()
+			require.NoError(t, err)
+			assert.False(t, result.Valid)
+			require.NotNil(t, result.BrokenAt)
+			assert.Equal(t, tt.brokenAt, *result.BrokenAt)
+			assert.NotEmpty(t, result.Reason)
+		})
+	}
+}
+
+func TestLog_MemoryHistoryIsBounded(t *testing.T) {
+	l, err := Open("", 2)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		record(t, l, "alice", "packets.clear", models.AuditSuccess)
+	}
+
+	entries := l.List(nil)
+	require.Len(t, entries, 2)
+	assert.EqualValues(t, 5, entries[0].Sequence)
+
+	// The kept suffix of the chain still verifies
+	result, err := l.Verify()
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.EqualValues(t, 2, result.Entries)
+}
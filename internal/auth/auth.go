@@ -0,0 +1,218 @@
+// Package auth authenticates API clients with API keys or JWT bearer
+// tokens and maps them to roles.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Role grants access to a group of routes. Each role includes the
+// permissions of the roles below it.
+type Role string
+
+// Roles, from least to most privileged
+const (
+	RoleViewer  Role = "viewer"
+	RoleAnalyst Role = "analyst"
+	RoleAdmin   Role = "admin"
+)
+
+// Authentication methods
+const (
+	MethodAPIKey    = "api_key"
+	MethodJWT       = "jwt"
+	MethodAnonymous = "anonymous"
+)
+
+// HeaderAPIKey is the request header carrying an API key
+const HeaderAPIKey = "X-API-Key"
+
+var (
+	// ErrUnauthenticated is returned when a request carries no credentials
+	ErrUnauthenticated = errors.New("missing credentials")
+
+	// ErrInvalidCredentials is returned when credentials are unknown,
+	// expired or malformed
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrInvalidRole is returned for unknown role names
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+// levels orders the roles by privilege
+var levels = map[Role]int{
+	RoleViewer:  1,
+	RoleAnalyst: 2,
+	RoleAdmin:   3,
+}
+
+// ParseRole validates a role name
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := levels[role]; !ok {
+		return "", fmt.Errorf("%w: %q (expected viewer, analyst or admin)", ErrInvalidRole, s)
+	}
+	return role, nil
+}
+
+// Allows reports whether the role grants the permissions of required
+func (r Role) Allows(required Role) bool {
+	return levels[r] >= levels[required]
+}
+
+// Principal is the authenticated client of a request
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    Role   `json:"role" swaggertype:"string" example:"analyst"`
+	Method  string `json:"method" example:"api_key"`
+	KeyID   string `json:"key_id,omitempty"`
+}
+
+// Anonymous is the principal of every request when authentication is
+// disabled
+var Anonymous = &Principal{Subject: "anonymous", Role: RoleAdmin, Method: MethodAnonymous}
+
+// JWTConfig holds the settings used to verify bearer tokens. Tokens must
+// be signed with HS256 using Secret and carry "sub", "role" and "exp"
+// claims.
+type JWTConfig struct {
+	Secret   string
+	Issuer   string
+	Audience string
+}
+
+// Authenticator resolves the principal of a request from its API key or
+// bearer token
+type Authenticator struct {
+	keys *KeyStore
+	jwt  *JWTConfig
+}
+
+// NewAuthenticator creates an authenticator. Either source may be nil to
+// disable it.
+func NewAuthenticator(keys *KeyStore, jwtConfig *JWTConfig) *Authenticator {
+	if jwtConfig != nil && jwtConfig.Secret == "" {
+		jwtConfig = nil
+	}
+	return &Authenticator{keys: keys, jwt: jwtConfig}
+}
+
+// Keys returns the API key store
+func (a *Authenticator) Keys() *KeyStore {
+	return a.keys
+}
+
+// Authenticate resolves the principal of a request. API keys are read
+// from the X-API-Key header or an "ApiKey" authorization scheme; JWTs from
+// a "Bearer" authorization scheme.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	return a.AuthenticateCredentials(r.Header.Get(HeaderAPIKey), r.Header.Get("Authorization"))
+}
+
+// AuthenticateCredentials resolves a principal from the values of the
+// X-API-Key and Authorization headers, or of their equivalent in other
+// protocols. Either value may be empty.
+func (a *Authenticator) AuthenticateCredentials(key, header string) (*Principal, error) {
+	if key != "" {
+		return a.authenticateKey(key)
+	}
+
+	if header == "" {
+		return nil, ErrUnauthenticated
+	}
+	scheme, credentials, _ := strings.Cut(header, " ")
+	credentials = strings.TrimSpace(credentials)
+	switch strings.ToLower(scheme) {
+	case "apikey":
+		return a.authenticateKey(credentials)
+	case "bearer":
+		// API keys are accepted as bearer tokens too since they are easy
+		// to tell apart from JWTs
+		if strings.HasPrefix(credentials, KeyPrefix) {
+			return a.authenticateKey(credentials)
+		}
+		return a.authenticateJWT(credentials)
+	default:
+		return nil, fmt.Errorf("%w: unsupported authorization scheme %q", ErrInvalidCredentials, scheme)
+	}
+}
+
+// authenticateKey looks up an API key
+func (a *Authenticator) authenticateKey(key string) (*Principal, error) {
+	if a.keys == nil {
+		return nil, fmt.Errorf("%w: API keys are not accepted", ErrInvalidCredentials)
+	}
+	apiKey := a.keys.Verify(key)
+	if apiKey == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Subject: apiKey.Name, Role: Role(apiKey.Role), Method: MethodAPIKey, KeyID: apiKey.ID}, nil
+}
+
+// claims are the JWT claims read by the authenticator
+type claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// authenticateJWT verifies a bearer token
+func (a *Authenticator) authenticateJWT(token string) (*Principal, error) {
+	if a.jwt == nil {
+		return nil, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidCredentials)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if a.jwt.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.jwt.Issuer))
+	}
+	if a.jwt.Audience != "" {
+		options = append(options, jwt.WithAudience(a.jwt.Audience))
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (interface{}, error) {
+		return []byte(a.jwt.Secret), nil
+	}, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	role, err := ParseRole(c.Role)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: c.Subject, Role: role, Method: MethodJWT}, nil
+}
+
+// IssueToken signs a token for a subject and role. It is mainly meant for
+// tests and tooling; production tokens are expected to come from an
+// identity provider sharing the secret.
+func IssueToken(config JWTConfig, subject string, role Role, ttl time.Duration) (string, error) {
+	now := time.Now()
+	c := claims{
+		Role: string(role),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    config.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	if config.Audience != "" {
+		c.Audience = jwt.ClaimStrings{config.Audience}
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(config.Secret))
+}
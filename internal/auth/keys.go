@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// KeyPrefix starts every generated API key
+const KeyPrefix = "nsk_"
+
+// displayPrefixLength is the number of key characters kept to identify a
+// key in listings
+const displayPrefixLength = len(KeyPrefix) + 6
+
+var (
+	// ErrKeyNotFound is returned when a key ID does not exist
+	ErrKeyNotFound = errors.New("API key not found")
+
+	// ErrStaticKey is returned when revoking a key defined in the
+	// configuration rather than through the API
+	ErrStaticKey = errors.New("API key is defined in the configuration")
+)
+
+// storedKey is an API key as persisted: only the SHA-256 of the secret is
+// kept
+type storedKey struct {
+	models.APIKey
+	Hash string `json:"hash"`
+}
+
+// KeyStore holds the hashed API keys. Keys created through the store are
+// persisted to a JSON file when one is configured.
+type KeyStore struct {
+	mutex  sync.RWMutex
+	path   string
+	keys   map[string]*storedKey
+	byHash map[string]*storedKey
+	now    func() time.Time
+}
+
+// OpenKeyStore opens the key store persisted at path. An empty path keeps
+// the keys in memory only.
+func OpenKeyStore(path string) (*KeyStore, error) {
+	s := &KeyStore{
+		path:   path,
+		keys:   make(map[string]*storedKey),
+		byHash: make(map[string]*storedKey),
+		now:    time.Now,
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*storedKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, key := range keys {
+		if _, err := ParseRole(key.Role); err != nil {
+			return nil, fmt.Errorf("parse %s: key %s: %w", path, key.ID, err)
+		}
+		s.keys[key.ID] = key
+		s.byHash[key.Hash] = key
+	}
+	return s, nil
+}
+
+// AddStatic registers a key defined in the configuration. Static keys are
+// not persisted, cannot be revoked through the API and do not expose a
+// prefix since their secret may be short.
+func (s *KeyStore) AddStatic(name, secret string, role Role) {
+	key := &storedKey{
+		APIKey: models.APIKey{
+			ID:        "key_" + name,
+			Name:      name,
+			Role:      string(role),
+			Static:    true,
+			CreatedAt: s.now(),
+		},
+		Hash: hashKey(secret),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key
+}
+
+// Create generates a new key and returns its secret, which is not stored
+// and cannot be retrieved later
+func (s *KeyStore) Create(name string, role Role, ttl time.Duration) (string, *models.APIKey, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil, errors.New("name is required")
+	}
+	if _, ok := levels[role]; !ok {
+		return "", nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret := KeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	now := s.now()
+	key := &storedKey{
+		APIKey: models.APIKey{
+			ID:        newKeyID(),
+			Name:      name,
+			Role:      string(role),
+			Prefix:    displayPrefix(secret),
+			CreatedAt: now,
+		},
+		Hash: hashKey(secret),
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.keys[key.ID] = key
+	s.byHash[key.Hash] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, key.ID)
+		delete(s.byHash, key.Hash)
+		return "", nil, err
+	}
+	apiKey := key.APIKey
+	return secret, &apiKey, nil
+}
+
+// Revoke deletes a key
+func (s *KeyStore) Revoke(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if key.Static {
+		return ErrStaticKey
+	}
+
+	delete(s.keys, id)
+	delete(s.byHash, key.Hash)
+	if err := s.save(); err != nil {
+		s.keys[id] = key
+		s.byHash[key.Hash] = key
+		return err
+	}
+	return nil
+}
+
+// List returns the keys ordered by creation time
+func (s *KeyStore) List() []models.APIKey {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key.APIKey)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Verify returns the key matching a secret, or nil if it is unknown or
+// expired
+func (s *KeyStore) Verify(secret string) *models.APIKey {
+	hash := hashKey(secret)
+	now := s.now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, ok := s.byHash[hash]
+	if !ok || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil
+	}
+	// Last use is tracked in memory only to avoid a write per request
+	key.LastUsedAt = &now
+	apiKey := key.APIKey
+	return &apiKey
+}
+
+// save writes the keys created through the API to the store file
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	keys := make([]*storedKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if !key.Static {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// hashKey returns the hex SHA-256 of a key secret. Generated keys carry
+// 192 bits of entropy so a fast hash is sufficient.
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// displayPrefix returns the beginning of a key shown in listings
+func displayPrefix(secret string) string {
+	if len(secret) > displayPrefixLength {
+		return secret[:displayPrefixLength]
+	}
+	return secret
+}
+
+// newKeyID returns a random key identifier
+func newKeyID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "key_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,194 @@
+// Package collector tracks the remote capture agents forwarding packets to
+// a collector.
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// MaxIDLength bounds the length of agent IDs
+const MaxIDLength = 64
+
+var (
+	// ErrInvalidAgentID is returned for an agent ID rejected by ValidateID
+	ErrInvalidAgentID = errors.New("invalid agent ID")
+
+	// ErrUnknownAgent is returned for an agent that did not register
+	ErrUnknownAgent = errors.New("agent not registered")
+
+	// ErrAgentOwned is returned when an agent ID is used by another
+	// principal than the one that registered it
+	ErrAgentOwned = errors.New("agent registered by another principal")
+
+	// ErrTooManyAgents is returned when registering an agent beyond the
+	// capacity of the registry
+	ErrTooManyAgents = errors.New("too many agents")
+)
+
+// ValidateID checks that an agent ID is made of letters, digits, dots,
+// dashes and underscores, and at most MaxIDLength long
+func ValidateID(id string) error {
+	if id == "" || len(id) > MaxIDLength {
+		return fmt.Errorf("%w: must hold 1 to %d characters", ErrInvalidAgentID, MaxIDLength)
+	}
+	for _, c := range id {
+		valid := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_'
+		if !valid {
+			return fmt.Errorf("%w: %q holds characters other than letters, digits, dots, dashes and underscores", ErrInvalidAgentID, id)
+		}
+	}
+	return nil
+}
+
+// Registry holds the registered agents. Each agent ID belongs to the
+// principal that registered it. It is safe for concurrent use.
+type Registry struct {
+	mutex     sync.Mutex
+	agents    map[string]*agent
+	maxAgents int
+	now       func() time.Time
+}
+
+// agent is the state of a registered agent. Batches are stored one at a
+// time so that duplicates are detected across concurrent streams.
+type agent struct {
+	info    models.Agent
+	owner   string
+	streams int
+
+	forwarding sync.Mutex
+	buffer     string
+	acked      uint64
+}
+
+// NewRegistry creates an empty registry holding at most maxAgents agents
+func NewRegistry(maxAgents int) *Registry {
+	return &Registry{
+		agents:    make(map[string]*agent),
+		maxAgents: maxAgents,
+		now:       time.Now,
+	}
+}
+
+// Register adds an agent owned by owner, or refreshes the hostname and
+// address of a registered one, and returns it
+func (r *Registry) Register(id, owner, hostname, address string) (models.Agent, error) {
+	if err := ValidateID(id); err != nil {
+		return models.Agent{}, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	entry, ok := r.agents[id]
+	switch {
+	case ok && entry.owner != owner:
+		return models.Agent{}, fmt.Errorf("%w: %s", ErrAgentOwned, id)
+	case !ok && len(r.agents) >= r.maxAgents:
+		return models.Agent{}, fmt.Errorf("%w: at most %d can be registered", ErrTooManyAgents, r.maxAgents)
+	case !ok:
+		entry = &agent{info: models.Agent{ID: id, RegisteredAt: now}, owner: owner}
+		r.agents[id] = entry
+	}
+	entry.info.Hostname = hostname
+	entry.info.Address = address
+	entry.info.LastSeenAt = now
+	return entry.info, nil
+}
+
+// Connect marks an agent as forwarding over a new stream of its owner.
+// Every successful call must be followed by a call to Disconnect once the
+// stream ends.
+func (r *Registry) Connect(id, owner string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entry, ok := r.agents[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownAgent, id)
+	}
+	if entry.owner != owner {
+		return fmt.Errorf("%w: %s", ErrAgentOwned, id)
+	}
+	entry.streams++
+	if entry.streams == 1 {
+		entry.info.Connected = true
+		metrics.CollectorAgentsConnected.Inc()
+	}
+	return nil
+}
+
+// Disconnect ends a stream opened by Connect
+func (r *Registry) Disconnect(id string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entry, ok := r.agents[id]
+	if !ok || entry.streams == 0 {
+		return
+	}
+	entry.streams--
+	if entry.streams == 0 {
+		entry.info.Connected = false
+		metrics.CollectorAgentsConnected.Dec()
+	}
+}
+
+// Forward stores a batch of an agent through store, unless its sequence
+// number was already acknowledged for the same buffer, and counts it.
+// duplicate reports a batch that was not stored again. A batch whose store
+// fails is not acknowledged, so that it is stored when it is resent.
+func (r *Registry) Forward(id, buffer string, sequence uint64, store func() (accepted, rejected int, err error)) (accepted, rejected int, duplicate bool, err error) {
+	r.mutex.Lock()
+	entry, ok := r.agents[id]
+	r.mutex.Unlock()
+	if !ok {
+		return 0, 0, false, fmt.Errorf("%w: %s", ErrUnknownAgent, id)
+	}
+
+	entry.forwarding.Lock()
+	defer entry.forwarding.Unlock()
+
+	duplicate = buffer == entry.buffer && sequence <= entry.acked
+	if duplicate {
+		metrics.CollectorBatches.WithLabelValues("duplicate").Inc()
+	} else {
+		if accepted, rejected, err = store(); err != nil {
+			return accepted, rejected, false, err
+		}
+		entry.buffer = buffer
+		entry.acked = sequence
+		metrics.CollectorBatches.WithLabelValues("stored").Inc()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entry.info.LastSeenAt = r.now()
+	if !duplicate {
+		entry.info.Batches++
+		entry.info.Packets += uint64(accepted)
+		entry.info.Rejected += uint64(rejected)
+	}
+	return accepted, rejected, duplicate, nil
+}
+
+// List returns the registered agents sorted by ID
+func (r *Registry) List() []models.Agent {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	agents := make([]models.Agent, 0, len(r.agents))
+	for _, entry := range r.agents {
+		agents = append(agents, entry.info)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+	return agents
+}
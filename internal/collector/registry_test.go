@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"edge-1", "host.example.com", "A_b-9"} {
+		assert.NoError(t, ValidateID(id), id)
+	}
+	for _, id := range []string{"", "edge 1", "edge/1", "édge", string(make([]byte, MaxIDLength+1))} {
+		assert.ErrorIs(t, ValidateID(id), ErrInvalidAgentID, id)
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewRegistry(10)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+
+	_, err := registry.Register("edge 1", "key:a", "", "")
+	assert.ErrorIs(t, err, ErrInvalidAgentID)
+
+	registered, err := registry.Register("edge-2", "key:a", "host-2", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, now, registered.RegisteredAt)
+	_, err = registry.Register("edge-1", "key:a", "host-1", "10.0.0.1")
+	require.NoError(t, err)
+
+	// Registering again refreshes the details but keeps the registration time
+	now = now.Add(time.Minute)
+	refreshed, err := registry.Register("edge-2", "key:a", "host-2b", "10.0.0.3")
+	require.NoError(t, err)
+	assert.Equal(t, registered.RegisteredAt, refreshed.RegisteredAt)
+	assert.Equal(t, now, refreshed.LastSeenAt)
+	assert.Equal(t, "host-2b", refreshed.Hostname)
+
+	agents := registry.List()
+	require.Len(t, agents, 2)
+	assert.Equal(t, "edge-1", agents[0].ID)
+	assert.Equal(t, "10.0.0.3", agents[1].Address)
+
+	// Agent IDs belong to the principal that registered them
+	_, err = registry.Register("edge-2", "key:b", "host-x", "10.0.0.9")
+	assert.ErrorIs(t, err, ErrAgentOwned)
+	assert.Equal(t, "host-2b", registry.List()[1].Hostname)
+}
+
+func TestRegistry_MaxAgents(t *testing.T) {
+	registry := NewRegistry(2)
+	for _, id := range []string{"edge-1", "edge-2"} {
+		_, err := registry.Register(id, "key:a", "", "")
+		require.NoError(t, err)
+	}
+	_, err := registry.Register("edge-3", "key:a", "", "")
+	assert.ErrorIs(t, err, ErrTooManyAgents)
+
+	// Registered agents can still refresh their registration
+	_, err = registry.Register("edge-1", "key:a", "host-1", "")
+	assert.NoError(t, err)
+}
+
+func TestRegistry_Connect(t *testing.T) {
+	registry := NewRegistry(10)
+	assert.ErrorIs(t, registry.Connect("edge-1", "key:a"), ErrUnknownAgent)
+
+	_, err := registry.Register("edge-1", "key:a", "", "")
+	require.NoError(t, err)
+	require.NoError(t, registry.Connect("edge-1", "key:a"))
+	require.NoError(t, registry.Connect("edge-1", "key:a"))
+	registry.Disconnect("edge-1")
+	assert.True(t, registry.List()[0].Connected)
+	registry.Disconnect("edge-1")
+	assert.False(t, registry.List()[0].Connected)
+
+	assert.ErrorIs(t, registry.Connect("edge-1", "key:b"), ErrAgentOwned)
+	assert.False(t, registry.List()[0].Connected)
+}
+
+func TestRegistry_Forward(t *testing.T) {
+	registry := NewRegistry(10)
+	stored := 0
+	store := func() (int, int, error) {
+		stored++
+		return 3, 1, nil
+	}
+
+	_, _, _, err := registry.Forward("edge-1", "buffer_a", 1, store)
+	assert.ErrorIs(t, err, ErrUnknownAgent)
+	_, err = registry.Register("edge-1", "key:a", "", "")
+	require.NoError(t, err)
+
+	accepted, rejected, duplicate, err := registry.Forward("edge-1", "buffer_a", 1, store)
+	require.NoError(t, err)
+	assert.Equal(t, 3, accepted)
+	assert.Equal(t, 1, rejected)
+	assert.False(t, duplicate)
+
+	// A batch resent after a broken stream is not stored again
+	_, _, duplicate, err = registry.Forward("edge-1", "buffer_a", 1, store)
+	require.NoError(t, err)
+	assert.True(t, duplicate)
+	assert.Equal(t, 1, stored)
+
+	// Sequence numbers restart with a new buffer
+	_, _, duplicate, err = registry.Forward("edge-1", "buffer_b", 1, store)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+	assert.Equal(t, 2, stored)
+
+	// A batch that failed to store is not acknowledged
+	_, _, _, err = registry.Forward("edge-1", "buffer_b", 2, func() (int, int, error) { return 0, 0, assert.AnError })
+	assert.ErrorIs(t, err, assert.AnError)
+	_, _, duplicate, err = registry.Forward("edge-1", "buffer_b", 2, store)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+
+	agent := registry.List()[0]
+	assert.Equal(t, uint64(3), agent.Batches)
+	assert.Equal(t, uint64(9), agent.Packets)
+	assert.Equal(t, uint64(3), agent.Rejected)
+}
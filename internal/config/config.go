@@ -1,34 +1,540 @@
+// Package config loads the service configuration from a YAML file and
+// environment variables.
 package config
 
 import (
-	"log"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// ErrInvalidConfig is returned for a configuration file or environment
+// variable holding a value that cannot be used
+var ErrInvalidConfig = errors.New("invalid configuration")
+
 // Config holds all application configuration
 type Config struct {
-	StorageMaxSize   int
-	SniffingInterval time.Duration
-	ServerPort       string
-	ShutdownTimeout  time.Duration
+	// File is the YAML file the configuration was read from, if any
+	File string `yaml:"-"`
+
+	// Mode is standalone, collector or agent
+	Mode string `yaml:"mode"`
+
+	Server     ServerConfig     `yaml:"server"`
+	GRPC       GRPCConfig       `yaml:"grpc"`
+	Logging    LoggingConfig    `yaml:"logging"`
+	Storage    StorageConfig    `yaml:"storage"`
+	Capture    CaptureConfig    `yaml:"capture"`
+	Alerting   AlertingConfig   `yaml:"alerting"`
+	Detection  DetectionConfig  `yaml:"detection"`
+	Webhooks   WebhooksConfig   `yaml:"webhooks"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Audit      AuditConfig      `yaml:"audit"`
+	Upload     UploadConfig     `yaml:"upload"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Health     HealthConfig     `yaml:"health"`
+	Collector  CollectorConfig  `yaml:"collector"`
+	Agent      AgentConfig      `yaml:"agent"`
+	FlowExport FlowExportConfig `yaml:"flow_export"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port               string        `yaml:"port"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout"`
+	CORSAllowedOrigins []string      `yaml:"cors_allowed_origins"`
+	TrustedProxies     []string      `yaml:"trusted_proxies"`
+}
+
+// GRPCConfig configures the gRPC server
+type GRPCConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	Port             string `yaml:"port"`
+	SubscriberBuffer int    `yaml:"subscriber_buffer"`
+	TLSCertFile      string `yaml:"tls_cert_file"`
+	TLSKeyFile       string `yaml:"tls_key_file"`
+}
+
+// LoggingConfig configures log records
+type LoggingConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// StorageConfig configures packet storage
+type StorageConfig struct {
+	Backend string `yaml:"backend"`
+	MaxSize int    `yaml:"max_size"`
+}
+
+// CaptureConfig configures the packet source
+type CaptureConfig struct {
+	Source          string        `yaml:"source"`
+	Interval        time.Duration `yaml:"interval"`
+	ScanProbability float64       `yaml:"scan_probability"`
+	ConfigFile      string        `yaml:"config_file"`
+}
+
+// AlertingConfig configures alert rule evaluation
+type AlertingConfig struct {
+	HistorySize int    `yaml:"history_size"`
+	RulesFile   string `yaml:"rules_file"`
+}
+
+// DetectionConfig configures scan and anomaly detection
+type DetectionConfig struct {
+	FindingsHistorySize int           `yaml:"findings_history_size"`
+	Scan                ScanConfig    `yaml:"scan"`
+	Anomaly             AnomalyConfig `yaml:"anomaly"`
+}
+
+// ScanConfig configures port scan and host sweep detection
+type ScanConfig struct {
+	Window              time.Duration `yaml:"window"`
+	VerticalThreshold   int           `yaml:"vertical_threshold"`
+	HorizontalThreshold int           `yaml:"horizontal_threshold"`
+	HalfOpenThreshold   int           `yaml:"half_open_threshold"`
+	Cooldown            time.Duration `yaml:"cooldown"`
+}
+
+// AnomalyConfig configures traffic baseline learning
+type AnomalyConfig struct {
+	Interval   time.Duration `yaml:"interval"`
+	Alpha      float64       `yaml:"alpha"`
+	ZThreshold float64       `yaml:"z_threshold"`
+	MinSamples int           `yaml:"min_samples"`
+	Cooldown   time.Duration `yaml:"cooldown"`
+}
+
+// WebhooksConfig configures notification delivery
+type WebhooksConfig struct {
+	URLs              []string      `yaml:"urls"`
+	Secret            string        `yaml:"secret"`
+	MaxAttempts       int           `yaml:"max_attempts"`
+	InitialBackoff    time.Duration `yaml:"initial_backoff"`
+	MaxBackoff        time.Duration `yaml:"max_backoff"`
+	Timeout           time.Duration `yaml:"timeout"`
+	QueueSize         int           `yaml:"queue_size"`
+	DeadLetterFile    string        `yaml:"dead_letter_file"`
+	DeadLetterMaxSize int           `yaml:"dead_letter_max_size"`
+}
+
+// AuthConfig configures API authentication
+type AuthConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	KeysFile    string `yaml:"keys_file"`
+	AdminKey    string `yaml:"admin_key"`
+	JWTSecret   string `yaml:"jwt_secret"`
+	JWTIssuer   string `yaml:"jwt_issuer"`
+	JWTAudience string `yaml:"jwt_audience"`
+}
+
+// AuditConfig configures the audit log
+type AuditConfig struct {
+	LogFile     string `yaml:"log_file"`
+	HistorySize int    `yaml:"history_size"`
+}
+
+// UploadConfig configures the ingestion of uploaded capture files
+type UploadConfig struct {
+	MaxSizeMB   int    `yaml:"max_size_mb"`
+	Dir         string `yaml:"dir"`
+	HistorySize int    `yaml:"history_size"`
+	MaxPackets  int    `yaml:"max_packets"`
 }
 
-// Load loads configuration from .env file and environment variables
-func Load() *Config {
+// RateLimitConfig configures per-client rate limiting
+type RateLimitConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	Rate           float64 `yaml:"rate"`
+	Burst          int     `yaml:"burst"`
+	ExpensiveRate  float64 `yaml:"expensive_rate"`
+	ExpensiveBurst int     `yaml:"expensive_burst"`
+}
+
+// TracingConfig configures trace export
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter"`
+	ServiceName  string  `yaml:"service_name"`
+	File         string  `yaml:"file"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint"`
+	OTLPInsecure bool    `yaml:"otlp_insecure"`
+	SampleRatio  float64 `yaml:"sample_ratio"`
+}
+
+// HealthConfig configures the readiness checks
+type HealthConfig struct {
+	CheckTimeout             time.Duration `yaml:"check_timeout"`
+	MinFreeDiskMB            int           `yaml:"min_free_disk_mb"`
+	NotificationBacklogRatio float64       `yaml:"notification_backlog_ratio"`
+}
+
+// CollectorConfig configures the collector mode
+type CollectorConfig struct {
+	MaxAgents int `yaml:"max_agents"`
+}
+
+// AgentConfig configures the agent mode: packets captured locally are
+// forwarded to the collector at CollectorAddress
+type AgentConfig struct {
+	ID               string        `yaml:"id"`
+	CollectorAddress string        `yaml:"collector_address"`
+	APIKey           string        `yaml:"api_key"`
+	Insecure         bool          `yaml:"insecure"`
+	CAFile           string        `yaml:"ca_file"`
+	BufferDir        string        `yaml:"buffer_dir"`
+	BufferMaxMB      int           `yaml:"buffer_max_mb"`
+	BatchSize        int           `yaml:"batch_size"`
+	FlushInterval    time.Duration `yaml:"flush_interval"`
+}
+
+// FlowExportConfig configures the export of flow records to the NetFlow v9
+// or IPFIX collectors of Collectors
+type FlowExportConfig struct {
+	Collectors       []string      `yaml:"collectors"`
+	Protocol         string        `yaml:"protocol"`
+	ActiveTimeout    time.Duration `yaml:"active_timeout"`
+	InactiveTimeout  time.Duration `yaml:"inactive_timeout"`
+	TemplateInterval time.Duration `yaml:"template_interval"`
+	MaxFlows         int           `yaml:"max_flows"`
+	DomainID         int           `yaml:"domain_id"`
+}
+
+// Default returns the configuration used when neither the file nor the
+// environment set a value
+func Default() *Config {
+	return &Config{
+		Mode: ModeStandalone,
+		Server: ServerConfig{
+			Port:            "8080",
+			ShutdownTimeout: 30 * time.Second,
+		},
+		GRPC:    GRPCConfig{Port: "50051", SubscriberBuffer: 1000},
+		Logging: LoggingConfig{Level: "info", Format: "text"},
+		Storage: StorageConfig{Backend: StorageMemory, MaxSize: 1000},
+		Capture: CaptureConfig{
+			Source:     CaptureSimulated,
+			Interval:   5 * time.Second,
+			ConfigFile: "sniffing.json",
+		},
+		Alerting: AlertingConfig{HistorySize: 1000},
+		Detection: DetectionConfig{
+			FindingsHistorySize: 1000,
+			Scan: ScanConfig{
+				Window:              time.Minute,
+				VerticalThreshold:   20,
+				HorizontalThreshold: 20,
+				HalfOpenThreshold:   20,
+				Cooldown:            5 * time.Minute,
+			},
+			Anomaly: AnomalyConfig{
+				Interval:   10 * time.Second,
+				Alpha:      0.1,
+				ZThreshold: 3,
+				MinSamples: 30,
+				Cooldown:   5 * time.Minute,
+			},
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:       5,
+			InitialBackoff:    time.Second,
+			MaxBackoff:        time.Minute,
+			Timeout:           10 * time.Second,
+			QueueSize:         1000,
+			DeadLetterFile:    "dead-letters.jsonl",
+			DeadLetterMaxSize: 10000,
+		},
+		Auth:   AuthConfig{Enabled: true},
+		Audit:  AuditConfig{HistorySize: 10000},
+		Upload: UploadConfig{MaxSizeMB: 100, HistorySize: 100, MaxPackets: 100000},
+		RateLimit: RateLimitConfig{
+			Enabled:        true,
+			Rate:           20,
+			Burst:          40,
+			ExpensiveRate:  2,
+			ExpensiveBurst: 10,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			ServiceName: "network-sniffer",
+			File:        "traces.jsonl",
+			SampleRatio: 1,
+		},
+		Health: HealthConfig{
+			CheckTimeout:             2 * time.Second,
+			MinFreeDiskMB:            100,
+			NotificationBacklogRatio: 0.9,
+		},
+		Collector: CollectorConfig{MaxAgents: 1000},
+		Agent: AgentConfig{
+			BufferDir:     "agent-buffer",
+			BufferMaxMB:   100,
+			BatchSize:     500,
+			FlushInterval: 5 * time.Second,
+		},
+		FlowExport: FlowExportConfig{
+			Protocol:         "ipfix",
+			ActiveTimeout:    time.Minute,
+			InactiveTimeout:  15 * time.Second,
+			TemplateInterval: time.Minute,
+			MaxFlows:         65536,
+		},
+	}
+}
+
+// Load loads the .env file of the environment, then builds the
+// configuration from the YAML file at path and environment variables, which
+// override the file. An empty path selects the CONFIG_FILE variable; when
+// that is empty too, only environment variables are read.
+func Load(path string) (*Config, error) {
 	// Load appropriate .env file
 	loadEnvFile(getEnvWithDefault("ENV", "development"))
 
-	// Return config with environment variables (override .env file values)
-	return &Config{
-		StorageMaxSize:   getEnvIntWithDefault("STORAGE_MAX_SIZE", 1000),
-		SniffingInterval: getEnvDurationWithDefault("SNIFFING_INTERVAL", 5*time.Second),
-		ServerPort:       getEnvWithDefault("SERVER_PORT", "8080"),
-		ShutdownTimeout:  getEnvDurationWithDefault("SERVER_SHUTDOWN_TIMEOUT", 30*time.Second),
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	return load(path)
+}
+
+// load builds and validates the configuration from the YAML file at path,
+// if any, and environment variables
+func load(path string) (*Config, error) {
+	config := Default()
+	if path != "" {
+		if err := config.readFile(path); err != nil {
+			return nil, err
+		}
+		config.File = path
+	}
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// readFile decodes the YAML file at path over the current values. Unknown
+// keys are rejected so that typos do not go unnoticed.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
 	}
+	return nil
+}
+
+// envVar binds an environment variable to a configuration value
+type envVar struct {
+	name string
+	set  func(value string) error
+}
+
+// envVars lists the environment variables overriding the file
+func (c *Config) envVars() []envVar {
+	return []envVar{
+		stringVar("SERVICE_MODE", &c.Mode),
+
+		stringVar("SERVER_PORT", &c.Server.Port),
+		durationVar("SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout),
+		listVar("CORS_ALLOWED_ORIGINS", &c.Server.CORSAllowedOrigins),
+		listVar("TRUSTED_PROXIES", &c.Server.TrustedProxies),
+
+		boolVar("GRPC_ENABLED", &c.GRPC.Enabled),
+		stringVar("GRPC_PORT", &c.GRPC.Port),
+		intVar("GRPC_SUBSCRIBER_BUFFER", &c.GRPC.SubscriberBuffer),
+		stringVar("GRPC_TLS_CERT_FILE", &c.GRPC.TLSCertFile),
+		stringVar("GRPC_TLS_KEY_FILE", &c.GRPC.TLSKeyFile),
+
+		stringVar("LOG_LEVEL", &c.Logging.Level),
+		stringVar("LOG_FORMAT", &c.Logging.Format),
+
+		stringVar("STORAGE_BACKEND", &c.Storage.Backend),
+		intVar("STORAGE_MAX_SIZE", &c.Storage.MaxSize),
+
+		stringVar("CAPTURE_SOURCE", &c.Capture.Source),
+		durationVar("SNIFFING_INTERVAL", &c.Capture.Interval),
+		floatVar("SNIFFING_SCAN_PROBABILITY", &c.Capture.ScanProbability),
+		stringVar("SNIFFING_CONFIG_FILE", &c.Capture.ConfigFile),
+
+		intVar("ALERT_HISTORY_SIZE", &c.Alerting.HistorySize),
+		stringVar("ALERT_RULES_FILE", &c.Alerting.RulesFile),
+
+		intVar("FINDINGS_HISTORY_SIZE", &c.Detection.FindingsHistorySize),
+		durationVar("SCAN_WINDOW", &c.Detection.Scan.Window),
+		intVar("SCAN_VERTICAL_THRESHOLD", &c.Detection.Scan.VerticalThreshold),
+		intVar("SCAN_HORIZONTAL_THRESHOLD", &c.Detection.Scan.HorizontalThreshold),
+		intVar("SCAN_HALF_OPEN_THRESHOLD", &c.Detection.Scan.HalfOpenThreshold),
+		durationVar("SCAN_COOLDOWN", &c.Detection.Scan.Cooldown),
+		durationVar("ANOMALY_INTERVAL", &c.Detection.Anomaly.Interval),
+		floatVar("ANOMALY_ALPHA", &c.Detection.Anomaly.Alpha),
+		floatVar("ANOMALY_Z_THRESHOLD", &c.Detection.Anomaly.ZThreshold),
+		intVar("ANOMALY_MIN_SAMPLES", &c.Detection.Anomaly.MinSamples),
+		durationVar("ANOMALY_COOLDOWN", &c.Detection.Anomaly.Cooldown),
+
+		listVar("WEBHOOK_URLS", &c.Webhooks.URLs),
+		stringVar("WEBHOOK_SECRET", &c.Webhooks.Secret),
+		intVar("WEBHOOK_MAX_ATTEMPTS", &c.Webhooks.MaxAttempts),
+		durationVar("WEBHOOK_INITIAL_BACKOFF", &c.Webhooks.InitialBackoff),
+		durationVar("WEBHOOK_MAX_BACKOFF", &c.Webhooks.MaxBackoff),
+		durationVar("WEBHOOK_TIMEOUT", &c.Webhooks.Timeout),
+		intVar("WEBHOOK_QUEUE_SIZE", &c.Webhooks.QueueSize),
+		stringVar("WEBHOOK_DEAD_LETTER_FILE", &c.Webhooks.DeadLetterFile),
+		intVar("WEBHOOK_DEAD_LETTER_MAX_SIZE", &c.Webhooks.DeadLetterMaxSize),
+
+		boolVar("AUTH_ENABLED", &c.Auth.Enabled),
+		stringVar("AUTH_KEYS_FILE", &c.Auth.KeysFile),
+		stringVar("AUTH_ADMIN_KEY", &c.Auth.AdminKey),
+		stringVar("AUTH_JWT_SECRET", &c.Auth.JWTSecret),
+		stringVar("AUTH_JWT_ISSUER", &c.Auth.JWTIssuer),
+		stringVar("AUTH_JWT_AUDIENCE", &c.Auth.JWTAudience),
+
+		stringVar("AUDIT_LOG_FILE", &c.Audit.LogFile),
+		intVar("AUDIT_HISTORY_SIZE", &c.Audit.HistorySize),
+
+		intVar("UPLOAD_MAX_SIZE_MB", &c.Upload.MaxSizeMB),
+		stringVar("UPLOAD_DIR", &c.Upload.Dir),
+		intVar("UPLOAD_HISTORY_SIZE", &c.Upload.HistorySize),
+		intVar("UPLOAD_MAX_PACKETS", &c.Upload.MaxPackets),
+
+		boolVar("RATE_LIMIT_ENABLED", &c.RateLimit.Enabled),
+		floatVar("RATE_LIMIT_RATE", &c.RateLimit.Rate),
+		intVar("RATE_LIMIT_BURST", &c.RateLimit.Burst),
+		floatVar("RATE_LIMIT_EXPENSIVE_RATE", &c.RateLimit.ExpensiveRate),
+		intVar("RATE_LIMIT_EXPENSIVE_BURST", &c.RateLimit.ExpensiveBurst),
+
+		stringVar("TRACING_EXPORTER", &c.Tracing.Exporter),
+		stringVar("TRACING_SERVICE_NAME", &c.Tracing.ServiceName),
+		stringVar("TRACING_FILE", &c.Tracing.File),
+		stringVar("TRACING_OTLP_ENDPOINT", &c.Tracing.OTLPEndpoint),
+		boolVar("TRACING_OTLP_INSECURE", &c.Tracing.OTLPInsecure),
+		floatVar("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio),
+
+		durationVar("HEALTH_CHECK_TIMEOUT", &c.Health.CheckTimeout),
+		intVar("HEALTH_MIN_FREE_DISK_MB", &c.Health.MinFreeDiskMB),
+		floatVar("HEALTH_NOTIFICATION_BACKLOG_RATIO", &c.Health.NotificationBacklogRatio),
+
+		intVar("COLLECTOR_MAX_AGENTS", &c.Collector.MaxAgents),
+
+		stringVar("AGENT_ID", &c.Agent.ID),
+		stringVar("AGENT_COLLECTOR_ADDRESS", &c.Agent.CollectorAddress),
+		stringVar("AGENT_API_KEY", &c.Agent.APIKey),
+		boolVar("AGENT_INSECURE", &c.Agent.Insecure),
+		stringVar("AGENT_CA_FILE", &c.Agent.CAFile),
+		stringVar("AGENT_BUFFER_DIR", &c.Agent.BufferDir),
+		intVar("AGENT_BUFFER_MAX_MB", &c.Agent.BufferMaxMB),
+		intVar("AGENT_BATCH_SIZE", &c.Agent.BatchSize),
+		durationVar("AGENT_FLUSH_INTERVAL", &c.Agent.FlushInterval),
+
+		listVar("FLOW_EXPORT_COLLECTORS", &c.FlowExport.Collectors),
+		stringVar("FLOW_EXPORT_PROTOCOL", &c.FlowExport.Protocol),
+		durationVar("FLOW_EXPORT_ACTIVE_TIMEOUT", &c.FlowExport.ActiveTimeout),
+		durationVar("FLOW_EXPORT_INACTIVE_TIMEOUT", &c.FlowExport.InactiveTimeout),
+		durationVar("FLOW_EXPORT_TEMPLATE_INTERVAL", &c.FlowExport.TemplateInterval),
+		intVar("FLOW_EXPORT_MAX_FLOWS", &c.FlowExport.MaxFlows),
+		intVar("FLOW_EXPORT_DOMAIN_ID", &c.FlowExport.DomainID),
+	}
+}
+
+// applyEnv overrides the values of the set environment variables. Every
+// variable that cannot be parsed is reported.
+func (c *Config) applyEnv() error {
+	var problems []string
+	for _, variable := range c.envVars() {
+		value := os.Getenv(variable.name)
+		if value == "" {
+			continue
+		}
+		if err := variable.set(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s=%q %v", variable.name, value, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func stringVar(name string, target *string) envVar {
+	return envVar{name: name, set: func(value string) error {
+		*target = value
+		return nil
+	}}
+}
+
+func intVar(name string, target *int) envVar {
+	return envVar{name: name, set: func(value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("is not an integer")
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+func floatVar(name string, target *float64) envVar {
+	return envVar{name: name, set: func(value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("is not a number")
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+func boolVar(name string, target *bool) envVar {
+	return envVar{name: name, set: func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("is not a boolean")
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+func durationVar(name string, target *time.Duration) envVar {
+	return envVar{name: name, set: func(value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("is not a duration")
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+// listVar binds a comma-separated list
+func listVar(name string, target *[]string) envVar {
+	return envVar{name: name, set: func(value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*target = list
+		return nil
+	}}
 }
 
 // loadEnvFile loads the appropriate .env file based on environment
@@ -41,19 +547,19 @@ func loadEnvFile(env string) {
 
 	// Try to load the environment-specific file
 	if err := godotenv.Load(envFile); err == nil {
-		log.Printf("Loaded configuration from %s", envFile)
+		slog.Info("Loaded configuration", "file", envFile)
 		return
 	}
 
 	// Fallback for production: try development file
 	if env == "production" {
 		if err := godotenv.Load(".env.development"); err == nil {
-			log.Println("No .env.production found, using .env.development")
+			slog.Info("No .env.production found, using .env.development")
 			return
 		}
 	}
 
-	log.Println("No .env file found, using environment variables only")
+	slog.Info("No .env file found, using environment variables only")
 }
 
 // getEnvWithDefault returns environment variable value or default if not set
@@ -63,23 +569,3 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
-
-// getEnvIntWithDefault returns environment variable as int or default if not set
-func getEnvIntWithDefault(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
-	}
-	return defaultValue
-}
-
-// getEnvDurationWithDefault returns environment variable as duration or default if not set
-func getEnvDurationWithDefault(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
-		}
-	}
-	return defaultValue
-}
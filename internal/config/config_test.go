@@ -0,0 +1,247 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	config, err := load("")
+	require.NoError(t, err)
+	assert.Equal(t, Default(), config)
+}
+
+func TestLoad_FileAndEnvironment(t *testing.T) {
+	path := writeFile(t, `
+server:
+  port: "9090"
+  cors_allowed_origins: ["https://console.example.com"]
+storage:
+  max_size: 5000
+capture:
+  interval: 2s
+webhooks:
+  urls: [https://hooks.example.com/a]
+rate_limit:
+  burst: 80
+`)
+	t.Setenv("STORAGE_MAX_SIZE", "250")
+	t.Setenv("WEBHOOK_URLS", "https://hooks.example.com/b, https://hooks.example.com/c")
+
+	config, err := load(path)
+	require.NoError(t, err)
+	assert.Equal(t, path, config.File)
+	assert.Equal(t, "9090", config.Server.Port)
+	assert.Equal(t, []string{"https://console.example.com"}, config.Server.CORSAllowedOrigins)
+	assert.Equal(t, 2*time.Second, config.Capture.Interval)
+	assert.Equal(t, 80, config.RateLimit.Burst)
+
+	// Environment variables override the file
+	assert.Equal(t, 250, config.Storage.MaxSize)
+	assert.Equal(t, []string{"https://hooks.example.com/b", "https://hooks.example.com/c"}, config.Webhooks.URLs)
+
+	// Settings absent from both keep their default
+	assert.Equal(t, 30*time.Second, config.Server.ShutdownTimeout)
+	assert.Equal(t, 20.0, config.RateLimit.Rate)
+}
+
+func TestLoad_InvalidEnvironment(t *testing.T) {
+	t.Setenv("STORAGE_MAX_SIZE", "abc")
+	t.Setenv("AUTH_ENABLED", "sometimes")
+
+	_, err := load("")
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, `STORAGE_MAX_SIZE="abc" is not an integer`)
+	assert.ErrorContains(t, err, `AUTH_ENABLED="sometimes" is not a boolean`)
+}
+
+func TestLoad_InvalidFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown key":    "server:\n  prot: \"9090\"\n",
+		"wrong type":     "storage:\n  max_size: lots\n",
+		"bad duration":   "capture:\n  interval: soon\n",
+		"not a document": "- server\n",
+	} {
+		_, err := load(writeFile(t, content))
+		assert.ErrorIs(t, err, ErrInvalidConfig, name)
+	}
+
+	_, err := load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoad_EmptyFile(t *testing.T) {
+	config, err := load(writeFile(t, ""))
+	require.NoError(t, err)
+	assert.Equal(t, Default().Server, config.Server)
+}
+
+func TestValidate(t *testing.T) {
+	config := Default()
+	config.Server.Port = "70000"
+	config.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy.example.com"}
+	config.GRPC.Enabled = true
+	config.GRPC.SubscriberBuffer = 0
+	config.Storage.Backend = "postgres"
+	config.Storage.MaxSize = 0
+	config.Capture.Interval = time.Millisecond
+	config.Webhooks.URLs = []string{"ftp://hooks.example.com"}
+	config.Webhooks.MaxBackoff = time.Millisecond
+	config.Auth.JWTIssuer = "issuer"
+	config.Upload.MaxSizeMB = 0
+	config.Tracing.Exporter = "jaeger"
+	config.Tracing.SampleRatio = 2
+
+	err := config.Validate()
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	for _, problem := range []string{
+		"server.port",
+		`server.trusted_proxies must hold IP addresses or CIDR ranges, got "proxy.example.com"`,
+		"grpc.subscriber_buffer must be positive",
+		`storage.backend must be one of memory, got "postgres"`,
+		"storage.max_size must be positive",
+		"capture.interval",
+		`webhooks.urls must hold http(s) URLs, got "ftp://hooks.example.com"`,
+		"webhooks.max_backoff",
+		"auth.jwt_issuer",
+		"upload.max_size_mb must be positive",
+		"tracing.exporter",
+		"tracing.sample_ratio",
+	} {
+		assert.ErrorContains(t, err, problem)
+	}
+
+	config = Default()
+	config.GRPC.Enabled = true
+	config.GRPC.Port = config.Server.Port
+	assert.ErrorContains(t, config.Validate(), "grpc.port must differ from server.port")
+
+	config = Default()
+	config.Mode = "relay"
+	config.GRPC.Enabled = true
+	config.GRPC.TLSCertFile = "server.crt"
+	assert.ErrorContains(t, config.Validate(), `mode must be one of standalone, collector, agent, got "relay"`)
+	assert.ErrorContains(t, config.Validate(), "grpc.tls_cert_file and grpc.tls_key_file must be set together")
+
+	config = Default()
+	config.Mode = ModeCollector
+	config.Collector.MaxAgents = 0
+	assert.ErrorContains(t, config.Validate(), "collector mode requires grpc.enabled")
+	assert.ErrorContains(t, config.Validate(), "collector.max_agents must be positive")
+
+	// Agent settings are only checked in agent mode
+	config = Default()
+	config.Agent = AgentConfig{ID: "edge 1", Insecure: true, CAFile: "ca.pem"}
+	assert.NoError(t, config.Validate())
+	config.Mode = ModeAgent
+	err = config.Validate()
+	for _, problem := range []string{
+		"agent.id",
+		"agent.collector_address is required",
+		"agent.ca_file cannot be used with agent.insecure",
+		"agent.buffer_dir is required",
+		"agent.buffer_max_mb must be positive",
+		"agent.batch_size",
+		"agent.flush_interval",
+	} {
+		assert.ErrorContains(t, err, problem)
+	}
+
+	// Flow export settings are only checked when there are collectors
+	config = Default()
+	config.FlowExport = FlowExportConfig{Protocol: "sflow", DomainID: -1}
+	assert.NoError(t, config.Validate())
+	config.FlowExport.Collectors = []string{"127.0.0.1:2055", "flows.example.com", ":4739"}
+	err = config.Validate()
+	for _, problem := range []string{
+		`flow_export.collectors must hold host:port addresses, got "flows.example.com"`,
+		`flow_export.collectors must hold host:port addresses, got ":4739"`,
+		`flow_export.protocol must be one of netflow9, ipfix, got "sflow"`,
+		"flow_export.active_timeout",
+		"flow_export.inactive_timeout",
+		"flow_export.template_interval",
+		"flow_export.max_flows must be positive",
+		"flow_export.domain_id must be between 0 and 4294967295",
+	} {
+		assert.ErrorContains(t, err, problem)
+	}
+	assert.NotContains(t, err.Error(), "127.0.0.1:2055")
+
+	// Rate limits are not checked when limiting is disabled
+	config = Default()
+	config.RateLimit = RateLimitConfig{Enabled: false}
+	assert.NoError(t, config.Validate())
+}
+
+func TestChanges(t *testing.T) {
+	previous := Default()
+	next := Default()
+	assert.Empty(t, Changes(previous, next))
+
+	next.Logging.Level = "debug"
+	next.Detection.Scan.Window = time.Hour
+	next.Server.CORSAllowedOrigins = []string{"*"}
+	next.File = "other.yaml"
+	assert.Equal(t, []string{
+		"server.cors_allowed_origins",
+		"logging.level",
+		"detection.scan.window",
+	}, Changes(previous, next))
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	path := writeFile(t, "logging:\n  level: info\n")
+	current, err := load(path)
+	require.NoError(t, err)
+
+	var reloads []*Config
+	watcher := NewWatcher(current, time.Millisecond, nil).
+		OnReload(func(previous, next *Config) {
+			assert.NotEqual(t, previous.Logging.Level, next.Logging.Level)
+			reloads = append(reloads, next)
+		})
+
+	// Unchanged files do not call the handlers
+	require.NoError(t, watcher.Reload())
+	assert.Empty(t, reloads)
+
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: debug\n"), 0o600))
+	require.NoError(t, watcher.Reload())
+	require.Len(t, reloads, 1)
+	assert.Equal(t, "debug", watcher.Current().Logging.Level)
+
+	// Settings that require a restart keep their running value
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: debug\nserver:\n  port: \"9090\"\n"), 0o600))
+	require.NoError(t, watcher.Reload())
+	assert.Len(t, reloads, 1)
+	assert.Equal(t, "8080", watcher.Current().Server.Port)
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: warn\nserver:\n  port: \"9090\"\n"), 0o600))
+	require.NoError(t, watcher.Reload())
+	require.Len(t, reloads, 2)
+	assert.Equal(t, "warn", watcher.Current().Logging.Level)
+	assert.Equal(t, "8080", watcher.Current().Server.Port)
+
+	// An invalid file keeps the current configuration
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: loud\n"), 0o600))
+	assert.ErrorIs(t, watcher.Reload(), ErrInvalidConfig)
+	assert.Len(t, reloads, 2)
+	assert.Equal(t, "warn", watcher.Current().Logging.Level)
+}
+
+func TestLoad_Example(t *testing.T) {
+	config, err := load(filepath.Join("..", "..", "config.example.yaml"))
+	require.NoError(t, err)
+	assert.Empty(t, Changes(Default(), config))
+}
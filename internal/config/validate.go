@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/collector"
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/netflow"
+	"github.com/cryptonextsecurity/network-sniffer/internal/tracing"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+)
+
+// Service modes: a standalone service captures and analyses packets, a
+// collector also receives the packets of agents, which forward the
+// packets they capture instead of storing them
+const (
+	ModeStandalone = "standalone"
+	ModeCollector  = "collector"
+	ModeAgent      = "agent"
+)
+
+// Supported storage backends
+const (
+	StorageMemory = "memory"
+)
+
+// Supported capture sources
+const (
+	CaptureSimulated = "simulated"
+)
+
+// Validate checks every value and reports all the problems found at once
+func (c *Config) Validate() error {
+	var v validator
+
+	v.oneOf("mode", c.Mode, ModeStandalone, ModeCollector, ModeAgent)
+
+	port, err := strconv.Atoi(c.Server.Port)
+	v.check(err == nil && port > 0 && port <= 65535, "server.port must be a port number between 1 and 65535")
+	v.positiveDuration("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	for _, origin := range c.Server.CORSAllowedOrigins {
+		v.check(origin == "*" || isHTTPURL(origin), "server.cors_allowed_origins must hold \"*\" or http(s) origins, got %q", origin)
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		v.check(isIPOrCIDR(proxy), "server.trusted_proxies must hold IP addresses or CIDR ranges, got %q", proxy)
+	}
+
+	if c.GRPC.Enabled {
+		grpcPort, err := strconv.Atoi(c.GRPC.Port)
+		v.check(err == nil && grpcPort > 0 && grpcPort <= 65535, "grpc.port must be a port number between 1 and 65535")
+		v.check(err != nil || grpcPort != port, "grpc.port must differ from server.port")
+		v.positive("grpc.subscriber_buffer", c.GRPC.SubscriberBuffer)
+		v.check((c.GRPC.TLSCertFile == "") == (c.GRPC.TLSKeyFile == ""), "grpc.tls_cert_file and grpc.tls_key_file must be set together")
+	}
+	v.check(c.Mode != ModeCollector || c.GRPC.Enabled, "collector mode requires grpc.enabled")
+
+	_, err = logging.ParseLevel(c.Logging.Level)
+	v.check(err == nil, "logging.level must be debug, info, warn or error")
+	v.oneOf("logging.format", strings.ToLower(c.Logging.Format), logging.FormatText, logging.FormatJSON)
+
+	v.oneOf("storage.backend", c.Storage.Backend, StorageMemory)
+	v.positive("storage.max_size", c.Storage.MaxSize)
+
+	v.oneOf("capture.source", c.Capture.Source, CaptureSimulated)
+	v.check(c.Capture.Interval >= sniffing.MinInterval && c.Capture.Interval <= sniffing.MaxInterval,
+		"capture.interval must be between %s and %s", sniffing.MinInterval, sniffing.MaxInterval)
+	v.ratio("capture.scan_probability", c.Capture.ScanProbability)
+
+	v.positive("alerting.history_size", c.Alerting.HistorySize)
+
+	v.positive("detection.findings_history_size", c.Detection.FindingsHistorySize)
+	v.positiveDuration("detection.scan.window", c.Detection.Scan.Window)
+	v.positive("detection.scan.vertical_threshold", c.Detection.Scan.VerticalThreshold)
+	v.positive("detection.scan.horizontal_threshold", c.Detection.Scan.HorizontalThreshold)
+	v.positive("detection.scan.half_open_threshold", c.Detection.Scan.HalfOpenThreshold)
+	v.check(c.Detection.Scan.Cooldown >= 0, "detection.scan.cooldown must not be negative")
+	v.positiveDuration("detection.anomaly.interval", c.Detection.Anomaly.Interval)
+	v.check(c.Detection.Anomaly.Alpha > 0 && c.Detection.Anomaly.Alpha <= 1, "detection.anomaly.alpha must be greater than 0 and at most 1")
+	v.check(c.Detection.Anomaly.ZThreshold > 0, "detection.anomaly.z_threshold must be positive")
+	v.positive("detection.anomaly.min_samples", c.Detection.Anomaly.MinSamples)
+	v.check(c.Detection.Anomaly.Cooldown >= 0, "detection.anomaly.cooldown must not be negative")
+
+	for _, endpoint := range c.Webhooks.URLs {
+		v.check(isHTTPURL(endpoint), "webhooks.urls must hold http(s) URLs, got %q", endpoint)
+	}
+	v.positive("webhooks.max_attempts", c.Webhooks.MaxAttempts)
+	v.positiveDuration("webhooks.initial_backoff", c.Webhooks.InitialBackoff)
+	v.check(c.Webhooks.MaxBackoff >= c.Webhooks.InitialBackoff, "webhooks.max_backoff must be at least webhooks.initial_backoff")
+	v.positiveDuration("webhooks.timeout", c.Webhooks.Timeout)
+	v.positive("webhooks.queue_size", c.Webhooks.QueueSize)
+	v.positive("webhooks.dead_letter_max_size", c.Webhooks.DeadLetterMaxSize)
+
+	v.check(c.Auth.JWTSecret != "" || (c.Auth.JWTIssuer == "" && c.Auth.JWTAudience == ""),
+		"auth.jwt_issuer and auth.jwt_audience require auth.jwt_secret")
+
+	v.positive("audit.history_size", c.Audit.HistorySize)
+
+	v.positive("upload.max_size_mb", c.Upload.MaxSizeMB)
+	v.positive("upload.history_size", c.Upload.HistorySize)
+	v.positive("upload.max_packets", c.Upload.MaxPackets)
+
+	if c.RateLimit.Enabled {
+		v.check(c.RateLimit.Rate > 0, "rate_limit.rate must be positive")
+		v.positive("rate_limit.burst", c.RateLimit.Burst)
+		v.check(c.RateLimit.ExpensiveRate > 0, "rate_limit.expensive_rate must be positive")
+		v.positive("rate_limit.expensive_burst", c.RateLimit.ExpensiveBurst)
+	}
+
+	v.oneOf("tracing.exporter", c.Tracing.Exporter,
+		tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterFile, tracing.ExporterOTLP)
+	v.check(c.Tracing.Exporter != tracing.ExporterFile || c.Tracing.File != "", "tracing.file is required by the file exporter")
+	v.ratio("tracing.sample_ratio", c.Tracing.SampleRatio)
+
+	v.positiveDuration("health.check_timeout", c.Health.CheckTimeout)
+	v.check(c.Health.MinFreeDiskMB >= 0, "health.min_free_disk_mb must not be negative")
+	v.check(c.Health.NotificationBacklogRatio > 0 && c.Health.NotificationBacklogRatio <= 1,
+		"health.notification_backlog_ratio must be greater than 0 and at most 1")
+
+	if c.Mode == ModeCollector {
+		v.positive("collector.max_agents", c.Collector.MaxAgents)
+	}
+
+	if c.Mode == ModeAgent {
+		v.check(c.Agent.ID == "" || collector.ValidateID(c.Agent.ID) == nil,
+			"agent.id must hold at most %d letters, digits, dots, dashes and underscores", collector.MaxIDLength)
+		v.check(c.Agent.CollectorAddress != "", "agent.collector_address is required in agent mode")
+		v.check(!c.Agent.Insecure || c.Agent.CAFile == "", "agent.ca_file cannot be used with agent.insecure")
+		v.check(c.Agent.BufferDir != "", "agent.buffer_dir is required in agent mode")
+		v.positive("agent.buffer_max_mb", c.Agent.BufferMaxMB)
+		v.check(c.Agent.BatchSize > 0 && c.Agent.BatchSize <= 10000, "agent.batch_size must be between 1 and 10000")
+		v.positiveDuration("agent.flush_interval", c.Agent.FlushInterval)
+	}
+
+	if len(c.FlowExport.Collectors) > 0 {
+		for _, address := range c.FlowExport.Collectors {
+			v.check(isHostPort(address), "flow_export.collectors must hold host:port addresses, got %q", address)
+		}
+		v.oneOf("flow_export.protocol", c.FlowExport.Protocol, netflow.ProtocolNetFlow9, netflow.ProtocolIPFIX)
+		v.positiveDuration("flow_export.active_timeout", c.FlowExport.ActiveTimeout)
+		v.positiveDuration("flow_export.inactive_timeout", c.FlowExport.InactiveTimeout)
+		v.positiveDuration("flow_export.template_interval", c.FlowExport.TemplateInterval)
+		v.positive("flow_export.max_flows", c.FlowExport.MaxFlows)
+		v.check(c.FlowExport.DomainID >= 0 && int64(c.FlowExport.DomainID) <= math.MaxUint32,
+			"flow_export.domain_id must be between 0 and %d", uint32(math.MaxUint32))
+	}
+
+	return v.err()
+}
+
+// validator collects the problems of a configuration
+type validator struct {
+	problems []string
+}
+
+func (v *validator) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) positive(key string, value int) {
+	v.check(value > 0, "%s must be positive", key)
+}
+
+func (v *validator) positiveDuration(key string, value time.Duration) {
+	v.check(value > 0, "%s must be a positive duration", key)
+}
+
+func (v *validator) ratio(key string, value float64) {
+	v.check(value >= 0 && value <= 1, "%s must be between 0 and 1", key)
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	v.check(false, "%s must be one of %s, got %q", key, strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(v.problems, "; "))
+}
+
+// isHTTPURL reports whether value is an absolute http or https URL
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// isHostPort reports whether value is a host and a port number
+func isHostPort(value string) bool {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil || host == "" {
+		return false
+	}
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number <= 65535
+}
+
+// isIPOrCIDR reports whether value is an IP address or a CIDR range
+func isIPOrCIDR(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	return net.ParseIP(value) != nil
+}
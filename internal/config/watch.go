@@ -0,0 +1,223 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+)
+
+// DefaultWatchInterval is how often the configuration file is checked for
+// changes
+const DefaultWatchInterval = 5 * time.Second
+
+// Reloadable lists the settings applied without a restart. A change to any
+// other setting is only logged.
+var Reloadable = map[string]bool{
+	"logging.level":              true,
+	"capture.interval":           true,
+	"capture.scan_probability":   true,
+	"rate_limit.rate":            true,
+	"rate_limit.burst":           true,
+	"rate_limit.expensive_rate":  true,
+	"rate_limit.expensive_burst": true,
+}
+
+// Watcher reloads the configuration when its file changes on disk or the
+// process receives SIGHUP, and applies the Reloadable settings of every
+// valid new configuration; the others keep their running value until a
+// restart. An invalid file is reported and the current configuration kept.
+type Watcher struct {
+	interval time.Duration
+	logger   *slog.Logger
+
+	mutex    sync.Mutex
+	current  *Config
+	modTime  time.Time
+	size     int64
+	handlers []func(previous, next *Config)
+}
+
+// NewWatcher creates a watcher of the file current was loaded from. A nil
+// logger selects the default logger.
+func NewWatcher(current *Config, interval time.Duration, logger *slog.Logger) *Watcher {
+	w := &Watcher{
+		interval: interval,
+		logger:   logging.OrDefault(logger),
+		current:  current,
+	}
+	w.modTime, w.size = w.stat()
+	return w
+}
+
+// OnReload registers a handler called with the previous and the new
+// configuration after each successful reload that changed a Reloadable
+// setting
+func (w *Watcher) OnReload(handler func(previous, next *Config)) *Watcher {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.handlers = append(w.handlers, handler)
+	return w
+}
+
+// Current returns the configuration in effect
+func (w *Watcher) Current() *Config {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.current
+}
+
+// Run reloads the configuration on SIGHUP and, when it was read from a
+// file, whenever the file changes, until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var poll <-chan time.Time
+	if w.Current().File != "" && w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			w.logger.Info("Received SIGHUP, reloading configuration")
+			w.Reload()
+		case <-poll:
+			w.mutex.Lock()
+			modTime, size := w.stat()
+			changed := !modTime.Equal(w.modTime) || size != w.size
+			w.mutex.Unlock()
+			if changed {
+				w.logger.Info("Configuration file changed, reloading", "file", w.Current().File)
+				w.Reload()
+			}
+		}
+	}
+}
+
+// Reload reads the configuration again and applies its Reloadable
+// settings. It returns the error of an invalid configuration, which is not
+// applied.
+func (w *Watcher) Reload() error {
+	w.mutex.Lock()
+	previous := w.current
+	w.modTime, w.size = w.stat()
+	w.mutex.Unlock()
+
+	next, err := load(previous.File)
+	if err != nil {
+		w.logger.Error("Configuration reload failed, keeping the current configuration", "error", err.Error())
+		return err
+	}
+
+	changes := Changes(previous, next)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var applied, pending []string
+	for _, key := range changes {
+		if Reloadable[key] {
+			applied = append(applied, key)
+		} else {
+			pending = append(pending, key)
+		}
+	}
+
+	if len(pending) > 0 {
+		w.logger.Warn("Configuration changes require a restart", "settings", strings.Join(pending, ","))
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+
+	// Settings that require a restart keep their running value
+	running := *previous
+	reload("", reflect.ValueOf(&running).Elem(), reflect.ValueOf(*next))
+
+	w.mutex.Lock()
+	w.current = &running
+	handlers := append([]func(previous, next *Config){}, w.handlers...)
+	w.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(previous, &running)
+	}
+	w.logger.Info("Configuration reloaded", "applied", strings.Join(applied, ","))
+	return nil
+}
+
+// stat returns the modification time and size of the configuration file.
+// The caller must hold the mutex.
+func (w *Watcher) stat() (time.Time, int64) {
+	if w.current.File == "" {
+		return time.Time{}, 0
+	}
+	info, err := os.Stat(w.current.File)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
+
+// Changes returns the keys of the settings that differ between two
+// configurations, such as "rate_limit.burst"
+func Changes(previous, next *Config) []string {
+	var changes []string
+	diff("", reflect.ValueOf(*previous), reflect.ValueOf(*next), &changes)
+	return changes
+}
+
+// reload copies the Reloadable fields of src to dst
+func reload(prefix string, dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		name := strings.Split(dst.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+
+		if dst.Field(i).Kind() == reflect.Struct {
+			reload(key+".", dst.Field(i), src.Field(i))
+		} else if Reloadable[key] {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// diff appends the keys of the differing fields of two structs
+func diff(prefix string, a, b reflect.Value, changes *[]string) {
+	for i := 0; i < a.NumField(); i++ {
+		name := strings.Split(a.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+
+		if a.Field(i).Kind() == reflect.Struct {
+			diff(key+".", a.Field(i), b.Field(i), changes)
+			continue
+		}
+		if a.Field(i).Kind() == reflect.Slice && a.Field(i).Len() == 0 && b.Field(i).Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			*changes = append(*changes, key)
+		}
+	}
+}
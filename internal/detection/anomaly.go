@@ -0,0 +1,370 @@
+package detection
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// DetectorAnomaly is the detector name of baseline deviation findings
+const DetectorAnomaly = "anomaly"
+
+// Baseline metrics learned for each host and port
+const (
+	MetricPacketRate  = "packet_rate"
+	MetricPacketSize  = "packet_size"
+	MetricNewPeerRate = "new_peer_rate"
+)
+
+const (
+	// maxGapIntervals bounds the idle intervals replayed after a traffic gap
+	maxGapIntervals = 100
+
+	// maxAnomalyPacketIDs caps the packet references kept per finding
+	maxAnomalyPacketIDs = 20
+)
+
+// minStdDev keeps z-scores meaningful for metrics that have barely varied
+var minStdDev = map[string]float64{
+	MetricPacketRate:  1,
+	MetricPacketSize:  16,
+	MetricNewPeerRate: 1,
+}
+
+// AnomalyConfig controls baseline learning and the deviation thresholds
+type AnomalyConfig struct {
+	Interval    time.Duration
+	Alpha       float64
+	ZThreshold  float64
+	MinSamples  int
+	Cooldown    time.Duration
+	MaxEntities int
+	MaxPeers    int
+}
+
+// DefaultAnomalyConfig returns 10s intervals with a 3 sigma threshold
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{
+		Interval:    10 * time.Second,
+		Alpha:       0.1,
+		ZThreshold:  3,
+		MinSamples:  30,
+		Cooldown:    5 * time.Minute,
+		MaxEntities: 10000,
+		MaxPeers:    1000,
+	}
+}
+
+// AnomalyDetector learns per-host and per-port baselines of packet rate,
+// packet size and new-peer rate as exponentially weighted moving averages,
+// and reports intervals that deviate beyond the configured z-score.
+// It implements storage.Observer.
+//
+// Closing an interval only evaluates the entities seen during it. Idle
+// intervals are learned by an entity when it is seen again or its baseline
+// is read, so a traffic gap does not replay every entity at once. Once
+// MaxEntities are tracked, the least recently seen entity is forgotten to
+// make room, unless it was seen in the open interval.
+type AnomalyDetector struct {
+	mutex    sync.Mutex
+	config   AnomalyConfig
+	findings *FindingStore
+	entities map[string]*entityState
+	recent   *list.List
+	active   []string
+	current  int64
+	cooldown map[string]time.Time
+}
+
+// NewAnomalyDetector creates an anomaly detector reporting into findings
+func NewAnomalyDetector(config AnomalyConfig, findings *FindingStore) *AnomalyDetector {
+	defaults := DefaultAnomalyConfig()
+	if config.Interval <= 0 {
+		config.Interval = defaults.Interval
+	}
+	if config.Alpha <= 0 || config.Alpha > 1 {
+		config.Alpha = defaults.Alpha
+	}
+	if config.ZThreshold <= 0 {
+		config.ZThreshold = defaults.ZThreshold
+	}
+	if config.MaxEntities <= 0 {
+		config.MaxEntities = defaults.MaxEntities
+	}
+	if config.MaxPeers <= 0 {
+		config.MaxPeers = defaults.MaxPeers
+	}
+	return &AnomalyDetector{
+		config:   config,
+		findings: findings,
+		entities: make(map[string]*entityState),
+		recent:   list.New(),
+		current:  -1,
+		cooldown: make(map[string]time.Time),
+	}
+}
+
+// OnStore accounts a stored packet to its source host and destination port
+func (d *AnomalyDetector) OnStore(packet *models.Packet) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	interval := packet.Timestamp.UnixNano() / int64(d.config.Interval)
+	if d.current < 0 {
+		d.current = interval
+	}
+	if interval > d.current {
+		d.advance(interval)
+	}
+
+	d.account("host:"+packet.SourceIP, packet.DestinationIP, packet)
+	d.account("port:"+strconv.Itoa(packet.Port), packet.SourceIP, packet)
+}
+
+// Baselines returns the learned baselines, optionally restricted to one
+// entity such as "host:10.0.0.1" or "port:443"
+func (d *AnomalyDetector) Baselines(entity string) []models.EntityBaseline {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	baselines := make([]models.EntityBaseline, 0)
+	for name, state := range d.entities {
+		if entity != "" && name != entity {
+			continue
+		}
+		if state.count == 0 {
+			d.catchUp(name, state)
+		}
+		baselines = append(baselines, models.EntityBaseline{
+			Entity: name,
+			Metrics: map[string]models.Baseline{
+				MetricPacketRate:  state.rate.baseline(),
+				MetricPacketSize:  state.size.baseline(),
+				MetricNewPeerRate: state.peers.baseline(),
+			},
+			LastSeen: state.lastSeen,
+		})
+	}
+	sort.Slice(baselines, func(i, j int) bool { return baselines[i].Entity < baselines[j].Entity })
+	return baselines
+}
+
+// account adds a packet to the open interval of an entity
+func (d *AnomalyDetector) account(name, peer string, packet *models.Packet) {
+	state, ok := d.entities[name]
+	if !ok {
+		if len(d.entities) >= d.config.MaxEntities && !d.evict() {
+			metrics.AnomalyEntitiesDropped.WithLabelValues("full").Inc()
+			return
+		}
+		state = &entityState{known: make(map[string]struct{}), closed: d.current}
+		state.element = d.recent.PushBack(name)
+		d.entities[name] = state
+	}
+
+	// The first packet of the interval folds the idle intervals since the
+	// entity was last seen
+	if state.count == 0 {
+		d.catchUp(name, state)
+		d.active = append(d.active, name)
+	}
+	d.recent.MoveToBack(state.element)
+
+	state.count++
+	state.bytes += float64(packet.Size)
+	if _, seen := state.known[peer]; !seen {
+		state.newPeers++
+		if len(state.known) < d.config.MaxPeers {
+			state.known[peer] = struct{}{}
+		}
+	}
+	if len(state.packetIDs) < maxAnomalyPacketIDs {
+		state.packetIDs = append(state.packetIDs, packet.ID)
+	}
+	if packet.Timestamp.After(state.lastSeen) {
+		state.lastSeen = packet.Timestamp
+	}
+}
+
+// evict forgets the least recently seen entity, unless it was seen in the
+// open interval, and reports whether it did
+func (d *AnomalyDetector) evict() bool {
+	front := d.recent.Front()
+	if front == nil {
+		return false
+	}
+	name := front.Value.(string)
+	if d.entities[name].count > 0 {
+		return false
+	}
+
+	d.recent.Remove(front)
+	delete(d.entities, name)
+	for _, metric := range []string{MetricPacketRate, MetricPacketSize, MetricNewPeerRate} {
+		delete(d.cooldown, name+"|"+metric)
+	}
+	metrics.AnomalyEntitiesDropped.WithLabelValues("idle").Inc()
+	return true
+}
+
+// advance closes the open interval of the entities seen during it. Their
+// idle intervals up to next are learned when they are seen again.
+func (d *AnomalyDetector) advance(next int64) {
+	for _, name := range d.active {
+		state := d.entities[name]
+		d.close(name, state, d.current)
+		state.closed = d.current + 1
+	}
+	clear(d.active)
+	d.active = d.active[:0]
+	d.current = next
+}
+
+// catchUp evaluates and learns from the idle intervals of an entity up to
+// the open interval, at most maxGapIntervals of them
+func (d *AnomalyDetector) catchUp(name string, state *entityState) {
+	closing := max(state.closed, d.current-maxGapIntervals)
+	for ; closing < d.current; closing++ {
+		d.close(name, state, closing)
+	}
+	state.closed = max(state.closed, d.current)
+}
+
+// close evaluates and learns from the observations of an entity during one
+// interval
+func (d *AnomalyDetector) close(name string, state *entityState, interval int64) {
+	end := time.Unix(0, (interval+1)*int64(d.config.Interval))
+
+	d.check(name, MetricPacketRate, &state.rate, float64(state.count), state, end)
+	if state.count > 0 {
+		d.check(name, MetricPacketSize, &state.size, state.bytes/float64(state.count), state, end)
+	}
+	d.check(name, MetricNewPeerRate, &state.peers, float64(state.newPeers), state, end)
+
+	state.rate.update(float64(state.count), d.config.Alpha)
+	if state.count > 0 {
+		state.size.update(state.bytes/float64(state.count), d.config.Alpha)
+	}
+	state.peers.update(float64(state.newPeers), d.config.Alpha)
+	state.reset()
+}
+
+// check raises a finding when observed deviates from the baseline
+func (d *AnomalyDetector) check(name, metric string, baseline *ewma, observed float64, state *entityState, end time.Time) {
+	if baseline.samples < d.config.MinSamples {
+		return
+	}
+
+	stdDev := math.Max(baseline.stdDev(), minStdDev[metric])
+	z := (observed - baseline.mean) / stdDev
+	if math.Abs(z) <= d.config.ZThreshold {
+		return
+	}
+
+	key := name + "|" + metric
+	if until, ok := d.cooldown[key]; ok && end.Before(until) {
+		return
+	}
+	d.cooldown[key] = end.Add(d.config.Cooldown)
+
+	finding := &models.Finding{
+		Detector:  DetectorAnomaly,
+		Type:      metric,
+		Severity:  severityFor(z, d.config.ZThreshold),
+		Summary:   fmt.Sprintf("%s %s of %.1f deviates from baseline %.1f±%.1f (z=%.1f)", name, strings.ReplaceAll(metric, "_", " "), observed, baseline.mean, stdDev, z),
+		Count:     state.count,
+		PacketIDs: append([]string(nil), state.packetIDs...),
+		Anomaly: &models.AnomalyDetail{
+			Entity:   name,
+			Metric:   metric,
+			Interval: d.config.Interval.String(),
+			Observed: observed,
+			Baseline: baseline.baseline(),
+			ZScore:   z,
+		},
+		FirstSeen: end.Add(-d.config.Interval),
+		LastSeen:  end,
+	}
+	if kind, value, ok := strings.Cut(name, ":"); ok {
+		if kind == "host" {
+			finding.SourceIP = value
+		} else if port, err := strconv.Atoi(value); err == nil {
+			finding.Port = port
+		}
+	}
+	d.findings.Add(finding)
+}
+
+// severityFor grades a deviation by how far it exceeds the threshold
+func severityFor(z, threshold float64) string {
+	switch a := math.Abs(z); {
+	case a > 3*threshold:
+		return "high"
+	case a > 2*threshold:
+		return "medium"
+	}
+	return "low"
+}
+
+// entityState accumulates the open interval and baselines of an entity.
+// The intervals before closed are learned.
+type entityState struct {
+	rate      ewma
+	size      ewma
+	peers     ewma
+	count     int
+	bytes     float64
+	newPeers  int
+	packetIDs []string
+	known     map[string]struct{}
+	lastSeen  time.Time
+	closed    int64
+	element   *list.Element
+}
+
+// reset clears the open interval counters
+func (s *entityState) reset() {
+	s.count = 0
+	s.bytes = 0
+	s.newPeers = 0
+	s.packetIDs = s.packetIDs[:0]
+}
+
+// ewma is an exponentially weighted moving mean and variance
+type ewma struct {
+	mean     float64
+	variance float64
+	samples  int
+}
+
+// update folds an observation into the moving statistics
+func (e *ewma) update(x, alpha float64) {
+	if e.samples == 0 {
+		e.mean = x
+		e.samples = 1
+		return
+	}
+	diff := x - e.mean
+	incr := alpha * diff
+	e.mean += incr
+	e.variance = (1 - alpha) * (e.variance + diff*incr)
+	e.samples++
+}
+
+// stdDev returns the moving standard deviation
+func (e *ewma) stdDev() float64 {
+	return math.Sqrt(e.variance)
+}
+
+// baseline exports the moving statistics
+func (e *ewma) baseline() models.Baseline {
+	return models.Baseline{Mean: e.mean, StdDev: e.stdDev(), Samples: e.samples}
+}
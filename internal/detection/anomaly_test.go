@@ -0,0 +1,171 @@
+package detection
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// steadyTraffic emits count packets per interval from src to a fixed set of
+// peers, with slightly varying volume and sizes
+func steadyTraffic(d *AnomalyDetector, src string, start time.Time, intervals int) time.Time {
+	interval := d.config.Interval
+	for i := 0; i < intervals; i++ {
+		base := start.Add(time.Duration(i) * interval)
+		count := 10 + i%3
+		for j := 0; j < count; j++ {
+			p := models.NewPacket(src, fmt.Sprintf("172.16.0.%d", j%3), "TCP", 443, 500+(i+j)%5*10)
+			p.Timestamp = base.Add(time.Duration(j) * time.Millisecond)
+			d.OnStore(p)
+		}
+	}
+	return start.Add(time.Duration(intervals) * interval)
+}
+
+// closeInterval flushes the open interval by storing an unrelated packet
+// at the start of the following one
+func closeInterval(d *AnomalyDetector, next time.Time) {
+	p := models.NewPacket("10.9.9.9", "10.9.9.8", "UDP", 9999, 100)
+	p.Timestamp = next
+	d.OnStore(p)
+}
+
+func newTestAnomalyDetector() (*AnomalyDetector, *FindingStore) {
+	findings := NewFindingStore(100)
+	return NewAnomalyDetector(DefaultAnomalyConfig(), findings), findings
+}
+
+func TestAnomalyDetector_SteadyTrafficIsQuiet(t *testing.T) {
+	detector, findings := newTestAnomalyDetector()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(detector, "10.0.0.1", start, 100)
+	closeInterval(detector, next)
+
+	assert.Empty(t, findings.List(&models.FindingFilter{Detector: DetectorAnomaly}))
+
+	baselines := detector.Baselines("host:10.0.0.1")
+	require.Len(t, baselines, 1)
+	rate := baselines[0].Metrics[MetricPacketRate]
+	assert.InDelta(t, 11, rate.Mean, 1.5)
+	assert.GreaterOrEqual(t, rate.Samples, 100)
+}
+
+func TestAnomalyDetector_RateSpike(t *testing.T) {
+	detector, findings := newTestAnomalyDetector()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(detector, "10.0.0.1", start, 60)
+	for j := 0; j < 100; j++ {
+		p := models.NewPacket("10.0.0.1", "172.16.0.1", "TCP", 443, 520)
+		p.Timestamp = next.Add(time.Duration(j) * time.Millisecond)
+		detector.OnStore(p)
+	}
+	closeInterval(detector, next.Add(detector.config.Interval))
+
+	list := findings.List(&models.FindingFilter{Detector: DetectorAnomaly, Type: MetricPacketRate, SourceIP: "10.0.0.1"})
+	require.Len(t, list, 1)
+	f := list[0]
+	require.NotNil(t, f.Anomaly)
+	assert.Equal(t, "host:10.0.0.1", f.Anomaly.Entity)
+	assert.Equal(t, 100.0, f.Anomaly.Observed)
+	assert.InDelta(t, 11, f.Anomaly.Baseline.Mean, 1)
+	assert.Greater(t, f.Anomaly.ZScore, 3.0)
+	assert.Equal(t, "high", f.Severity)
+	assert.Len(t, f.PacketIDs, maxAnomalyPacketIDs)
+}
+
+func TestAnomalyDetector_SizeShiftAndNewPeers(t *testing.T) {
+	detector, findings := newTestAnomalyDetector()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(detector, "10.0.0.1", start, 60)
+	// Same volume, but jumbo packets to hosts never contacted before
+	for j := 0; j < 11; j++ {
+		p := models.NewPacket("10.0.0.1", fmt.Sprintf("203.0.113.%d", j), "TCP", 443, 1500)
+		p.Timestamp = next.Add(time.Duration(j) * time.Millisecond)
+		detector.OnStore(p)
+	}
+	closeInterval(detector, next.Add(detector.config.Interval))
+
+	size := findings.List(&models.FindingFilter{Type: MetricPacketSize, SourceIP: "10.0.0.1"})
+	require.Len(t, size, 1)
+	assert.Equal(t, 1500.0, size[0].Anomaly.Observed)
+	assert.InDelta(t, 520, size[0].Anomaly.Baseline.Mean, 20)
+
+	peers := findings.List(&models.FindingFilter{Type: MetricNewPeerRate, SourceIP: "10.0.0.1"})
+	require.Len(t, peers, 1)
+	assert.Equal(t, 11.0, peers[0].Anomaly.Observed)
+
+	assert.Empty(t, findings.List(&models.FindingFilter{Type: MetricPacketRate, SourceIP: "10.0.0.1"}))
+}
+
+func TestAnomalyDetector_Cooldown(t *testing.T) {
+	detector, findings := newTestAnomalyDetector()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(detector, "10.0.0.1", start, 60)
+	// Two consecutive spikes inside the cooldown raise a single finding
+	for i := 0; i < 2; i++ {
+		base := next.Add(time.Duration(i) * detector.config.Interval)
+		for j := 0; j < 100; j++ {
+			p := models.NewPacket("10.0.0.1", "172.16.0.1", "TCP", 443, 520)
+			p.Timestamp = base.Add(time.Duration(j) * time.Millisecond)
+			detector.OnStore(p)
+		}
+	}
+	closeInterval(detector, next.Add(2*detector.config.Interval))
+
+	assert.Len(t, findings.List(&models.FindingFilter{Type: MetricPacketRate, SourceIP: "10.0.0.1"}), 1)
+}
+
+func TestAnomalyDetector_IdleIntervalsLearnedLazily(t *testing.T) {
+	detector, _ := newTestAnomalyDetector()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	next := steadyTraffic(detector, "10.0.0.1", start, 40)
+	// Ten quiet intervals only close the interval of the active entities
+	closeInterval(detector, next.Add(10*detector.config.Interval))
+	assert.Equal(t, 40, detector.entities["host:10.0.0.1"].rate.samples)
+
+	// The idle intervals are learned once the baseline is read
+	rate := detector.Baselines("host:10.0.0.1")[0].Metrics[MetricPacketRate]
+	assert.Equal(t, 50, rate.Samples)
+	assert.Less(t, rate.Mean, 5.0)
+}
+
+func TestAnomalyDetector_MaxEntities(t *testing.T) {
+	config := DefaultAnomalyConfig()
+	config.MaxEntities = 3
+	detector := NewAnomalyDetector(config, NewFindingStore(100))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	idle := testutil.ToFloat64(metrics.AnomalyEntitiesDropped.WithLabelValues("idle"))
+	full := testutil.ToFloat64(metrics.AnomalyEntitiesDropped.WithLabelValues("full"))
+
+	store := func(src string, interval int) {
+		p := models.NewPacket(src, "172.16.0.1", "TCP", 443, 500)
+		p.Timestamp = start.Add(time.Duration(interval) * config.Interval)
+		detector.OnStore(p)
+	}
+	store("10.0.0.1", 0)
+	store("10.0.0.2", 1)
+	// The least recently seen host makes room for a new one
+	store("10.0.0.2", 2)
+	store("10.0.0.3", 2)
+	// Every other entity is active: the new host is not tracked
+	store("10.0.0.4", 2)
+
+	var entities []string
+	for _, baseline := range detector.Baselines("") {
+		entities = append(entities, baseline.Entity)
+	}
+	assert.Equal(t, []string{"host:10.0.0.2", "host:10.0.0.3", "port:443"}, entities)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AnomalyEntitiesDropped.WithLabelValues("idle"))-idle)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AnomalyEntitiesDropped.WithLabelValues("full"))-full)
+}
@@ -0,0 +1,142 @@
+// Package detection implements built-in detectors that look for suspicious
+// patterns in the stored packet stream and report them as findings.
+package detection
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// DefaultHistorySize is the number of findings kept when none is configured
+const DefaultHistorySize = 1000
+
+// FindingStore keeps the most recent findings of every detector
+type FindingStore struct {
+	mutex     sync.RWMutex
+	findings  []*models.Finding
+	byID      map[string]*models.Finding
+	size      int
+	listeners []func(models.Finding)
+}
+
+// NewFindingStore creates a store keeping at most size findings
+func NewFindingStore(size int) *FindingStore {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &FindingStore{
+		byID: make(map[string]*models.Finding),
+		size: size,
+	}
+}
+
+// OnFinding registers a function called with every new finding. Later
+// updates of an ongoing finding are not reported. Listeners are called
+// outside the store lock and must not block.
+func (s *FindingStore) OnFinding(fn func(models.Finding)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Add records a new finding, assigning it an ID when it has none
+func (s *FindingStore) Add(finding *models.Finding) {
+	if finding.ID == "" {
+		finding.ID = newID("finding")
+	}
+
+	s.mutex.Lock()
+	s.add(finding)
+	added := copyFinding(finding)
+	listeners := s.listeners
+	s.mutex.Unlock()
+
+	for _, fn := range listeners {
+		fn(added)
+	}
+}
+
+// add appends a finding to the history, dropping the oldest ones
+func (s *FindingStore) add(finding *models.Finding) {
+	s.findings = append(s.findings, finding)
+	s.byID[finding.ID] = finding
+	if len(s.findings) > s.size {
+		for _, dropped := range s.findings[:len(s.findings)-s.size] {
+			delete(s.byID, dropped.ID)
+		}
+		s.findings = append(s.findings[:0], s.findings[len(s.findings)-s.size:]...)
+	}
+}
+
+// Update applies fn to a stored finding under the store lock. It reports
+// false if the finding has already been dropped from the history.
+func (s *FindingStore) Update(id string, fn func(*models.Finding)) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	finding, ok := s.byID[id]
+	if ok {
+		fn(finding)
+	}
+	return ok
+}
+
+// Get returns a copy of a finding by ID, or nil if it does not exist
+func (s *FindingStore) Get(id string) *models.Finding {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if finding, ok := s.byID[id]; ok {
+		f := copyFinding(finding)
+		return &f
+	}
+	return nil
+}
+
+// List returns the findings matching the filter, newest first
+func (s *FindingStore) List(filter *models.FindingFilter) []models.Finding {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	findings := make([]models.Finding, 0)
+	for i := len(s.findings) - 1; i >= 0; i-- {
+		f := s.findings[i]
+		if filter != nil {
+			if filter.Detector != "" && f.Detector != filter.Detector {
+				continue
+			}
+			if filter.Type != "" && f.Type != filter.Type {
+				continue
+			}
+			if filter.SourceIP != "" && f.SourceIP != filter.SourceIP {
+				continue
+			}
+			if !filter.Since.IsZero() && f.LastSeen.Before(filter.Since) {
+				continue
+			}
+		}
+
+		findings = append(findings, copyFinding(f))
+		if filter != nil && filter.Limit > 0 && len(findings) >= filter.Limit {
+			break
+		}
+	}
+	return findings
+}
+
+// copyFinding returns a deep copy of a finding
+func copyFinding(f *models.Finding) models.Finding {
+	c := *f
+	c.PacketIDs = append([]string(nil), f.PacketIDs...)
+	return c
+}
+
+// newID returns a random identifier with the given prefix
+func newID(prefix string) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return prefix + "_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,281 @@
+package detection
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// DetectorPortScan is the detector name of scan findings
+const DetectorPortScan = "portscan"
+
+// Scan finding types
+const (
+	FindingVerticalScan    = "vertical_scan"
+	FindingHorizontalSweep = "horizontal_sweep"
+	FindingSYNScan         = "syn_scan"
+)
+
+// sweepInterval is the number of packets between state cleanups
+const sweepInterval = 1024
+
+// ScanConfig holds the thresholds of the scan detector. A finding is raised
+// when a threshold is exceeded within Window.
+type ScanConfig struct {
+	Window              time.Duration
+	VerticalThreshold   int
+	HorizontalThreshold int
+	HalfOpenThreshold   int
+	Cooldown            time.Duration
+	MaxPacketIDs        int
+}
+
+// DefaultScanConfig returns thresholds that ignore ordinary client traffic
+func DefaultScanConfig() ScanConfig {
+	return ScanConfig{
+		Window:              time.Minute,
+		VerticalThreshold:   20,
+		HorizontalThreshold: 20,
+		HalfOpenThreshold:   20,
+		Cooldown:            5 * time.Minute,
+		MaxPacketIDs:        100,
+	}
+}
+
+// ScanDetector identifies vertical port scans (many ports on one host),
+// horizontal sweeps (one port on many hosts) and half-open SYN scans
+// (SYN probes never followed by an ACK). It implements storage.Observer.
+type ScanDetector struct {
+	mutex      sync.Mutex
+	config     ScanConfig
+	findings   *FindingStore
+	vertical   map[string]*probeSet
+	horizontal map[string]*probeSet
+	halfOpen   map[string]*probeSet
+	active     map[string]activeFinding
+	seen       int
+}
+
+// activeFinding tracks the finding that absorbs repeats during the cooldown
+type activeFinding struct {
+	id    string
+	until time.Time
+}
+
+// NewScanDetector creates a scan detector reporting into findings
+func NewScanDetector(config ScanConfig, findings *FindingStore) *ScanDetector {
+	if config.MaxPacketIDs <= 0 {
+		config.MaxPacketIDs = DefaultScanConfig().MaxPacketIDs
+	}
+	return &ScanDetector{
+		config:     config,
+		findings:   findings,
+		vertical:   make(map[string]*probeSet),
+		horizontal: make(map[string]*probeSet),
+		halfOpen:   make(map[string]*probeSet),
+		active:     make(map[string]activeFinding),
+	}
+}
+
+// OnStore inspects a stored packet
+func (d *ScanDetector) OnStore(packet *models.Packet) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	cutoff := packet.Timestamp.Add(-d.config.Window)
+	port := strconv.Itoa(packet.Port)
+
+	// Vertical: distinct destination ports from one source to one host
+	key := packet.SourceIP + "|" + packet.DestinationIP
+	set := probes(d.vertical, key)
+	set.add(port, packet)
+	if n := set.live(cutoff, d.config.VerticalThreshold); n > d.config.VerticalThreshold {
+		d.report(FindingVerticalScan, key, set, n, packet, &models.Finding{
+			Severity:      "medium",
+			Summary:       fmt.Sprintf("%s probed %d ports on %s within %s", packet.SourceIP, n, packet.DestinationIP, d.config.Window),
+			SourceIP:      packet.SourceIP,
+			DestinationIP: packet.DestinationIP,
+		})
+	}
+
+	// Horizontal: distinct destination hosts from one source on one port
+	key = packet.SourceIP + "|" + port
+	set = probes(d.horizontal, key)
+	set.add(packet.DestinationIP, packet)
+	if n := set.live(cutoff, d.config.HorizontalThreshold); n > d.config.HorizontalThreshold {
+		d.report(FindingHorizontalSweep, key, set, n, packet, &models.Finding{
+			Severity: "high",
+			Summary:  fmt.Sprintf("%s swept %d hosts on port %d within %s", packet.SourceIP, n, packet.Port, d.config.Window),
+			SourceIP: packet.SourceIP,
+			Port:     packet.Port,
+		})
+	}
+
+	// Half-open: SYN probes that the source never completes with an ACK
+	if isTCP(packet.Protocol) {
+		target := packet.DestinationIP + ":" + port
+		set = probes(d.halfOpen, packet.SourceIP)
+		switch {
+		case isSYNOnly(packet.Flags):
+			set.add(target, packet)
+		case hasFlag(packet.Flags, "ACK"):
+			set.remove(target)
+		}
+		if n := set.live(cutoff, d.config.HalfOpenThreshold); n > d.config.HalfOpenThreshold {
+			d.report(FindingSYNScan, packet.SourceIP, set, n, packet, &models.Finding{
+				Severity: "high",
+				Summary:  fmt.Sprintf("%s sent %d half-open SYN probes within %s", packet.SourceIP, n, d.config.Window),
+				SourceIP: packet.SourceIP,
+			})
+		}
+	}
+
+	d.seen++
+	if d.seen%sweepInterval == 0 {
+		d.sweep(cutoff, packet.Timestamp)
+	}
+}
+
+// report raises a new finding, or folds the packet into the finding raised
+// for the same pattern earlier in the cooldown
+func (d *ScanDetector) report(findingType, key string, set *probeSet, count int, packet *models.Packet, finding *models.Finding) {
+	activeKey := findingType + "|" + key
+	if active, ok := d.active[activeKey]; ok && packet.Timestamp.Before(active.until) {
+		updated := d.findings.Update(active.id, func(f *models.Finding) {
+			if count > f.Count {
+				f.Count = count
+			}
+			f.LastSeen = packet.Timestamp
+			if len(f.PacketIDs) < d.config.MaxPacketIDs {
+				f.PacketIDs = append(f.PacketIDs, packet.ID)
+			}
+		})
+		if updated {
+			return
+		}
+	}
+
+	finding.Detector = DetectorPortScan
+	finding.Type = findingType
+	finding.Count = count
+	finding.PacketIDs, finding.FirstSeen = set.packetIDs(d.config.MaxPacketIDs)
+	finding.LastSeen = packet.Timestamp
+	d.findings.Add(finding)
+
+	d.active[activeKey] = activeFinding{id: finding.ID, until: packet.Timestamp.Add(d.config.Cooldown)}
+}
+
+// sweep drops probe sets without recent probes and expired cooldowns
+func (d *ScanDetector) sweep(cutoff, now time.Time) {
+	for _, sets := range []map[string]*probeSet{d.vertical, d.horizontal, d.halfOpen} {
+		for key, set := range sets {
+			if set.last.Before(cutoff) {
+				delete(sets, key)
+			}
+		}
+	}
+	for key, active := range d.active {
+		if !now.Before(active.until) {
+			delete(d.active, key)
+		}
+	}
+}
+
+// isTCP reports whether a protocol is carried over TCP
+func isTCP(protocol string) bool {
+	return protocol == "TCP" || protocol == "HTTP" || protocol == "HTTPS"
+}
+
+// isSYNOnly reports whether flags hold a bare SYN
+func isSYNOnly(flags string) bool {
+	return flags == "SYN"
+}
+
+// hasFlag reports whether a comma or pipe separated flag list contains flag
+func hasFlag(flags, flag string) bool {
+	for _, f := range strings.FieldsFunc(flags, func(r rune) bool { return r == ',' || r == '|' || r == ' ' }) {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// probe is the most recent packet seen for a distinct value
+type probe struct {
+	at       time.Time
+	packetID string
+}
+
+// probeSet tracks the distinct values probed under one key
+type probeSet struct {
+	probes map[string]probe
+	last   time.Time
+}
+
+// probes returns the probe set of key, creating it if needed
+func probes(sets map[string]*probeSet, key string) *probeSet {
+	set, ok := sets[key]
+	if !ok {
+		set = &probeSet{probes: make(map[string]probe)}
+		sets[key] = set
+	}
+	return set
+}
+
+// add records a probe of value by packet
+func (s *probeSet) add(value string, packet *models.Packet) {
+	s.probes[value] = probe{at: packet.Timestamp, packetID: packet.ID}
+	if packet.Timestamp.After(s.last) {
+		s.last = packet.Timestamp
+	}
+}
+
+// remove forgets a probe
+func (s *probeSet) remove(value string) {
+	delete(s.probes, value)
+}
+
+// live returns the number of probes newer than cutoff. Expired probes are
+// only pruned once the set grows beyond threshold, keeping the common case
+// constant time.
+func (s *probeSet) live(cutoff time.Time, threshold int) int {
+	if len(s.probes) <= threshold {
+		return len(s.probes)
+	}
+	for value, p := range s.probes {
+		if p.at.Before(cutoff) {
+			delete(s.probes, value)
+		}
+	}
+	return len(s.probes)
+}
+
+// packetIDs returns up to n packet IDs of the set in time order, along
+// with the time of the earliest probe
+func (s *probeSet) packetIDs(n int) ([]string, time.Time) {
+	list := make([]probe, 0, len(s.probes))
+	for _, p := range s.probes {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].at.Before(list[j].at) })
+
+	var first time.Time
+	if len(list) > 0 {
+		first = list[0].at
+	}
+	if len(list) > n {
+		list = list[:n]
+	}
+
+	ids := make([]string, len(list))
+	for i, p := range list {
+		ids[i] = p.packetID
+	}
+	return ids, first
+}
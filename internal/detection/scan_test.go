@@ -0,0 +1,155 @@
+package detection
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDetector() (*ScanDetector, *FindingStore) {
+	findings := NewFindingStore(100)
+	return NewScanDetector(DefaultScanConfig(), findings), findings
+}
+
+func feed(d *ScanDetector, packets []*models.Packet) {
+	for _, p := range packets {
+		d.OnStore(p)
+	}
+}
+
+func packetIDs(packets []*models.Packet) map[string]bool {
+	ids := make(map[string]bool, len(packets))
+	for _, p := range packets {
+		ids[p.ID] = true
+	}
+	return ids
+}
+
+func findingTypes(findings []models.Finding) []string {
+	types := make([]string, len(findings))
+	for i, f := range findings {
+		types[i] = f.Type
+	}
+	return types
+}
+
+func TestScanDetector_VerticalScan(t *testing.T) {
+	detector, findings := newTestDetector()
+	scan := sniffing.NewScanGenerator(100*time.Millisecond).
+		Vertical("10.0.0.66", "10.0.0.1", sniffing.PortRange(1, 30), time.Now())
+	feed(detector, scan)
+
+	list := findings.List(nil)
+	require.Len(t, list, 1, "got %v", findingTypes(list))
+	f := list[0]
+	assert.Equal(t, DetectorPortScan, f.Detector)
+	assert.Equal(t, FindingVerticalScan, f.Type)
+	assert.Equal(t, "10.0.0.66", f.SourceIP)
+	assert.Equal(t, "10.0.0.1", f.DestinationIP)
+	assert.Equal(t, 30, f.Count, "count follows the scan while in cooldown")
+
+	generated := packetIDs(scan)
+	require.NotEmpty(t, f.PacketIDs)
+	for _, id := range f.PacketIDs {
+		assert.True(t, generated[id], "finding references unknown packet %s", id)
+	}
+}
+
+func TestScanDetector_HorizontalSweep(t *testing.T) {
+	detector, findings := newTestDetector()
+
+	targets := make([]string, 0, 25)
+	for i := 1; i <= 25; i++ {
+		targets = append(targets, fmt.Sprintf("192.168.1.%d", i))
+	}
+	scan := sniffing.NewScanGenerator(100*time.Millisecond).Horizontal("10.0.0.66", targets, 22, time.Now())
+	feed(detector, scan)
+
+	list := findings.List(nil)
+	require.Len(t, list, 1, "got %v", findingTypes(list))
+	assert.Equal(t, FindingHorizontalSweep, list[0].Type)
+	assert.Equal(t, 22, list[0].Port)
+	assert.Equal(t, 25, list[0].Count)
+}
+
+func TestScanDetector_HalfOpenScan(t *testing.T) {
+	detector, findings := newTestDetector()
+	scan := sniffing.NewScanGenerator(100*time.Millisecond).
+		HalfOpen("10.0.0.66", "10.0.0.1", sniffing.PortRange(1000, 1029), time.Now())
+	feed(detector, scan)
+
+	syn := findings.List(&models.FindingFilter{Type: FindingSYNScan})
+	require.Len(t, syn, 1)
+	assert.Equal(t, "10.0.0.66", syn[0].SourceIP)
+	assert.Equal(t, 30, syn[0].Count)
+
+	generated := packetIDs(scan)
+	for _, id := range syn[0].PacketIDs {
+		assert.True(t, generated[id])
+	}
+}
+
+func TestScanDetector_IgnoresNormalTraffic(t *testing.T) {
+	detector, findings := newTestDetector()
+	start := time.Now()
+
+	// Busy clients repeatedly completing connections to a few services
+	ports := []int{80, 443, 53, 22, 8080}
+	for i := 0; i < 1000; i++ {
+		src := fmt.Sprintf("10.0.0.%d", i%10)
+		dst := fmt.Sprintf("172.16.0.%d", i%5)
+		port := ports[i%len(ports)]
+		at := start.Add(time.Duration(i) * 10 * time.Millisecond)
+
+		syn := models.NewPacket(src, dst, "TCP", port, 64)
+		syn.Flags = "SYN"
+		syn.Timestamp = at
+		ack := models.NewPacket(src, dst, "TCP", port, 1200)
+		ack.Timestamp = at.Add(time.Millisecond)
+		feed(detector, []*models.Packet{syn, ack})
+	}
+
+	assert.Empty(t, findings.List(nil))
+}
+
+func TestScanDetector_SlowScanOutsideWindow(t *testing.T) {
+	detector, findings := newTestDetector()
+	// 30 ports at one probe every 5s never has more than 12 ports in a minute
+	scan := sniffing.NewScanGenerator(5*time.Second).
+		Vertical("10.0.0.66", "10.0.0.1", sniffing.PortRange(1, 30), time.Now())
+	feed(detector, scan)
+
+	assert.Empty(t, findings.List(nil))
+}
+
+func TestScanDetector_CooldownAndRepeat(t *testing.T) {
+	detector, findings := newTestDetector()
+	generator := sniffing.NewScanGenerator(100 * time.Millisecond)
+	start := time.Now()
+
+	feed(detector, generator.Vertical("10.0.0.66", "10.0.0.1", sniffing.PortRange(1, 30), start))
+	feed(detector, generator.Vertical("10.0.0.66", "10.0.0.1", sniffing.PortRange(31, 60), start.Add(time.Minute)))
+	assert.Len(t, findings.List(nil), 1, "repeat within cooldown is deduplicated")
+
+	feed(detector, generator.Vertical("10.0.0.66", "10.0.0.1", sniffing.PortRange(61, 90), start.Add(10*time.Minute)))
+	assert.Len(t, findings.List(nil), 2)
+}
+
+func TestFindingStore_HistoryLimit(t *testing.T) {
+	store := NewFindingStore(2)
+	for i := 0; i < 3; i++ {
+		store.Add(&models.Finding{Detector: DetectorPortScan, SourceIP: fmt.Sprintf("10.0.0.%d", i)})
+	}
+
+	list := store.List(nil)
+	require.Len(t, list, 2)
+	assert.Equal(t, "10.0.0.2", list[0].SourceIP)
+	assert.NotNil(t, store.Get(list[0].ID))
+	assert.Len(t, store.List(&models.FindingFilter{SourceIP: "10.0.0.1"}), 1)
+	assert.Empty(t, store.List(&models.FindingFilter{SourceIP: "10.0.0.0"}))
+}
@@ -0,0 +1,249 @@
+// Package export encodes stored packets as files for tools outside the
+// service: CSV for spreadsheets, NDJSON for scripts, Parquet for notebooks
+// and warehouses and pcapng for packet analyzers such as Wireshark. Every
+// format is written batch by batch, so exports of any size are produced in
+// bounded memory.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/parquet"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/pcap"
+)
+
+// Format is an export file format
+type Format string
+
+// Export formats
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+	FormatPcapng  Format = "pcapng"
+)
+
+// ErrUnknownFormat is returned for an unsupported export format
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// ParseFormat validates an export format name
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case FormatCSV, FormatNDJSON, FormatParquet, FormatPcapng:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w %q, expected csv, ndjson, parquet or pcapng", ErrUnknownFormat, name)
+}
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	case FormatPcapng:
+		return "application/x-pcapng"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// Extension returns the file name extension of the format
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Compressible reports whether the encoded packets benefit from further
+// compression. Parquet data pages are already gzip compressed.
+func (f Format) Compressible() bool {
+	return f != FormatParquet
+}
+
+// Writer encodes packets in an export format
+type Writer interface {
+	// Write encodes a batch of packets
+	Write(packets []models.Packet) error
+	// Close writes any buffered data and the end of the file. It does not
+	// close the underlying writer.
+	Close() error
+}
+
+// NewWriter creates a writer of packets in format to w
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		buffered := bufio.NewWriter(w)
+		return &ndjsonWriter{buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	case FormatParquet:
+		return &parquetWriter{w: parquet.NewWriter(w, parquetColumns).WithCompression(parquet.Gzip)}, nil
+	case FormatPcapng:
+		buffered := bufio.NewWriter(w)
+		return &pcapngWriter{buffered: buffered, w: pcap.NewWriter(buffered, pcap.LinkTypeEthernet)}, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+}
+
+// csvHeader names the CSV columns
+var csvHeader = []string{"id", "timestamp", "protocol", "source_ip", "destination_ip", "port", "size", "ttl", "flags", "payload", "session"}
+
+// csvWriter writes a header line, then one line per packet. Timestamps are
+// RFC 3339 in UTC and a missing TTL is empty.
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (c *csvWriter) Write(packets []models.Packet) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	for i := range packets {
+		p := &packets[i]
+		ttl := ""
+		if p.TTL != 0 {
+			ttl = strconv.Itoa(p.TTL)
+		}
+		record := []string{
+			p.ID, p.Timestamp.UTC().Format(time.RFC3339Nano), p.Protocol, p.SourceIP, p.DestinationIP,
+			strconv.Itoa(p.Port), strconv.Itoa(p.Size), ttl, p.Flags, p.Payload, p.Session,
+		}
+		if err := c.w.Write(record); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// writeHeader writes the header line once
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	return c.w.Write(csvHeader)
+}
+
+// ndjsonWriter writes one JSON packet per line, as served by the API
+type ndjsonWriter struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(packets []models.Packet) error {
+	for i := range packets {
+		if err := n.encoder.Encode(&packets[i]); err != nil {
+			return err
+		}
+	}
+	return n.buffered.Flush()
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.buffered.Flush()
+}
+
+// parquetColumns is the Parquet schema of packets. Columns omitted from
+// the JSON encoding when empty are optional.
+var parquetColumns = []parquet.Column{
+	{Name: "id", Type: parquet.String},
+	{Name: "timestamp", Type: parquet.Timestamp},
+	{Name: "protocol", Type: parquet.String},
+	{Name: "source_ip", Type: parquet.String},
+	{Name: "destination_ip", Type: parquet.String},
+	{Name: "port", Type: parquet.Int32},
+	{Name: "size", Type: parquet.Int32},
+	{Name: "ttl", Type: parquet.Int32, Optional: true},
+	{Name: "flags", Type: parquet.String, Optional: true},
+	{Name: "payload", Type: parquet.String, Optional: true},
+	{Name: "session", Type: parquet.String, Optional: true},
+}
+
+// parquetWriter writes packets as rows of a Parquet table
+type parquetWriter struct {
+	w *parquet.Writer
+}
+
+func (p *parquetWriter) Write(packets []models.Packet) error {
+	for i := range packets {
+		packet := &packets[i]
+		if err := p.w.Write(
+			packet.ID, packet.Timestamp, packet.Protocol, packet.SourceIP, packet.DestinationIP,
+			packet.Port, packet.Size, optional(packet.TTL), optional(packet.Flags), optional(packet.Payload),
+			optional(packet.Session),
+		); err != nil {
+			return fmt.Errorf("packet %s: %w", packet.ID, err)
+		}
+	}
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	return p.w.Close()
+}
+
+// Comment prefixes of the packets written by pcapngWriter
+const (
+	CommentPacketID = "packet_id: "
+	CommentSession  = "session: "
+)
+
+// pcapngWriter writes packets as synthesized Ethernet frames, commented
+// with their packet ID and session
+type pcapngWriter struct {
+	buffered *bufio.Writer
+	w        *pcap.Writer
+}
+
+func (p *pcapngWriter) Write(packets []models.Packet) error {
+	for i := range packets {
+		packet := &packets[i]
+		record, err := pcap.Encode(packet)
+		if err != nil {
+			return fmt.Errorf("packet %s: %w", packet.ID, err)
+		}
+		record.Comments = []string{CommentPacketID + packet.ID}
+		if packet.Session != "" {
+			record.Comments = append(record.Comments, CommentSession+packet.Session)
+		}
+		if err := p.w.Write(record); err != nil {
+			return err
+		}
+	}
+	return p.buffered.Flush()
+}
+
+func (p *pcapngWriter) Close() error {
+	if err := p.w.Close(); err != nil {
+		return err
+	}
+	return p.buffered.Flush()
+}
+
+// optional returns nil for the zero value, written as a null
+func optional[T comparable](value T) any {
+	var zero T
+	if value == zero {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,100 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/pcap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var packets = []models.Packet{
+	{ID: "p1", Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC), Protocol: "TCP", SourceIP: "10.0.0.1", DestinationIP: "10.0.0.2", Port: 443, Size: 1200, TTL: 64, Flags: "SYN"},
+	{ID: "p2", Timestamp: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC), Protocol: "UDP", SourceIP: "10.0.0.3", DestinationIP: "10.0.0.4", Port: 53, Size: 80, Payload: "a,\"b\"\nc", Session: "upload-1"},
+}
+
+// exportPackets writes packets in format, one batch per packet
+func exportPackets(t *testing.T, format Format) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	require.NoError(t, err)
+	for i := range packets {
+		require.NoError(t, w.Write(packets[i:i+1]))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("parquet")
+	require.NoError(t, err)
+	assert.Equal(t, FormatParquet, format)
+	assert.False(t, format.Compressible())
+	assert.Equal(t, "text/csv; charset=utf-8", FormatCSV.ContentType())
+
+	_, err = ParseFormat("xml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestWriter_CSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(exportPackets(t, FormatCSV))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, []string{"p1", "2024-01-02T03:04:05.000006Z", "TCP", "10.0.0.1", "10.0.0.2", "443", "1200", "64", "SYN", "", ""}, records[1])
+	assert.Equal(t, []string{"p2", "2024-01-02T03:04:06Z", "UDP", "10.0.0.3", "10.0.0.4", "53", "80", "", "", "a,\"b\"\nc", "upload-1"}, records[2])
+
+	// An empty export still has its header
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, "id,timestamp,protocol,source_ip,destination_ip,port,size,ttl,flags,payload,session\n", buf.String())
+}
+
+func TestWriter_NDJSON(t *testing.T) {
+	scanner := bufio.NewScanner(bytes.NewReader(exportPackets(t, FormatNDJSON)))
+	var decoded []models.Packet
+	for scanner.Scan() {
+		var p models.Packet
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &p))
+		decoded = append(decoded, p)
+	}
+	assert.Equal(t, packets, decoded)
+}
+
+func TestWriter_Parquet(t *testing.T) {
+	file := exportPackets(t, FormatParquet)
+	assert.Equal(t, "PAR1", string(file[:4]))
+	assert.Equal(t, "PAR1", string(file[len(file)-4:]))
+	assert.Contains(t, string(file), "destination_ip")
+}
+
+func TestWriter_Pcapng(t *testing.T) {
+	reader, err := pcap.NewReader(bytes.NewReader(exportPackets(t, FormatPcapng)))
+	require.NoError(t, err)
+	for _, want := range packets {
+		record, err := reader.Next()
+		require.NoError(t, err)
+		comments := []string{CommentPacketID + want.ID}
+		if want.Session != "" {
+			comments = append(comments, CommentSession+want.Session)
+		}
+		assert.Equal(t, comments, record.Comments)
+
+		got, err := pcap.Decode(record)
+		require.NoError(t, err)
+		assert.Equal(t, want.Timestamp, got.Timestamp)
+		assert.Equal(t, want.SourceIP, got.SourceIP)
+		assert.Equal(t, want.Port, got.Port)
+		assert.Equal(t, want.Size, got.Size)
+		assert.Equal(t, want.Payload, got.Payload)
+	}
+}
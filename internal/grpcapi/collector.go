@@ -0,0 +1,171 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/collector"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ingest"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	snifferv1 "github.com/cryptonextsecurity/network-sniffer/proto/sniffer/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxForwardedBatch bounds the packets of a forwarded batch, like the
+// batches pushed over REST
+const maxForwardedBatch = 10000
+
+// collectorService implements the CollectorService of the gRPC API
+type collectorService struct {
+	snifferv1.UnimplementedCollectorServiceServer
+
+	server   *Server
+	registry *collector.Registry
+}
+
+// RegisterAgent adds an agent owned by the caller to the registry, or
+// refreshes it
+func (c *collectorService) RegisterAgent(ctx context.Context, request *snifferv1.RegisterAgentRequest) (*snifferv1.Agent, error) {
+	agent, err := c.registry.Register(request.AgentId, agentOwner(ctx), request.Hostname, clientIP(ctx))
+	switch {
+	case errors.Is(err, collector.ErrAgentOwned):
+		return nil, status.Error(codes.PermissionDenied, "Agent "+strconv.Quote(request.AgentId)+" was registered by another principal")
+	case errors.Is(err, collector.ErrTooManyAgents):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	c.server.logger.InfoContext(ctx, "Agent registered", "agent_id", agent.ID, "hostname", agent.Hostname, "address", agent.Address)
+	return toAgent(&agent), nil
+}
+
+// ForwardPackets stores the batches of an agent as live traffic tagged with
+// its ID, acknowledging each one, until the agent closes the stream or the
+// server shuts down
+func (c *collectorService) ForwardPackets(stream snifferv1.CollectorService_ForwardPacketsServer) error {
+	ctx := stream.Context()
+	var agentID string
+	defer func() {
+		if agentID != "" {
+			c.registry.Disconnect(agentID)
+			c.server.logger.InfoContext(ctx, "Agent disconnected", "agent_id", agentID)
+		}
+	}()
+
+	requests, errs := receive(ctx, stream)
+	for {
+		var request *snifferv1.ForwardPacketsRequest
+		select {
+		case <-c.server.feed.done:
+			return status.Error(codes.Unavailable, "The server is shutting down")
+		case err := <-errs:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case request = <-requests:
+		}
+
+		if agentID == "" {
+			switch err := c.registry.Connect(request.AgentId, agentOwner(ctx)); {
+			case errors.Is(err, collector.ErrAgentOwned):
+				return status.Error(codes.PermissionDenied, "Agent "+strconv.Quote(request.AgentId)+" was registered by another principal")
+			case err != nil:
+				return status.Error(codes.FailedPrecondition, "Agent "+strconv.Quote(request.AgentId)+" is not registered: call RegisterAgent first")
+			}
+			agentID = request.AgentId
+			c.server.logger.InfoContext(ctx, "Agent connected", "agent_id", agentID)
+		} else if request.AgentId != agentID {
+			return status.Error(codes.InvalidArgument, "A stream forwards the packets of a single agent")
+		}
+		if len(request.Packets) > maxForwardedBatch {
+			return status.Error(codes.InvalidArgument, "A batch holds at most "+strconv.Itoa(maxForwardedBatch)+" packets")
+		}
+
+		accepted, rejected, duplicate, err := c.registry.Forward(agentID, request.BufferId, request.Sequence, func() (int, int, error) {
+			return c.store(ctx, agentID, request.Packets)
+		})
+		if err != nil {
+			return internalError(err, "Failed to store packets")
+		}
+		if err := stream.Send(&snifferv1.ForwardPacketsResponse{
+			Sequence:  request.Sequence,
+			Accepted:  int32(accepted),
+			Rejected:  int32(rejected),
+			Duplicate: duplicate,
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// agentOwner identifies the principal of a call to the registry: its API
+// key, or its token subject
+func agentOwner(ctx context.Context) string {
+	principal := PrincipalFrom(ctx)
+	if principal == nil {
+		return ""
+	}
+	if principal.KeyID != "" {
+		return "key:" + principal.KeyID
+	}
+	return principal.Method + ":" + principal.Subject
+}
+
+// receive reads the requests of a stream in the background, so that the
+// stream can end while waiting for the next one. The first receive error,
+// io.EOF once the agent closes the stream, ends the reads.
+func receive(ctx context.Context, stream snifferv1.CollectorService_ForwardPacketsServer) (<-chan *snifferv1.ForwardPacketsRequest, <-chan error) {
+	requests := make(chan *snifferv1.ForwardPacketsRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			request, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case requests <- request:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return requests, errs
+}
+
+// store ingests the packets of a batch as live traffic of an agent. Invalid
+// packets are counted as rejected; any other error fails the batch.
+func (c *collectorService) store(ctx context.Context, agentID string, messages []*snifferv1.Packet) (accepted, rejected int, err error) {
+	packets := make([]*models.Packet, len(messages))
+	for i, message := range messages {
+		packets[i] = fromPacket(message)
+		packets[i].Session = ""
+		packets[i].Agent = agentID
+	}
+	for _, err := range c.server.packetService.IngestPackets(ctx, packets) {
+		switch {
+		case err == nil:
+			accepted++
+		case errors.Is(err, ingest.ErrInvalidPacket):
+			rejected++
+		default:
+			return accepted, rejected, err
+		}
+	}
+	return accepted, rejected, nil
+}
+
+// ListAgents returns the registered agents
+func (c *collectorService) ListAgents(context.Context, *snifferv1.ListAgentsRequest) (*snifferv1.ListAgentsResponse, error) {
+	agents := c.registry.List()
+	response := &snifferv1.ListAgentsResponse{Agents: make([]*snifferv1.Agent, len(agents))}
+	for i := range agents {
+		response.Agents[i] = toAgent(&agents[i])
+	}
+	return response, nil
+}
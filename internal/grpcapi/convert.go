@@ -0,0 +1,116 @@
+package grpcapi
+
+import (
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	snifferv1 "github.com/cryptonextsecurity/network-sniffer/proto/sniffer/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toPacket converts a packet to its protobuf message
+func toPacket(packet *models.Packet) *snifferv1.Packet {
+	return &snifferv1.Packet{
+		Id:            packet.ID,
+		SourceIp:      packet.SourceIP,
+		DestinationIp: packet.DestinationIP,
+		Protocol:      packet.Protocol,
+		Port:          int32(packet.Port),
+		Size:          int32(packet.Size),
+		Timestamp:     timestamppb.New(packet.Timestamp),
+		Ttl:           int32(packet.TTL),
+		Flags:         packet.Flags,
+		Payload:       packet.Payload,
+		Session:       packet.Session,
+		Agent:         packet.Agent,
+	}
+}
+
+// fromPacket converts a protobuf packet, a missing timestamp staying zero
+func fromPacket(packet *snifferv1.Packet) *models.Packet {
+	converted := &models.Packet{
+		ID:            packet.Id,
+		SourceIP:      packet.SourceIp,
+		DestinationIP: packet.DestinationIp,
+		Protocol:      packet.Protocol,
+		Port:          int(packet.Port),
+		Size:          int(packet.Size),
+		TTL:           int(packet.Ttl),
+		Flags:         packet.Flags,
+		Payload:       packet.Payload,
+		Session:       packet.Session,
+		Agent:         packet.Agent,
+	}
+	if packet.Timestamp != nil {
+		converted.Timestamp = packet.Timestamp.AsTime()
+	}
+	return converted
+}
+
+// toAgent converts an agent to its protobuf message
+func toAgent(agent *models.Agent) *snifferv1.Agent {
+	return &snifferv1.Agent{
+		Id:           agent.ID,
+		Hostname:     agent.Hostname,
+		Address:      agent.Address,
+		RegisteredAt: timestamppb.New(agent.RegisteredAt),
+		LastSeenAt:   timestamppb.New(agent.LastSeenAt),
+		Connected:    agent.Connected,
+		Batches:      agent.Batches,
+		Packets:      agent.Packets,
+		Rejected:     agent.Rejected,
+	}
+}
+
+// toStats converts storage statistics to their protobuf message
+func toStats(stats *models.Stats) *snifferv1.Stats {
+	message := &snifferv1.Stats{
+		TotalPackets: int32(stats.TotalPackets),
+		Capacity:     int32(stats.Capacity),
+		OldestAt:     toTimestamp(stats.OldestAt),
+		NewestAt:     toTimestamp(stats.NewestAt),
+	}
+	for _, window := range stats.Windows {
+		message.Windows = append(message.Windows, &snifferv1.WindowStats{
+			Window:               window.Window,
+			Packets:              window.Packets,
+			Bytes:                window.Bytes,
+			PacketsPerSecond:     window.PacketsPerSecond,
+			BytesPerSecond:       window.BytesPerSecond,
+			DistinctSources:      window.DistinctSources,
+			DistinctDestinations: window.DistinctDestinations,
+			Protocols:            window.Protocols,
+		})
+	}
+	return message
+}
+
+// toTimestamp converts an optional time, nil staying unset
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// fromFilter converts a protobuf packet filter, nil matching every live
+// packet
+func fromFilter(filter *snifferv1.PacketFilter) models.PacketFilter {
+	if filter == nil {
+		return models.PacketFilter{}
+	}
+	converted := models.PacketFilter{
+		Protocol:      filter.Protocol,
+		SourceIP:      filter.SourceIp,
+		DestinationIP: filter.DestinationIp,
+		Session:       filter.Session,
+		Agent:         filter.Agent,
+	}
+	if filter.From != nil {
+		converted.FromTimestamp = filter.From.AsTime()
+	}
+	if filter.To != nil {
+		converted.ToTimestamp = filter.To.AsTime()
+	}
+	return converted
+}
@@ -0,0 +1,114 @@
+package grpcapi
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// DefaultFeedBuffer is the number of packets buffered per subscriber when
+// NewFeed is given no buffer size
+const DefaultFeedBuffer = 1000
+
+// Feed fans the live packets accepted by storage out to the subscribers of
+// the packet stream. Each subscriber has a buffer of its own; a subscriber
+// whose buffer is full misses packets rather than slowing storage down.
+type Feed struct {
+	buffer int
+
+	mutex         sync.RWMutex
+	subscriptions map[*subscription]struct{}
+	closed        bool
+	done          chan struct{}
+}
+
+// subscription is the state of one subscriber
+type subscription struct {
+	filter  models.PacketFilter
+	packets chan models.Packet
+	dropped atomic.Uint64
+}
+
+// NewFeed creates a feed buffering buffer packets per subscriber. It must
+// be registered as a storage observer.
+func NewFeed(buffer int) *Feed {
+	if buffer <= 0 {
+		buffer = DefaultFeedBuffer
+	}
+	return &Feed{
+		buffer:        buffer,
+		subscriptions: make(map[*subscription]struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// OnStore hands a packet to every subscriber whose filter matches it
+func (f *Feed) OnStore(packet *models.Packet) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	for s := range f.subscriptions {
+		if !s.matches(packet) {
+			continue
+		}
+		select {
+		case s.packets <- *packet:
+		default:
+			s.dropped.Add(1)
+			metrics.StreamPacketsDropped.Inc()
+		}
+	}
+}
+
+// Len returns the number of subscribers
+func (f *Feed) Len() int {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return len(f.subscriptions)
+}
+
+// Close ends every subscription and refuses new ones
+func (f *Feed) Close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.closed = true
+	close(f.done)
+}
+
+// subscribe registers a subscriber to the packets matching the protocol
+// and addresses of filter. It returns false once the feed is closed.
+func (f *Feed) subscribe(filter models.PacketFilter) (*subscription, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return nil, false
+	}
+	s := &subscription{filter: filter, packets: make(chan models.Packet, f.buffer)}
+	f.subscriptions[s] = struct{}{}
+	metrics.StreamSubscribers.Set(float64(len(f.subscriptions)))
+	return s, true
+}
+
+// unsubscribe forgets a subscriber
+func (f *Feed) unsubscribe(s *subscription) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.subscriptions, s)
+	metrics.StreamSubscribers.Set(float64(len(f.subscriptions)))
+}
+
+// matches reports whether a packet matches the protocol and addresses of
+// the filter of a subscriber
+func (s *subscription) matches(packet *models.Packet) bool {
+	return (s.filter.Protocol == "" || packet.Protocol == s.filter.Protocol) &&
+		(s.filter.SourceIP == "" || packet.SourceIP == s.filter.SourceIP) &&
+		(s.filter.DestinationIP == "" || packet.DestinationIP == s.filter.DestinationIP)
+}
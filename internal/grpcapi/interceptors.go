@@ -0,0 +1,351 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ratelimit"
+	snifferv1 "github.com/cryptonextsecurity/network-sniffer/proto/sniffer/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metadata keys read and written by the interceptors
+const (
+	metadataAPIKey        = "x-api-key"
+	metadataAuthorization = "authorization"
+	metadataRequestID     = "x-request-id"
+)
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// methodRoles is the role required by each method, the one of the
+// matching REST route
+var methodRoles = map[string]auth.Role{
+	snifferv1.SnifferService_ListPackets_FullMethodName:      auth.RoleViewer,
+	snifferv1.SnifferService_GetPacket_FullMethodName:        auth.RoleViewer,
+	snifferv1.SnifferService_DeletePacket_FullMethodName:     auth.RoleAdmin,
+	snifferv1.SnifferService_ClearPackets_FullMethodName:     auth.RoleAdmin,
+	snifferv1.SnifferService_StartSniffing_FullMethodName:    auth.RoleAnalyst,
+	snifferv1.SnifferService_StopSniffing_FullMethodName:     auth.RoleAnalyst,
+	snifferv1.SnifferService_GetSnifferStatus_FullMethodName: auth.RoleViewer,
+	snifferv1.SnifferService_GetStats_FullMethodName:         auth.RoleViewer,
+	snifferv1.SnifferService_Subscribe_FullMethodName:        auth.RoleViewer,
+
+	// Agents ingest packets, like analysts pushing them over REST
+	snifferv1.CollectorService_RegisterAgent_FullMethodName:  auth.RoleAnalyst,
+	snifferv1.CollectorService_ForwardPackets_FullMethodName: auth.RoleAnalyst,
+	snifferv1.CollectorService_ListAgents_FullMethodName:     auth.RoleViewer,
+}
+
+// expensiveMethods are the methods charged to the expensive budget
+var expensiveMethods = map[string]bool{
+	snifferv1.SnifferService_ListPackets_FullMethodName: true,
+}
+
+// auditActions names the mutating methods in the audit log, like their
+// REST routes
+var auditActions = map[string]string{
+	snifferv1.SnifferService_DeletePacket_FullMethodName:    "packets.delete",
+	snifferv1.SnifferService_ClearPackets_FullMethodName:    "packets.clear",
+	snifferv1.SnifferService_StartSniffing_FullMethodName:   "sniffing.start",
+	snifferv1.SnifferService_StopSniffing_FullMethodName:    "sniffing.stop",
+	snifferv1.CollectorService_RegisterAgent_FullMethodName: "agents.register",
+}
+
+// principalKey is the context key of the authenticated principal
+type principalKey struct{}
+
+// PrincipalFrom returns the principal a call is authenticated as, or nil
+func PrincipalFrom(ctx context.Context) *auth.Principal {
+	principal, _ := ctx.Value(principalKey{}).(*auth.Principal)
+	return principal
+}
+
+// unaryInterceptor serves unary calls through intercept
+func (s *Server) unaryInterceptor(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var response any
+	err := s.intercept(ctx, info.FullMethod, request, func(ctx context.Context) error {
+		var err error
+		response, err = handler(ctx, request)
+		return err
+	})
+	return response, err
+}
+
+// streamInterceptor serves streaming calls through intercept
+func (s *Server) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return s.intercept(stream.Context(), info.FullMethod, nil, func(ctx context.Context) error {
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	})
+}
+
+// serverStream replaces the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// intercept correlates a call with its log lines, authenticates,
+// authorizes and rate limits it, then serves it. Panics are recovered;
+// once served the call is counted, audited when it mutates state and
+// logged.
+func (s *Server) intercept(ctx context.Context, method string, request any, serve func(ctx context.Context) error) (err error) {
+	start := time.Now()
+	id := requestID(ctx)
+	ctx = logging.WithRequestID(ctx, id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, id))
+
+	var principal *auth.Principal
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			s.logger.ErrorContext(ctx, "Recovered from panic", "panic", recovered, "method", method)
+			err = status.Error(codes.Internal, "An unexpected error occurred")
+		}
+		code := status.Code(err)
+		metrics.GRPCRequests.WithLabelValues(method, code.String()).Inc()
+		s.audit(ctx, method, request, principal, code)
+		s.logCall(ctx, method, principal, code, start, err)
+	}()
+
+	principal, err = s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	if required, ok := methodRoles[method]; ok && !principal.Role.Allows(required) {
+		return status.Error(codes.PermissionDenied, "This operation requires the "+string(required)+" role")
+	}
+	if err := s.rateLimit(ctx, method, principal); err != nil {
+		return err
+	}
+	return serve(context.WithValue(ctx, principalKey{}, principal))
+}
+
+// authenticate resolves the principal of a call from its metadata.
+// Without an authenticator every call is served as the anonymous admin.
+// Failed attempts are charged to the standard budget of the client IP.
+func (s *Server) authenticate(ctx context.Context) (*auth.Principal, error) {
+	if s.authenticator == nil {
+		return auth.Anonymous, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	principal, err := s.authenticator.AuthenticateCredentials(first(md, metadataAPIKey), first(md, metadataAuthorization))
+	if err != nil {
+		if s.standardLimiter != nil {
+			if decision := s.standardLimiter.Allow("ip:" + clientIP(ctx)); !decision.Allowed {
+				return nil, rateLimited(ctx, decision)
+			}
+		}
+		message := "Invalid credentials"
+		if errors.Is(err, auth.ErrUnauthenticated) {
+			message = "Authentication required: provide x-api-key or authorization metadata"
+		}
+		return nil, status.Error(codes.Unauthenticated, message)
+	}
+	return principal, nil
+}
+
+// rateLimit charges a call to the budget of its client. Clients are
+// identified like REST clients.
+func (s *Server) rateLimit(ctx context.Context, method string, principal *auth.Principal) error {
+	if s.standardLimiter == nil {
+		return nil
+	}
+	limiter := s.standardLimiter
+	if expensiveMethods[method] {
+		limiter = s.expensiveLimiter
+	}
+
+	var key string
+	switch {
+	case principal.Method == auth.MethodAnonymous:
+		key = "ip:" + clientIP(ctx)
+	case principal.KeyID != "":
+		key = "key:" + principal.KeyID
+	default:
+		key = principal.Method + ":" + principal.Subject
+	}
+
+	if decision := limiter.Allow(key); !decision.Allowed {
+		return rateLimited(ctx, decision)
+	}
+	return nil
+}
+
+// rateLimited returns the error of a call whose client exhausted its budget
+func rateLimited(ctx context.Context, decision ratelimit.Decision) error {
+	seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.Itoa(seconds)))
+	return status.Error(codes.ResourceExhausted, "Rate limit exceeded, retry in "+strconv.Itoa(seconds)+"s")
+}
+
+// audit records a mutating call, including a rejected one
+func (s *Server) audit(ctx context.Context, method string, request any, principal *auth.Principal, code codes.Code) {
+	action, ok := auditActions[method]
+	if !ok || s.auditLog == nil {
+		return
+	}
+
+	httpStatus := httpStatusFromCode(code)
+	entry := models.AuditEntry{
+		Actor:      "unauthenticated",
+		Action:     action,
+		Method:     "GRPC",
+		Path:       method,
+		Parameters: auditParameters(request),
+		ClientIP:   clientIP(ctx),
+		Status:     httpStatus,
+		Outcome:    models.AuditSuccess,
+	}
+	switch {
+	case httpStatus == http.StatusUnauthorized || httpStatus == http.StatusForbidden:
+		entry.Outcome = models.AuditDenied
+	case httpStatus >= 400:
+		entry.Outcome = models.AuditFailure
+	}
+	if principal != nil {
+		entry.Actor = principal.Subject
+		entry.Role = string(principal.Role)
+		entry.AuthMethod = principal.Method
+	}
+	if _, err := s.auditLog.Record(entry); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to record audit entry", "action", entry.Action, "error", err.Error())
+	}
+}
+
+// auditParameters returns the fields of a request message
+func auditParameters(request any) map[string]any {
+	message, ok := request.(proto.Message)
+	if !ok {
+		return nil
+	}
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+	if err != nil {
+		return nil
+	}
+	var parameters map[string]any
+	if json.Unmarshal(data, &parameters) != nil || len(parameters) == 0 {
+		return nil
+	}
+	return parameters
+}
+
+// logCall writes one line per call once it has been served. Server errors
+// are logged at error level, client errors at warn level.
+func (s *Server) logCall(ctx context.Context, method string, principal *auth.Principal, code codes.Code, start time.Time, err error) {
+	level := slog.LevelInfo
+	switch httpStatusFromCode(code) / 100 {
+	case 5:
+		level = slog.LevelError
+	case 4:
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.String("client_ip", clientIP(ctx)),
+	}
+	if principal != nil {
+		attrs = append(attrs, slog.String("principal", principal.Subject))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	s.logger.LogAttrs(ctx, level, "gRPC call", attrs...)
+}
+
+// httpStatusFromCode maps a status code to the HTTP status of the
+// equivalent REST response
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// requestID returns the ID sent by the client when it is printable and
+// reasonably short, or a new one
+func requestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := first(md, metadataRequestID)
+	valid := id != "" && len(id) <= maxRequestIDLength
+	for i := 0; valid && i < len(id); i++ {
+		valid = id[i] >= 0x21 && id[i] <= 0x7e
+	}
+	if valid {
+		return id
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "req_" + hex.EncodeToString(b)
+}
+
+// clientIP returns the address of the client of a call
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// first returns the first value of a metadata key
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,268 @@
+// Package grpcapi serves the gRPC API defined in proto/sniffer/v1, next to
+// the REST API. Calls are authenticated, authorized, rate limited, audited
+// and logged like REST requests.
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/audit"
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/collector"
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ratelimit"
+	"github.com/cryptonextsecurity/network-sniffer/internal/services"
+	snifferv1 "github.com/cryptonextsecurity/network-sniffer/proto/sniffer/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the SnifferService of the gRPC API
+type Server struct {
+	snifferv1.UnimplementedSnifferServiceServer
+
+	packetService *services.PacketService
+	feed          *Feed
+	authenticator *auth.Authenticator
+	auditLog      *audit.Log
+	registry      *collector.Registry
+	options       []grpc.ServerOption
+	logger        *slog.Logger
+
+	standardLimiter  *ratelimit.Limiter
+	expensiveLimiter *ratelimit.Limiter
+}
+
+// NewServer creates the gRPC API of a packet service, streaming the live
+// packets of feed. A nil logger selects the default logger.
+func NewServer(packetService *services.PacketService, feed *Feed, logger *slog.Logger) *Server {
+	return &Server{
+		packetService: packetService,
+		feed:          feed,
+		logger:        logging.OrDefault(logger),
+	}
+}
+
+// WithAuth requires every call to be authenticated and authorizes it by
+// role. Without it every call is served as the anonymous admin.
+func (s *Server) WithAuth(authenticator *auth.Authenticator) *Server {
+	s.authenticator = authenticator
+	return s
+}
+
+// WithAudit records the mutating calls in an audit log
+func (s *Server) WithAudit(auditLog *audit.Log) *Server {
+	s.auditLog = auditLog
+	return s
+}
+
+// WithCollector also serves the CollectorService, receiving the packets of
+// the agents of registry
+func (s *Server) WithCollector(registry *collector.Registry) *Server {
+	s.registry = registry
+	return s
+}
+
+// WithOptions adds options, such as transport credentials, to the server
+// created by Setup
+func (s *Server) WithOptions(options ...grpc.ServerOption) *Server {
+	s.options = append(s.options, options...)
+	return s
+}
+
+// WithRateLimits limits the call rate of every client. Calls have budgets
+// of their own, separate from the ones of REST requests. Without it calls
+// are not limited.
+func (s *Server) WithRateLimits(standard, expensive ratelimit.Limit) *Server {
+	s.standardLimiter = ratelimit.NewLimiter(standard)
+	s.expensiveLimiter = ratelimit.NewLimiter(expensive)
+	return s
+}
+
+// UpdateRateLimits changes the budgets of a server set up with
+// WithRateLimits. It does nothing when calls are not limited.
+func (s *Server) UpdateRateLimits(standard, expensive ratelimit.Limit) {
+	if s.standardLimiter == nil {
+		return
+	}
+	s.standardLimiter.SetLimit(standard)
+	s.expensiveLimiter.SetLimit(expensive)
+}
+
+// Setup creates a gRPC server serving the API, and the CollectorService
+// when set up with WithCollector
+func (s *Server) Setup() *grpc.Server {
+	options := append([]grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	}, s.options...)
+	server := grpc.NewServer(options...)
+	snifferv1.RegisterSnifferServiceServer(server, s)
+	if s.registry != nil {
+		snifferv1.RegisterCollectorServiceServer(server, &collectorService{server: s, registry: s.registry})
+	}
+	return server
+}
+
+// ListPackets returns the stored packets matching a filter
+func (s *Server) ListPackets(ctx context.Context, request *snifferv1.ListPacketsRequest) (*snifferv1.ListPacketsResponse, error) {
+	if request.Limit < 0 || request.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	filter := fromFilter(request.Filter)
+	filter.Limit = int(request.Limit)
+	filter.Offset = int(request.Offset)
+
+	response, err := s.packetService.GetPackets(ctx, &filter)
+	if err != nil {
+		return nil, internalError(err, "Failed to retrieve packets")
+	}
+	packets := make([]*snifferv1.Packet, len(response.Packets))
+	for i := range response.Packets {
+		packets[i] = toPacket(&response.Packets[i])
+	}
+	return &snifferv1.ListPacketsResponse{
+		Packets:   packets,
+		Total:     int32(response.Total),
+		Timestamp: timestamppb.New(response.Timestamp),
+	}, nil
+}
+
+// GetPacket returns a packet by ID
+func (s *Server) GetPacket(ctx context.Context, request *snifferv1.GetPacketRequest) (*snifferv1.Packet, error) {
+	packet, err := s.packetService.GetPacketByID(ctx, request.Id)
+	if err != nil {
+		return nil, internalError(err, "Failed to retrieve packet")
+	}
+	if packet == nil {
+		return nil, status.Error(codes.NotFound, "Packet not found")
+	}
+	return toPacket(packet), nil
+}
+
+// DeletePacket removes a packet by ID
+func (s *Server) DeletePacket(ctx context.Context, request *snifferv1.DeletePacketRequest) (*snifferv1.DeletePacketResponse, error) {
+	if err := s.packetService.DeletePacketByID(ctx, request.Id); err != nil {
+		return nil, internalError(err, "Failed to delete packet")
+	}
+	return &snifferv1.DeletePacketResponse{}, nil
+}
+
+// ClearPackets removes every stored packet
+func (s *Server) ClearPackets(ctx context.Context, _ *snifferv1.ClearPacketsRequest) (*snifferv1.ClearPacketsResponse, error) {
+	if err := s.packetService.ClearPackets(ctx); err != nil {
+		return nil, internalError(err, "Failed to clear packets")
+	}
+	return &snifferv1.ClearPacketsResponse{}, nil
+}
+
+// StartSniffing starts capture
+func (s *Server) StartSniffing(ctx context.Context, _ *snifferv1.StartSniffingRequest) (*snifferv1.StartSniffingResponse, error) {
+	if err := s.packetService.StartSniffing(ctx); err != nil {
+		return nil, internalError(err, "Failed to start sniffing")
+	}
+	return &snifferv1.StartSniffingResponse{}, nil
+}
+
+// StopSniffing stops capture
+func (s *Server) StopSniffing(ctx context.Context, _ *snifferv1.StopSniffingRequest) (*snifferv1.StopSniffingResponse, error) {
+	if err := s.packetService.StopSniffing(ctx); err != nil {
+		return nil, internalError(err, "Failed to stop sniffing")
+	}
+	return &snifferv1.StopSniffingResponse{}, nil
+}
+
+// GetSnifferStatus reports whether capture is running
+func (s *Server) GetSnifferStatus(context.Context, *snifferv1.GetSnifferStatusRequest) (*snifferv1.SnifferStatus, error) {
+	return &snifferv1.SnifferStatus{Running: s.packetService.IsSniffingRunning()}, nil
+}
+
+// GetStats returns storage statistics
+func (s *Server) GetStats(ctx context.Context, _ *snifferv1.GetStatsRequest) (*snifferv1.Stats, error) {
+	stats, err := s.packetService.StorageStats(ctx)
+	if err != nil {
+		return nil, internalError(err, "Failed to get stats")
+	}
+	if stats == nil {
+		stats = &models.Stats{}
+	}
+	return toStats(stats), nil
+}
+
+// Subscribe streams the live packets matching a filter until the client
+// cancels the call or the feed is closed
+func (s *Server) Subscribe(request *snifferv1.SubscribeRequest, stream snifferv1.SnifferService_SubscribeServer) error {
+	subscription, ok := s.feed.subscribe(models.PacketFilter{
+		Protocol:      request.Protocol,
+		SourceIP:      request.SourceIp,
+		DestinationIP: request.DestinationIp,
+	})
+	if !ok {
+		return status.Error(codes.Unavailable, "The server is shutting down")
+	}
+	defer s.feed.unsubscribe(subscription)
+
+	ctx := stream.Context()
+	var reported uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.feed.done:
+			return status.Error(codes.Unavailable, "The server is shutting down")
+		case packet := <-subscription.packets:
+			dropped := subscription.dropped.Load()
+			if err := stream.Send(&snifferv1.SubscribeResponse{Packet: toPacket(&packet), Dropped: dropped - reported}); err != nil {
+				return err
+			}
+			reported = dropped
+		}
+	}
+}
+
+// Shutdown closes the packet feed, ending the subscriptions and the
+// forwarding streams of agents, then stops the server gracefully. Calls still running when ctx is done are cancelled.
+func Shutdown(ctx context.Context, server *grpc.Server, feed *Feed) {
+	feed.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+		<-stopped
+	}
+}
+
+// internalError hides the cause of a failed call from the client; it is
+// logged by the interceptors
+func internalError(err error, message string) error {
+	return &callError{status: status.New(codes.Internal, message), cause: err}
+}
+
+// callError is a status returned to the client along with its cause
+type callError struct {
+	status *status.Status
+	cause  error
+}
+
+func (e *callError) Error() string {
+	return e.status.Message() + ": " + e.cause.Error()
+}
+
+// GRPCStatus returns the status sent to the client
+func (e *callError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+func (e *callError) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,368 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/audit"
+	"github.com/cryptonextsecurity/network-sniffer/internal/auth"
+	"github.com/cryptonextsecurity/network-sniffer/internal/collector"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ratelimit"
+	"github.com/cryptonextsecurity/network-sniffer/internal/services"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	snifferv1 "github.com/cryptonextsecurity/network-sniffer/proto/sniffer/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fixture is a gRPC server over an in-memory connection
+type fixture struct {
+	storage   *storage.InMemoryStorage
+	feed      *Feed
+	server    *Server
+	client    snifferv1.SnifferServiceClient
+	collector snifferv1.CollectorServiceClient
+}
+
+// newFixture serves the API of a stopped sniffer; configure adjusts the
+// server before it starts
+func newFixture(t *testing.T, configure func(*Server)) *fixture {
+	t.Helper()
+	store := storage.NewInMemoryStorage(100)
+	feed := NewFeed(10)
+	store.AddObserver(feed)
+	packetService := services.NewPacketService(store, sniffing.NewPacketSniffer(store, time.Hour), nil)
+	server := NewServer(packetService, feed, nil)
+	if configure != nil {
+		configure(server)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	rpcServer := server.Setup()
+	go rpcServer.Serve(listener)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		Shutdown(ctx, rpcServer, feed)
+	})
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &fixture{
+		storage:   store,
+		feed:      feed,
+		server:    server,
+		client:    snifferv1.NewSnifferServiceClient(conn),
+		collector: snifferv1.NewCollectorServiceClient(conn),
+	}
+}
+
+// store adds a packet to storage
+func (f *fixture) store(t *testing.T, packet *models.Packet) *models.Packet {
+	t.Helper()
+	require.NoError(t, f.storage.Store(context.Background(), packet))
+	return packet
+}
+
+func TestServer_Packets(t *testing.T) {
+	f := newFixture(t, nil)
+	ctx := context.Background()
+	tcp := f.store(t, models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 60))
+	f.store(t, models.NewPacket("10.0.0.3", "10.0.0.4", "UDP", 53, 80))
+
+	list, err := f.client.ListPackets(ctx, &snifferv1.ListPacketsRequest{Filter: &snifferv1.PacketFilter{Protocol: "TCP"}})
+	require.NoError(t, err)
+	require.Len(t, list.Packets, 1)
+	assert.Equal(t, int32(1), list.Total)
+	assert.Equal(t, tcp.ID, list.Packets[0].Id)
+	assert.Equal(t, "10.0.0.1", list.Packets[0].SourceIp)
+	assert.Equal(t, int32(443), list.Packets[0].Port)
+	assert.True(t, tcp.Timestamp.Equal(list.Packets[0].Timestamp.AsTime()))
+
+	_, err = f.client.ListPackets(ctx, &snifferv1.ListPacketsRequest{Limit: -1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	packet, err := f.client.GetPacket(ctx, &snifferv1.GetPacketRequest{Id: tcp.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "TCP", packet.Protocol)
+
+	_, err = f.client.DeletePacket(ctx, &snifferv1.DeletePacketRequest{Id: tcp.ID})
+	require.NoError(t, err)
+	_, err = f.client.GetPacket(ctx, &snifferv1.GetPacketRequest{Id: tcp.ID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stats, err := f.client.GetStats(ctx, &snifferv1.GetStatsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), stats.TotalPackets)
+	assert.Equal(t, int32(100), stats.Capacity)
+	assert.NotNil(t, stats.NewestAt)
+
+	_, err = f.client.StartSniffing(ctx, &snifferv1.StartSniffingRequest{})
+	require.NoError(t, err)
+	running, err := f.client.GetSnifferStatus(ctx, &snifferv1.GetSnifferStatusRequest{})
+	require.NoError(t, err)
+	assert.True(t, running.Running)
+	_, err = f.client.StopSniffing(ctx, &snifferv1.StopSniffingRequest{})
+	require.NoError(t, err)
+
+	_, err = f.client.ClearPackets(ctx, &snifferv1.ClearPacketsRequest{})
+	require.NoError(t, err)
+	stats, err = f.client.GetStats(ctx, &snifferv1.GetStatsRequest{})
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalPackets)
+	assert.Nil(t, stats.NewestAt)
+}
+
+func TestServer_Auth(t *testing.T) {
+	keys, err := auth.OpenKeyStore("")
+	require.NoError(t, err)
+	keys.AddStatic("dashboard", "nsk_viewer", auth.RoleViewer)
+	keys.AddStatic("ops", "nsk_admin", auth.RoleAdmin)
+	auditLog, err := audit.Open("", 10)
+	require.NoError(t, err)
+
+	f := newFixture(t, func(s *Server) {
+		s.WithAuth(auth.NewAuthenticator(keys, nil)).WithAudit(auditLog)
+	})
+	viewer := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "nsk_viewer")
+	admin := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer nsk_admin")
+
+	_, err = f.client.GetStats(context.Background(), &snifferv1.GetStatsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = f.client.GetStats(metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "nsk_unknown"), &snifferv1.GetStatsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = f.client.GetStats(viewer, &snifferv1.GetStatsRequest{})
+	assert.NoError(t, err)
+	_, err = f.client.ClearPackets(viewer, &snifferv1.ClearPacketsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = f.client.DeletePacket(admin, &snifferv1.DeletePacketRequest{Id: "packet_1"})
+	assert.NoError(t, err)
+
+	// Mutating calls are audited, including the rejected ones
+	entries := auditLog.List(&models.AuditFilter{})
+	require.Len(t, entries, 2)
+	assert.Equal(t, "packets.delete", entries[0].Action)
+	assert.Equal(t, "ops", entries[0].Actor)
+	assert.Equal(t, models.AuditSuccess, entries[0].Outcome)
+	assert.Equal(t, map[string]any{"id": "packet_1"}, entries[0].Parameters)
+	assert.Equal(t, snifferv1.SnifferService_DeletePacket_FullMethodName, entries[0].Path)
+	assert.Equal(t, "packets.clear", entries[1].Action)
+	assert.Equal(t, "dashboard", entries[1].Actor)
+	assert.Equal(t, models.AuditDenied, entries[1].Outcome)
+	assert.Equal(t, 403, entries[1].Status)
+}
+
+func TestServer_RateLimits(t *testing.T) {
+	f := newFixture(t, func(s *Server) {
+		s.WithRateLimits(ratelimit.Limit{Rate: 100, Burst: 100}, ratelimit.Limit{Rate: 0.001, Burst: 1})
+	})
+	ctx := context.Background()
+
+	_, err := f.client.ListPackets(ctx, &snifferv1.ListPacketsRequest{})
+	require.NoError(t, err)
+	var trailer metadata.MD
+	_, err = f.client.ListPackets(ctx, &snifferv1.ListPacketsRequest{}, grpc.Trailer(&trailer))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, trailer.Get("retry-after"))
+
+	// Other methods use the standard budget
+	_, err = f.client.GetStats(ctx, &snifferv1.GetStatsRequest{})
+	assert.NoError(t, err)
+}
+
+func TestServer_RateLimitFailedAuthentication(t *testing.T) {
+	keys, err := auth.OpenKeyStore("")
+	require.NoError(t, err)
+	keys.AddStatic("ops", "nsk_admin", auth.RoleAdmin)
+	f := newFixture(t, func(s *Server) {
+		s.WithAuth(auth.NewAuthenticator(keys, nil)).
+			WithRateLimits(ratelimit.Limit{Rate: 0.001, Burst: 2}, ratelimit.Limit{Rate: 0.001, Burst: 2})
+	})
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	// Guessed keys are charged to the client IP until it is throttled
+	for _, key := range []string{"nsk_guess1", "nsk_guess2"} {
+		_, err = f.client.GetStats(withKey(key), &snifferv1.GetStatsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+	_, err = f.client.GetStats(withKey("nsk_guess3"), &snifferv1.GetStatsRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Valid keys keep their own budget
+	_, err = f.client.GetStats(withKey("nsk_admin"), &snifferv1.GetStatsRequest{})
+	assert.NoError(t, err)
+}
+
+func TestServer_Subscribe(t *testing.T) {
+	f := newFixture(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := f.client.Subscribe(ctx, &snifferv1.SubscribeRequest{Protocol: "UDP"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return f.feed.Len() == 1 }, time.Second, time.Millisecond)
+
+	f.store(t, models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 60))
+	uploaded := models.NewPacket("10.0.0.1", "10.0.0.2", "UDP", 53, 60)
+	uploaded.Session = "upload_1"
+	f.store(t, uploaded)
+	udp := f.store(t, models.NewPacket("10.0.0.3", "10.0.0.4", "UDP", 53, 80))
+
+	// Only the matching live packet is streamed
+	response, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, udp.ID, response.Packet.Id)
+	assert.Zero(t, response.Dropped)
+
+	// Closing the feed ends the subscription
+	f.feed.Close()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Eventually(t, func() bool { return f.feed.Len() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestServer_Collector(t *testing.T) {
+	registry := collector.NewRegistry(10)
+	f := newFixture(t, func(s *Server) { s.WithCollector(registry) })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	batch := &snifferv1.ForwardPacketsRequest{
+		AgentId:  "edge-1",
+		BufferId: "buf_1",
+		Sequence: 1,
+		Packets: []*snifferv1.Packet{
+			{Id: "remote_1", SourceIp: "10.0.0.1", DestinationIp: "10.0.0.2", Protocol: "TCP", Port: 443, Size: 60, Timestamp: timestamppb.Now(), Session: "upload_1", Agent: "spoofed"},
+			{SourceIp: "10.0.0.1", DestinationIp: "10.0.0.2", Protocol: "TCP", Port: 443, Size: 60},
+		},
+	}
+
+	// Agents register before forwarding
+	stream, err := f.collector.ForwardPackets(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(batch))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = f.collector.RegisterAgent(ctx, &snifferv1.RegisterAgentRequest{AgentId: "edge 1"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	agent, err := f.collector.RegisterAgent(ctx, &snifferv1.RegisterAgentRequest{AgentId: "edge-1", Hostname: "edge-host"})
+	require.NoError(t, err)
+	assert.Equal(t, "edge-1", agent.Id)
+
+	stream, err = f.collector.ForwardPackets(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(batch))
+	ack, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), ack.Sequence)
+	assert.Equal(t, int32(1), ack.Accepted)
+	assert.Equal(t, int32(1), ack.Rejected)
+	assert.False(t, ack.Duplicate)
+
+	// A resent batch is acknowledged without being stored again
+	require.NoError(t, stream.Send(batch))
+	ack, err = stream.Recv()
+	require.NoError(t, err)
+	assert.True(t, ack.Duplicate)
+
+	// Forwarded packets are live traffic tagged with the agent
+	list, err := f.client.ListPackets(ctx, &snifferv1.ListPacketsRequest{Filter: &snifferv1.PacketFilter{Agent: "edge-1"}})
+	require.NoError(t, err)
+	require.Len(t, list.Packets, 1)
+	assert.Equal(t, "edge-1", list.Packets[0].Agent)
+	assert.NotEqual(t, "remote_1", list.Packets[0].Id)
+
+	agents, err := f.collector.ListAgents(ctx, &snifferv1.ListAgentsRequest{})
+	require.NoError(t, err)
+	require.Len(t, agents.Agents, 1)
+	assert.True(t, agents.Agents[0].Connected)
+	assert.Equal(t, uint64(1), agents.Agents[0].Packets)
+
+	// A stream serves a single agent
+	require.NoError(t, stream.Send(&snifferv1.ForwardPacketsRequest{AgentId: "edge-2", Sequence: 2}))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Eventually(t, func() bool { return !registry.List()[0].Connected }, time.Second, time.Millisecond)
+
+	// Shutting down ends the streams of idle agents
+	stream, err = f.collector.ForwardPackets(ctx)
+	require.NoError(t, err)
+	batch.Sequence = 2
+	require.NoError(t, stream.Send(batch))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	f.feed.Close()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestServer_CollectorAgentOwners(t *testing.T) {
+	keys, err := auth.OpenKeyStore("")
+	require.NoError(t, err)
+	keys.AddStatic("edge", "nsk_edge", auth.RoleAnalyst)
+	keys.AddStatic("other", "nsk_other", auth.RoleAnalyst)
+	f := newFixture(t, func(s *Server) {
+		s.WithAuth(auth.NewAuthenticator(keys, nil)).WithCollector(collector.NewRegistry(1))
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	edge := metadata.AppendToOutgoingContext(ctx, "x-api-key", "nsk_edge")
+	other := metadata.AppendToOutgoingContext(ctx, "x-api-key", "nsk_other")
+
+	_, err = f.collector.RegisterAgent(edge, &snifferv1.RegisterAgentRequest{AgentId: "edge-1"})
+	require.NoError(t, err)
+
+	// Other principals can neither take over nor forward as the agent
+	_, err = f.collector.RegisterAgent(other, &snifferv1.RegisterAgentRequest{AgentId: "edge-1"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	stream, err := f.collector.ForwardPackets(other)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&snifferv1.ForwardPacketsRequest{AgentId: "edge-1", BufferId: "buf_1", Sequence: 1}))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// The registry is full
+	_, err = f.collector.RegisterAgent(other, &snifferv1.RegisterAgentRequest{AgentId: "edge-2"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestServer_CollectorDisabled(t *testing.T) {
+	f := newFixture(t, nil)
+	_, err := f.collector.ListAgents(context.Background(), &snifferv1.ListAgentsRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestFeed_Drops(t *testing.T) {
+	feed := NewFeed(2)
+	subscription, ok := feed.subscribe(models.PacketFilter{})
+	require.True(t, ok)
+
+	for i := 0; i < 5; i++ {
+		feed.OnStore(models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 60))
+	}
+	assert.Len(t, subscription.packets, 2)
+	assert.Equal(t, uint64(3), subscription.dropped.Load())
+
+	feed.unsubscribe(subscription)
+	feed.Close()
+	_, ok = feed.subscribe(models.PacketFilter{})
+	assert.False(t, ok)
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+)
+
+// stallIntervals is the number of capture intervals without a capture
+// after which a running sniffer is considered stalled
+const stallIntervals = 3
+
+// errUnsupported is returned by freeBytes on platforms where free disk
+// space cannot be read
+var errUnsupported = errors.New("free disk space is not supported on this platform")
+
+// StorageCheck reports storage degraded when it does not accept writes or
+// cannot report its statistics
+func StorageCheck(store storage.Storage) Check {
+	return func(ctx context.Context) models.ComponentHealth {
+		if checker, ok := store.(storage.WritableChecker); ok {
+			if err := checker.CheckWritable(ctx); err != nil {
+				return Degraded("storage is not writable: " + err.Error())
+			}
+		}
+
+		stats, err := store.Stats(ctx)
+		if err != nil {
+			return Degraded("failed to read storage statistics: " + err.Error())
+		}
+		result := OK("storage is writable")
+		result.Details = map[string]interface{}{
+			"packets":  stats.TotalPackets,
+			"capacity": stats.Capacity,
+		}
+		return result
+	}
+}
+
+// SnifferStatus is implemented by sniffers reporting their capture state
+type SnifferStatus interface {
+	Status() sniffing.Status
+}
+
+// SnifferCheck reports the sniffer healthy while it captures packets at
+// its interval, or when it was stopped on request. A sniffer that never
+// started, stopped on its own or stalled is degraded.
+func SnifferCheck(sniffer SnifferStatus) Check {
+	return func(ctx context.Context) models.ComponentHealth {
+		status := sniffer.Status()
+		details := map[string]interface{}{
+			"running":  status.Running,
+			"interval": status.Interval.String(),
+		}
+		if !status.LastCapture.IsZero() {
+			details["last_capture"] = status.LastCapture
+		}
+
+		var result models.ComponentHealth
+		switch {
+		case !status.Running && status.StopReason == sniffing.StopReasonRequested:
+			result = OK("sniffing was stopped on request")
+		case !status.Running:
+			result = Degraded("sniffing is not running: " + status.StopReason)
+		default:
+			last := status.LastCapture
+			if last.Before(status.StartedAt) {
+				last = status.StartedAt
+			}
+			if idle := time.Since(last); idle > stallIntervals*status.Interval {
+				result = Degraded(fmt.Sprintf("no packet captured for %s", idle.Round(time.Second)))
+			} else {
+				result = OK("sniffing is running")
+			}
+		}
+		result.Details = details
+		return result
+	}
+}
+
+// DiskCheck reports degraded when the file system holding any of the
+// given files has less than minFree bytes available. Empty paths are
+// ignored, as is free space on platforms where it cannot be read.
+func DiskCheck(paths []string, minFree uint64) Check {
+	return func(ctx context.Context) models.ComponentHealth {
+		details := make(map[string]interface{})
+		result := OK("enough disk space available")
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			free, err := freeBytes(filepath.Dir(path))
+			if errors.Is(err, errUnsupported) {
+				details[path] = "unknown"
+				continue
+			}
+			if err != nil {
+				result = Degraded(fmt.Sprintf("failed to read free space for %s: %v", path, err))
+				continue
+			}
+			details[path] = free
+			if free < minFree && result.Status == models.HealthOK {
+				result = Degraded(fmt.Sprintf("%s has %d bytes free, below the %d bytes minimum", path, free, minFree))
+			}
+		}
+		result.Details = details
+		return result
+	}
+}
+
+// NotifierBacklog is implemented by notifiers reporting their queue usage
+type NotifierBacklog interface {
+	Backlog() (queued, capacity int)
+	DeadLetterCount() int
+}
+
+// NotifierCheck reports degraded when the queued notifications exceed
+// maxRatio of the queue capacity, as new ones would soon be dead-lettered
+func NotifierCheck(notifier NotifierBacklog, maxRatio float64) Check {
+	return func(ctx context.Context) models.ComponentHealth {
+		queued, capacity := notifier.Backlog()
+		result := OK("notification backlog within limits")
+		if capacity > 0 && float64(queued) > maxRatio*float64(capacity) {
+			result = Degraded(fmt.Sprintf("%d of %d notification queue slots in use", queued, capacity))
+		}
+		result.Details = map[string]interface{}{
+			"queued":       queued,
+			"capacity":     capacity,
+			"dead_letters": notifier.DeadLetterCount(),
+		}
+		return result
+	}
+}
+
+// Forwarder is implemented by agents reporting their link to the collector
+type Forwarder interface {
+	Connected() bool
+	Buffered() (batches int, bytes int64)
+}
+
+// ForwarderCheck reports degraded while an agent is not connected to its
+// collector; captured packets are buffered on disk meanwhile
+func ForwarderCheck(forwarder Forwarder) Check {
+	return func(ctx context.Context) models.ComponentHealth {
+		result := OK("connected to the collector")
+		if !forwarder.Connected() {
+			result = Degraded("not connected to the collector, buffering packets")
+		}
+		batches, bytes := forwarder.Buffered()
+		result.Details = map[string]interface{}{
+			"buffered_batches": batches,
+			"buffered_bytes":   bytes,
+		}
+		return result
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package health
+
+// freeBytes is not supported on this platform
+func freeBytes(dir string) (uint64, error) {
+	return 0, errUnsupported
+}
@@ -0,0 +1,108 @@
+// Package health runs the component checks behind the liveness and
+// readiness probes.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// Check reports the health of one component. Checks should return once ctx
+// is done.
+type Check func(ctx context.Context) models.ComponentHealth
+
+// Checker runs a set of named checks concurrently, each bounded by a
+// timeout
+type Checker struct {
+	timeout time.Duration
+
+	mutex  sync.RWMutex
+	checks map[string]Check
+}
+
+// NewChecker creates a checker whose checks are abandoned after timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Register adds or replaces the check of a component
+func (c *Checker) Register(name string, check Check) *Checker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.checks[name] = check
+	return c
+}
+
+// Run runs every check and aggregates their results. A check that does
+// not return within the timeout is reported degraded.
+func (c *Checker) Run(ctx context.Context) models.HealthReport {
+	c.mutex.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mutex.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	report := models.HealthReport{
+		Status:     models.HealthOK,
+		Components: make(map[string]models.ComponentHealth, len(checks)),
+	}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := run(ctx, check)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Components[name] = result
+			if result.Status != models.HealthOK {
+				report.Status = models.HealthDegraded
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	report.Timestamp = time.Now()
+	return report
+}
+
+// run runs one check, giving up when ctx is done
+func run(ctx context.Context, check Check) models.ComponentHealth {
+	start := time.Now()
+	done := make(chan models.ComponentHealth, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var result models.ComponentHealth
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = Degraded("check did not complete: " + ctx.Err().Error())
+	}
+	result.Duration = float64(time.Since(start).Microseconds()) / 1000
+	return result
+}
+
+// OK returns a healthy result
+func OK(message string) models.ComponentHealth {
+	return models.ComponentHealth{Status: models.HealthOK, Message: message}
+}
+
+// Degraded returns an unhealthy result
+func Degraded(message string) models.ComponentHealth {
+	return models.ComponentHealth{Status: models.HealthDegraded, Message: message}
+}
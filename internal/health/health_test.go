@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSniffer sniffing.Status
+
+func (f fakeSniffer) Status() sniffing.Status { return sniffing.Status(f) }
+
+type fakeNotifier struct{ queued, capacity int }
+
+func (f fakeNotifier) Backlog() (int, int)  { return f.queued, f.capacity }
+func (f fakeNotifier) DeadLetterCount() int { return 2 }
+
+type fakeForwarder struct{ connected bool }
+
+func (f fakeForwarder) Connected() bool        { return f.connected }
+func (f fakeForwarder) Buffered() (int, int64) { return 3, 1024 }
+
+func TestChecker_Run(t *testing.T) {
+	checker := NewChecker(50*time.Millisecond).
+		Register("fast", func(ctx context.Context) models.ComponentHealth { return OK("fine") })
+
+	report := checker.Run(context.Background())
+	assert.Equal(t, models.HealthOK, report.Status)
+	assert.Equal(t, "fine", report.Components["fast"].Message)
+
+	checker.Register("slow", func(ctx context.Context) models.ComponentHealth {
+		time.Sleep(time.Second)
+		return OK("too late")
+	})
+	report = checker.Run(context.Background())
+	assert.Equal(t, models.HealthDegraded, report.Status)
+	assert.Equal(t, models.HealthOK, report.Components["fast"].Status)
+	assert.Equal(t, models.HealthDegraded, report.Components["slow"].Status)
+	assert.Contains(t, report.Components["slow"].Message, "did not complete")
+}
+
+func TestStorageCheck(t *testing.T) {
+	store := storage.NewInMemoryStorage(10)
+	result := StorageCheck(store)(context.Background())
+	assert.Equal(t, models.HealthOK, result.Status)
+	assert.Equal(t, 10, result.Details["capacity"])
+}
+
+func TestSnifferCheck(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		status sniffing.Status
+		want   string
+	}{
+		{"capturing", sniffing.Status{Running: true, Interval: time.Second, StartedAt: now.Add(-time.Minute), LastCapture: now}, models.HealthOK},
+		{"just started", sniffing.Status{Running: true, Interval: time.Second, StartedAt: now}, models.HealthOK},
+		{"stalled", sniffing.Status{Running: true, Interval: time.Second, StartedAt: now.Add(-time.Minute), LastCapture: now.Add(-10 * time.Second)}, models.HealthDegraded},
+		{"stopped on request", sniffing.Status{StopReason: sniffing.StopReasonRequested}, models.HealthOK},
+		{"never started", sniffing.Status{StopReason: sniffing.StopReasonNotStarted}, models.HealthDegraded},
+		{"context cancelled", sniffing.Status{StopReason: "context canceled"}, models.HealthDegraded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SnifferCheck(fakeSniffer(tt.status))(context.Background())
+			assert.Equal(t, tt.want, result.Status, result.Message)
+		})
+	}
+}
+
+func TestDiskCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	result := DiskCheck([]string{"", path}, 0)(context.Background())
+	require.Equal(t, models.HealthOK, result.Status, result.Message)
+	assert.Contains(t, result.Details, path)
+
+	result = DiskCheck([]string{path}, math.MaxUint64)(context.Background())
+	assert.Equal(t, models.HealthDegraded, result.Status)
+}
+
+func TestNotifierCheck(t *testing.T) {
+	result := NotifierCheck(fakeNotifier{queued: 10, capacity: 100}, 0.9)(context.Background())
+	assert.Equal(t, models.HealthOK, result.Status)
+	assert.Equal(t, 2, result.Details["dead_letters"])
+
+	result = NotifierCheck(fakeNotifier{queued: 95, capacity: 100}, 0.9)(context.Background())
+	assert.Equal(t, models.HealthDegraded, result.Status)
+
+	result = NotifierCheck(fakeNotifier{}, 0.9)(context.Background())
+	assert.Equal(t, models.HealthOK, result.Status)
+}
+
+func TestForwarderCheck(t *testing.T) {
+	result := ForwarderCheck(fakeForwarder{connected: true})(context.Background())
+	assert.Equal(t, models.HealthOK, result.Status)
+	assert.Equal(t, 3, result.Details["buffered_batches"])
+
+	result = ForwarderCheck(fakeForwarder{})(context.Background())
+	assert.Equal(t, models.HealthDegraded, result.Status)
+}
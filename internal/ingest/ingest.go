@@ -0,0 +1,151 @@
+// Package ingest brings packets captured outside the service into storage.
+// Packets pushed by producers such as remote capture agents and test
+// harnesses are validated against the validate tags of models.Packet, and
+// batches are read as NDJSON, one packet per line, so that every packet is
+// accepted or rejected on its own. Uploaded capture files are decoded in
+// the background by jobs, each into a session of its own.
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/go-playground/validator/v10"
+)
+
+// Errors returned for rejected input
+var (
+	ErrInvalidPacket  = errors.New("invalid packet")
+	ErrTooManyPackets = errors.New("too many packets")
+	ErrLineTooLong    = errors.New("line too long")
+)
+
+// MaxLineSize is the size of the longest NDJSON line ReadBatch accepts
+const MaxLineSize = 1 << 20
+
+// Range of the timestamps of ingested packets. Packets dated further in
+// the future would hold back the time of the alert rules and detectors;
+// MaxPacketAge leaves agents a day to forward the packets they buffered.
+const (
+	MaxClockSkew = 5 * time.Minute
+	MaxPacketAge = 24 * time.Hour
+)
+
+// validate checks structs against their validate tags and names fields by
+// their JSON name
+var validate = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}()
+
+// Validate checks a packet against the validate tags of models.Packet, and
+// that its timestamp is at most MaxClockSkew ahead and MaxPacketAge behind
+// the current time. The returned error wraps ErrInvalidPacket and
+// describes every invalid field.
+func Validate(packet *models.Packet) error {
+	var problems []string
+	if err := validate.Struct(packet); err != nil {
+		var fieldErrors validator.ValidationErrors
+		if !errors.As(err, &fieldErrors) {
+			return fmt.Errorf("%w: %v", ErrInvalidPacket, err)
+		}
+		for _, fieldError := range fieldErrors {
+			problems = append(problems, describe(fieldError))
+		}
+	}
+	if !packet.Timestamp.IsZero() {
+		now := time.Now()
+		switch {
+		case packet.Timestamp.After(now.Add(MaxClockSkew)):
+			problems = append(problems, fmt.Sprintf("timestamp is more than %s in the future", MaxClockSkew))
+		case packet.Timestamp.Before(now.Add(-MaxPacketAge)):
+			problems = append(problems, fmt.Sprintf("timestamp is more than %s old", MaxPacketAge))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidPacket, strings.Join(problems, "; "))
+}
+
+// describe explains a failed validation of a field
+func describe(fieldError validator.FieldError) string {
+	field := fieldError.Field()
+	switch fieldError.Tag() {
+	case "required":
+		return field + " is required"
+	case "ip":
+		return fmt.Sprintf("%s %q is not an IP address", field, fieldError.Value())
+	case "oneof":
+		return fmt.Sprintf("%s %q is not one of %s", field, fieldError.Value(), strings.ReplaceAll(fieldError.Param(), " ", ", "))
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", field, fieldError.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", field, fieldError.Param())
+	}
+	return fmt.Sprintf("%s fails the %s check", field, fieldError.Tag())
+}
+
+// Line is a packet read from a line of a batch
+type Line struct {
+	// Number is the line number, starting at 1
+	Number int
+	// Packet is the decoded packet, nil when Err is set
+	Packet *models.Packet
+	// Err wraps ErrInvalidPacket when the line is not a JSON packet
+	Err error
+}
+
+// ReadBatch reads the NDJSON packets of r. Blank lines are skipped but
+// counted, so that line numbers match the ones of the input. A line that
+// does not decode is returned with its error rather than failing the
+// batch. The whole batch is refused with ErrTooManyPackets when it holds
+// more than limit packets, with ErrLineTooLong when a line exceeds
+// MaxLineSize, or with the error of r.
+func ReadBatch(r io.Reader, limit int) ([]Line, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxLineSize)
+
+	var lines []Line
+	number := 0
+	for scanner.Scan() {
+		number++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(lines) == limit {
+			return nil, fmt.Errorf("%w: a batch holds at most %d packets", ErrTooManyPackets, limit)
+		}
+		line := Line{Number: number}
+		var packet models.Packet
+		if err := json.Unmarshal(data, &packet); err != nil {
+			line.Err = fmt.Errorf("%w: %v", ErrInvalidPacket, err)
+		} else {
+			line.Packet = &packet
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("%w: line %d exceeds %d bytes", ErrLineTooLong, number+1, MaxLineSize)
+		}
+		return nil, err
+	}
+	return lines, nil
+}
@@ -0,0 +1,83 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validPacket() *models.Packet {
+	return &models.Packet{
+		ID:            "p1",
+		SourceIP:      "10.0.0.1",
+		DestinationIP: "2001:db8::1",
+		Protocol:      "TCP",
+		Port:          443,
+		Size:          60,
+		Timestamp:     time.Now(),
+	}
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate(validPacket()))
+
+	packet := validPacket()
+	packet.ID = ""
+	packet.SourceIP = "10.0.0.300"
+	packet.Protocol = "tcp"
+	packet.Port = 70000
+	packet.Size = 0
+	packet.Timestamp = time.Time{}
+	err := Validate(packet)
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+	assert.Equal(t, `invalid packet: id is required; source_ip "10.0.0.300" is not an IP address; `+
+		`protocol "tcp" is not one of TCP, UDP, ICMP, HTTP, HTTPS; port must be at most 65535; `+
+		`size must be at least 1; timestamp is required`, err.Error())
+}
+
+func TestValidate_Timestamp(t *testing.T) {
+	packet := validPacket()
+	packet.Timestamp = time.Now().Add(MaxClockSkew / 2)
+	assert.NoError(t, Validate(packet))
+
+	packet.Timestamp = time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.EqualError(t, Validate(packet), "invalid packet: timestamp is more than 5m0s in the future")
+	packet.Timestamp = time.Now().Add(-MaxPacketAge - time.Minute)
+	assert.EqualError(t, Validate(packet), "invalid packet: timestamp is more than 24h0m0s old")
+}
+
+func TestReadBatch(t *testing.T) {
+	batch := `{"source_ip":"10.0.0.1","destination_ip":"10.0.0.2","protocol":"UDP","port":53,"size":80,"timestamp":"2024-01-02T03:04:05Z"}
+
+not json
+{"source_ip":"10.0.0.1","port":"53"}
+`
+	lines, err := ReadBatch(strings.NewReader(batch), 10)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+
+	assert.Equal(t, 1, lines[0].Number)
+	require.NoError(t, lines[0].Err)
+	assert.Equal(t, "UDP", lines[0].Packet.Protocol)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), lines[0].Packet.Timestamp)
+
+	// Blank lines are counted but not returned
+	assert.Equal(t, 3, lines[1].Number)
+	assert.ErrorIs(t, lines[1].Err, ErrInvalidPacket)
+	assert.Nil(t, lines[1].Packet)
+	assert.Equal(t, 4, lines[2].Number)
+	assert.ErrorIs(t, lines[2].Err, ErrInvalidPacket)
+}
+
+func TestReadBatch_Limits(t *testing.T) {
+	_, err := ReadBatch(strings.NewReader("{}\n{}\n{}\n"), 2)
+	assert.ErrorIs(t, err, ErrTooManyPackets)
+
+	_, err = ReadBatch(strings.NewReader("{}\n\n"+strings.Repeat(" ", MaxLineSize+1)), 10)
+	assert.ErrorIs(t, err, ErrLineTooLong)
+	assert.Contains(t, err.Error(), "line 3 ")
+}
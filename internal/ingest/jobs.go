@@ -0,0 +1,398 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/pcap"
+)
+
+// Errors returned for refused uploads
+var (
+	ErrTooLarge   = errors.New("capture file too large")
+	ErrNotCapture = errors.New("not a pcap or pcapng file")
+	ErrQueueFull  = errors.New("too many captures waiting to be decoded")
+	ErrStopped    = errors.New("ingestion stopped")
+)
+
+// queueSize is the number of uploads that may wait for the decoder
+const queueSize = 16
+
+// gzipMagic starts gzip compressed files
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Config configures the ingestion of uploaded captures
+type Config struct {
+	// MaxSize is the size in bytes of the largest file accepted
+	MaxSize int64
+	// Dir is the directory uploads are spooled to until they are decoded,
+	// the system temporary directory when empty
+	Dir string
+	// HistorySize is the number of finished jobs kept
+	HistorySize int
+}
+
+// DefaultConfig returns the ingestion settings used for unset values
+func DefaultConfig() Config {
+	return Config{MaxSize: 100 << 20, HistorySize: 100}
+}
+
+// Jobs decodes uploaded capture files, one at a time and in order of
+// upload, into the packets of a new session. Each upload is tracked by a
+// job, which reports the progress of the decoding while it runs and its
+// outcome once finished.
+type Jobs struct {
+	config  Config
+	storage storage.Storage
+	logger  *slog.Logger
+	queue   chan *job
+
+	mutex   sync.Mutex
+	jobs    []*job
+	started bool
+	stopped bool
+	stop    chan struct{}
+	cancel  context.CancelFunc
+	ctx     context.Context
+	wg      sync.WaitGroup
+}
+
+// job is an ingestion job and the spooled file it decodes
+type job struct {
+	models.IngestJob
+	path string
+}
+
+// NewJobs creates the ingestion jobs of captures stored into storage. A nil
+// logger selects the default logger.
+func NewJobs(config Config, storage storage.Storage, logger *slog.Logger) *Jobs {
+	defaults := DefaultConfig()
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaults.MaxSize
+	}
+	if config.HistorySize <= 0 {
+		config.HistorySize = defaults.HistorySize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Jobs{
+		config:  config,
+		storage: storage,
+		logger:  logging.OrDefault(logger),
+		queue:   make(chan *job, queueSize),
+		stop:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// MaxSize returns the size in bytes of the largest capture file accepted
+func (j *Jobs) MaxSize() int64 {
+	return j.config.MaxSize
+}
+
+// Start launches the decoder
+func (j *Jobs) Start() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.started || j.stopped {
+		return
+	}
+	j.started = true
+	j.wg.Add(1)
+	go j.run()
+}
+
+// Stop interrupts the running job and fails the queued ones. Their spooled
+// files are removed; the packets already stored are kept.
+func (j *Jobs) Stop() {
+	j.mutex.Lock()
+	if j.stopped {
+		j.mutex.Unlock()
+		return
+	}
+	j.stopped = true
+	close(j.stop)
+	j.cancel()
+	j.mutex.Unlock()
+
+	j.wg.Wait()
+	for len(j.queue) > 0 {
+		queued := <-j.queue
+		os.Remove(queued.path)
+		j.finish(queued, ErrStopped)
+	}
+}
+
+// Upload spools a pcap or pcapng file, which may be gzip compressed, and
+// queues it for decoding into a new session. The file is refused with
+// ErrNotCapture when it does not start like a capture, ErrTooLarge when it
+// exceeds the maximum size and ErrQueueFull when too many files wait.
+func (j *Jobs) Upload(name string, r io.Reader) (*models.IngestJob, error) {
+	input := bufio.NewReader(r)
+	header, _ := input.Peek(4)
+	if !bytes.HasPrefix(header, gzipMagic) && !pcap.IsCapture(header) {
+		return nil, ErrNotCapture
+	}
+
+	file, err := os.CreateTemp(j.config.Dir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(file, io.LimitReader(input, j.config.MaxSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > j.config.MaxSize {
+		err = fmt.Errorf("%w: the limit is %d bytes", ErrTooLarge, j.config.MaxSize)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	id := newID()
+	queued := &job{
+		IngestJob: models.IngestJob{
+			ID:        "job_" + id,
+			Session:   "upload_" + id,
+			FileName:  name,
+			Size:      size,
+			Status:    models.IngestJobQueued,
+			CreatedAt: time.Now(),
+		},
+		path: file.Name(),
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.stopped {
+		os.Remove(queued.path)
+		return nil, ErrStopped
+	}
+	select {
+	case j.queue <- queued:
+	default:
+		os.Remove(queued.path)
+		return nil, ErrQueueFull
+	}
+	j.jobs = append(j.jobs, queued)
+	j.prune()
+	snapshot := queued.snapshot()
+	return &snapshot, nil
+}
+
+// Job returns a job by ID
+func (j *Jobs) Job(id string) (*models.IngestJob, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for _, tracked := range j.jobs {
+		if tracked.ID == id {
+			snapshot := tracked.snapshot()
+			return &snapshot, true
+		}
+	}
+	return nil, false
+}
+
+// List returns every job, newest first
+func (j *Jobs) List() []models.IngestJob {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	jobs := make([]models.IngestJob, 0, len(j.jobs))
+	for i := len(j.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, j.jobs[i].snapshot())
+	}
+	return jobs
+}
+
+// run decodes the queued files until the jobs are stopped
+func (j *Jobs) run() {
+	defer j.wg.Done()
+	for {
+		select {
+		case <-j.stop:
+			return
+		case queued := <-j.queue:
+			j.ingest(queued)
+		}
+	}
+}
+
+// ingest decodes the spooled file of a job into its session
+func (j *Jobs) ingest(queued *job) {
+	defer os.Remove(queued.path)
+
+	j.mutex.Lock()
+	started := time.Now()
+	queued.Status = models.IngestJobRunning
+	queued.StartedAt = &started
+	j.mutex.Unlock()
+
+	file, err := os.Open(queued.path)
+	if err == nil {
+		err = j.decode(queued, &countingReader{r: file})
+		file.Close()
+	}
+	j.finish(queued, err)
+
+	snapshot := queued.snapshot()
+	attributes := []any{
+		"job_id", snapshot.ID, "session", snapshot.Session, "file", snapshot.FileName,
+		"packets", snapshot.PacketsStored, "skipped", snapshot.PacketsSkipped,
+		"duration", time.Since(started).String(),
+	}
+	if err != nil {
+		j.logger.Error("Capture ingestion failed", append(attributes, "error", err.Error())...)
+		return
+	}
+	j.logger.Info("Capture ingested", attributes...)
+}
+
+// decode stores the packets of a capture read from file
+func (j *Jobs) decode(queued *job, file *countingReader) error {
+	input := bufio.NewReader(file)
+	if header, _ := input.Peek(2); bytes.Equal(header, gzipMagic) {
+		decompressed, err := gzip.NewReader(input)
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		input = bufio.NewReader(decompressed)
+	}
+
+	reader, err := pcap.NewReader(input)
+	if err != nil {
+		return err
+	}
+	counter, _ := j.storage.(storage.SessionCounter)
+	stored, kept := 0, 0
+	for {
+		if err := j.ctx.Err(); err != nil {
+			return ErrStopped
+		}
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		packet, decodeErr := pcap.Decode(record)
+		if decodeErr == nil {
+			packet.Session = queued.Session
+			if err := j.storage.Store(j.ctx, packet); err != nil {
+				return err
+			}
+			stored++
+			kept = stored
+			// A capture larger than the capacity of sessions evicts its
+			// own oldest packets
+			if counter != nil {
+				if kept, err = counter.SessionSize(j.ctx, queued.Session); err != nil {
+					return err
+				}
+			}
+		}
+
+		j.mutex.Lock()
+		queued.PacketsRead++
+		if decodeErr != nil {
+			queued.PacketsSkipped++
+			if queued.DecodeErrors == nil {
+				queued.DecodeErrors = make(map[string]int)
+			}
+			queued.DecodeErrors[decodeErr.Error()]++
+		}
+		queued.PacketsStored = kept
+		queued.PacketsEvicted = stored - kept
+		queued.Progress = min(float64(file.n)/float64(max(queued.Size, 1)), 1)
+		j.mutex.Unlock()
+	}
+}
+
+// finish records the outcome of a job and forgets old finished jobs
+func (j *Jobs) finish(queued *job, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	finished := time.Now()
+	queued.FinishedAt = &finished
+	if err != nil {
+		queued.Status = models.IngestJobFailed
+		queued.Error = err.Error()
+	} else {
+		queued.Status = models.IngestJobCompleted
+		queued.Progress = 1
+	}
+	j.prune()
+}
+
+// prune drops the oldest finished jobs beyond the history size
+func (j *Jobs) prune() {
+	finished := 0
+	for _, tracked := range j.jobs {
+		if tracked.FinishedAt != nil {
+			finished++
+		}
+	}
+	kept := j.jobs[:0]
+	for _, tracked := range j.jobs {
+		if tracked.FinishedAt != nil && finished > j.config.HistorySize {
+			finished--
+			continue
+		}
+		kept = append(kept, tracked)
+	}
+	clear(j.jobs[len(kept):])
+	j.jobs = kept
+}
+
+// snapshot copies the state of a job
+func (j *job) snapshot() models.IngestJob {
+	snapshot := j.IngestJob
+	if j.DecodeErrors != nil {
+		snapshot.DecodeErrors = make(map[string]int, len(j.DecodeErrors))
+		for reason, count := range j.DecodeErrors {
+			snapshot.DecodeErrors[reason] = count
+		}
+	}
+	return snapshot
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// newID returns a random job identifier
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,197 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/pcap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capture returns a pcapng file of two packets and an ARP frame
+func capture(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := pcap.NewWriter(&buf, pcap.LinkTypeEthernet)
+	for _, packet := range []*models.Packet{
+		models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 60),
+		models.NewPacket("10.0.0.3", "10.0.0.4", "UDP", 53, 80),
+	} {
+		record, err := pcap.Encode(packet)
+		require.NoError(t, err)
+		require.NoError(t, w.Write(record))
+	}
+	arp := make([]byte, 42)
+	arp[12], arp[13] = 0x08, 0x06
+	require.NoError(t, w.Write(&pcap.Record{Timestamp: time.Now(), LinkType: pcap.LinkTypeEthernet, Data: arp, Length: len(arp)}))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// wait polls a job until it is finished
+func wait(t *testing.T, jobs *Jobs, id string) *models.IngestJob {
+	t.Helper()
+	var job *models.IngestJob
+	require.Eventually(t, func() bool {
+		var ok bool
+		job, ok = jobs.Job(id)
+		return ok && job.FinishedAt != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestJobs_Upload(t *testing.T) {
+	store := storage.NewInMemoryStorage(100)
+	jobs := NewJobs(Config{Dir: t.TempDir()}, store, nil)
+	jobs.Start()
+	defer jobs.Stop()
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(capture(t))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	for name, file := range map[string][]byte{"capture.pcapng": capture(t), "capture.pcapng.gz": compressed.Bytes()} {
+		queued, err := jobs.Upload(name, bytes.NewReader(file))
+		require.NoError(t, err)
+		assert.Equal(t, models.IngestJobQueued, queued.Status)
+		assert.Equal(t, int64(len(file)), queued.Size)
+		assert.True(t, strings.HasPrefix(queued.Session, "upload_"))
+
+		job := wait(t, jobs, queued.ID)
+		assert.Equal(t, models.IngestJobCompleted, job.Status, job.Error)
+		assert.Equal(t, 1.0, job.Progress)
+		assert.Equal(t, 3, job.PacketsRead)
+		assert.Equal(t, 2, job.PacketsStored)
+		assert.Equal(t, 1, job.PacketsSkipped)
+		assert.Equal(t, map[string]int{"unsupported packet: EtherType 0x0806": 1}, job.DecodeErrors)
+
+		// The packets are only found in the session of the job
+		response, err := store.Get(context.Background(), &models.PacketFilter{Session: job.Session})
+		require.NoError(t, err)
+		assert.Equal(t, 2, response.Total)
+	}
+	live, err := store.Get(context.Background(), &models.PacketFilter{})
+	require.NoError(t, err)
+	assert.Zero(t, live.Total)
+
+	list := jobs.List()
+	require.Len(t, list, 2)
+	assert.True(t, !list[0].CreatedAt.Before(list[1].CreatedAt))
+}
+
+func TestJobs_Capacity(t *testing.T) {
+	store := storage.NewInMemoryStorage(10).WithSessionCapacity(3)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Store(context.Background(), models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 60)))
+	}
+	jobs := NewJobs(Config{Dir: t.TempDir()}, store, nil)
+	jobs.Start()
+	defer jobs.Stop()
+
+	// A full storage of live packets still holds every uploaded packet
+	queued, err := jobs.Upload("capture.pcapng", bytes.NewReader(capture(t)))
+	require.NoError(t, err)
+	job := wait(t, jobs, queued.ID)
+	assert.Equal(t, 2, job.PacketsStored)
+	assert.Zero(t, job.PacketsEvicted)
+	live, err := store.Get(context.Background(), &models.PacketFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 10, live.Total)
+
+	// A capture beyond the capacity of sessions reports the packets evicted
+	var buf bytes.Buffer
+	w := pcap.NewWriter(&buf, pcap.LinkTypeEthernet)
+	for i := 0; i < 5; i++ {
+		record, err := pcap.Encode(models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 60))
+		require.NoError(t, err)
+		require.NoError(t, w.Write(record))
+	}
+	require.NoError(t, w.Close())
+	queued, err = jobs.Upload("large.pcapng", &buf)
+	require.NoError(t, err)
+	job = wait(t, jobs, queued.ID)
+	assert.Equal(t, 5, job.PacketsRead)
+	assert.Equal(t, 3, job.PacketsStored)
+	assert.Equal(t, 2, job.PacketsEvicted)
+	response, err := store.Get(context.Background(), &models.PacketFilter{Session: job.Session})
+	require.NoError(t, err)
+	assert.Equal(t, 3, response.Total)
+}
+
+func TestJobs_Refused(t *testing.T) {
+	dir := t.TempDir()
+	jobs := NewJobs(Config{MaxSize: 100, Dir: dir}, storage.NewInMemoryStorage(100), nil)
+	defer jobs.Stop()
+
+	_, err := jobs.Upload("notes.txt", strings.NewReader("not a capture"))
+	assert.ErrorIs(t, err, ErrNotCapture)
+
+	_, err = jobs.Upload("big.pcapng", bytes.NewReader(capture(t)))
+	assert.ErrorIs(t, err, ErrTooLarge)
+	assert.Empty(t, jobs.List())
+}
+
+func TestJobs_Truncated(t *testing.T) {
+	jobs := NewJobs(Config{Dir: t.TempDir()}, storage.NewInMemoryStorage(100), nil)
+	jobs.Start()
+	defer jobs.Stop()
+
+	file := capture(t)
+	queued, err := jobs.Upload("cut.pcapng", bytes.NewReader(file[:len(file)-10]))
+	require.NoError(t, err)
+
+	job := wait(t, jobs, queued.ID)
+	assert.Equal(t, models.IngestJobFailed, job.Status)
+	assert.NotEmpty(t, job.Error)
+	assert.Equal(t, 2, job.PacketsStored)
+}
+
+func TestJobs_History(t *testing.T) {
+	jobs := NewJobs(Config{Dir: t.TempDir(), HistorySize: 1}, storage.NewInMemoryStorage(100), nil)
+	jobs.Start()
+	defer jobs.Stop()
+
+	first, err := jobs.Upload("first.pcapng", bytes.NewReader(capture(t)))
+	require.NoError(t, err)
+	wait(t, jobs, first.ID)
+	second, err := jobs.Upload("second.pcapng", bytes.NewReader(capture(t)))
+	require.NoError(t, err)
+	wait(t, jobs, second.ID)
+
+	_, ok := jobs.Job(first.ID)
+	assert.False(t, ok)
+	assert.Len(t, jobs.List(), 1)
+}
+
+func TestJobs_Stop(t *testing.T) {
+	dir := t.TempDir()
+	jobs := NewJobs(Config{Dir: dir}, storage.NewInMemoryStorage(100), nil)
+
+	// Without a running decoder the job stays queued until stopped
+	queued, err := jobs.Upload("capture.pcapng", bytes.NewReader(capture(t)))
+	require.NoError(t, err)
+	jobs.Stop()
+
+	job, ok := jobs.Job(queued.ID)
+	require.True(t, ok)
+	assert.Equal(t, models.IngestJobFailed, job.Status)
+	assert.Equal(t, ErrStopped.Error(), job.Error)
+
+	_, err = jobs.Upload("capture.pcapng", bytes.NewReader(capture(t)))
+	assert.ErrorIs(t, err, ErrStopped)
+
+	// Spooled files are removed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
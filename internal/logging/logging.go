@@ -0,0 +1,133 @@
+// Package logging builds the structured logger of the service. Records
+// logged with a context carry the request ID and trace of that context, so
+// a line written deep in the service can be matched to its access log.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Supported formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	// ErrInvalidLevel is returned for a level that is not debug, info, warn or error
+	ErrInvalidLevel = errors.New("invalid log level")
+
+	// ErrInvalidFormat is returned for a format that is not text or json
+	ErrInvalidFormat = errors.New("invalid log format")
+)
+
+// Config selects the minimum level and the format of log records
+type Config struct {
+	Level  string
+	Format string
+
+	// LevelVar, when set, receives the parsed level and controls the
+	// minimum level of the logger afterwards, so that it can change at
+	// runtime
+	LevelVar *slog.LevelVar
+}
+
+// ParseLevel parses debug, info, warn or error, case-insensitively
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLevel, level)
+	}
+}
+
+// New creates a logger writing to w
+func New(w io.Writer, config Config) (*slog.Logger, error) {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	if config.LevelVar != nil {
+		config.LevelVar.Set(level)
+		options.Level = config.LevelVar
+	}
+	var handler slog.Handler
+	switch strings.ToLower(config.Format) {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, config.Format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// OrDefault returns logger, or the default logger when it is nil
+func OrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying a request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID and the trace of the context of a
+// record to its attributes
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the context attributes and passes the record on
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			record.AddAttrs(slog.String("request_id", id))
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			record.AddAttrs(
+				slog.String("trace_id", span.TraceID().String()),
+				slog.String("span_id", span.SpanID().String()),
+			)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler adding attrs to every record
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler nesting attributes under name
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
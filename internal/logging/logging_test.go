@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestParseLevel(t *testing.T) {
+	for input, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"":      slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		level, err := ParseLevel(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, level, input)
+	}
+
+	_, err := ParseLevel("verbose")
+	assert.ErrorIs(t, err, ErrInvalidLevel)
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, Config{Format: "xml"})
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestNew_ContextAttributes(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, Config{Level: "info", Format: FormatJSON})
+	require.NoError(t, err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = WithRequestID(ctx, "req_1")
+
+	logger.With("component", "test").InfoContext(ctx, "Dropped packet", "packet_id", "pkt_1")
+	logger.DebugContext(ctx, "Not written")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "Dropped packet", record["msg"])
+	assert.Equal(t, "test", record["component"])
+	assert.Equal(t, "pkt_1", record["packet_id"])
+	assert.Equal(t, "req_1", record["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", record["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", record["span_id"])
+}
+
+func TestNew_TextWithoutContext(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, Config{})
+	require.NoError(t, err)
+
+	logger.Info("Server starting", "port", "8080")
+	assert.Contains(t, out.String(), `msg="Server starting" port=8080`)
+	assert.NotContains(t, out.String(), "request_id")
+}
+
+func TestNew_LevelVar(t *testing.T) {
+	var out bytes.Buffer
+	level := new(slog.LevelVar)
+	logger, err := New(&out, Config{Level: "warn", LevelVar: level})
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level.Level())
+
+	logger.Info("Not written")
+	assert.Empty(t, out.String())
+
+	level.Set(slog.LevelDebug)
+	logger.Debug("Written")
+	assert.Contains(t, out.String(), "Written")
+}
@@ -0,0 +1,244 @@
+// Package metrics defines the Prometheus metrics of the service and the
+// registry they are exposed from.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "sniffer"
+
+// Registry holds every metric of the service along with the Go runtime and
+// process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// PacketsGenerated counts the packets produced by the simulated sniffer
+	PacketsGenerated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "packets_generated_total",
+		Help:      "Packets produced by the sniffer.",
+	}, []string{"protocol"})
+
+	// PacketsStored counts the packets accepted by storage
+	PacketsStored = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "packets_stored_total",
+		Help:      "Packets accepted by storage.",
+	}, []string{"protocol"})
+
+	// PacketsEvicted counts the packets removed to make room for new ones
+	PacketsEvicted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "packets_evicted_total",
+		Help:      "Packets evicted from storage because it was full.",
+	}, []string{"protocol"})
+
+	// PacketsDropped counts the packets that could not be stored
+	PacketsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "packets_dropped_total",
+		Help:      "Packets that could not be stored.",
+	}, []string{"protocol", "reason"})
+
+	// PacketsIngested counts the packets pushed through the ingestion API,
+	// accepted or rejected
+	PacketsIngested = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "packets_ingested_total",
+		Help:      "Packets pushed through the ingestion API, by result.",
+	}, []string{"result"})
+
+	// StoragePackets is the number of live packets currently stored
+	StoragePackets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "storage_packets",
+		Help:      "Live packets currently held in storage.",
+	})
+
+	// StorageCapacity is the maximum number of live packets storage holds
+	StorageCapacity = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "storage_capacity_packets",
+		Help:      "Maximum number of live packets held in storage before eviction.",
+	})
+
+	// StorageDuration observes the latency of storage operations
+	StorageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of storage operations.",
+		Buckets:   []float64{.00001, .000025, .00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025, .1},
+	}, []string{"operation"})
+
+	// SnifferRunning reports whether the sniffer is capturing
+	SnifferRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "running",
+		Help:      "Whether the sniffer is capturing packets (1) or stopped (0).",
+	})
+
+	// HTTPRequests counts the API requests by route and status
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration observes the latency of API requests by route
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// GRPCRequests counts the gRPC calls by method and status code
+	GRPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC calls handled, by method and status code.",
+	}, []string{"method", "code"})
+
+	// StreamSubscribers tracks the clients subscribed to live packets
+	StreamSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stream_subscribers",
+		Help:      "Clients subscribed to the live packet stream.",
+	})
+
+	// StreamPacketsDropped counts the packets not sent to subscribers that
+	// did not keep up
+	StreamPacketsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stream_packets_dropped_total",
+		Help:      "Live packets dropped for subscribers whose buffer was full.",
+	})
+
+	// CollectorAgentsConnected tracks the agents with a forwarding stream
+	// open to the collector
+	CollectorAgentsConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "collector_agents_connected",
+		Help:      "Capture agents with a forwarding stream open.",
+	})
+
+	// CollectorBatches counts the batches forwarded by agents, stored or
+	// acknowledged again as duplicates
+	CollectorBatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "collector_batches_total",
+		Help:      "Packet batches forwarded by agents, by result.",
+	}, []string{"result"})
+
+	// AgentBufferedBatches is the number of batches an agent holds on disk
+	// until the collector acknowledges them
+	AgentBufferedBatches = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "agent_buffered_batches",
+		Help:      "Packet batches buffered on disk, waiting to be forwarded.",
+	})
+
+	// AgentBufferedBytes is the size of the batches an agent holds on disk
+	AgentBufferedBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "agent_buffered_bytes",
+		Help:      "Size in bytes of the packet batches buffered on disk.",
+	})
+
+	// AgentForwardedPackets counts the packets an agent forwarded and the
+	// collector acknowledged
+	AgentForwardedPackets = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "agent_forwarded_packets_total",
+		Help:      "Packets forwarded to and acknowledged by the collector.",
+	})
+
+	// AgentDroppedPackets counts the packets an agent discarded because its
+	// buffer was full
+	AgentDroppedPackets = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "agent_dropped_packets_total",
+		Help:      "Packets discarded because the disk buffer was full.",
+	})
+
+	// FlowsActive is the number of flows tracked by the flow exporter
+	FlowsActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "flow_export_active_flows",
+		Help:      "Flows tracked by the flow exporter, not exported yet.",
+	})
+
+	// FlowRecordsExported counts the flow records sent to collectors, by
+	// the reason the flow ended
+	FlowRecordsExported = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "flow_export_records_total",
+		Help:      "Flow records exported, by end reason.",
+	}, []string{"reason"})
+
+	// FlowExportErrors counts the flow export messages that could not be
+	// sent to a collector
+	FlowExportErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "flow_export_errors_total",
+		Help:      "Flow export messages that could not be sent, by collector.",
+	}, []string{"collector"})
+
+	// DeadLettersDropped counts the undelivered notifications dropped
+	// because the dead-letter queue was full
+	DeadLettersDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dead_letters_dropped_total",
+		Help:      "Undelivered notifications dropped because the dead-letter queue was full.",
+	})
+
+	// AnomalyEntitiesDropped counts the hosts and ports the anomaly
+	// detector stopped tracking, or refused to track, for lack of room
+	AnomalyEntitiesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "anomaly_entities_dropped_total",
+		Help:      "Anomaly detector entities forgotten because idle, or refused because every tracked entity was active.",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PacketsGenerated,
+		PacketsStored,
+		PacketsEvicted,
+		PacketsDropped,
+		PacketsIngested,
+		StoragePackets,
+		StorageCapacity,
+		StorageDuration,
+		SnifferRunning,
+		HTTPRequests,
+		HTTPDuration,
+		GRPCRequests,
+		StreamSubscribers,
+		StreamPacketsDropped,
+		CollectorAgentsConnected,
+		CollectorBatches,
+		AgentBufferedBatches,
+		AgentBufferedBytes,
+		AgentForwardedPackets,
+		AgentDroppedPackets,
+		FlowsActive,
+		FlowRecordsExported,
+		FlowExportErrors,
+		DeadLettersDropped,
+		AnomalyEntitiesDropped,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
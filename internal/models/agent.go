@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Agent is a remote capture agent registered with a collector. LastSeenAt
+// is the time of its last registration or forwarded batch; Connected
+// reports whether it has a forwarding stream open.
+type Agent struct {
+	ID           string    `json:"id"`
+	Hostname     string    `json:"hostname,omitempty"`
+	Address      string    `json:"address,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	Connected    bool      `json:"connected"`
+	Batches      uint64    `json:"batches"`
+	Packets      uint64    `json:"packets"`
+	Rejected     uint64    `json:"rejected"`
+}
+
+// AgentsResponse lists the registered agents by ID
+type AgentsResponse struct {
+	Agents []Agent `json:"agents"`
+	Total  int     `json:"total"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// AlertCondition restricts the packets a rule applies to
+type AlertCondition struct {
+	Field    string `json:"field" example:"destination_ip"`
+	Operator string `json:"op" example:"eq"`
+	Value    any    `json:"value" swaggertype:"string" example:"8.8.8.8"`
+}
+
+// AlertAggregation describes the value computed per group over a rule window
+type AlertAggregation struct {
+	Function string `json:"function" example:"distinct"`
+	Field    string `json:"field,omitempty" example:"port"`
+}
+
+// AlertRule defines when an alert is raised from stored packets.
+// The rule fires when the aggregated value of the matching packets of a
+// group exceeds Threshold within Window.
+type AlertRule struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name" example:"Port scan"`
+	Description string           `json:"description,omitempty"`
+	Enabled     bool             `json:"enabled"`
+	Severity    string           `json:"severity" example:"high"`
+	Conditions  []AlertCondition `json:"conditions,omitempty"`
+	GroupBy     []string         `json:"group_by,omitempty" example:"source_ip"`
+	Aggregation AlertAggregation `json:"aggregation"`
+	Threshold   float64          `json:"threshold" example:"100"`
+	Window      Duration         `json:"window" swaggertype:"string" example:"60s"`
+	Cooldown    Duration         `json:"cooldown" swaggertype:"string" example:"5m"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// Alert is raised when a rule threshold is exceeded. Repeated triggers of
+// the same rule and group during the cooldown are folded into one alert.
+type Alert struct {
+	ID          string            `json:"id"`
+	RuleID      string            `json:"rule_id"`
+	RuleName    string            `json:"rule_name"`
+	Severity    string            `json:"severity"`
+	Group       map[string]string `json:"group,omitempty"`
+	Value       float64           `json:"value"`
+	Threshold   float64           `json:"threshold"`
+	Message     string            `json:"message"`
+	PacketIDs   []string          `json:"packet_ids"`
+	Occurrences int               `json:"occurrences"`
+	FirstSeen   time.Time         `json:"first_seen"`
+	LastSeen    time.Time         `json:"last_seen"`
+}
+
+// AlertFilter represents filtering options for the alert history
+type AlertFilter struct {
+	RuleID   string    `json:"rule_id,omitempty"`
+	Severity string    `json:"severity,omitempty"`
+	Since    time.Time `json:"since,omitempty"`
+	Limit    int       `json:"limit,omitempty"`
+}
+
+// AlertResponse represents the API response for the alert history
+type AlertResponse struct {
+	Alerts    []Alert   `json:"alerts"`
+	Total     int       `json:"total"`
+	Timestamp time.Time `json:"timestamp"`
+}
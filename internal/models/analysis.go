@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// AnalysisReport summarises the packets of capture files and packet dumps
+// analysed offline
+type AnalysisReport struct {
+	Sources    []AnalysisSource `json:"sources"`
+	Packets    int              `json:"packets"`
+	Bytes      int              `json:"bytes"`
+	Dropped    int              `json:"dropped,omitempty"`
+	From       *time.Time       `json:"from,omitempty"`
+	To         *time.Time       `json:"to,omitempty"`
+	Protocols  []TopNEntry      `json:"protocols"`
+	TopTalkers []TopNEntry      `json:"top_talkers"`
+	Flows      []Flow           `json:"flows"`
+	Crypto     []CryptoEndpoint `json:"crypto"`
+	Findings   []Finding        `json:"findings"`
+	Timestamp  time.Time        `json:"timestamp"`
+}
+
+// AnalysisSource describes a file loaded for analysis
+type AnalysisSource struct {
+	Name    string         `json:"name"`
+	Format  string         `json:"format"`
+	Packets int            `json:"packets"`
+	Skipped map[string]int `json:"skipped,omitempty"`
+}
+
+// Flow aggregates the packets exchanged by a source and a destination on a
+// port
+type Flow struct {
+	Protocol      string    `json:"protocol"`
+	SourceIP      string    `json:"source_ip"`
+	DestinationIP string    `json:"destination_ip"`
+	Port          int       `json:"port"`
+	Packets       int       `json:"packets"`
+	Bytes         int       `json:"bytes"`
+	Flags         []string  `json:"flags,omitempty"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// CryptoEndpoint is a service of the crypto inventory: the protocol it
+// speaks and, when handshakes were captured, the negotiated parameters
+type CryptoEndpoint struct {
+	Address      string    `json:"address"`
+	Port         int       `json:"port"`
+	Protocol     string    `json:"protocol"`
+	Encrypted    bool      `json:"encrypted"`
+	Evidence     string    `json:"evidence"`
+	Versions     []string  `json:"versions,omitempty"`
+	CipherSuites []string  `json:"cipher_suites,omitempty"`
+	ServerNames  []string  `json:"server_names,omitempty"`
+	Weaknesses   []string  `json:"weaknesses,omitempty"`
+	Clients      int       `json:"clients"`
+	Packets      int       `json:"packets"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+}
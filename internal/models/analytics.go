@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// TopNEntry represents a single ranked key of an aggregation
+type TopNEntry struct {
+	Key     string  `json:"key"`
+	Packets int     `json:"packets"`
+	Bytes   int     `json:"bytes"`
+	Share   float64 `json:"share"`
+}
+
+// TopNResponse represents the API response for top-N aggregations
+type TopNResponse struct {
+	Dimension    string      `json:"dimension"`
+	Metric       string      `json:"metric"`
+	From         *time.Time  `json:"from,omitempty"`
+	To           *time.Time  `json:"to,omitempty"`
+	TotalPackets int         `json:"total_packets"`
+	TotalBytes   int         `json:"total_bytes"`
+	Entries      []TopNEntry `json:"entries"`
+	Approximate  bool        `json:"approximate,omitempty"`
+	Timestamp    time.Time   `json:"timestamp"`
+}
+
+// WindowStats summarises traffic observed over a sliding time window
+type WindowStats struct {
+	Window               string            `json:"window"`
+	Packets              uint64            `json:"packets"`
+	Bytes                uint64            `json:"bytes"`
+	PacketsPerSecond     float64           `json:"packets_per_second"`
+	BytesPerSecond       float64           `json:"bytes_per_second"`
+	DistinctSources      uint64            `json:"distinct_sources"`
+	DistinctDestinations uint64            `json:"distinct_destinations"`
+	Protocols            map[string]uint64 `json:"protocols"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// APIKey describes an API key. The secret itself is only returned once,
+// when the key is created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name" example:"soar-integration"`
+	Role       string     `json:"role" example:"analyst"`
+	Prefix     string     `json:"prefix,omitempty" example:"nsk_Ab3dEf"`
+	Static     bool       `json:"static,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// APIKeyRequest is the body of an API key creation request
+type APIKeyRequest struct {
+	Name      string   `json:"name" binding:"required" example:"soar-integration"`
+	Role      string   `json:"role" binding:"required" example:"analyst"`
+	ExpiresIn Duration `json:"expires_in,omitempty" swaggertype:"string" example:"720h"`
+}
+
+// APIKeyCreated is returned when a key is created and carries its secret
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key" example:"nsk_Ab3dEf..."`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditDenied  = "denied"
+	AuditFailure = "failure"
+)
+
+// AuditEntry records one mutating API operation. Each entry holds the hash
+// of the previous one so that altering or removing an entry breaks the
+// chain. PeerIP is the address of the trusted proxy that forwarded the
+// request, when ClientIP was taken from its forwarding headers.
+type AuditEntry struct {
+	Sequence   uint64         `json:"sequence"`
+	Timestamp  time.Time      `json:"timestamp"`
+	Actor      string         `json:"actor" example:"soar-integration"`
+	Role       string         `json:"role,omitempty" example:"admin"`
+	AuthMethod string         `json:"auth_method,omitempty" example:"api_key"`
+	Action     string         `json:"action" example:"packets.clear"`
+	Method     string         `json:"method" example:"DELETE"`
+	Path       string         `json:"path" example:"/api/v1/packets"`
+	Parameters map[string]any `json:"parameters,omitempty"`
+	ClientIP   string         `json:"client_ip"`
+	PeerIP     string         `json:"peer_ip,omitempty"`
+	Status     int            `json:"status" example:"204"`
+	Outcome    string         `json:"outcome" example:"success"`
+	PrevHash   string         `json:"prev_hash"`
+	Hash       string         `json:"hash"`
+}
+
+// AuditFilter represents filtering options for audit entries
+type AuditFilter struct {
+	Actor   string
+	Action  string
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// AuditResponse represents the response of the audit listing
+type AuditResponse struct {
+	Entries   []AuditEntry `json:"entries"`
+	Total     int          `json:"total"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// AuditVerification reports whether the audit chain is intact
+type AuditVerification struct {
+	Valid     bool      `json:"valid"`
+	Entries   uint64    `json:"entries"`
+	BrokenAt  *uint64   `json:"broken_at,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
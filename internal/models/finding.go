@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Finding is a suspicious traffic pattern reported by a built-in detector
+type Finding struct {
+	ID            string         `json:"id"`
+	Detector      string         `json:"detector"`
+	Type          string         `json:"type"`
+	Severity      string         `json:"severity"`
+	Summary       string         `json:"summary"`
+	SourceIP      string         `json:"source_ip,omitempty"`
+	DestinationIP string         `json:"destination_ip,omitempty"`
+	Port          int            `json:"port,omitempty"`
+	Count         int            `json:"count"`
+	PacketIDs     []string       `json:"packet_ids"`
+	Anomaly       *AnomalyDetail `json:"anomaly,omitempty"`
+	FirstSeen     time.Time      `json:"first_seen"`
+	LastSeen      time.Time      `json:"last_seen"`
+}
+
+// Baseline is the learned distribution of a traffic metric
+type Baseline struct {
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"std_dev"`
+	Samples int     `json:"samples"`
+}
+
+// AnomalyDetail describes how an observation deviates from its baseline
+type AnomalyDetail struct {
+	Entity   string   `json:"entity"`
+	Metric   string   `json:"metric"`
+	Interval string   `json:"interval"`
+	Observed float64  `json:"observed"`
+	Baseline Baseline `json:"baseline"`
+	ZScore   float64  `json:"z_score"`
+}
+
+// EntityBaseline holds the learned baselines of a host or port
+type EntityBaseline struct {
+	Entity   string              `json:"entity"`
+	Metrics  map[string]Baseline `json:"metrics"`
+	LastSeen time.Time           `json:"last_seen"`
+}
+
+// FindingFilter represents filtering options for findings
+type FindingFilter struct {
+	Detector string    `json:"detector,omitempty"`
+	Type     string    `json:"type,omitempty"`
+	SourceIP string    `json:"source_ip,omitempty"`
+	Since    time.Time `json:"since,omitempty"`
+	Limit    int       `json:"limit,omitempty"`
+}
+
+// FindingResponse represents the API response for findings
+type FindingResponse struct {
+	Findings  []Finding `json:"findings"`
+	Total     int       `json:"total"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Health statuses of a component or of the whole service
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// ComponentHealth is the result of one component check
+type ComponentHealth struct {
+	Status   string                 `json:"status"`
+	Message  string                 `json:"message,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+	Duration float64                `json:"duration_ms"`
+}
+
+// HealthReport aggregates the component checks of a probe. The service is
+// degraded as soon as one component is.
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+	Timestamp  time.Time                  `json:"timestamp"`
+}
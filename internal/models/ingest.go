@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// IngestResult is the outcome of a line of an ingested batch
+type IngestResult struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// IngestResponse reports the packets of a batch that were stored under a
+// new ID and the ones that were rejected, line by line
+type IngestResponse struct {
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Results  []IngestResult `json:"results"`
+}
+
+// Ingestion job statuses
+const (
+	IngestJobQueued    = "queued"
+	IngestJobRunning   = "running"
+	IngestJobCompleted = "completed"
+	IngestJobFailed    = "failed"
+)
+
+// IngestJob tracks the decoding of an uploaded capture file into the
+// packets of a session. Progress is the fraction of the file decoded so
+// far. Records that are not decodable packets are skipped and counted by
+// reason in DecodeErrors; Error is set when the file could not be read to
+// its end. PacketsStored counts the packets of the session still held, and
+// PacketsEvicted those removed since to make room for newer ones.
+type IngestJob struct {
+	ID             string         `json:"id"`
+	Session        string         `json:"session"`
+	FileName       string         `json:"file_name"`
+	Size           int64          `json:"size"`
+	Status         string         `json:"status"`
+	Progress       float64        `json:"progress"`
+	PacketsRead    int            `json:"packets_read"`
+	PacketsStored  int            `json:"packets_stored"`
+	PacketsSkipped int            `json:"packets_skipped"`
+	PacketsEvicted int            `json:"packets_evicted"`
+	DecodeErrors   map[string]int `json:"decode_errors,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	StartedAt      *time.Time     `json:"started_at,omitempty"`
+	FinishedAt     *time.Time     `json:"finished_at,omitempty"`
+}
+
+// IngestJobsResponse lists ingestion jobs, newest first
+type IngestJobsResponse struct {
+	Jobs  []IngestJob `json:"jobs"`
+	Total int         `json:"total"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification events
+const (
+	EventAlert   = "alert"
+	EventFinding = "finding"
+)
+
+// Notification is the JSON document POSTed to webhook endpoints
+type Notification struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event" example:"alert"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// DeadLetter is a notification that could not be delivered to an endpoint
+type DeadLetter struct {
+	Notification Notification `json:"notification"`
+	Endpoint     string       `json:"endpoint"`
+	Attempts     int          `json:"attempts"`
+	LastError    string       `json:"last_error"`
+	FailedAt     time.Time    `json:"failed_at"`
+}
+
+// EndpointStatus reports the delivery state of a webhook endpoint
+type EndpointStatus struct {
+	Name           string     `json:"name"`
+	URL            string     `json:"url"`
+	Queued         int        `json:"queued"`
+	Delivered      uint64     `json:"delivered"`
+	Retries        uint64     `json:"retries"`
+	DeadLettered   uint64     `json:"dead_lettered"`
+	LastAttempt    *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess    *time.Time `json:"last_success,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// NotificationStatusResponse represents the response of the notifications endpoint
+type NotificationStatusResponse struct {
+	Endpoints   []EndpointStatus `json:"endpoints"`
+	DeadLetters int              `json:"dead_letters"`
+	Timestamp   time.Time        `json:"timestamp"`
+}
+
+// DeadLetterResponse represents the response of the dead-letter listing
+type DeadLetterResponse struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+	Total       int          `json:"total"`
+	Timestamp   time.Time    `json:"timestamp"`
+}
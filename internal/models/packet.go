@@ -2,10 +2,14 @@ package models
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
-// Packet represents a network packet with metadata
+// Packet represents a network packet with metadata. Session is the
+// namespace of packets that were not captured live, such as the ones of an
+// uploaded capture file; it is empty for live traffic. Agent is the ID of
+// the remote agent that captured the packet, empty for local packets.
 type Packet struct {
 	ID            string    `json:"id" validate:"required"`
 	SourceIP      string    `json:"source_ip" validate:"required,ip"`
@@ -17,6 +21,8 @@ type Packet struct {
 	TTL           int       `json:"ttl,omitempty"`
 	Flags         string    `json:"flags,omitempty"`
 	Payload       string    `json:"payload,omitempty"`
+	Session       string    `json:"session,omitempty"`
+	Agent         string    `json:"agent,omitempty"`
 }
 
 // PacketResponse represents the API response for packets
@@ -26,7 +32,9 @@ type PacketResponse struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
-// PacketFilter represents filtering options for packets
+// PacketFilter represents filtering options for packets. Packets only
+// match the filter of their Session, so an empty Session selects live
+// traffic.
 type PacketFilter struct {
 	Protocol      string    `json:"protocol,omitempty"`
 	SourceIP      string    `json:"source_ip,omitempty"`
@@ -35,20 +43,23 @@ type PacketFilter struct {
 	ToTimestamp   time.Time `json:"to_timestamp,omitempty"`
 	Limit         int       `json:"limit,omitempty"`
 	Offset        int       `json:"offset,omitempty"`
+	Session       string    `json:"session,omitempty"`
+	Agent         string    `json:"agent,omitempty"`
 }
 
 // Stats contains basic storage statistics
 type Stats struct {
-	TotalPackets int        `json:"total_packets"`
-	Capacity     int        `json:"capacity"`
-	OldestAt     *time.Time `json:"oldest_at,omitempty"`
-	NewestAt     *time.Time `json:"newest_at,omitempty"`
+	TotalPackets int           `json:"total_packets"`
+	Capacity     int           `json:"capacity"`
+	OldestAt     *time.Time    `json:"oldest_at,omitempty"`
+	NewestAt     *time.Time    `json:"newest_at,omitempty"`
+	Windows      []WindowStats `json:"windows,omitempty"`
 }
 
 // NewPacket creates a new packet with default values
 func NewPacket(sourceIP, destIP, protocol string, port, size int) *Packet {
 	return &Packet{
-		ID:            generatePacketID(),
+		ID:            NewPacketID(),
 		SourceIP:      sourceIP,
 		DestinationIP: destIP,
 		Protocol:      protocol,
@@ -56,11 +67,27 @@ func NewPacket(sourceIP, destIP, protocol string, port, size int) *Packet {
 		Size:          size,
 		Timestamp:     time.Now(),
 		TTL:           64,
-		Flags:         "SYN",
+		Flags:         "ACK",
 	}
 }
 
-// generatePacketID creates a unique packet ID
-func generatePacketID() string {
-	return "packet_" + time.Now().Format("20060102150405") + "_" + fmt.Sprintf("%09d", time.Now().UnixNano()%1000000000)
+// lastPacketNanos is the timestamp of the most recently generated packet ID
+var lastPacketNanos atomic.Int64
+
+// NewPacketID creates a unique packet ID. IDs generated within the same
+// nanosecond are bumped forward so bursts never collide.
+func NewPacketID() string {
+	nanos := time.Now().UnixNano()
+	for {
+		last := lastPacketNanos.Load()
+		if nanos <= last {
+			nanos = last + 1
+		}
+		if lastPacketNanos.CompareAndSwap(last, nanos) {
+			break
+		}
+	}
+
+	t := time.Unix(0, nanos)
+	return "packet_" + t.Format("20060102150405") + "_" + fmt.Sprintf("%09d", nanos%1000000000)
 }
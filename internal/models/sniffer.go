@@ -0,0 +1,46 @@
+package models
+
+// SnifferConfig holds the capture parameters of the simulated sniffer that
+// can change while it runs
+type SnifferConfig struct {
+	Interval        Duration           `json:"interval" swaggertype:"string" example:"5s"`
+	RateProfile     string             `json:"rate_profile" example:"constant"`
+	ProtocolWeights map[string]float64 `json:"protocol_weights"`
+	Addresses       []string           `json:"addresses"`
+	Ports           []int              `json:"ports"`
+	ScanProbability float64            `json:"scan_probability" example:"0.05"`
+}
+
+// SnifferConfigPatch changes some fields of a SnifferConfig. Omitted fields
+// keep their value; lists and weights are replaced as a whole.
+type SnifferConfigPatch struct {
+	Interval        *Duration          `json:"interval,omitempty" swaggertype:"string" example:"2s"`
+	RateProfile     *string            `json:"rate_profile,omitempty" example:"bursty"`
+	ProtocolWeights map[string]float64 `json:"protocol_weights,omitempty"`
+	Addresses       []string           `json:"addresses,omitempty"`
+	Ports           []int              `json:"ports,omitempty"`
+	ScanProbability *float64           `json:"scan_probability,omitempty"`
+}
+
+// Apply returns config with the fields set in the patch replaced
+func (p SnifferConfigPatch) Apply(config SnifferConfig) SnifferConfig {
+	if p.Interval != nil {
+		config.Interval = *p.Interval
+	}
+	if p.RateProfile != nil {
+		config.RateProfile = *p.RateProfile
+	}
+	if p.ProtocolWeights != nil {
+		config.ProtocolWeights = p.ProtocolWeights
+	}
+	if p.Addresses != nil {
+		config.Addresses = p.Addresses
+	}
+	if p.Ports != nil {
+		config.Ports = p.Ports
+	}
+	if p.ScanProbability != nil {
+		config.ScanProbability = *p.ScanProbability
+	}
+	return config
+}
@@ -0,0 +1,317 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// maxMessageSize bounds the size of an export message so that it fits in
+// a single UDP datagram on common paths without fragmentation
+const maxMessageSize = 1400
+
+// Information elements of the records. NetFlow v9 field types and IPFIX
+// information element IDs share these numbers.
+const (
+	fieldOctets            = 1
+	fieldPackets           = 2
+	fieldProtocol          = 4
+	fieldTCPFlags          = 6
+	fieldSourcePort        = 7
+	fieldSourceIPv4        = 8
+	fieldDestinationPort   = 11
+	fieldDestinationIPv4   = 12
+	fieldLastSwitched      = 21
+	fieldFirstSwitched     = 22
+	fieldSourceIPv6        = 27
+	fieldDestinationIPv6   = 28
+	fieldEndReason         = 136
+	fieldStartMilliseconds = 152
+	fieldEndMilliseconds   = 153
+)
+
+// Template IDs of the IPv4 and IPv6 flow records
+const (
+	templateIPv4 = 256
+	templateIPv6 = 257
+)
+
+// Set IDs of the template sets of each protocol
+const (
+	netflow9TemplateSet = 0
+	ipfixTemplateSet    = 2
+)
+
+// field is an information element of a template and its encoded length
+type field struct {
+	id     uint16
+	length uint16
+}
+
+// template describes the layout of a data record
+type template struct {
+	id     uint16
+	fields []field
+}
+
+// recordLength returns the size of an encoded record of the template
+func (t *template) recordLength() int {
+	length := 0
+	for _, f := range t.fields {
+		length += int(f.length)
+	}
+	return length
+}
+
+// templates returns the IPv4 and IPv6 templates of a protocol. NetFlow v9
+// records times relative to the exporter uptime; IPFIX records absolute
+// times and the reason the flow ended.
+func templates(version uint16) []template {
+	common := []field{
+		{fieldSourcePort, 2},
+		{fieldDestinationPort, 2},
+		{fieldProtocol, 1},
+		{fieldOctets, 8},
+		{fieldPackets, 8},
+	}
+	var times []field
+	if version == versionNetFlow9 {
+		common = append(common, field{fieldTCPFlags, 1})
+		times = []field{{fieldFirstSwitched, 4}, {fieldLastSwitched, 4}}
+	} else {
+		common = append(common, field{fieldTCPFlags, 2})
+		times = []field{{fieldStartMilliseconds, 8}, {fieldEndMilliseconds, 8}, {fieldEndReason, 1}}
+	}
+
+	build := func(id, source, destination, addressLength uint16) template {
+		fields := []field{{source, addressLength}, {destination, addressLength}}
+		fields = append(fields, common...)
+		return template{id: id, fields: append(fields, times...)}
+	}
+	return []template{
+		build(templateIPv4, fieldSourceIPv4, fieldDestinationIPv4, 4),
+		build(templateIPv6, fieldSourceIPv6, fieldDestinationIPv6, 16),
+	}
+}
+
+// encoder builds the export messages of one protocol and numbers them. It
+// is not safe for concurrent use.
+type encoder struct {
+	version          uint16
+	domainID         uint32
+	templateInterval time.Duration
+	templates        []template
+
+	// boot is the origin of the NetFlow v9 uptime
+	boot time.Time
+
+	// sequence counts the messages sent with NetFlow v9 and the data
+	// records sent with IPFIX
+	sequence        uint32
+	templatesSentAt time.Time
+}
+
+func newEncoder(version uint16, domainID uint32, templateInterval time.Duration, boot time.Time) *encoder {
+	return &encoder{
+		version:          version,
+		domainID:         domainID,
+		templateInterval: templateInterval,
+		templates:        templates(version),
+		boot:             boot,
+	}
+}
+
+// message is an export message being built
+type message struct {
+	buf []byte
+
+	// records counts the data records, count all the records including
+	// templates
+	records int
+	count   int
+
+	// set is the offset of the open data set and setID its template
+	set   int
+	setID uint16
+}
+
+// encode returns the messages exporting flows. The templates lead the
+// first message, then every templateInterval, so that collectors that
+// started late or lost a datagram can decode the records.
+func (e *encoder) encode(flows []*flow, now time.Time) [][]byte {
+	withTemplates := e.templatesSentAt.IsZero() || now.Sub(e.templatesSentAt) >= e.templateInterval
+	var messages [][]byte
+	var m *message
+	for _, f := range flows {
+		t := &e.templates[0]
+		if !f.key.source.Is4() {
+			t = &e.templates[1]
+		}
+		needed := t.recordLength() + e.maxPadding()
+		if m == nil || m.setID != t.id {
+			needed += 4
+		}
+		if m != nil && len(m.buf)+needed > maxMessageSize {
+			messages = append(messages, e.finish(m, now))
+			m = nil
+		}
+		if m == nil {
+			m = e.start(withTemplates)
+			if withTemplates {
+				e.templatesSentAt = now
+				withTemplates = false
+			}
+		}
+		if m.setID != t.id {
+			e.closeSet(m)
+			m.set, m.setID = len(m.buf), t.id
+			m.buf = binary.BigEndian.AppendUint16(m.buf, t.id)
+			m.buf = append(m.buf, 0, 0)
+		}
+		m.buf = e.appendRecord(m.buf, t, f)
+		m.records++
+		m.count++
+	}
+	if m != nil {
+		messages = append(messages, e.finish(m, now))
+	}
+	return messages
+}
+
+// start begins a message with room for its header, followed by the
+// template set when requested
+func (e *encoder) start(withTemplates bool) *message {
+	headerLength := 16
+	if e.version == versionNetFlow9 {
+		headerLength = 20
+	}
+	m := &message{buf: make([]byte, headerLength, maxMessageSize), set: -1}
+	if !withTemplates {
+		return m
+	}
+
+	setID := uint16(ipfixTemplateSet)
+	if e.version == versionNetFlow9 {
+		setID = netflow9TemplateSet
+	}
+	offset := len(m.buf)
+	m.buf = binary.BigEndian.AppendUint16(m.buf, setID)
+	m.buf = append(m.buf, 0, 0)
+	for _, t := range e.templates {
+		m.buf = binary.BigEndian.AppendUint16(m.buf, t.id)
+		m.buf = binary.BigEndian.AppendUint16(m.buf, uint16(len(t.fields)))
+		for _, f := range t.fields {
+			m.buf = binary.BigEndian.AppendUint16(m.buf, f.id)
+			m.buf = binary.BigEndian.AppendUint16(m.buf, f.length)
+		}
+		m.count++
+	}
+	binary.BigEndian.PutUint16(m.buf[offset+2:], uint16(len(m.buf)-offset))
+	return m
+}
+
+// closeSet writes the length of the open data set. NetFlow v9 sets are
+// padded to a multiple of 4 bytes.
+func (e *encoder) closeSet(m *message) {
+	if m.set < 0 {
+		return
+	}
+	if e.version == versionNetFlow9 {
+		for (len(m.buf)-m.set)%4 != 0 {
+			m.buf = append(m.buf, 0)
+		}
+	}
+	binary.BigEndian.PutUint16(m.buf[m.set+2:], uint16(len(m.buf)-m.set))
+	m.set = -1
+}
+
+// maxPadding returns the most padding closing a set can add
+func (e *encoder) maxPadding() int {
+	if e.version == versionNetFlow9 {
+		return 3
+	}
+	return 0
+}
+
+// finish closes the message, writes its header and advances the sequence
+// number
+func (e *encoder) finish(m *message, now time.Time) []byte {
+	e.closeSet(m)
+	header := m.buf
+	binary.BigEndian.PutUint16(header, e.version)
+	if e.version == versionNetFlow9 {
+		binary.BigEndian.PutUint16(header[2:], uint16(m.count))
+		binary.BigEndian.PutUint32(header[4:], e.uptime(now))
+		binary.BigEndian.PutUint32(header[8:], uint32(now.Unix()))
+		binary.BigEndian.PutUint32(header[12:], e.sequence)
+		binary.BigEndian.PutUint32(header[16:], e.domainID)
+		e.sequence++
+	} else {
+		binary.BigEndian.PutUint16(header[2:], uint16(len(m.buf)))
+		binary.BigEndian.PutUint32(header[4:], uint32(now.Unix()))
+		binary.BigEndian.PutUint32(header[8:], e.sequence)
+		binary.BigEndian.PutUint32(header[12:], e.domainID)
+		e.sequence += uint32(m.records)
+	}
+	return m.buf
+}
+
+// appendRecord appends the data record of a flow laid out by t
+func (e *encoder) appendRecord(buf []byte, t *template, f *flow) []byte {
+	for _, fd := range t.fields {
+		switch fd.id {
+		case fieldSourceIPv4:
+			address := f.key.source.As4()
+			buf = append(buf, address[:]...)
+		case fieldDestinationIPv4:
+			address := f.key.destination.As4()
+			buf = append(buf, address[:]...)
+		case fieldSourceIPv6:
+			address := f.key.source.As16()
+			buf = append(buf, address[:]...)
+		case fieldDestinationIPv6:
+			address := f.key.destination.As16()
+			buf = append(buf, address[:]...)
+		case fieldSourcePort:
+			buf = appendUint(buf, uint64(f.key.sourcePort), fd.length)
+		case fieldDestinationPort:
+			buf = appendUint(buf, uint64(f.key.destinationPort), fd.length)
+		case fieldProtocol:
+			buf = appendUint(buf, uint64(f.key.protocol), fd.length)
+		case fieldTCPFlags:
+			buf = appendUint(buf, uint64(f.tcpFlags), fd.length)
+		case fieldOctets:
+			buf = appendUint(buf, f.bytes, fd.length)
+		case fieldPackets:
+			buf = appendUint(buf, f.packets, fd.length)
+		case fieldFirstSwitched:
+			buf = appendUint(buf, uint64(e.uptime(f.start)), fd.length)
+		case fieldLastSwitched:
+			buf = appendUint(buf, uint64(e.uptime(f.end)), fd.length)
+		case fieldStartMilliseconds:
+			buf = appendUint(buf, uint64(f.start.UnixMilli()), fd.length)
+		case fieldEndMilliseconds:
+			buf = appendUint(buf, uint64(f.end.UnixMilli()), fd.length)
+		case fieldEndReason:
+			buf = appendUint(buf, uint64(f.reason), fd.length)
+		}
+	}
+	return buf
+}
+
+// uptime returns the milliseconds elapsed from the boot of the exporter to
+// t. Times before the boot, such as the ones of packets captured by an
+// agent before the collector started, are clamped to it.
+func (e *encoder) uptime(t time.Time) uint32 {
+	if t.Before(e.boot) {
+		return 0
+	}
+	return uint32(t.Sub(e.boot).Milliseconds())
+}
+
+// appendUint appends the length low bytes of value in network order
+func appendUint(buf []byte, value uint64, length uint16) []byte {
+	for i := int(length) - 1; i >= 0; i-- {
+		buf = append(buf, byte(value>>(8*i)))
+	}
+	return buf
+}
@@ -0,0 +1,466 @@
+// Package netflow exports the flows of live traffic as NetFlow v9 or IPFIX
+// records over UDP, so that the sniffer can feed flow collectors.
+package netflow
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/pcap"
+)
+
+// Export protocols
+const (
+	ProtocolNetFlow9 = "netflow9"
+	ProtocolIPFIX    = "ipfix"
+)
+
+// Version numbers of the export message headers
+const (
+	versionNetFlow9 = 9
+	versionIPFIX    = 10
+)
+
+// IP protocol numbers of the flow keys
+const (
+	protocolICMP   = 1
+	protocolTCP    = 6
+	protocolUDP    = 17
+	protocolICMPv6 = 58
+)
+
+// TCP flags ending a connection
+const (
+	tcpFIN = 0x01
+	tcpRST = 0x04
+)
+
+// ErrInvalidProtocol is returned for an export protocol other than
+// netflow9 and ipfix
+var ErrInvalidProtocol = errors.New("invalid export protocol")
+
+// EndReason is the reason a flow was exported, with the values of the IPFIX
+// flowEndReason information element
+type EndReason uint8
+
+// Reasons a flow ends
+const (
+	// EndIdle is the end of a flow without packets for the inactive timeout
+	EndIdle EndReason = 1
+
+	// EndActive is the export of a flow that lasted the active timeout;
+	// its next packets start a new flow
+	EndActive EndReason = 2
+
+	// EndOfFlow is the end of a TCP connection, seen by a FIN or RST flag
+	EndOfFlow EndReason = 3
+
+	// EndForced is the export of the flows in progress when the exporter
+	// stops
+	EndForced EndReason = 4
+
+	// EndLackOfResources is the export of the flows in progress when the
+	// flow cache is full
+	EndLackOfResources EndReason = 5
+)
+
+// String returns the name of the reason, as used in metrics
+func (r EndReason) String() string {
+	switch r {
+	case EndIdle:
+		return "idle"
+	case EndActive:
+		return "active"
+	case EndOfFlow:
+		return "end_of_flow"
+	case EndForced:
+		return "forced"
+	case EndLackOfResources:
+		return "lack_of_resources"
+	default:
+		return "unknown"
+	}
+}
+
+// Config configures an Exporter
+type Config struct {
+	// Protocol is netflow9 or ipfix
+	Protocol string
+
+	// Collectors are the host:port UDP addresses receiving every record
+	Collectors []string
+
+	// ActiveTimeout bounds the duration of a flow record: longer flows
+	// are exported in several records
+	ActiveTimeout time.Duration
+
+	// InactiveTimeout ends a flow without packets for that long
+	InactiveTimeout time.Duration
+
+	// TemplateInterval is the period at which templates are sent again
+	TemplateInterval time.Duration
+
+	// MaxFlows bounds the flows tracked at once
+	MaxFlows int
+
+	// DomainID is the source ID of NetFlow v9 messages and the observation
+	// domain ID of IPFIX messages
+	DomainID uint32
+}
+
+// DefaultConfig returns the export settings used for unset fields
+func DefaultConfig() Config {
+	return Config{
+		Protocol:         ProtocolIPFIX,
+		ActiveTimeout:    time.Minute,
+		InactiveTimeout:  15 * time.Second,
+		TemplateInterval: time.Minute,
+		MaxFlows:         65536,
+	}
+}
+
+// key identifies a flow: packets of the same addresses, ports and
+// protocol belong to the same flow
+type key struct {
+	source          netip.Addr
+	destination     netip.Addr
+	protocol        uint8
+	sourcePort      uint16
+	destinationPort uint16
+}
+
+// flow accumulates the packets of a key until it is exported. start and
+// end are the timestamps of its first and last packets, firstSeen and
+// lastSeen the times they were observed, which drive the timeouts.
+type flow struct {
+	key       key
+	start     time.Time
+	end       time.Time
+	packets   uint64
+	bytes     uint64
+	tcpFlags  uint8
+	firstSeen time.Time
+	lastSeen  time.Time
+	reason    EndReason
+}
+
+// collector is the connection to a collector
+type collector struct {
+	address string
+	conn    net.Conn
+}
+
+// Exporter tracks the flows of the packets it observes and exports each
+// one to every collector when it ends: after the inactive timeout, after
+// the active timeout, when a TCP connection closes, when the flow cache is
+// full or when the exporter stops.
+type Exporter struct {
+	config     Config
+	collectors []collector
+	logger     *slog.Logger
+	now        func() time.Time
+
+	mutex sync.Mutex
+	flows map[key]*flow
+
+	// ended holds the flows ended by the cache filling up until the next
+	// export
+	ended []*flow
+
+	// sendMutex serializes exports, which number their messages
+	sendMutex sync.Mutex
+	encoder   *encoder
+
+	wake    chan struct{}
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	started bool
+	stopped bool
+}
+
+// New creates an exporter sending to the collectors of config. Unset
+// fields take the values of DefaultConfig. A nil logger selects the default
+// logger.
+func New(config Config, logger *slog.Logger) (*Exporter, error) {
+	defaults := DefaultConfig()
+	if config.Protocol == "" {
+		config.Protocol = defaults.Protocol
+	}
+	if config.ActiveTimeout <= 0 {
+		config.ActiveTimeout = defaults.ActiveTimeout
+	}
+	if config.InactiveTimeout <= 0 {
+		config.InactiveTimeout = defaults.InactiveTimeout
+	}
+	if config.TemplateInterval <= 0 {
+		config.TemplateInterval = defaults.TemplateInterval
+	}
+	if config.MaxFlows <= 0 {
+		config.MaxFlows = defaults.MaxFlows
+	}
+
+	var version uint16
+	switch strings.ToLower(config.Protocol) {
+	case ProtocolNetFlow9:
+		version = versionNetFlow9
+	case ProtocolIPFIX:
+		version = versionIPFIX
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProtocol, config.Protocol)
+	}
+
+	e := &Exporter{
+		config: config,
+		logger: logging.OrDefault(logger),
+		now:    time.Now,
+		flows:  make(map[key]*flow),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+	for _, address := range config.Collectors {
+		conn, err := net.Dial("udp", address)
+		if err != nil {
+			e.closeCollectors()
+			return nil, fmt.Errorf("failed to resolve collector %s: %w", address, err)
+		}
+		e.collectors = append(e.collectors, collector{address: address, conn: conn})
+	}
+	e.encoder = newEncoder(version, config.DomainID, config.TemplateInterval, e.now())
+	return e, nil
+}
+
+// OnStore adds a stored packet to its flow. Packets whose addresses cannot
+// be exported, and the ones stored once the exporter stopped, are ignored.
+func (e *Exporter) OnStore(packet *models.Packet) {
+	k, ok := flowKey(packet)
+	if !ok {
+		return
+	}
+	now := e.now()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.stopped {
+		return
+	}
+
+	f := e.flows[k]
+	if f == nil {
+		if len(e.flows) >= e.config.MaxFlows {
+			e.endAll(EndLackOfResources)
+			e.signal()
+		}
+		f = &flow{key: k, start: packet.Timestamp, end: packet.Timestamp, firstSeen: now}
+		e.flows[k] = f
+		metrics.FlowsActive.Set(float64(len(e.flows)))
+	}
+	if packet.Timestamp.Before(f.start) {
+		f.start = packet.Timestamp
+	}
+	if packet.Timestamp.After(f.end) {
+		f.end = packet.Timestamp
+	}
+	f.packets++
+	f.bytes += uint64(max(packet.Size, 0))
+	f.lastSeen = now
+	if k.protocol == protocolTCP {
+		f.tcpFlags |= pcap.TCPFlags(packet.Flags)
+		if f.tcpFlags&(tcpFIN|tcpRST) != 0 {
+			f.reason = EndOfFlow
+		}
+	}
+}
+
+// Start launches the export of the flows that end
+func (e *Exporter) Start() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.started || e.stopped {
+		return
+	}
+	e.started = true
+	e.wg.Add(1)
+	go e.run()
+}
+
+// Stop exports the flows in progress and closes the connections to the
+// collectors
+func (e *Exporter) Stop() {
+	e.mutex.Lock()
+	if e.stopped {
+		e.mutex.Unlock()
+		return
+	}
+	e.stopped = true
+	e.mutex.Unlock()
+
+	close(e.stop)
+	e.wg.Wait()
+
+	e.mutex.Lock()
+	e.endAll(EndForced)
+	e.mutex.Unlock()
+	e.export(nil)
+	e.closeCollectors()
+}
+
+// Flows returns the number of flows in progress
+func (e *Exporter) Flows() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return len(e.flows)
+}
+
+// run exports the ended flows periodically, and as soon as the cache fills
+// up, until Stop
+func (e *Exporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.sweepInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.export(e.expire(e.now()))
+		case <-e.wake:
+			e.export(nil)
+		}
+	}
+}
+
+// sweepInterval is the period at which timeouts are checked: a fraction of
+// the shortest timeout, at most a second
+func (e *Exporter) sweepInterval() time.Duration {
+	interval := min(time.Second, e.config.InactiveTimeout/4, e.config.ActiveTimeout/4)
+	return max(interval, time.Millisecond)
+}
+
+// expire removes the flows that ended at now from the cache and returns
+// them
+func (e *Exporter) expire(now time.Time) []*flow {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var expired []*flow
+	for k, f := range e.flows {
+		switch {
+		case f.reason == EndOfFlow:
+		case now.Sub(f.lastSeen) >= e.config.InactiveTimeout:
+			f.reason = EndIdle
+		case now.Sub(f.firstSeen) >= e.config.ActiveTimeout:
+			f.reason = EndActive
+		default:
+			continue
+		}
+		expired = append(expired, f)
+		delete(e.flows, k)
+	}
+	metrics.FlowsActive.Set(float64(len(e.flows)))
+	return expired
+}
+
+// endAll moves every flow of the cache to the ended flows. The caller
+// holds the mutex.
+func (e *Exporter) endAll(reason EndReason) {
+	for k, f := range e.flows {
+		if f.reason == 0 {
+			f.reason = reason
+		}
+		e.ended = append(e.ended, f)
+		delete(e.flows, k)
+	}
+	metrics.FlowsActive.Set(0)
+}
+
+// signal wakes the export loop up. The caller holds the mutex.
+func (e *Exporter) signal() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// export sends the records of flows and of the flows ended by the cache to
+// every collector, oldest first
+func (e *Exporter) export(flows []*flow) {
+	e.mutex.Lock()
+	flows = append(flows, e.ended...)
+	e.ended = nil
+	e.mutex.Unlock()
+	if len(flows) == 0 {
+		return
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].start.Before(flows[j].start) })
+
+	e.sendMutex.Lock()
+	defer e.sendMutex.Unlock()
+
+	messages := e.encoder.encode(flows, e.now())
+	for _, c := range e.collectors {
+		for _, message := range messages {
+			if _, err := c.conn.Write(message); err != nil {
+				metrics.FlowExportErrors.WithLabelValues(c.address).Inc()
+				e.logger.Warn("Failed to export flows", "collector", c.address, "error", err.Error())
+				break
+			}
+		}
+	}
+	for _, f := range flows {
+		metrics.FlowRecordsExported.WithLabelValues(f.reason.String()).Inc()
+	}
+}
+
+// closeCollectors closes the connections to the collectors
+func (e *Exporter) closeCollectors() {
+	for _, c := range e.collectors {
+		c.conn.Close()
+	}
+}
+
+// flowKey returns the flow of a packet. Ports are the ones of the frames
+// synthesized for capture files, ICMP flows have none.
+func flowKey(packet *models.Packet) (key, bool) {
+	source, err := netip.ParseAddr(packet.SourceIP)
+	if err != nil {
+		return key{}, false
+	}
+	destination, err := netip.ParseAddr(packet.DestinationIP)
+	if err != nil {
+		return key{}, false
+	}
+	source, destination = source.Unmap(), destination.Unmap()
+	if source.Is4() != destination.Is4() || packet.Port < 0 || packet.Port > 0xffff {
+		return key{}, false
+	}
+
+	k := key{source: source, destination: destination}
+	switch strings.ToUpper(packet.Protocol) {
+	case "TCP", "HTTP", "HTTPS":
+		k.protocol = protocolTCP
+	case "UDP":
+		k.protocol = protocolUDP
+	case "ICMP":
+		k.protocol = protocolICMP
+		if source.Is6() {
+			k.protocol = protocolICMPv6
+		}
+		return k, true
+	default:
+		return key{}, false
+	}
+	k.sourcePort = pcap.SourcePort(packet)
+	k.destinationPort = uint16(packet.Port)
+	return k, true
+}
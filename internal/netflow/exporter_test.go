@@ -0,0 +1,361 @@
+package netflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/pcap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flowCollector is a UDP collector decoding the messages it receives with
+// the templates they carried
+type flowCollector struct {
+	conn      *net.UDPConn
+	templates map[uint16][]field
+}
+
+// decoded is an export message
+type decoded struct {
+	version    uint16
+	count      uint16
+	uptime     uint32
+	exportTime uint32
+	sequence   uint32
+	domainID   uint32
+	size       int
+	templates  int
+	records    []record
+}
+
+// record holds the values of a data record by field
+type record map[uint16][]byte
+
+func (r record) uint(id uint16) uint64 {
+	var value uint64
+	for _, b := range r[id] {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func (r record) addr(id uint16) netip.Addr {
+	addr, _ := netip.AddrFromSlice(r[id])
+	return addr
+}
+
+func listen(t *testing.T) *flowCollector {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &flowCollector{conn: conn, templates: make(map[uint16][]field)}
+}
+
+func (c *flowCollector) address() string {
+	return c.conn.LocalAddr().String()
+}
+
+// receive decodes the next message
+func (c *flowCollector) receive(t *testing.T) decoded {
+	t.Helper()
+	buf := make([]byte, 65535)
+	require.NoError(t, c.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	n, err := c.conn.Read(buf)
+	require.NoError(t, err)
+	m, err := c.decode(buf[:n])
+	require.NoError(t, err)
+	return m
+}
+
+// decode parses a NetFlow v9 or IPFIX message, learning its templates
+func (c *flowCollector) decode(data []byte) (decoded, error) {
+	m := decoded{version: binary.BigEndian.Uint16(data), size: len(data)}
+	offset := 16
+	templateSet := uint16(ipfixTemplateSet)
+	switch m.version {
+	case versionNetFlow9:
+		m.count = binary.BigEndian.Uint16(data[2:])
+		m.uptime = binary.BigEndian.Uint32(data[4:])
+		m.exportTime = binary.BigEndian.Uint32(data[8:])
+		m.sequence = binary.BigEndian.Uint32(data[12:])
+		m.domainID = binary.BigEndian.Uint32(data[16:])
+		offset, templateSet = 20, netflow9TemplateSet
+	case versionIPFIX:
+		if length := int(binary.BigEndian.Uint16(data[2:])); length != len(data) {
+			return m, fmt.Errorf("message length %d, received %d bytes", length, len(data))
+		}
+		m.exportTime = binary.BigEndian.Uint32(data[4:])
+		m.sequence = binary.BigEndian.Uint32(data[8:])
+		m.domainID = binary.BigEndian.Uint32(data[12:])
+	default:
+		return m, fmt.Errorf("unknown version %d", m.version)
+	}
+
+	for offset < len(data) {
+		if len(data)-offset < 4 {
+			return m, fmt.Errorf("truncated set header at %d", offset)
+		}
+		setID := binary.BigEndian.Uint16(data[offset:])
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if length < 4 || offset+length > len(data) {
+			return m, fmt.Errorf("set %d of length %d at %d", setID, length, offset)
+		}
+		body := data[offset+4 : offset+length]
+		offset += length
+
+		if setID == templateSet {
+			for len(body) >= 4 {
+				id, count := binary.BigEndian.Uint16(body), int(binary.BigEndian.Uint16(body[2:]))
+				body = body[4:]
+				fields := make([]field, count)
+				for i := range fields {
+					fields[i] = field{binary.BigEndian.Uint16(body), binary.BigEndian.Uint16(body[2:])}
+					body = body[4:]
+				}
+				c.templates[id] = fields
+				m.templates++
+			}
+			continue
+		}
+		fields, ok := c.templates[setID]
+		if !ok {
+			return m, fmt.Errorf("data set %d without template", setID)
+		}
+		recordLength := 0
+		for _, f := range fields {
+			recordLength += int(f.length)
+		}
+		for len(body) >= recordLength {
+			r := record{}
+			for _, f := range fields {
+				r[f.id], body = body[:f.length], body[f.length:]
+			}
+			m.records = append(m.records, r)
+		}
+		if len(body) > 3 {
+			return m, fmt.Errorf("%d bytes left in data set %d", len(body), setID)
+		}
+	}
+	return m, nil
+}
+
+// newExporter returns an exporter sending to collector
+func newExporter(t *testing.T, protocol string, collector *flowCollector) *Exporter {
+	t.Helper()
+	config := DefaultConfig()
+	config.Protocol = protocol
+	config.Collectors = []string{collector.address()}
+	config.DomainID = 7
+	e, err := New(config, nil)
+	require.NoError(t, err)
+	return e
+}
+
+// packet returns a packet at time at
+func packet(source, destination, protocol string, port, size int, flags string, at time.Time) *models.Packet {
+	p := models.NewPacket(source, destination, protocol, port, size)
+	p.Flags = flags
+	p.Timestamp = at
+	return p
+}
+
+func TestNew_InvalidProtocol(t *testing.T) {
+	_, err := New(Config{Protocol: "sflow"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidProtocol)
+}
+
+func TestExporter_IPFIX(t *testing.T) {
+	collector := listen(t)
+	e := newExporter(t, ProtocolIPFIX, collector)
+
+	start := time.Now().Truncate(time.Millisecond)
+	https := []*models.Packet{
+		packet("10.0.0.1", "10.0.0.2", "HTTPS", 443, 60, "SYN", start),
+		packet("10.0.0.1", "10.0.0.2", "HTTPS", 443, 1500, "ACK", start.Add(time.Second)),
+		packet("10.0.0.1", "10.0.0.2", "HTTPS", 443, 40, "PSH,ACK", start.Add(2*time.Second)),
+	}
+	for _, p := range https {
+		e.OnStore(p)
+	}
+	e.OnStore(packet("2001:db8::1", "2001:db8::2", "UDP", 53, 80, "", start.Add(time.Second)))
+	e.OnStore(packet("10.0.0.3", "not an address", "UDP", 53, 80, "", start))
+	assert.Equal(t, 2, e.Flows())
+
+	// Stopping exports the flows in progress
+	e.Stop()
+	m := collector.receive(t)
+	assert.Equal(t, uint16(versionIPFIX), m.version)
+	assert.Equal(t, uint32(0), m.sequence)
+	assert.Equal(t, uint32(7), m.domainID)
+	assert.Equal(t, 2, m.templates)
+	require.Len(t, m.records, 2)
+
+	tcp := m.records[0]
+	assert.Equal(t, netip.MustParseAddr("10.0.0.1"), tcp.addr(fieldSourceIPv4))
+	assert.Equal(t, netip.MustParseAddr("10.0.0.2"), tcp.addr(fieldDestinationIPv4))
+	assert.Equal(t, uint64(pcap.SourcePort(https[0])), tcp.uint(fieldSourcePort))
+	assert.Equal(t, uint64(443), tcp.uint(fieldDestinationPort))
+	assert.Equal(t, uint64(protocolTCP), tcp.uint(fieldProtocol))
+	assert.Equal(t, uint64(3), tcp.uint(fieldPackets))
+	assert.Equal(t, uint64(1600), tcp.uint(fieldOctets))
+	assert.Equal(t, uint64(0x1a), tcp.uint(fieldTCPFlags))
+	assert.Equal(t, uint64(start.UnixMilli()), tcp.uint(fieldStartMilliseconds))
+	assert.Equal(t, uint64(start.Add(2*time.Second).UnixMilli()), tcp.uint(fieldEndMilliseconds))
+	assert.Equal(t, uint64(EndForced), tcp.uint(fieldEndReason))
+
+	udp := m.records[1]
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), udp.addr(fieldSourceIPv6))
+	assert.Equal(t, netip.MustParseAddr("2001:db8::2"), udp.addr(fieldDestinationIPv6))
+	assert.Equal(t, uint64(protocolUDP), udp.uint(fieldProtocol))
+	assert.Equal(t, uint64(1), udp.uint(fieldPackets))
+	assert.Equal(t, uint64(80), udp.uint(fieldOctets))
+
+	// Packets stored once stopped are ignored
+	e.OnStore(https[0])
+	assert.Zero(t, e.Flows())
+}
+
+func TestExporter_NetFlow9(t *testing.T) {
+	collector := listen(t)
+	e := newExporter(t, ProtocolNetFlow9, collector)
+	boot := e.encoder.boot
+
+	e.OnStore(packet("10.0.0.1", "10.0.0.2", "TCP", 22, 100, "SYN", boot.Add(time.Second)))
+	e.OnStore(packet("10.0.0.1", "10.0.0.2", "TCP", 22, 100, "ACK", boot.Add(3*time.Second)))
+	e.OnStore(packet("10.0.0.1", "10.0.0.9", "ICMP", 0, 64, "", boot.Add(2*time.Second)))
+	e.Stop()
+
+	m := collector.receive(t)
+	assert.Equal(t, uint16(versionNetFlow9), m.version)
+	assert.Equal(t, uint32(0), m.sequence)
+	assert.Equal(t, uint32(7), m.domainID)
+	assert.Equal(t, 2, m.templates)
+	assert.Equal(t, uint16(4), m.count, "templates and data records")
+	assert.Zero(t, m.size%4, "sets are padded")
+	require.Len(t, m.records, 2)
+
+	tcp := m.records[0]
+	assert.Equal(t, uint64(22), tcp.uint(fieldDestinationPort))
+	assert.Equal(t, uint64(2), tcp.uint(fieldPackets))
+	assert.Equal(t, uint64(200), tcp.uint(fieldOctets))
+	assert.Equal(t, uint64(0x12), tcp.uint(fieldTCPFlags))
+	assert.Equal(t, uint64(1000), tcp.uint(fieldFirstSwitched))
+	assert.Equal(t, uint64(3000), tcp.uint(fieldLastSwitched))
+
+	icmp := m.records[1]
+	assert.Equal(t, uint64(protocolICMP), icmp.uint(fieldProtocol))
+	assert.Zero(t, icmp.uint(fieldSourcePort))
+	assert.Zero(t, icmp.uint(fieldDestinationPort))
+}
+
+func TestExporter_Timeouts(t *testing.T) {
+	e, err := New(Config{ActiveTimeout: time.Minute, InactiveTimeout: 10 * time.Second, MaxFlows: 3}, nil)
+	require.NoError(t, err)
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	e.OnStore(packet("10.0.0.1", "10.0.0.2", "TCP", 80, 60, "SYN", now))
+	e.OnStore(packet("10.0.0.1", "10.0.0.3", "UDP", 53, 60, "", now))
+	e.OnStore(packet("10.0.0.1", "10.0.0.4", "TCP", 80, 60, "SYN", now))
+	e.OnStore(packet("10.0.0.1", "10.0.0.4", "TCP", 80, 60, "FIN,ACK", now))
+
+	// A closed connection ends at once
+	expired := e.expire(now)
+	require.Len(t, expired, 1)
+	assert.Equal(t, EndOfFlow, expired[0].reason)
+	assert.Equal(t, "10.0.0.4", expired[0].key.destination.String())
+
+	// A flow without packets ends after the inactive timeout, a busy one
+	// after the active timeout
+	for i := 0; i < 5; i++ {
+		now = now.Add(10 * time.Second)
+		e.OnStore(packet("10.0.0.1", "10.0.0.2", "TCP", 80, 60, "ACK", now))
+	}
+	expired = e.expire(now)
+	require.Len(t, expired, 1)
+	assert.Equal(t, EndIdle, expired[0].reason)
+	assert.Equal(t, "10.0.0.3", expired[0].key.destination.String())
+
+	now = now.Add(10 * time.Second)
+	e.OnStore(packet("10.0.0.1", "10.0.0.2", "TCP", 80, 60, "ACK", now))
+	expired = e.expire(now)
+	require.Len(t, expired, 1)
+	assert.Equal(t, EndActive, expired[0].reason)
+	assert.Equal(t, uint64(7), expired[0].packets)
+	assert.Zero(t, e.Flows())
+
+	// A full cache is emptied to make room for new flows
+	for i := 1; i <= 4; i++ {
+		e.OnStore(packet("10.0.0.1", fmt.Sprintf("10.0.1.%d", i), "UDP", 53, 60, "", now))
+	}
+	assert.Equal(t, 1, e.Flows())
+	require.Len(t, e.ended, 3)
+	for _, f := range e.ended {
+		assert.Equal(t, EndLackOfResources, f.reason)
+	}
+}
+
+func TestExporter_Sequence(t *testing.T) {
+	collector := listen(t)
+	e := newExporter(t, ProtocolIPFIX, collector)
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	// Records are split across messages that fit in a datagram
+	flows := func(n int) []*flow {
+		result := make([]*flow, n)
+		for i := range result {
+			k, _ := flowKey(packet("10.0.0.1", fmt.Sprintf("10.0.%d.%d", i/256, i%256), "UDP", 53, 60, "", now))
+			result[i] = &flow{key: k, start: now, end: now, packets: 1, bytes: 60, reason: EndIdle}
+		}
+		return result
+	}
+	e.export(flows(100))
+	var records int
+	for records < 100 {
+		m := collector.receive(t)
+		assert.LessOrEqual(t, m.size, maxMessageSize)
+		assert.Equal(t, uint32(records), m.sequence)
+		assert.Equal(t, records == 0, m.templates > 0, "templates lead the first message only")
+		records += len(m.records)
+	}
+	assert.Equal(t, 100, records)
+
+	// Templates are sent again after the template interval
+	e.export(flows(1))
+	m := collector.receive(t)
+	assert.Equal(t, uint32(100), m.sequence)
+	assert.Zero(t, m.templates)
+
+	now = now.Add(time.Minute)
+	e.export(flows(1))
+	m = collector.receive(t)
+	assert.Equal(t, uint32(101), m.sequence)
+	assert.Equal(t, 2, m.templates)
+	e.Stop()
+}
+
+func TestExporter_Start(t *testing.T) {
+	collector := listen(t)
+	config := DefaultConfig()
+	config.Collectors = []string{collector.address()}
+	config.InactiveTimeout = 20 * time.Millisecond
+	e, err := New(config, nil)
+	require.NoError(t, err)
+	e.Start()
+	defer e.Stop()
+
+	// Idle flows are exported by the background loop
+	e.OnStore(packet("10.0.0.1", "10.0.0.2", "UDP", 123, 90, "", time.Now()))
+	m := collector.receive(t)
+	require.Len(t, m.records, 1)
+	assert.Equal(t, uint64(EndIdle), m.records[0].uint(fieldEndReason))
+	assert.Equal(t, uint64(123), m.records[0].uint(fieldDestinationPort))
+}
@@ -0,0 +1,221 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// DefaultDeadLetterQueueSize is the number of dead letters kept when no
+// size is set
+const DefaultDeadLetterQueueSize = 10000
+
+// DeadLetterQueue keeps the notifications that exhausted their delivery
+// attempts. When backed by a file, every letter is appended to it as one
+// JSON line so undelivered notifications survive a restart. Once the queue
+// holds maxSize letters, the oldest are dropped to make room.
+//
+// Replayed letters stay in the file until they are delivered or dead
+// lettered again, so a crash during a replay does not lose them. The file
+// is rewritten once most of its lines are stale.
+type DeadLetterQueue struct {
+	mutex     sync.Mutex
+	path      string
+	maxSize   int
+	letters   []models.DeadLetter
+	replaying map[string]models.DeadLetter
+	lines     int
+}
+
+// OpenDeadLetterQueue opens the queue persisted at path, loading the
+// letters it already holds. An empty path keeps the queue in memory only,
+// and a size of zero or less selects DefaultDeadLetterQueueSize.
+func OpenDeadLetterQueue(path string, maxSize int) (*DeadLetterQueue, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultDeadLetterQueueSize
+	}
+	q := &DeadLetterQueue{path: path, maxSize: maxSize, replaying: make(map[string]models.DeadLetter)}
+	if path == "" {
+		return q, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// A letter dead lettered again after a replay is appended once more,
+	// the last line winning
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		q.lines++
+		var letter models.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("parse %s: line %d: %w", path, line, err)
+		}
+		key := letterKey(letter.Endpoint, letter.Notification.ID)
+		if i, ok := index[key]; ok {
+			q.letters[i] = letter
+			continue
+		}
+		index[key] = len(q.letters)
+		q.letters = append(q.letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	q.trim()
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Add records a dead letter, dropping the oldest one when the queue is
+// full
+func (q *DeadLetterQueue) Add(letter models.DeadLetter) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	delete(q.replaying, letterKey(letter.Endpoint, letter.Notification.ID))
+	q.letters = append(q.letters, letter)
+	q.trim()
+	if q.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	q.lines++
+	return q.compact()
+}
+
+// List returns the dead letters, oldest first
+func (q *DeadLetterQueue) List() []models.DeadLetter {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return append([]models.DeadLetter{}, q.letters...)
+}
+
+// Len returns the number of dead letters
+func (q *DeadLetterQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.letters)
+}
+
+// Replay removes and returns the dead letters selected by replay. They
+// stay in the file until Delivered or Add is called with them.
+func (q *DeadLetterQueue) Replay(replay func(letter models.DeadLetter) bool) []models.DeadLetter {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var replayed []models.DeadLetter
+	kept := q.letters[:0]
+	for _, letter := range q.letters {
+		if !replay(letter) {
+			kept = append(kept, letter)
+			continue
+		}
+		q.replaying[letterKey(letter.Endpoint, letter.Notification.ID)] = letter
+		replayed = append(replayed, letter)
+	}
+	clear(q.letters[len(kept):])
+	q.letters = kept
+	return replayed
+}
+
+// Delivered forgets a replayed letter once its notification reached the
+// endpoint
+func (q *DeadLetterQueue) Delivered(endpoint, id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	key := letterKey(endpoint, id)
+	if _, ok := q.replaying[key]; !ok {
+		return nil
+	}
+	delete(q.replaying, key)
+	return q.compact()
+}
+
+// trim drops the oldest letters beyond the size of the queue
+func (q *DeadLetterQueue) trim() {
+	if dropped := len(q.letters) - q.maxSize; dropped > 0 {
+		clear(q.letters[:dropped])
+		q.letters = q.letters[dropped:]
+		metrics.DeadLettersDropped.Add(float64(dropped))
+	}
+}
+
+// compact rewrites the file with the letters held, replayed ones included,
+// once more than half of its lines are stale
+func (q *DeadLetterQueue) compact() error {
+	held := len(q.letters) + len(q.replaying)
+	if q.path == "" || q.lines <= 2*held {
+		return nil
+	}
+
+	letters := make([]models.DeadLetter, 0, held)
+	for _, letter := range q.replaying {
+		letters = append(letters, letter)
+	}
+	slices.SortFunc(letters, func(a, b models.DeadLetter) int {
+		return a.FailedAt.Compare(b.FailedAt)
+	})
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, letter := range append(letters, q.letters...) {
+		if err := encoder.Encode(letter); err != nil {
+			return err
+		}
+	}
+
+	// Replace the file at once so that a crash leaves either version
+	temporary := q.path + ".tmp"
+	if err := os.WriteFile(temporary, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary, q.path); err != nil {
+		return err
+	}
+	q.lines = held
+	return nil
+}
+
+// letterKey identifies the letter of a notification to an endpoint
+func letterKey(endpoint, id string) string {
+	return endpoint + "/" + id
+}
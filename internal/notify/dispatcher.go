@@ -0,0 +1,427 @@
+// Package notify delivers alerts and findings to external systems through
+// signed webhook calls.
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// Headers set on every webhook request
+const (
+	HeaderEvent     = "X-Sniffer-Event"
+	HeaderDelivery  = "X-Sniffer-Delivery"
+	HeaderTimestamp = "X-Sniffer-Timestamp"
+	HeaderSignature = "X-Sniffer-Signature-256"
+)
+
+// Endpoint is a webhook receiving notifications. Requests are signed with
+// Secret when it is set.
+type Endpoint struct {
+	Name   string
+	URL    string
+	Secret string
+}
+
+// Config holds the delivery settings of a Dispatcher
+type Config struct {
+	Endpoints      []Endpoint
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Timeout        time.Duration
+	QueueSize      int
+}
+
+// DefaultConfig returns the delivery settings used for unset fields
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Timeout:        10 * time.Second,
+		QueueSize:      1000,
+	}
+}
+
+// Dispatcher posts notifications to every configured endpoint. Each
+// endpoint has its own queue and worker so a slow receiver does not delay
+// the others. Failed deliveries are retried with exponential backoff and
+// end up in the dead-letter queue once the attempts are exhausted.
+type Dispatcher struct {
+	config      Config
+	client      *http.Client
+	endpoints   []*endpoint
+	deadLetters *DeadLetterQueue
+
+	mutex   sync.Mutex
+	started bool
+	stopped bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// endpoint is the queue and delivery state of one webhook
+type endpoint struct {
+	Endpoint
+	queue chan models.Notification
+
+	mutex  sync.Mutex
+	status models.EndpointStatus
+}
+
+// NewDispatcher creates a dispatcher. A nil dead-letter queue keeps
+// undelivered notifications in memory.
+func NewDispatcher(config Config, deadLetters *DeadLetterQueue) *Dispatcher {
+	defaults := DefaultConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaults.InitialBackoff
+	}
+	if config.MaxBackoff < config.InitialBackoff {
+		config.MaxBackoff = config.InitialBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if deadLetters == nil {
+		deadLetters, _ = OpenDeadLetterQueue("", 0)
+	}
+
+	d := &Dispatcher{
+		config:      config,
+		client:      &http.Client{Timeout: config.Timeout},
+		deadLetters: deadLetters,
+		stop:        make(chan struct{}),
+	}
+	for i, e := range config.Endpoints {
+		if e.Name == "" {
+			e.Name = fmt.Sprintf("webhook-%d", i+1)
+		}
+		d.endpoints = append(d.endpoints, &endpoint{
+			Endpoint: e,
+			queue:    make(chan models.Notification, config.QueueSize),
+			status:   models.EndpointStatus{Name: e.Name, URL: e.URL},
+		})
+	}
+	return d
+}
+
+// Start launches the delivery workers
+func (d *Dispatcher) Start() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.started || d.stopped {
+		return
+	}
+	d.started = true
+	for _, e := range d.endpoints {
+		d.wg.Add(1)
+		go d.run(e)
+	}
+}
+
+// Stop waits for in-flight requests to finish and moves the notifications
+// still queued or awaiting a retry to the dead-letter queue
+func (d *Dispatcher) Stop() {
+	d.mutex.Lock()
+	if d.stopped {
+		d.mutex.Unlock()
+		return
+	}
+	d.stopped = true
+	close(d.stop)
+	d.mutex.Unlock()
+
+	d.wg.Wait()
+	for _, e := range d.endpoints {
+		for len(e.queue) > 0 {
+			d.deadLetter(e, <-e.queue, 0, "dispatcher stopped")
+		}
+	}
+}
+
+// Publish queues a notification for every endpoint
+func (d *Dispatcher) Publish(event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encode %s notification: %w", event, err)
+	}
+	n := models.Notification{
+		ID:        newID(),
+		Event:     event,
+		Timestamp: time.Now(),
+		Data:      payload,
+	}
+
+	d.mutex.Lock()
+	stopped := d.stopped
+	d.mutex.Unlock()
+
+	for _, e := range d.endpoints {
+		if stopped {
+			d.deadLetter(e, n, 0, "dispatcher stopped")
+			continue
+		}
+		d.enqueue(e, n)
+	}
+	return nil
+}
+
+// NotifyAlert publishes a raised alert. It can be registered with
+// alerting.Engine.OnAlert.
+func (d *Dispatcher) NotifyAlert(alert models.Alert) {
+	_ = d.Publish(models.EventAlert, alert)
+}
+
+// NotifyFinding publishes a detector finding. It can be registered with
+// detection.FindingStore.OnFinding.
+func (d *Dispatcher) NotifyFinding(finding models.Finding) {
+	_ = d.Publish(models.EventFinding, finding)
+}
+
+// Status returns the delivery state of every endpoint
+func (d *Dispatcher) Status() []models.EndpointStatus {
+	statuses := make([]models.EndpointStatus, 0, len(d.endpoints))
+	for _, e := range d.endpoints {
+		e.mutex.Lock()
+		status := e.status
+		e.mutex.Unlock()
+		status.Queued = len(e.queue)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Backlog returns the number of notifications waiting in the endpoint
+// queues and the total capacity of those queues
+func (d *Dispatcher) Backlog() (queued, capacity int) {
+	for _, e := range d.endpoints {
+		queued += len(e.queue)
+		capacity += cap(e.queue)
+	}
+	return queued, capacity
+}
+
+// DeadLetters returns the notifications that could not be delivered
+func (d *Dispatcher) DeadLetters() []models.DeadLetter {
+	return d.deadLetters.List()
+}
+
+// DeadLetterCount returns the number of undelivered notifications
+func (d *Dispatcher) DeadLetterCount() int {
+	return d.deadLetters.Len()
+}
+
+// Replay queues the dead letters again for their endpoint and returns how
+// many were requeued. Letters of endpoints that are no longer configured
+// stay in the dead-letter queue, and replayed ones until they are
+// delivered or dead lettered again.
+func (d *Dispatcher) Replay() int {
+	byName := make(map[string]*endpoint, len(d.endpoints))
+	for _, e := range d.endpoints {
+		byName[e.Name] = e
+	}
+	letters := d.deadLetters.Replay(func(letter models.DeadLetter) bool {
+		_, ok := byName[letter.Endpoint]
+		return ok
+	})
+
+	replayed := 0
+	for _, letter := range letters {
+		if d.enqueue(byName[letter.Endpoint], letter.Notification) {
+			replayed++
+		}
+	}
+	return replayed
+}
+
+// enqueue adds a notification to an endpoint queue, dead-lettering it when
+// the queue is full
+func (d *Dispatcher) enqueue(e *endpoint, n models.Notification) bool {
+	select {
+	case e.queue <- n:
+		return true
+	default:
+		d.deadLetter(e, n, 0, "queue full")
+		return false
+	}
+}
+
+// run delivers the notifications of an endpoint in order
+func (d *Dispatcher) run(e *endpoint) {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case n := <-e.queue:
+			d.deliver(e, n)
+		}
+	}
+}
+
+// deliver posts a notification, retrying with exponential backoff
+func (d *Dispatcher) deliver(e *endpoint, n models.Notification) {
+	var lastErr error
+	attempts := 0
+	for attempts < d.config.MaxAttempts {
+		if attempts > 0 {
+			e.mutex.Lock()
+			e.status.Retries++
+			e.mutex.Unlock()
+
+			select {
+			case <-d.stop:
+				d.deadLetter(e, n, attempts, "dispatcher stopped: "+lastErr.Error())
+				return
+			case <-time.After(d.backoff(attempts)):
+			}
+		}
+
+		attempts++
+		code, err := d.post(e, n)
+		d.record(e, code, err)
+		if err == nil {
+			d.delivered(e, n)
+			return
+		}
+		lastErr = err
+		if code != 0 && !retryable(code) {
+			break
+		}
+	}
+	d.deadLetter(e, n, attempts, lastErr.Error())
+}
+
+// post sends one signed request and returns the response status code
+func (d *Dispatcher) post(e *endpoint, n models.Notification) (int, error) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, n.Event)
+	req.Header.Set(HeaderDelivery, n.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if e.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(e.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// record updates the endpoint status after an attempt
+func (d *Dispatcher) record(e *endpoint, code int, err error) {
+	now := time.Now()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.status.LastAttempt = &now
+	e.status.LastStatusCode = code
+	if err != nil {
+		e.status.LastError = err.Error()
+		return
+	}
+	e.status.Delivered++
+	e.status.LastSuccess = &now
+	e.status.LastError = ""
+}
+
+// delivered forgets the dead letter of a replayed notification
+func (d *Dispatcher) delivered(e *endpoint, n models.Notification) {
+	if err := d.deadLetters.Delivered(e.Name, n.ID); err != nil {
+		e.mutex.Lock()
+		e.status.LastError = "dead-letter queue: " + err.Error()
+		e.mutex.Unlock()
+	}
+}
+
+// deadLetter moves a notification to the dead-letter queue
+func (d *Dispatcher) deadLetter(e *endpoint, n models.Notification, attempts int, reason string) {
+	e.mutex.Lock()
+	e.status.DeadLettered++
+	e.mutex.Unlock()
+
+	if err := d.deadLetters.Add(models.DeadLetter{
+		Notification: n,
+		Endpoint:     e.Name,
+		Attempts:     attempts,
+		LastError:    reason,
+		FailedAt:     time.Now(),
+	}); err != nil {
+		e.mutex.Lock()
+		e.status.LastError = "dead-letter queue: " + err.Error()
+		e.mutex.Unlock()
+	}
+}
+
+// backoff returns the wait before the retry following attempt n
+func (d *Dispatcher) backoff(n int) time.Duration {
+	wait := d.config.InitialBackoff
+	for i := 1; i < n && wait < d.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.config.MaxBackoff {
+		wait = d.config.MaxBackoff
+	}
+	return wait
+}
+
+// retryable reports whether a failed response may succeed later
+func retryable(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+}
+
+// Sign returns the signature header value of a request body: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the endpoint secret,
+// prefixed with "sha256=". Receivers recompute it to authenticate requests.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newID returns a random notification identifier
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "ntf_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,258 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a webhook test server answering with the scripted status codes
+// before accepting every request
+type receiver struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	calls    atomic.Int32
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mutex.Lock()
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		r.mutex.Unlock()
+
+		r.calls.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func fastConfig(endpoints ...Endpoint) Config {
+	return Config{
+		Endpoints:      endpoints,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Timeout:        time.Second,
+		QueueSize:      10,
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	require.Eventually(t, cond, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestDispatcher_SignedDelivery(t *testing.T) {
+	rcv := newReceiver(t)
+	d := NewDispatcher(fastConfig(Endpoint{Name: "soar", URL: rcv.URL, Secret: "s3cret"}), nil)
+	d.Start()
+	defer d.Stop()
+
+	alert := models.Alert{ID: "alert_1", RuleName: "Port scan", Severity: "high"}
+	d.NotifyAlert(alert)
+	waitFor(t, func() bool { return rcv.calls.Load() == 1 })
+
+	rcv.mutex.Lock()
+	req, body := rcv.requests[0], rcv.bodies[0]
+	rcv.mutex.Unlock()
+
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, models.EventAlert, req.Header.Get(HeaderEvent))
+	assert.Equal(t, Sign("s3cret", req.Header.Get(HeaderTimestamp), body), req.Header.Get(HeaderSignature))
+	assert.NotEqual(t, Sign("other", req.Header.Get(HeaderTimestamp), body), req.Header.Get(HeaderSignature))
+
+	var n models.Notification
+	require.NoError(t, json.Unmarshal(body, &n))
+	assert.Equal(t, req.Header.Get(HeaderDelivery), n.ID)
+	var delivered models.Alert
+	require.NoError(t, json.Unmarshal(n.Data, &delivered))
+	assert.Equal(t, alert.ID, delivered.ID)
+
+	waitFor(t, func() bool { return d.Status()[0].Delivered == 1 })
+	status := d.Status()[0]
+	assert.Equal(t, "soar", status.Name)
+	assert.Equal(t, http.StatusOK, status.LastStatusCode)
+	assert.NotNil(t, status.LastSuccess)
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	rcv := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	d := NewDispatcher(fastConfig(Endpoint{URL: rcv.URL}), nil)
+	d.Start()
+	defer d.Stop()
+
+	d.NotifyFinding(models.Finding{ID: "finding_1"})
+	waitFor(t, func() bool { return d.Status()[0].Delivered == 1 })
+
+	status := d.Status()[0]
+	assert.Equal(t, "webhook-1", status.Name)
+	assert.EqualValues(t, 3, rcv.calls.Load())
+	assert.EqualValues(t, 2, status.Retries)
+	assert.Empty(t, status.LastError)
+	assert.Zero(t, d.DeadLetterCount())
+	// The same delivery ID is used for every attempt
+	assert.Equal(t, rcv.requests[0].Header.Get(HeaderDelivery), rcv.requests[2].Header.Get(HeaderDelivery))
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(Config{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, nil)
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, 5*time.Second, d.backoff(4))
+	assert.Equal(t, 5*time.Second, d.backoff(10))
+}
+
+func TestDispatcher_DeadLetterPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	rcv := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError,
+		http.StatusBadRequest)
+
+	dlq, err := OpenDeadLetterQueue(path, 0)
+	require.NoError(t, err)
+	d := NewDispatcher(fastConfig(Endpoint{Name: "soar", URL: rcv.URL}), dlq)
+	d.Start()
+
+	// Exhausts the three attempts
+	d.NotifyAlert(models.Alert{ID: "alert_1"})
+	waitFor(t, func() bool { return d.DeadLetterCount() == 1 })
+	// A client error is not retried
+	d.NotifyAlert(models.Alert{ID: "alert_2"})
+	waitFor(t, func() bool { return d.DeadLetterCount() == 2 })
+	d.Stop()
+
+	assert.EqualValues(t, 4, rcv.calls.Load())
+	letters := d.DeadLetters()
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, "unexpected status 500", letters[0].LastError)
+	assert.Equal(t, 1, letters[1].Attempts)
+	assert.EqualValues(t, 2, d.Status()[0].DeadLettered)
+
+	// The dead letters survive a restart and can be replayed
+	dlq, err = OpenDeadLetterQueue(path, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, dlq.Len())
+	assert.Equal(t, letters[0].Notification.ID, dlq.List()[0].Notification.ID)
+
+	d = NewDispatcher(fastConfig(Endpoint{Name: "soar", URL: rcv.URL}), dlq)
+	d.Start()
+	defer d.Stop()
+
+	replayed := d.Replay()
+	assert.Equal(t, 2, replayed)
+	waitFor(t, func() bool { return d.Status()[0].Delivered == 2 })
+	assert.Zero(t, d.DeadLetterCount())
+
+	dlq, err = OpenDeadLetterQueue(path, 0)
+	require.NoError(t, err)
+	assert.Zero(t, dlq.Len())
+}
+
+func TestDispatcher_ReplayKeepsLettersUntilDelivered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	rcv := newReceiver(t)
+	dlq, err := OpenDeadLetterQueue(path, 0)
+	require.NoError(t, err)
+	for _, id := range []string{"ntf_1", "ntf_2"} {
+		require.NoError(t, dlq.Add(models.DeadLetter{Endpoint: "soar", Notification: models.Notification{ID: id}}))
+	}
+
+	// Replayed letters are only queued: a crash now must not lose them
+	d := NewDispatcher(fastConfig(Endpoint{Name: "soar", URL: rcv.URL}), dlq)
+	assert.Equal(t, 2, d.Replay())
+	assert.Zero(t, d.DeadLetterCount())
+	reopened, err := OpenDeadLetterQueue(path, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, reopened.Len())
+
+	d.Start()
+	waitFor(t, func() bool { return d.Status()[0].Delivered == 2 })
+	d.Stop()
+	reopened, err = OpenDeadLetterQueue(path, 0)
+	require.NoError(t, err)
+	assert.Zero(t, reopened.Len())
+}
+
+func TestDeadLetterQueue_MaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	dropped := testutil.ToFloat64(metrics.DeadLettersDropped)
+	dlq, err := OpenDeadLetterQueue(path, 2)
+	require.NoError(t, err)
+	for i := 1; i <= 9; i++ {
+		require.NoError(t, dlq.Add(models.DeadLetter{Endpoint: "soar", Notification: models.Notification{ID: fmt.Sprintf("ntf_%d", i)}}))
+	}
+
+	// The oldest letters are dropped, in memory and on disk
+	ids := func(letters []models.DeadLetter) []string {
+		var ids []string
+		for _, letter := range letters {
+			ids = append(ids, letter.Notification.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"ntf_8", "ntf_9"}, ids(dlq.List()))
+	assert.Equal(t, 7.0, testutil.ToFloat64(metrics.DeadLettersDropped)-dropped)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, bytes.Count(data, []byte("\n")), 4)
+
+	reopened, err := OpenDeadLetterQueue(path, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ntf_8", "ntf_9"}, ids(reopened.List()))
+}
+
+func TestDispatcher_ReplayKeepsUnknownEndpoints(t *testing.T) {
+	dlq, err := OpenDeadLetterQueue("", 0)
+	require.NoError(t, err)
+	require.NoError(t, dlq.Add(models.DeadLetter{Endpoint: "removed", Notification: models.Notification{ID: "ntf_1"}}))
+
+	d := NewDispatcher(fastConfig(Endpoint{Name: "soar", URL: "http://127.0.0.1:0"}), dlq)
+	replayed := d.Replay()
+	assert.Zero(t, replayed)
+	assert.Equal(t, 1, d.DeadLetterCount())
+}
+
+func TestDispatcher_StopDeadLettersPending(t *testing.T) {
+	rcv := newReceiver(t)
+	d := NewDispatcher(fastConfig(Endpoint{URL: rcv.URL}), nil)
+
+	// Not started: notifications stay queued until Stop dead-letters them
+	d.NotifyAlert(models.Alert{ID: "alert_1"})
+	d.NotifyAlert(models.Alert{ID: "alert_2"})
+	assert.Equal(t, 2, d.Status()[0].Queued)
+
+	d.Stop()
+	assert.Equal(t, 2, d.DeadLetterCount())
+	assert.Zero(t, rcv.calls.Load())
+
+	// Publishing after Stop does not lose the notification either
+	d.NotifyAlert(models.Alert{ID: "alert_3"})
+	assert.Equal(t, 3, d.DeadLetterCount())
+}
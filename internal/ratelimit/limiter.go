@@ -0,0 +1,121 @@
+// Package ratelimit implements per-client token bucket rate limiting.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the minimum time between removals of idle buckets
+const sweepInterval = time.Minute
+
+// Limit is a token bucket budget: Burst requests may be made at once and
+// the bucket refills at Rate requests per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision is the outcome of a rate limit check
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter keeps one token bucket per key
+type Limiter struct {
+	mutex     sync.Mutex
+	limit     Limit
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// bucket is the state of one client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a limiter applying the same budget to every key
+func NewLimiter(limit Limit) *Limiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &Limiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// SetLimit replaces the budget of every key. Buckets holding more tokens
+// than the new burst are trimmed on their next use.
+func (l *Limiter) SetLimit(limit Limit) {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.limit = limit
+}
+
+// Allow takes a token from the bucket of key if one is available
+func (l *Limiter) Allow(key string) Decision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+		b.last = now
+	}
+
+	decision := Decision{Limit: l.limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+		decision.Remaining = int(b.tokens)
+		return decision
+	}
+
+	if l.limit.Rate > 0 {
+		decision.RetryAfter = time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+	} else {
+		decision.RetryAfter = time.Duration(math.MaxInt64)
+	}
+	return decision
+}
+
+// Len returns the number of tracked clients
+func (l *Limiter) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.buckets)
+}
+
+// sweep drops the buckets that have refilled completely since they were
+// last used, since a new bucket would be in the same state
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval || l.limit.Rate <= 0 {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
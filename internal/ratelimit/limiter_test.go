@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_BurstAndRefill(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(Limit{Rate: 2, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	for i := 2; i >= 0; i-- {
+		d := l.Allow("client")
+		assert.True(t, d.Allowed)
+		assert.Equal(t, i, d.Remaining)
+		assert.Equal(t, 3, d.Limit)
+	}
+
+	d := l.Allow("client")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+	// Other clients have their own budget
+	assert.True(t, l.Allow("other").Allowed)
+
+	// Half a second refills one token
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow("client").Allowed)
+	assert.False(t, l.Allow("client").Allowed)
+
+	// The bucket never holds more than the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow("client").Allowed)
+	}
+	assert.False(t, l.Allow("client").Allowed)
+}
+
+func TestLimiter_SweepsIdleBuckets(t *testing.T) {
+	now := time.Now()
+	// Buckets refill completely in 100s
+	l := NewLimiter(Limit{Rate: 0.1, Burst: 10})
+	l.now = func() time.Time { return now }
+
+	l.Allow("idle")
+	now = now.Add(2 * time.Minute)
+	l.Allow("active")
+	assert.Equal(t, 1, l.Len())
+
+	// A bucket that has not refilled yet is kept
+	now = now.Add(sweepInterval + time.Second)
+	l.Allow("new")
+	assert.Equal(t, 2, l.Len())
+}
+
+func TestLimiter_SetLimit(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(Limit{Rate: 1, Burst: 10})
+	l.now = func() time.Time { return now }
+	assert.Equal(t, 9, l.Allow("client").Remaining)
+
+	// Existing buckets are trimmed to the new burst
+	l.SetLimit(Limit{Rate: 1, Burst: 2})
+	d := l.Allow("client")
+	assert.Equal(t, 2, d.Limit)
+	assert.Equal(t, 1, d.Remaining)
+}
@@ -2,34 +2,87 @@ package services
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/cryptonextsecurity/network-sniffer/internal/aggregate"
+	"github.com/cryptonextsecurity/network-sniffer/internal/analytics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/ingest"
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
 	"github.com/cryptonextsecurity/network-sniffer/internal/models"
 	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
 	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/cryptonextsecurity/network-sniffer/internal/services")
+
 // PacketService handles business logic for packet operations
 type PacketService struct {
-	storage storage.Storage
-	sniffer sniffing.Sniffer
+	storage    storage.Storage
+	sniffer    sniffing.Sniffer
+	aggregator *aggregate.Aggregator
+	logger     *slog.Logger
+
+	configMutex sync.Mutex
+	configFile  string
 }
 
-// NewPacketService creates a new packet service instance
-func NewPacketService(storage storage.Storage, sniffer sniffing.Sniffer, logger any) *PacketService {
+// ErrNotConfigurable is returned when the sniffer does not support runtime
+// reconfiguration
+var ErrNotConfigurable = errors.New("sniffer cannot be reconfigured at runtime")
+
+// NewPacketService creates a new packet service instance. A nil logger
+// selects the default logger.
+func NewPacketService(storage storage.Storage, sniffer sniffing.Sniffer, logger *slog.Logger) *PacketService {
 	return &PacketService{
 		storage: storage,
 		sniffer: sniffer,
+		logger:  logging.OrDefault(logger),
 	}
 }
 
-// StartSniffing begins the packet sniffing process
+// WithAggregator attaches rolling aggregates used to answer windowed
+// statistics and analytics in constant time
+func (s *PacketService) WithAggregator(aggregator *aggregate.Aggregator) *PacketService {
+	s.aggregator = aggregator
+	return s
+}
+
+// WithSnifferConfigFile persists the capture parameters changed through
+// UpdateSnifferConfig to path, so they survive a restart
+func (s *PacketService) WithSnifferConfigFile(path string) *PacketService {
+	s.configFile = path
+	return s
+}
+
+// StartSniffing begins the packet sniffing process. Cancelling ctx does
+// not stop it.
 func (s *PacketService) StartSniffing(ctx context.Context) error {
-	return s.sniffer.Start(ctx)
+	ctx, span := tracer.Start(ctx, "PacketService.StartSniffing")
+	defer span.End()
+
+	// Capture outlives the request that started it and only ends on
+	// StopSniffing
+	err := s.sniffer.Start(context.Background())
+	s.recordError(ctx, span, "StartSniffing", err)
+	return err
 }
 
 // StopSniffing stops the packet sniffing process
 func (s *PacketService) StopSniffing(ctx context.Context) error {
-	return s.sniffer.Stop(ctx)
+	ctx, span := tracer.Start(ctx, "PacketService.StopSniffing")
+	defer span.End()
+
+	err := s.sniffer.Stop(ctx)
+	s.recordError(ctx, span, "StopSniffing", err)
+	return err
 }
 
 // IsSniffingRunning returns true if sniffing is active
@@ -37,27 +90,248 @@ func (s *PacketService) IsSniffingRunning() bool {
 	return s.sniffer.IsRunning()
 }
 
+// SnifferConfig returns the current capture parameters
+func (s *PacketService) SnifferConfig() (*models.SnifferConfig, error) {
+	configurable, ok := s.sniffer.(sniffing.Configurable)
+	if !ok {
+		return nil, ErrNotConfigurable
+	}
+	config := configurable.Config()
+	return &config, nil
+}
+
+// UpdateSnifferConfig applies a patch to the capture parameters of the
+// running sniffer. The result is validated and persisted before it is
+// applied, so an invalid or unsaved change leaves the sniffer untouched.
+func (s *PacketService) UpdateSnifferConfig(ctx context.Context, patch models.SnifferConfigPatch) (*models.SnifferConfig, error) {
+	ctx, span := tracer.Start(ctx, "PacketService.UpdateSnifferConfig")
+	defer span.End()
+
+	configurable, ok := s.sniffer.(sniffing.Configurable)
+	if !ok {
+		return nil, ErrNotConfigurable
+	}
+
+	s.configMutex.Lock()
+	defer s.configMutex.Unlock()
+
+	config := patch.Apply(configurable.Config())
+	if err := sniffing.ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	if s.configFile != "" {
+		if err := sniffing.SaveConfig(s.configFile, config); err != nil {
+			s.recordError(ctx, span, "UpdateSnifferConfig", err)
+			return nil, err
+		}
+	}
+	if err := configurable.Configure(config); err != nil {
+		s.recordError(ctx, span, "UpdateSnifferConfig", err)
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "Sniffer reconfigured",
+		"interval", time.Duration(config.Interval).String(),
+		"rate_profile", config.RateProfile,
+		"addresses", len(config.Addresses),
+		"ports", len(config.Ports),
+		"persisted", s.configFile != "",
+	)
+	return &config, nil
+}
+
 // GetPackets retrieves packets with optional filtering
 func (s *PacketService) GetPackets(ctx context.Context, filter *models.PacketFilter) (*models.PacketResponse, error) {
-	return s.storage.Get(ctx, filter)
+	ctx, span := tracer.Start(ctx, "PacketService.GetPackets")
+	defer span.End()
+
+	response, err := s.storage.Get(ctx, filter)
+	s.recordError(ctx, span, "GetPackets", err)
+	if response != nil {
+		span.SetAttributes(attribute.Int("packets.returned", response.Total))
+	}
+	return response, err
+}
+
+// exportBatchSize is the number of packets copied from storage at a time
+// by ExportPackets
+const exportBatchSize = 1000
+
+// ExportPackets calls visit with successive batches of the packets matching
+// filter, oldest first. Backends implementing storage.Scanner are read a
+// batch at a time; others are read at once.
+func (s *PacketService) ExportPackets(ctx context.Context, filter *models.PacketFilter, visit func(packets []models.Packet) error) error {
+	ctx, span := tracer.Start(ctx, "PacketService.ExportPackets")
+	defer span.End()
+
+	exported := 0
+	count := func(packets []models.Packet) error {
+		exported += len(packets)
+		return visit(packets)
+	}
+
+	var err error
+	if scanner, ok := s.storage.(storage.Scanner); ok {
+		err = scanner.Scan(ctx, filter, exportBatchSize, count)
+	} else {
+		var response *models.PacketResponse
+		response, err = s.storage.Get(ctx, filter)
+		if err == nil && len(response.Packets) > 0 {
+			err = count(response.Packets)
+		}
+	}
+	span.SetAttributes(attribute.Int("packets.exported", exported))
+	s.recordError(ctx, span, "ExportPackets", err)
+	return err
+}
+
+// IngestPacket stores a packet pushed by a producer other than the sniffer.
+// The packet is given a new ID, then validated; an invalid packet is
+// rejected with an error wrapping ingest.ErrInvalidPacket.
+func (s *PacketService) IngestPacket(ctx context.Context, packet *models.Packet) error {
+	ctx, span := tracer.Start(ctx, "PacketService.IngestPacket")
+	defer span.End()
+
+	err := s.ingest(ctx, packet)
+	span.SetAttributes(attribute.String("packet.id", packet.ID))
+	if !errors.Is(err, ingest.ErrInvalidPacket) {
+		s.recordError(ctx, span, "IngestPacket", err)
+	}
+	return err
+}
+
+// IngestPackets stores a batch of packets like IngestPacket and returns
+// the error of each packet, nil for the stored ones
+func (s *PacketService) IngestPackets(ctx context.Context, packets []*models.Packet) []error {
+	ctx, span := tracer.Start(ctx, "PacketService.IngestPackets")
+	defer span.End()
+
+	errs := make([]error, len(packets))
+	accepted := 0
+	for i, packet := range packets {
+		errs[i] = s.ingest(ctx, packet)
+		if errs[i] == nil {
+			accepted++
+		} else if !errors.Is(errs[i], ingest.ErrInvalidPacket) {
+			s.recordError(ctx, span, "IngestPackets", errs[i])
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("packets.accepted", accepted),
+		attribute.Int("packets.rejected", len(packets)-accepted),
+	)
+	return errs
+}
+
+// ingest assigns a packet its ID, validates and stores it
+func (s *PacketService) ingest(ctx context.Context, packet *models.Packet) error {
+	packet.ID = models.NewPacketID()
+	if err := ingest.Validate(packet); err != nil {
+		metrics.PacketsIngested.WithLabelValues("rejected").Inc()
+		return err
+	}
+	if err := s.storage.Store(ctx, packet); err != nil {
+		metrics.PacketsIngested.WithLabelValues("rejected").Inc()
+		metrics.PacketsDropped.WithLabelValues(packet.Protocol, "store_error").Inc()
+		return err
+	}
+	metrics.PacketsIngested.WithLabelValues("accepted").Inc()
+	return nil
 }
 
 // GetPacketByID retrieves a single packet by ID
 func (s *PacketService) GetPacketByID(ctx context.Context, id string) (*models.Packet, error) {
-	return s.storage.GetByID(ctx, id)
+	ctx, span := tracer.Start(ctx, "PacketService.GetPacketByID", trace.WithAttributes(attribute.String("packet.id", id)))
+	defer span.End()
+
+	packet, err := s.storage.GetByID(ctx, id)
+	s.recordError(ctx, span, "GetPacketByID", err)
+	return packet, err
 }
 
 // DeletePacketByID removes a packet by ID
 func (s *PacketService) DeletePacketByID(ctx context.Context, id string) error {
-	return s.storage.DeleteByID(ctx, id)
+	ctx, span := tracer.Start(ctx, "PacketService.DeletePacketByID", trace.WithAttributes(attribute.String("packet.id", id)))
+	defer span.End()
+
+	err := s.storage.DeleteByID(ctx, id)
+	s.recordError(ctx, span, "DeletePacketByID", err)
+	return err
 }
 
-// ClearPackets removes all packets from storage
+// ClearPackets removes all packets from storage, and the rolling
+// aggregates of the deleted traffic when an aggregator is attached
 func (s *PacketService) ClearPackets(ctx context.Context) error {
-	return s.storage.Clear(ctx)
+	ctx, span := tracer.Start(ctx, "PacketService.ClearPackets")
+	defer span.End()
+
+	err := s.storage.Clear(ctx)
+	s.recordError(ctx, span, "ClearPackets", err)
+	if err == nil && s.aggregator != nil {
+		s.aggregator.Reset()
+	}
+	return err
 }
 
-// StorageStats returns storage statistics
+// StorageStats returns storage statistics, including rolling window
+// summaries when an aggregator is attached
 func (s *PacketService) StorageStats(ctx context.Context) (*models.Stats, error) {
-	return s.storage.Stats(ctx)
+	ctx, span := tracer.Start(ctx, "PacketService.StorageStats")
+	defer span.End()
+
+	stats, err := s.storage.Stats(ctx)
+	s.recordError(ctx, span, "StorageStats", err)
+	if err != nil || stats == nil || s.aggregator == nil {
+		return stats, err
+	}
+	stats.Windows = s.aggregator.Stats()
+	return stats, nil
+}
+
+// TopN aggregates packets by dimension and returns the highest ranked keys.
+// Sliding window queries without further filters are answered from the
+// rolling aggregates when available; anything else scans storage.
+func (s *PacketService) TopN(ctx context.Context, query *analytics.Query) (*models.TopNResponse, error) {
+	ctx, span := tracer.Start(ctx, "PacketService.TopN", trace.WithAttributes(
+		attribute.String("analytics.dimension", string(query.Dimension)),
+		attribute.String("analytics.metric", string(query.Metric)),
+	))
+	defer span.End()
+
+	if s.aggregator != nil && query.WindowOnly() {
+		if response, ok := s.aggregator.TopN(query.Window, query.Dimension, query.Metric, query.N); ok {
+			span.SetAttributes(attribute.Bool("analytics.aggregated", true))
+			return response, nil
+		}
+	}
+
+	scan := query.Filter
+	scan.Limit = 0
+	scan.Offset = 0
+
+	packets, err := s.storage.Get(ctx, &scan)
+	if err != nil {
+		s.recordError(ctx, span, "TopN", err)
+		return nil, err
+	}
+
+	response := analytics.TopN(packets.Packets, query.Dimension, query.Metric, query.N)
+	if !scan.FromTimestamp.IsZero() {
+		response.From = &scan.FromTimestamp
+	}
+	if !scan.ToTimestamp.IsZero() {
+		response.To = &scan.ToTimestamp
+	}
+	response.Timestamp = time.Now()
+	return response, nil
+}
+
+// recordError logs a failed operation and marks its span as failed when
+// err is not nil
+func (s *PacketService) recordError(ctx context.Context, span trace.Span, operation string, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		s.logger.ErrorContext(ctx, "Packet service operation failed", "operation", operation, "error", err.Error())
+	}
 }
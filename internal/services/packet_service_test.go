@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/aggregate"
+	"github.com/cryptonextsecurity/network-sniffer/internal/analytics"
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/cryptonextsecurity/network-sniffer/internal/storage"
+	"github.com/cryptonextsecurity/network-sniffer/pkg/sniffing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketService_ClearPacketsResetsAggregates(t *testing.T) {
+	store := storage.NewInMemoryStorage(100)
+	aggregator := aggregate.New(aggregate.DefaultConfig())
+	store.AddObserver(aggregator)
+	service := NewPacketService(store, sniffing.NewPacketSniffer(store, time.Hour), nil).
+		WithAggregator(aggregator)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.Store(ctx, models.NewPacket("10.0.0.1", "10.0.0.2", "TCP", 443, 100)))
+	}
+	query := &analytics.Query{
+		Window:    time.Minute,
+		Dimension: analytics.DimensionDestinationIP,
+		Metric:    analytics.MetricPackets,
+		N:         10,
+	}
+	top, err := service.TopN(ctx, query)
+	require.NoError(t, err)
+	require.Len(t, top.Entries, 1)
+
+	// The rolling aggregates forget the deleted traffic, like storage
+	require.NoError(t, service.ClearPackets(ctx))
+	top, err = service.TopN(ctx, query)
+	require.NoError(t, err)
+	assert.Empty(t, top.Entries)
+	assert.Zero(t, top.TotalPackets)
+
+	stats, err := service.StorageStats(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalPackets)
+	require.NotEmpty(t, stats.Windows)
+	for _, window := range stats.Windows {
+		assert.Zero(t, window.Packets, window.Window)
+		assert.Zero(t, window.Bytes, window.Window)
+	}
+}
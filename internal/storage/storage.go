@@ -1,13 +1,22 @@
 package storage
 
 import (
+	"container/list"
 	"context"
+	"fmt"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
 	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/cryptonextsecurity/network-sniffer/internal/storage")
+
 // Storage defines the interface for packet storage
 type Storage interface {
 	// Store adds a packet to storage
@@ -29,45 +38,162 @@ type Storage interface {
 	Stats(ctx context.Context) (*models.Stats, error)
 }
 
-// InMemoryStorage implements Storage interface with in-memory storage
+// SessionCounter is implemented by backends able to count the packets
+// currently held in a session
+type SessionCounter interface {
+	SessionSize(ctx context.Context, session string) (int, error)
+}
+
+// WritableChecker is implemented by backends able to report whether they
+// currently accept writes
+type WritableChecker interface {
+	CheckWritable(ctx context.Context) error
+}
+
+// Scanner is implemented by backends able to visit the packets matching a
+// filter in batches, so that large results are never copied at once
+type Scanner interface {
+	// Scan calls visit with successive batches of at most size packets
+	// matching filter, oldest first, until every packet is visited or visit
+	// returns an error. Packets stored or removed during a scan may or may
+	// not be visited.
+	Scan(ctx context.Context, filter *models.PacketFilter, size int, visit func(packets []models.Packet) error) error
+}
+
+// Observer is notified of every live packet accepted by a storage backend.
+// Packets of a session, such as an uploaded capture, are not observed so
+// that they do not disturb the analysis of live traffic.
+type Observer interface {
+	OnStore(packet *models.Packet)
+}
+
+// ObserverFunc adapts a plain function to the Observer interface
+type ObserverFunc func(packet *models.Packet)
+
+// OnStore calls f(packet)
+func (f ObserverFunc) OnStore(packet *models.Packet) {
+	f(packet)
+}
+
+// InMemoryStorage implements Storage interface with in-memory storage.
+// Packets are kept ordered by timestamp so eviction and stats do not need
+// to scan the whole store. Live packets and packets of sessions are held
+// in separate lists with their own capacity, so that an uploaded capture
+// never evicts live traffic, and the other way round.
 type InMemoryStorage struct {
-	packets map[string]*models.Packet
-	mutex   sync.RWMutex
+	packets map[string]*list.Element
+	order   *list.List
 	maxSize int
+
+	// sessions holds the packets of each session, and sessionOrder the
+	// sessions from the oldest created, the first evicted
+	sessions       map[string]*list.List
+	sessionOrder   []string
+	sessionPackets int
+	maxSessionSize int
+
+	mutex     sync.RWMutex
+	observers []Observer
 }
 
-// NewInMemoryStorage creates a new in-memory storage instance
+// NewInMemoryStorage creates a new in-memory storage instance holding up to
+// maxSize live packets, and as many packets of sessions
 func NewInMemoryStorage(maxSize int) *InMemoryStorage {
+	metrics.StorageCapacity.Set(float64(maxSize))
+	metrics.StoragePackets.Set(0)
 	return &InMemoryStorage{
-		packets: make(map[string]*models.Packet),
-		maxSize: maxSize,
+		packets:        make(map[string]*list.Element),
+		order:          list.New(),
+		maxSize:        maxSize,
+		sessions:       make(map[string]*list.List),
+		maxSessionSize: maxSize,
 	}
 }
 
+// WithSessionCapacity sets the number of packets of sessions held, across
+// all sessions. Once full, the packets of the oldest session are evicted
+// first.
+func (s *InMemoryStorage) WithSessionCapacity(size int) *InMemoryStorage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.maxSessionSize = size
+	return s
+}
+
+// AddObserver registers an observer notified after each stored packet
+func (s *InMemoryStorage) AddObserver(observer Observer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.observers = append(s.observers, observer)
+}
+
 // Store adds a packet to storage
 func (s *InMemoryStorage) Store(ctx context.Context, packet *models.Packet) error {
+	span := startSpan(ctx, "store", attribute.String("packet.id", packet.ID))
+	defer span.End()
+	start := time.Now()
+
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
 
-	// Check if we need to remove old packets to make room
-	if len(s.packets) >= s.maxSize {
-		s.removeOldestPacket()
+	// Replace any previous packet with the same ID
+	if element, ok := s.packets[packet.ID]; ok {
+		s.remove(element)
 	}
 
-	s.packets[packet.ID] = packet
+	// Check if we need to remove old packets to make room
+	if packet.Session == "" {
+		if s.order.Len() >= s.maxSize {
+			s.evict(s.order)
+		}
+		s.packets[packet.ID] = insertOrdered(s.order, packet)
+	} else {
+		if s.sessionPackets >= s.maxSessionSize && len(s.sessionOrder) > 0 {
+			s.evict(s.sessions[s.sessionOrder[0]])
+		}
+		packets, ok := s.sessions[packet.Session]
+		if !ok {
+			packets = list.New()
+			s.sessions[packet.Session] = packets
+			s.sessionOrder = append(s.sessionOrder, packet.Session)
+		}
+		s.packets[packet.ID] = insertOrdered(packets, packet)
+		s.sessionPackets++
+	}
+	metrics.StoragePackets.Set(float64(s.order.Len()))
+	observers := s.observers
+	s.mutex.Unlock()
+	// Time spent in the observers is not storage latency
+	observeDuration("store", start)
+
+	metrics.PacketsStored.WithLabelValues(packet.Protocol).Inc()
+	if packet.Session == "" {
+		for _, observer := range observers {
+			observer.OnStore(packet)
+		}
+	}
 	return nil
 }
 
-// Get retrieves packets with optional filtering
+// Get retrieves packets with optional filtering, oldest first. Without a
+// filter, live packets come first, then those of each session.
 func (s *InMemoryStorage) Get(ctx context.Context, filter *models.PacketFilter) (*models.PacketResponse, error) {
+	span := startSpan(ctx, "get")
+	defer span.End()
+	defer observeDuration("get", time.Now())
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	var packets []models.Packet
 
-	for _, packet := range s.packets {
-		if s.matchesFilter(packet, filter) {
-			packets = append(packets, *packet)
+	for _, packetList := range s.lists(filter) {
+		for element := packetList.Front(); element != nil; element = element.Next() {
+			packet := element.Value.(*models.Packet)
+			if s.matchesFilter(packet, filter) {
+				packets = append(packets, *packet)
+			}
 		}
 	}
 
@@ -84,6 +210,7 @@ func (s *InMemoryStorage) Get(ctx context.Context, filter *models.PacketFilter)
 		}
 	}
 
+	span.SetAttributes(attribute.Int("packets.returned", len(packets)))
 	return &models.PacketResponse{
 		Packets:   packets,
 		Total:     len(packets),
@@ -91,69 +218,209 @@ func (s *InMemoryStorage) Get(ctx context.Context, filter *models.PacketFilter)
 	}, nil
 }
 
+// Scan visits the packets matching filter in batches, oldest first. The
+// storage is only locked while a batch is copied, so a slow visit does not
+// hold up writers.
+func (s *InMemoryStorage) Scan(ctx context.Context, filter *models.PacketFilter, size int, visit func(packets []models.Packet) error) error {
+	span := startSpan(ctx, "scan")
+	defer span.End()
+	defer observeDuration("scan", time.Now())
+
+	if size < 1 {
+		size = 1
+	}
+	// A negative limit never runs out
+	offset, limit := 0, -1
+	if filter != nil && filter.Limit > 0 {
+		offset, limit = filter.Offset, filter.Limit
+	}
+
+	s.mutex.RLock()
+	lists := s.lists(filter)
+	s.mutex.RUnlock()
+
+	visited := 0
+	for _, packetList := range lists {
+		var cursor *list.Element
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			batch := make([]models.Packet, 0, size)
+			s.mutex.RLock()
+			element := s.resume(packetList, cursor)
+			for ; element != nil && len(batch) < size && limit != 0; element = element.Next() {
+				cursor = element
+				packet := element.Value.(*models.Packet)
+				switch {
+				case !s.matchesFilter(packet, filter):
+				case offset > 0:
+					offset--
+				default:
+					batch = append(batch, *packet)
+					limit--
+				}
+			}
+			s.mutex.RUnlock()
+
+			if len(batch) > 0 {
+				visited += len(batch)
+				if err := visit(batch); err != nil {
+					span.SetAttributes(attribute.Int("packets.returned", visited))
+					return err
+				}
+			}
+			if element == nil || limit == 0 {
+				break
+			}
+		}
+		if limit == 0 {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int("packets.returned", visited))
+	return nil
+}
+
+// resume returns the element of packets following cursor, or the oldest
+// element when cursor is nil. When cursor was removed since it was visited,
+// the scan resumes with the first packet stored after it.
+func (s *InMemoryStorage) resume(packets *list.List, cursor *list.Element) *list.Element {
+	if cursor == nil {
+		return packets.Front()
+	}
+	packet := cursor.Value.(*models.Packet)
+	if s.packets[packet.ID] == cursor {
+		return cursor.Next()
+	}
+	element := packets.Front()
+	for element != nil && !element.Value.(*models.Packet).Timestamp.After(packet.Timestamp) {
+		element = element.Next()
+	}
+	return element
+}
+
 // GetByID retrieves a single packet by ID
 func (s *InMemoryStorage) GetByID(ctx context.Context, id string) (*models.Packet, error) {
+	span := startSpan(ctx, "get_by_id", attribute.String("packet.id", id))
+	defer span.End()
+	defer observeDuration("get_by_id", time.Now())
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	if packet, ok := s.packets[id]; ok {
-		return packet, nil
+	if element, ok := s.packets[id]; ok {
+		return element.Value.(*models.Packet), nil
 	}
 	return nil, nil
 }
 
 // DeleteByID removes a packet by ID
 func (s *InMemoryStorage) DeleteByID(ctx context.Context, id string) error {
+	span := startSpan(ctx, "delete", attribute.String("packet.id", id))
+	defer span.End()
+	defer observeDuration("delete", time.Now())
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	delete(s.packets, id)
+	if element, ok := s.packets[id]; ok {
+		s.remove(element)
+		metrics.StoragePackets.Set(float64(s.order.Len()))
+	}
 	return nil
 }
 
 // Clear removes all packets from storage
 func (s *InMemoryStorage) Clear(ctx context.Context) error {
+	span := startSpan(ctx, "clear")
+	defer span.End()
+	defer observeDuration("clear", time.Now())
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.packets = make(map[string]*models.Packet)
+	s.packets = make(map[string]*list.Element)
+	s.order.Init()
+	s.sessions = make(map[string]*list.List)
+	s.sessionOrder = nil
+	s.sessionPackets = 0
+	metrics.StoragePackets.Set(0)
 	return nil
 }
 
-// Stats returns storage statistics
+// Stats returns statistics of the live packets in storage
 func (s *InMemoryStorage) Stats(ctx context.Context) (*models.Stats, error) {
+	span := startSpan(ctx, "stats")
+	defer span.End()
+	defer observeDuration("stats", time.Now())
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	var oldest *time.Time
 	var newest *time.Time
 
-	for _, p := range s.packets {
-		ts := p.Timestamp
-		if oldest == nil || ts.Before(*oldest) {
-			t := ts
-			oldest = &t
-		}
-		if newest == nil || ts.After(*newest) {
-			t := ts
-			newest = &t
-		}
+	if front := s.order.Front(); front != nil {
+		t := front.Value.(*models.Packet).Timestamp
+		oldest = &t
+	}
+	if back := s.order.Back(); back != nil {
+		t := back.Value.(*models.Packet).Timestamp
+		newest = &t
 	}
 
 	return &models.Stats{
-		TotalPackets: len(s.packets),
+		TotalPackets: s.order.Len(),
 		Capacity:     s.maxSize,
 		OldestAt:     oldest,
 		NewestAt:     newest,
 	}, nil
 }
 
+// SessionSize returns the number of packets currently held in session
+func (s *InMemoryStorage) SessionSize(ctx context.Context, session string) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if session == "" {
+		return s.order.Len(), nil
+	}
+	if packets, ok := s.sessions[session]; ok {
+		return packets.Len(), nil
+	}
+	return 0, nil
+}
+
+// CheckWritable reports whether a write could proceed, by taking the write
+// lock before ctx is done
+func (s *InMemoryStorage) CheckWritable(ctx context.Context) error {
+	for !s.mutex.TryLock() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("storage lock unavailable: %w", ctx.Err())
+		case <-time.After(time.Millisecond):
+		}
+	}
+	s.mutex.Unlock()
+	return nil
+}
+
 // matchesFilter checks if a packet matches the given filter
 func (s *InMemoryStorage) matchesFilter(packet *models.Packet, filter *models.PacketFilter) bool {
 	if filter == nil {
 		return true
 	}
 
+	if packet.Session != filter.Session {
+		return false
+	}
+
+	if filter.Agent != "" && packet.Agent != filter.Agent {
+		return false
+	}
+
 	if filter.Protocol != "" && packet.Protocol != filter.Protocol {
 		return false
 	}
@@ -177,24 +444,76 @@ func (s *InMemoryStorage) matchesFilter(packet *models.Packet, filter *models.Pa
 	return true
 }
 
-// removeOldestPacket removes the oldest packet to make room for new ones
-func (s *InMemoryStorage) removeOldestPacket() {
-	var oldestID string
-	var oldestTime time.Time
-	first := true
-
-	for id, packet := range s.packets {
-		if first {
-			oldestID = id
-			oldestTime = packet.Timestamp
-			first = false
-		} else if packet.Timestamp.Before(oldestTime) {
-			oldestID = id
-			oldestTime = packet.Timestamp
+// lists returns the lists holding the packets filter may match: the live
+// packets, the packets of a session, or every list without a filter
+func (s *InMemoryStorage) lists(filter *models.PacketFilter) []*list.List {
+	if filter != nil {
+		if filter.Session == "" {
+			return []*list.List{s.order}
+		}
+		if packets, ok := s.sessions[filter.Session]; ok {
+			return []*list.List{packets}
 		}
+		return nil
+	}
+	lists := []*list.List{s.order}
+	for _, session := range s.sessionOrder {
+		lists = append(lists, s.sessions[session])
 	}
+	return lists
+}
 
-	if oldestID != "" {
-		delete(s.packets, oldestID)
+// insertOrdered inserts a packet into a timestamp ordered list. Packets
+// usually arrive in order, so the search starts from the newest end.
+func insertOrdered(packets *list.List, packet *models.Packet) *list.Element {
+	for element := packets.Back(); element != nil; element = element.Prev() {
+		if !element.Value.(*models.Packet).Timestamp.After(packet.Timestamp) {
+			return packets.InsertAfter(packet, element)
+		}
 	}
+	return packets.PushFront(packet)
+}
+
+// evict removes the oldest packet of packets to make room for new ones
+func (s *InMemoryStorage) evict(packets *list.List) {
+	if front := packets.Front(); front != nil {
+		packet := s.remove(front)
+		metrics.PacketsEvicted.WithLabelValues(packet.Protocol).Inc()
+	}
+}
+
+// remove removes the packet of element from its list, and forgets its
+// session once empty
+func (s *InMemoryStorage) remove(element *list.Element) *models.Packet {
+	packet := element.Value.(*models.Packet)
+	delete(s.packets, packet.ID)
+	if packet.Session == "" {
+		s.order.Remove(element)
+		return packet
+	}
+
+	packets := s.sessions[packet.Session]
+	packets.Remove(element)
+	s.sessionPackets--
+	if packets.Len() == 0 {
+		delete(s.sessions, packet.Session)
+		s.sessionOrder = slices.DeleteFunc(s.sessionOrder, func(session string) bool {
+			return session == packet.Session
+		})
+	}
+	return packet
+}
+
+// observeDuration records the latency of a storage operation started at
+// start
+func observeDuration(operation string, start time.Time) {
+	metrics.StorageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// startSpan starts the span of a storage operation as a child of the span
+// in ctx, if any
+func startSpan(ctx context.Context, operation string, attributes ...attribute.KeyValue) trace.Span {
+	attributes = append(attributes, attribute.String("db.system", "memory"), attribute.String("db.operation", operation))
+	_, span := tracer.Start(ctx, "storage."+operation, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attributes...))
+	return span
 }
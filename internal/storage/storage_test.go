@@ -2,10 +2,14 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
 	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestInMemoryStorage_Store(t *testing.T) {
@@ -196,3 +200,257 @@ func TestInMemoryStorage_Stats(t *testing.T) {
 		t.Fatalf("expected non-nil timestamps with ordering, got %#v", s)
 	}
 }
+
+func TestInMemoryStorage_EvictsOldestByTimestamp(t *testing.T) {
+	storage := NewInMemoryStorage(2)
+	ctx := context.Background()
+
+	now := time.Now()
+	newest := models.NewPacket("10.0.0.1", "8.8.8.8", "TCP", 80, 100)
+	newest.Timestamp = now
+	oldest := models.NewPacket("10.0.0.2", "8.8.8.8", "TCP", 80, 100)
+	oldest.ID = "oldest"
+	oldest.Timestamp = now.Add(-time.Minute)
+	middle := models.NewPacket("10.0.0.3", "8.8.8.8", "TCP", 80, 100)
+	middle.ID = "middle"
+	middle.Timestamp = now.Add(-time.Second)
+
+	// Store out of order; the packet with the oldest timestamp must be evicted
+	_ = storage.Store(ctx, newest)
+	_ = storage.Store(ctx, oldest)
+	_ = storage.Store(ctx, middle)
+
+	resp, err := storage.Get(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Total != 2 {
+		t.Fatalf("expected 2 packets, got %d", resp.Total)
+	}
+	if resp.Packets[0].ID != middle.ID || resp.Packets[1].ID != newest.ID {
+		t.Fatalf("expected packets ordered oldest first, got %s then %s", resp.Packets[0].ID, resp.Packets[1].ID)
+	}
+
+	s, _ := storage.Stats(ctx)
+	if !s.OldestAt.Equal(middle.Timestamp) || !s.NewestAt.Equal(newest.Timestamp) {
+		t.Fatalf("unexpected stats bounds: %#v", s)
+	}
+}
+
+func TestInMemoryStorage_Observer(t *testing.T) {
+	storage := NewInMemoryStorage(10)
+	ctx := context.Background()
+
+	var observed []string
+	storage.AddObserver(ObserverFunc(func(p *models.Packet) {
+		observed = append(observed, p.ID)
+	}))
+
+	p := models.NewPacket("192.168.1.1", "8.8.8.8", "TCP", 80, 100)
+	_ = storage.Store(ctx, p)
+
+	if len(observed) != 1 || observed[0] != p.ID {
+		t.Fatalf("expected observer to see %s, got %v", p.ID, observed)
+	}
+
+	// Packets of a session are not live traffic
+	uploaded := models.NewPacket("192.168.1.1", "8.8.8.8", "TCP", 80, 100)
+	uploaded.Session = "upload_1"
+	_ = storage.Store(ctx, uploaded)
+	if len(observed) != 1 {
+		t.Errorf("expected session packets not to be observed, got %v", observed)
+	}
+}
+
+func TestInMemoryStorage_Sessions(t *testing.T) {
+	storage := NewInMemoryStorage(10)
+	ctx := context.Background()
+
+	for _, session := range []string{"", "upload_1", "upload_1", "upload_2"} {
+		p := models.NewPacket("192.168.1.1", "8.8.8.8", "TCP", 80, 100)
+		p.Session = session
+		_ = storage.Store(ctx, p)
+	}
+
+	for session, expected := range map[string]int{"": 1, "upload_1": 2, "upload_2": 1, "upload_3": 0} {
+		response, err := storage.Get(ctx, &models.PacketFilter{Session: session})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if response.Total != expected {
+			t.Errorf("Expected %d packets in session %q, got %d", expected, session, response.Total)
+		}
+	}
+
+	// Without a filter every packet is returned
+	response, _ := storage.Get(ctx, nil)
+	if response.Total != 4 {
+		t.Errorf("Expected 4 packets, got %d", response.Total)
+	}
+}
+
+func TestInMemoryStorage_SessionCapacity(t *testing.T) {
+	storage := NewInMemoryStorage(3).WithSessionCapacity(4)
+	ctx := context.Background()
+
+	store := func(session string, n int, start time.Time) {
+		for i := 0; i < n; i++ {
+			p := models.NewPacket("192.168.1.1", "8.8.8.8", "TCP", 80, 100)
+			p.Session = session
+			p.Timestamp = start.Add(time.Duration(i) * time.Second)
+			_ = storage.Store(ctx, p)
+		}
+	}
+	sizes := func() map[string]int {
+		sizes := make(map[string]int)
+		for _, session := range []string{"", "upload_1", "upload_2"} {
+			sizes[session], _ = storage.SessionSize(ctx, session)
+		}
+		return sizes
+	}
+
+	// An old capture uploaded into a full storage keeps live packets, and
+	// only evicts its own oldest packets beyond the capacity of sessions
+	store("", 3, time.Now())
+	store("upload_1", 5, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	if got := sizes(); got[""] != 3 || got["upload_1"] != 4 {
+		t.Errorf("Expected 3 live and 4 uploaded packets, got %v", got)
+	}
+	response, _ := storage.Get(ctx, &models.PacketFilter{Session: "upload_1"})
+	if response.Total != 4 || !response.Packets[0].Timestamp.Equal(time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC)) {
+		t.Errorf("Expected the oldest uploaded packet to be evicted, got %v", response.Packets)
+	}
+
+	// A newer upload evicts the oldest session first, and live traffic
+	// never evicts uploaded packets
+	store("upload_2", 2, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store("", 3, time.Now())
+	if got := sizes(); got[""] != 3 || got["upload_1"] != 2 || got["upload_2"] != 2 {
+		t.Errorf("Expected 3 live packets and 2 of each upload, got %v", got)
+	}
+
+	stats, _ := storage.Stats(ctx)
+	if stats.TotalPackets != 3 || stats.Capacity != 3 {
+		t.Errorf("Expected stats of live packets, got %d/%d", stats.TotalPackets, stats.Capacity)
+	}
+}
+
+func TestInMemoryStorage_Agents(t *testing.T) {
+	storage := NewInMemoryStorage(10)
+	ctx := context.Background()
+
+	for _, agent := range []string{"", "edge-1", "edge-1", "edge-2"} {
+		p := models.NewPacket("192.168.1.1", "8.8.8.8", "TCP", 80, 100)
+		p.Agent = agent
+		_ = storage.Store(ctx, p)
+	}
+
+	// Packets of every agent are live traffic
+	for agent, expected := range map[string]int{"": 4, "edge-1": 2, "edge-2": 1, "edge-3": 0} {
+		response, err := storage.Get(ctx, &models.PacketFilter{Agent: agent})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if response.Total != expected {
+			t.Errorf("Expected %d packets of agent %q, got %d", expected, agent, response.Total)
+		}
+	}
+}
+
+func TestInMemoryStorage_Metrics(t *testing.T) {
+	storage := NewInMemoryStorage(2)
+	ctx := context.Background()
+
+	stored := testutil.ToFloat64(metrics.PacketsStored.WithLabelValues("ICMP"))
+	evicted := testutil.ToFloat64(metrics.PacketsEvicted.WithLabelValues("ICMP"))
+
+	for i := 0; i < 3; i++ {
+		storage.Store(ctx, models.NewPacket("192.168.1.1", "8.8.8.8", "ICMP", 0, 64))
+	}
+
+	if got := testutil.ToFloat64(metrics.PacketsStored.WithLabelValues("ICMP")) - stored; got != 3 {
+		t.Errorf("Expected 3 stored packets, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.PacketsEvicted.WithLabelValues("ICMP")) - evicted; got != 1 {
+		t.Errorf("Expected 1 evicted packet, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.StoragePackets); got != 2 {
+		t.Errorf("Expected occupancy 2, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.StorageCapacity); got != 2 {
+		t.Errorf("Expected capacity 2, got %v", got)
+	}
+
+	storage.Clear(ctx)
+	if got := testutil.ToFloat64(metrics.StoragePackets); got != 0 {
+		t.Errorf("Expected occupancy 0 after clear, got %v", got)
+	}
+}
+
+func TestInMemoryStorage_Scan(t *testing.T) {
+	storage := NewInMemoryStorage(100)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		protocol := "TCP"
+		if i%2 == 1 {
+			protocol = "UDP"
+		}
+		p := models.NewPacket("10.0.0.1", "8.8.8.8", protocol, 80, 100)
+		p.ID = fmt.Sprintf("p%d", i)
+		p.Timestamp = start.Add(time.Duration(i) * time.Second)
+		_ = storage.Store(ctx, p)
+	}
+
+	scan := func(filter *models.PacketFilter, size int) ([]string, []int) {
+		var ids []string
+		var batches []int
+		err := storage.Scan(ctx, filter, size, func(packets []models.Packet) error {
+			batches = append(batches, len(packets))
+			for _, p := range packets {
+				ids = append(ids, p.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return ids, batches
+	}
+
+	ids, batches := scan(nil, 4)
+	if fmt.Sprint(batches) != "[4 4 2]" || ids[0] != "p0" || ids[9] != "p9" {
+		t.Errorf("expected every packet in batches of 4, oldest first, got %v in %v", ids, batches)
+	}
+
+	ids, _ = scan(&models.PacketFilter{Protocol: "UDP", Offset: 1, Limit: 3}, 2)
+	if fmt.Sprint(ids) != "[p3 p5 p7]" {
+		t.Errorf("expected the 2nd to 4th UDP packets, got %v", ids)
+	}
+
+	// Packets removed between batches do not stop the scan
+	ids = nil
+	err := storage.Scan(ctx, nil, 3, func(packets []models.Packet) error {
+		for _, p := range packets {
+			ids = append(ids, p.ID)
+		}
+		return storage.DeleteByID(ctx, packets[len(packets)-1].ID)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(ids) != "[p0 p1 p2 p3 p4 p5 p6 p7 p8 p9]" {
+		t.Errorf("expected every packet once, got %v", ids)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = storage.Scan(ctx, nil, 1, func(packets []models.Packet) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected the scan to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
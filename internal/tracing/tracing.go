@@ -0,0 +1,140 @@
+// Package tracing configures OpenTelemetry tracing for the service: the
+// tracer provider, its exporter and the W3C trace context propagator.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// Supported exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// ErrUnknownExporter is returned for an exporter name that is not supported
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// Config selects where spans are exported and how many are sampled
+type Config struct {
+	// Exporter is one of none, stdout, file or otlp
+	Exporter    string
+	ServiceName string
+	// File receives the spans of the file exporter as JSON lines
+	File string
+	// OTLPEndpoint is the host:port of an OTLP/HTTP collector. When empty
+	// the standard OTEL_EXPORTER_OTLP_* environment variables apply.
+	OTLPEndpoint string
+	OTLPInsecure bool
+	// SampleRatio is the fraction of new traces recorded. Traces started
+	// by a sampled caller are always recorded.
+	SampleRatio float64
+}
+
+// DefaultConfig returns a configuration that exports nothing
+func DefaultConfig() Config {
+	return Config{
+		Exporter:    ExporterNone,
+		ServiceName: "network-sniffer",
+		SampleRatio: 1,
+	}
+}
+
+// ShutdownFunc flushes pending spans and releases the exporter
+type ShutdownFunc func(ctx context.Context) error
+
+// Setup installs the global tracer provider and the W3C trace context and
+// baggage propagators. With the none exporter spans are not recorded, but
+// incoming trace context is still propagated.
+func Setup(ctx context.Context, config Config) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, closeOutput, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(config.ServiceName),
+	))
+	if err != nil {
+		closeOutput()
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		closeOutput()
+		return err
+	}, nil
+}
+
+// newExporter creates the exporter named by the configuration. The
+// returned function closes the output file of the file exporter.
+func newExporter(ctx context.Context, config Config) (sdktrace.SpanExporter, func(), error) {
+	noop := func() {}
+
+	switch config.Exporter {
+	case ExporterNone, "":
+		return nil, noop, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, noop, err
+	case ExporterFile:
+		if config.File == "" {
+			return nil, nil, errors.New("the file trace exporter requires a file")
+		}
+		file, err := os.OpenFile(config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, func() { file.Close() }, nil
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if config.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.OTLPEndpoint))
+		}
+		if config.OTLPInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		return exporter, noop, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnknownExporter, config.Exporter)
+	}
+}
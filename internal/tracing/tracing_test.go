@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// exportedSpan holds the fields of a span written by the stdout exporter
+type exportedSpan struct {
+	Name        string
+	SpanContext struct{ TraceID string }
+	Parent      struct{ SpanID string }
+	Resource    []struct {
+		Key   string
+		Value struct{ Value any }
+	}
+}
+
+func TestSetup_FileExporterContinuesIncomingTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	config := DefaultConfig()
+	config.Exporter = ExporterFile
+	config.File = path
+
+	shutdown, err := Setup(context.Background(), config)
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+
+	_, span := otel.Tracer("test").Start(ctx, "GET /api/v1/packets")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var spans []exportedSpan
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var s exportedSpan
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &s))
+		spans = append(spans, s)
+	}
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /api/v1/packets", spans[0].Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID)
+
+	serviceName := ""
+	for _, attribute := range spans[0].Resource {
+		if attribute.Key == "service.name" {
+			serviceName, _ = attribute.Value.Value.(string)
+		}
+	}
+	assert.Equal(t, "network-sniffer", serviceName)
+}
+
+func TestSetup_Exporters(t *testing.T) {
+	shutdown, err := Setup(context.Background(), DefaultConfig())
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), Config{Exporter: "jaeger"})
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+
+	_, err = Setup(context.Background(), Config{Exporter: ExporterFile})
+	assert.Error(t, err)
+}
@@ -0,0 +1,312 @@
+// Package client is a Go client of the network sniffer HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// ErrInvalidServer is returned for a server address that is not an
+// absolute http or https URL
+var ErrInvalidServer = errors.New("invalid server URL")
+
+// APIError is returned for a response with an error status
+type APIError struct {
+	StatusCode int
+	Err        string `json:"error"`
+	Message    string `json:"message"`
+
+	// Body is the raw response, such as the report of a failed probe
+	Body []byte `json:"-"`
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	title := e.Err
+	if title == "" {
+		title = http.StatusText(e.StatusCode)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, title)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, title, e.Message)
+}
+
+// Client calls the API of one service instance
+type Client struct {
+	baseURL *url.URL
+	apiKey  string
+	token   string
+	http    *http.Client
+}
+
+// Option configures a client
+type Option func(*Client)
+
+// WithAPIKey authenticates requests with an API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithToken authenticates requests with a JWT bearer token
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient sends requests through httpClient instead of a client
+// with a 30s timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.http = httpClient }
+}
+
+// New creates a client of the service listening at server, such as
+// "http://localhost:8080"
+func New(server string, options ...Option) (*Client, error) {
+	base, err := url.Parse(strings.TrimRight(server, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidServer, server)
+	}
+
+	c := &Client{
+		baseURL: base,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+// Server returns the root URL of the service
+func (c *Client) Server() string {
+	return c.baseURL.String()
+}
+
+// Do sends a request to path, relative to the server root, with body
+// encoded as JSON when not nil, and decodes the JSON response into out
+// when not nil. Error statuses are returned as *APIError.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	req, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := send(c.http, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// request builds an authenticated request to path with body encoded as
+// JSON when not nil
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	target := *c.baseURL
+	target.Path += path
+	target.RawQuery = query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// send sends a request and returns error statuses as *APIError. The
+// caller closes the body of a successful response.
+func send(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: data}
+		if json.Unmarshal(data, apiErr) != nil {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// Packets lists the stored packets matching filter, oldest first
+func (c *Client) Packets(ctx context.Context, filter models.PacketFilter) (*models.PacketResponse, error) {
+	var response models.PacketResponse
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/packets", FilterQuery(filter), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Packet returns one stored packet
+func (c *Client) Packet(ctx context.Context, id string) (*models.Packet, error) {
+	var packet models.Packet
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/packets/"+url.PathEscape(id), nil, nil, &packet); err != nil {
+		return nil, err
+	}
+	return &packet, nil
+}
+
+// Stats returns the storage usage and rolling traffic statistics
+func (c *Client) Stats(ctx context.Context) (*models.Stats, error) {
+	var stats models.Stats
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/stats", nil, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Export downloads the packets matching filter as a file in format, such
+// as "csv" or "pcapng", to w and returns the number of bytes written. The
+// transfer is gzip compressed when the format allows it. Exports are not
+// bound by the timeout of the HTTP client; cancel ctx to stop one.
+func (c *Client) Export(ctx context.Context, format string, filter models.PacketFilter, w io.Writer) (int64, error) {
+	query := FilterQuery(filter)
+	query.Set("format", format)
+	req, err := c.request(ctx, http.MethodGet, "/api/v1/packets/export", query, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	httpClient := *c.http
+	httpClient.Timeout = 0
+	resp, err := send(&httpClient, req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// Upload sends a pcap or pcapng capture file, read from r, to be decoded
+// into a new session and returns the queued ingestion job. Uploads are not
+// bound by the timeout of the HTTP client; cancel ctx to stop one.
+func (c *Client) Upload(ctx context.Context, name string, r io.Reader) (*models.IngestJob, error) {
+	req, err := c.request(ctx, http.MethodPost, "/api/v1/ingest/jobs", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stream the form rather than buffering the whole file
+	body, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		part, err := form.CreateFormFile("file", name)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		w.CloseWithError(err)
+	}()
+	req.Body = body
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := *c.http
+	httpClient.Timeout = 0
+	resp, err := send(&httpClient, req)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var job models.IngestJob
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// IngestJob returns the state of the ingestion job of an uploaded capture
+func (c *Client) IngestJob(ctx context.Context, id string) (*models.IngestJob, error) {
+	var job models.IngestJob
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/ingest/jobs/"+url.PathEscape(id), nil, nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// TopN ranks the values of dimension, such as "source_ip", by packets or
+// bytes. query holds the other parameters of the route.
+func (c *Client) TopN(ctx context.Context, dimension string, query url.Values) (*models.TopNResponse, error) {
+	var response models.TopNResponse
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/analytics/top/"+url.PathEscape(dimension), query, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// FilterQuery encodes a packet filter as the query string of the packet
+// routes
+func FilterQuery(filter models.PacketFilter) url.Values {
+	query := url.Values{}
+	if filter.Protocol != "" {
+		query.Set("protocol", filter.Protocol)
+	}
+	if filter.SourceIP != "" {
+		query.Set("source_ip", filter.SourceIP)
+	}
+	if filter.DestinationIP != "" {
+		query.Set("destination_ip", filter.DestinationIP)
+	}
+	if !filter.FromTimestamp.IsZero() {
+		query.Set("from", filter.FromTimestamp.Format(time.RFC3339Nano))
+	}
+	if !filter.ToTimestamp.IsZero() {
+		query.Set("to", filter.ToTimestamp.Format(time.RFC3339Nano))
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		query.Set("offset", strconv.Itoa(filter.Offset))
+	}
+	if filter.Session != "" {
+		query.Set("session", filter.Session)
+	}
+	if filter.Agent != "" {
+		query.Set("agent", filter.Agent)
+	}
+	return query
+}
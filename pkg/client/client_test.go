@@ -0,0 +1,169 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_InvalidServer(t *testing.T) {
+	for _, server := range []string{"", "localhost:8080", "ftp://localhost", "http://"} {
+		_, err := New(server)
+		assert.ErrorIs(t, err, ErrInvalidServer, server)
+	}
+}
+
+func TestClient_Packets(t *testing.T) {
+	from := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/prefix/api/v1/packets", r.URL.Path)
+		assert.Equal(t, "TCP", r.URL.Query().Get("protocol"))
+		assert.Equal(t, "2024-01-02T03:04:05.0000006Z", r.URL.Query().Get("from"))
+		assert.Equal(t, "5", r.URL.Query().Get("limit"))
+		assert.Empty(t, r.URL.Query().Get("offset"))
+		assert.Equal(t, "nsk_key", r.Header.Get("X-API-Key"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		json.NewEncoder(w).Encode(models.PacketResponse{
+			Packets: []models.Packet{{ID: "packet_1", Protocol: "TCP"}},
+			Total:   1,
+		})
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL+"/prefix/", WithAPIKey("nsk_key"), WithToken("token"))
+	require.NoError(t, err)
+
+	response, err := c.Packets(context.Background(), models.PacketFilter{Protocol: "TCP", FromTimestamp: from, Limit: 5})
+	require.NoError(t, err)
+	assert.Equal(t, 1, response.Total)
+	assert.Equal(t, "packet_1", response.Packets[0].ID)
+}
+
+func TestClient_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/packets/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"Not Found","message":"Packet not found"}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("upstream unavailable\n"))
+		}
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	_, err = c.Packet(context.Background(), "missing")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "404 Not Found: Packet not found", err.Error())
+
+	_, err = c.Stats(context.Background())
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "502 Bad Gateway: upstream unavailable", err.Error())
+	assert.Equal(t, "upstream unavailable\n", string(apiErr.Body))
+}
+
+func TestClient_Export(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/packets/export", r.URL.Path)
+		assert.Equal(t, "csv", r.URL.Query().Get("format"))
+		assert.Equal(t, "UDP", r.URL.Query().Get("protocol"))
+		assert.Contains(t, r.Header.Get("Accept-Encoding"), "gzip")
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("id,timestamp\npacket_1,2024-01-02T03:04:05Z\n"))
+		gz.Close()
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, WithHTTPClient(&http.Client{Timeout: time.Nanosecond}))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := c.Export(context.Background(), "csv", models.PacketFilter{Protocol: "UDP"}, &buf)
+	require.NoError(t, err, "the client timeout does not apply to exports")
+	assert.Equal(t, "id,timestamp\npacket_1,2024-01-02T03:04:05Z\n", buf.String())
+	assert.Equal(t, int64(buf.Len()), n)
+}
+
+func TestClient_Upload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/ingest/jobs", r.URL.Path)
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, _ := io.ReadAll(file)
+		assert.Equal(t, "capture.pcap", header.Filename)
+		assert.Equal(t, "pcap data", string(data))
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"id":"job_1","session":"upload_1","status":"queued","size":9}`))
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, WithHTTPClient(&http.Client{Timeout: time.Nanosecond}))
+	require.NoError(t, err)
+
+	job, err := c.Upload(context.Background(), "capture.pcap", strings.NewReader("pcap data"))
+	require.NoError(t, err, "the client timeout does not apply to uploads")
+	assert.Equal(t, "upload_1", job.Session)
+	assert.Equal(t, models.IngestJobQueued, job.Status)
+}
+
+func TestTail_Next(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stored := []models.Packet{
+		{ID: "packet_1", Timestamp: base},
+		{ID: "packet_2", Timestamp: base.Add(time.Second)},
+	}
+	var froms []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from := r.URL.Query().Get("from")
+		froms = append(froms, from)
+		var packets []models.Packet
+		for _, packet := range stored {
+			if t, _ := time.Parse(time.RFC3339Nano, from); !packet.Timestamp.Before(t) {
+				packets = append(packets, packet)
+			}
+		}
+		json.NewEncoder(w).Encode(models.PacketResponse{Packets: packets, Total: len(packets)})
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL)
+	require.NoError(t, err)
+	tail := c.Tail(models.PacketFilter{Limit: 1})
+
+	packets, err := tail.Next(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, packets, 2)
+
+	// The packet at the inclusive lower bound is not returned again
+	stored = append(stored, models.Packet{ID: "packet_3", Timestamp: base.Add(time.Second)})
+	packets, err = tail.Next(context.Background())
+	require.NoError(t, err)
+	require.Len(t, packets, 1)
+	assert.Equal(t, "packet_3", packets[0].ID)
+
+	packets, err = tail.Next(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, packets)
+	assert.Equal(t, []string{"", "2024-01-02T03:04:06Z", "2024-01-02T03:04:06Z"}, froms)
+}
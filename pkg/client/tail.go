@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// Tail follows the packets stored by the service
+type Tail struct {
+	client *Client
+	filter models.PacketFilter
+	newest time.Time
+	seen   map[string]bool
+}
+
+// Tail follows the packets matching filter, starting at its FromTimestamp.
+// Limit and Offset are ignored.
+func (c *Client) Tail(filter models.PacketFilter) *Tail {
+	filter.Limit = 0
+	filter.Offset = 0
+	return &Tail{client: c, filter: filter, seen: make(map[string]bool)}
+}
+
+// Skip makes the next call to Next only return the packets stored after
+// the newest one currently stored
+func (t *Tail) Skip(ctx context.Context) error {
+	stats, err := t.client.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	if stats.NewestAt == nil {
+		return nil
+	}
+	t.filter.FromTimestamp = *stats.NewestAt
+	_, err = t.Next(ctx)
+	return err
+}
+
+// Next returns the packets stored since the previous call, oldest first
+func (t *Tail) Next(ctx context.Context) ([]models.Packet, error) {
+	response, err := t.client.Packets(ctx, t.filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var fresh []models.Packet
+	for _, packet := range response.Packets {
+		if t.seen[packet.ID] || packet.Timestamp.Before(t.newest) {
+			continue
+		}
+		// The lower bound is inclusive, so only the IDs at the newest
+		// timestamp are needed to skip the packets returned again
+		if packet.Timestamp.After(t.newest) {
+			t.newest = packet.Timestamp
+			t.seen = make(map[string]bool)
+		}
+		t.seen[packet.ID] = true
+		fresh = append(fresh, packet)
+	}
+	if !t.newest.IsZero() {
+		t.filter.FromTimestamp = t.newest
+	}
+	return fresh, nil
+}
@@ -0,0 +1,92 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// encoder writes the Thrift compact protocol encoding of the Parquet
+// metadata structures
+type encoder struct {
+	buf []byte
+	// last holds the previous field ID of each open struct
+	last []int16
+}
+
+// newEncoder returns an encoder positioned inside a top level struct
+func newEncoder() *encoder {
+	return &encoder{last: []int16{0}}
+}
+
+// field writes the header of field id of type kind
+func (e *encoder) field(id int16, kind byte) {
+	top := len(e.last) - 1
+	if delta := id - e.last[top]; delta > 0 && delta <= 15 {
+		e.buf = append(e.buf, byte(delta)<<4|kind)
+	} else {
+		e.buf = append(e.buf, kind)
+		e.buf = binary.AppendVarint(e.buf, int64(id))
+	}
+	e.last[top] = id
+}
+
+// i32 writes an i32 or enum field
+func (e *encoder) i32(id int16, value int32) {
+	e.field(id, thriftI32)
+	e.buf = binary.AppendVarint(e.buf, int64(value))
+}
+
+// i64 writes an i64 field
+func (e *encoder) i64(id int16, value int64) {
+	e.field(id, thriftI64)
+	e.buf = binary.AppendVarint(e.buf, value)
+}
+
+// string writes a string field
+func (e *encoder) string(id int16, value string) {
+	e.field(id, thriftBinary)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(value)))
+	e.buf = append(e.buf, value...)
+}
+
+// list writes the header of a list field of n elements of type kind
+func (e *encoder) list(id int16, kind byte, n int) {
+	e.field(id, thriftList)
+	if n < 15 {
+		e.buf = append(e.buf, byte(n)<<4|kind)
+		return
+	}
+	e.buf = append(e.buf, 0xf0|kind)
+	e.buf = binary.AppendUvarint(e.buf, uint64(n))
+}
+
+// i32Element writes an i32 or enum list element
+func (e *encoder) i32Element(value int32) {
+	e.buf = binary.AppendVarint(e.buf, int64(value))
+}
+
+// stringElement writes a string list element
+func (e *encoder) stringElement(value string) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(value)))
+	e.buf = append(e.buf, value...)
+}
+
+// begin opens a struct field, or a struct list element when id is 0
+func (e *encoder) begin(id int16) {
+	if id != 0 {
+		e.field(id, thriftStruct)
+	}
+	e.last = append(e.last, 0)
+}
+
+// end closes the innermost struct
+func (e *encoder) end() {
+	e.buf = append(e.buf, 0)
+	e.last = e.last[:len(e.last)-1]
+}
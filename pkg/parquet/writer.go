@@ -0,0 +1,448 @@
+// Package parquet writes flat tables as Apache Parquet files.
+//
+// Rows are buffered in memory until a row group is full, then written as
+// one PLAIN encoded data page per column, so a table of any length is
+// written in bounded memory. Nested and repeated columns, dictionaries and
+// statistics are not supported.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Type is the type of the values of a column
+type Type int
+
+const (
+	// String columns hold UTF-8 strings
+	String Type = iota
+	// Int32 columns hold 32-bit integers, written from int or int32 values
+	Int32
+	// Int64 columns hold 64-bit integers, written from int or int64 values
+	Int64
+	// Timestamp columns hold UTC instants with microsecond precision,
+	// written from time.Time values
+	Timestamp
+)
+
+// Codec is the compression of the data pages
+type Codec int32
+
+// Compression codecs
+const (
+	Uncompressed Codec = 0
+	Gzip         Codec = 2
+)
+
+// Column describes a column of the table
+type Column struct {
+	Name string
+	Type Type
+	// Optional columns accept nil values
+	Optional bool
+}
+
+// DefaultRowGroupSize is the number of rows buffered before they are
+// written as a row group
+const DefaultRowGroupSize = 8192
+
+// ErrValue is returned when a value does not match the type of its column
+var ErrValue = errors.New("invalid value")
+
+var magic = []byte("PAR1")
+
+// Physical types, converted types, encodings and page types of the format
+const (
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	pageData = 0
+)
+
+// Writer writes rows to a Parquet file
+type Writer struct {
+	w            io.Writer
+	columns      []*column
+	codec        Codec
+	rowGroupSize int
+	createdBy    string
+
+	offset    int64
+	rows      int
+	rowGroups []rowGroup
+	total     int64
+	started   bool
+	closed    bool
+	err       error
+}
+
+// column buffers the values of a column in the current row group
+type column struct {
+	Column
+	values []byte
+	levels []byte
+	nulls  int
+}
+
+// rowGroup records a written row group for the file footer
+type rowGroup struct {
+	chunks []chunk
+	rows   int
+	size   int64
+}
+
+// chunk records a written column chunk for the file footer
+type chunk struct {
+	values       int
+	offset       int64
+	uncompressed int64
+	compressed   int64
+}
+
+// NewWriter creates a writer of a table with the given columns to w.
+// Nothing is written before the first row.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	writer := &Writer{
+		w:            w,
+		rowGroupSize: DefaultRowGroupSize,
+		createdBy:    "network-sniffer",
+	}
+	for _, c := range columns {
+		writer.columns = append(writer.columns, &column{Column: c})
+	}
+	return writer
+}
+
+// WithCompression compresses the data pages with codec
+func (w *Writer) WithCompression(codec Codec) *Writer {
+	w.codec = codec
+	return w
+}
+
+// WithRowGroupSize sets the number of rows of each row group
+func (w *Writer) WithRowGroupSize(rows int) *Writer {
+	if rows > 0 {
+		w.rowGroupSize = rows
+	}
+	return w
+}
+
+// Write appends a row holding one value per column. A row holding an
+// invalid value is rejected without being written.
+func (w *Writer) Write(row ...any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return errors.New("parquet: write to closed writer")
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("%w: row of %d values for %d columns", ErrValue, len(row), len(w.columns))
+	}
+
+	encoded := make([][]byte, len(row))
+	for i, value := range row {
+		c := w.columns[i]
+		if value == nil {
+			if !c.Optional {
+				return fmt.Errorf("%w: nil value of required column %q", ErrValue, c.Name)
+			}
+			continue
+		}
+		data, err := encode(c.Type, value)
+		if err != nil {
+			return fmt.Errorf("%w: column %q: %v", ErrValue, c.Name, err)
+		}
+		encoded[i] = data
+	}
+
+	for i, c := range w.columns {
+		switch {
+		case encoded[i] != nil:
+			c.values = append(c.values, encoded[i]...)
+			c.levels = append(c.levels, 1)
+		default:
+			c.levels = append(c.levels, 0)
+			c.nulls++
+		}
+	}
+	w.rows++
+	if w.rows >= w.rowGroupSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered rows as a row group
+func (w *Writer) Flush() error {
+	if w.err != nil || w.rows == 0 {
+		return w.err
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	group := rowGroup{rows: w.rows}
+	for _, c := range w.columns {
+		written, err := w.writeChunk(c)
+		if err != nil {
+			w.err = err
+			return err
+		}
+		group.chunks = append(group.chunks, written)
+		group.size += written.uncompressed
+		c.values = c.values[:0]
+		c.levels = c.levels[:0]
+		c.nulls = 0
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.total += int64(w.rows)
+	w.rows = 0
+	return nil
+}
+
+// Close writes the buffered rows and the file footer. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	footer := w.footer()
+	trailer := binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))
+	trailer = append(trailer, magic...)
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	return w.write(trailer)
+}
+
+// start writes the leading magic number once
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	return w.write(magic)
+}
+
+// write writes data and tracks the file offset
+func (w *Writer) write(data []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	n, err := w.w.Write(data)
+	w.offset += int64(n)
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+// writeChunk writes the buffered values of a column as one data page
+func (w *Writer) writeChunk(c *column) (chunk, error) {
+	var page []byte
+	if c.Optional {
+		levels := encodeLevels(c.levels)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+	}
+	page = append(page, c.values...)
+	if len(page) > math.MaxInt32 {
+		return chunk{}, fmt.Errorf("parquet: page of column %q exceeds 2 GiB", c.Name)
+	}
+
+	body := page
+	if w.codec == Gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(page)
+		if err := gz.Close(); err != nil {
+			return chunk{}, err
+		}
+		body = buf.Bytes()
+	}
+
+	header := newEncoder()
+	header.i32(1, pageData)
+	header.i32(2, int32(len(page)))
+	header.i32(3, int32(len(body)))
+	header.begin(5)
+	header.i32(1, int32(len(c.levels)))
+	header.i32(2, encodingPlain)
+	header.i32(3, encodingRLE)
+	header.i32(4, encodingRLE)
+	header.end()
+	header.end()
+
+	written := chunk{
+		values:       len(c.levels),
+		offset:       w.offset,
+		uncompressed: int64(len(header.buf) + len(page)),
+		compressed:   int64(len(header.buf) + len(body)),
+	}
+	if err := w.write(header.buf); err != nil {
+		return chunk{}, err
+	}
+	if err := w.write(body); err != nil {
+		return chunk{}, err
+	}
+	return written, nil
+}
+
+// footer encodes the file metadata
+func (w *Writer) footer() []byte {
+	e := newEncoder()
+	e.i32(1, 1)
+
+	e.list(2, thriftStruct, len(w.columns)+1)
+	e.begin(0)
+	e.string(4, "schema")
+	e.i32(5, int32(len(w.columns)))
+	e.end()
+	for _, c := range w.columns {
+		e.begin(0)
+		e.i32(1, physicalType(c.Type))
+		repetition := int32(repetitionRequired)
+		if c.Optional {
+			repetition = repetitionOptional
+		}
+		e.i32(3, repetition)
+		e.string(4, c.Name)
+		switch c.Type {
+		case String:
+			e.i32(6, convertedUTF8)
+		case Timestamp:
+			e.i32(6, convertedTimestampMicros)
+		}
+		e.end()
+	}
+
+	e.i64(3, w.total)
+
+	e.list(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		e.begin(0)
+		e.list(1, thriftStruct, len(group.chunks))
+		for i, written := range group.chunks {
+			c := w.columns[i]
+			e.begin(0)
+			e.i64(2, written.offset)
+			e.begin(3)
+			e.i32(1, physicalType(c.Type))
+			e.list(2, thriftI32, 2)
+			e.i32Element(encodingPlain)
+			e.i32Element(encodingRLE)
+			e.list(3, thriftBinary, 1)
+			e.stringElement(c.Name)
+			e.i32(4, int32(w.codec))
+			e.i64(5, int64(written.values))
+			e.i64(6, written.uncompressed)
+			e.i64(7, written.compressed)
+			e.i64(9, written.offset)
+			e.end()
+			e.end()
+		}
+		e.i64(2, group.size)
+		e.i64(3, int64(group.rows))
+		e.end()
+	}
+
+	e.string(6, w.createdBy)
+	e.end()
+	return e.buf
+}
+
+// physicalType returns the physical type of the values of a column type
+func physicalType(t Type) int32 {
+	switch t {
+	case Int32:
+		return physicalInt32
+	case Int64, Timestamp:
+		return physicalInt64
+	default:
+		return physicalByteArray
+	}
+}
+
+// encode returns the PLAIN encoding of a value of type t
+func encode(t Type, value any) ([]byte, error) {
+	switch t {
+	case String:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %T", value)
+		}
+		data := binary.LittleEndian.AppendUint32(nil, uint32(len(s)))
+		return append(data, s...), nil
+	case Int32:
+		var n int64
+		switch v := value.(type) {
+		case int:
+			n = int64(v)
+		case int32:
+			n = int64(v)
+		default:
+			return nil, fmt.Errorf("expected an int32, got %T", value)
+		}
+		if n < math.MinInt32 || n > math.MaxInt32 {
+			return nil, fmt.Errorf("%d overflows int32", n)
+		}
+		return binary.LittleEndian.AppendUint32(nil, uint32(int32(n))), nil
+	case Int64:
+		switch v := value.(type) {
+		case int:
+			return binary.LittleEndian.AppendUint64(nil, uint64(v)), nil
+		case int64:
+			return binary.LittleEndian.AppendUint64(nil, uint64(v)), nil
+		}
+		return nil, fmt.Errorf("expected an int64, got %T", value)
+	case Timestamp:
+		v, ok := value.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("expected a time.Time, got %T", value)
+		}
+		return binary.LittleEndian.AppendUint64(nil, uint64(v.UnixMicro())), nil
+	}
+	return nil, fmt.Errorf("unknown column type %d", t)
+}
+
+// encodeLevels encodes definition levels of bit width 1 as runs of the
+// RLE/bit-packing hybrid encoding
+func encodeLevels(levels []byte) []byte {
+	var data []byte
+	for start := 0; start < len(levels); {
+		end := start + 1
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		data = binary.AppendUvarint(data, uint64(end-start)<<1)
+		data = append(data, levels[start])
+		start = end
+	}
+	return data
+}
@@ -0,0 +1,237 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decoder reads Thrift compact protocol structs as maps of field IDs to
+// int64, []byte, []any or nested map values
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) == 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) value(kind byte) any {
+	switch kind {
+	case thriftI32, thriftI64:
+		return d.varint()
+	case thriftBinary:
+		n := int(d.uvarint())
+		if n > len(d.data) {
+			d.err = io.ErrUnexpectedEOF
+			return nil
+		}
+		v := d.data[:n]
+		d.data = d.data[n:]
+		return v
+	case thriftList:
+		header := d.byte()
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(d.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = d.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return d.fields()
+	}
+	d.err = errors.New("unexpected type")
+	return nil
+}
+
+func (d *decoder) fields() map[int16]any {
+	fields := map[int16]any{}
+	var last int16
+	for d.err == nil {
+		header := d.byte()
+		if header == 0 {
+			break
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(d.varint())
+		}
+		fields[id] = d.value(header & 0x0f)
+		last = id
+	}
+	return fields
+}
+
+// readFile decodes a Parquet file written by Writer into its footer and
+// the values of each column, nil for nulls
+func readFile(t *testing.T, file []byte) (map[int16]any, [][]any) {
+	t.Helper()
+	require.Equal(t, magic, file[:4])
+	require.Equal(t, magic, file[len(file)-4:])
+	length := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footerData := file[len(file)-8-length : len(file)-8]
+	footerDecoder := &decoder{data: footerData}
+	footer := footerDecoder.fields()
+	require.NoError(t, footerDecoder.err)
+	require.Empty(t, footerDecoder.data)
+
+	schema := footer[2].([]any)
+	columns := make([][]any, len(schema)-1)
+	for _, group := range footer[4].([]any) {
+		for i, c := range group.(map[int16]any)[1].([]any) {
+			meta := c.(map[int16]any)[3].(map[int16]any)
+			element := schema[i+1].(map[int16]any)
+			offset := meta[9].(int64)
+
+			d := &decoder{data: file[offset:]}
+			header := d.fields()
+			require.NoError(t, d.err)
+			body := d.data[:header[3].(int64)]
+			if meta[4].(int64) == int64(Gzip) {
+				gz, err := gzip.NewReader(bytes.NewReader(body))
+				require.NoError(t, err)
+				body, err = io.ReadAll(gz)
+				require.NoError(t, err)
+			}
+			require.Len(t, body, int(header[2].(int64)))
+			headerSize := int64(len(file[offset:]) - len(d.data))
+			require.Equal(t, headerSize+header[3].(int64), meta[7].(int64))
+
+			n := int(header[5].(map[int16]any)[1].(int64))
+			levels := make([]byte, 0, n)
+			if element[3].(int64) == repetitionOptional {
+				size := binary.LittleEndian.Uint32(body)
+				runs := body[4 : 4+size]
+				body = body[4+size:]
+				for len(runs) > 0 {
+					count, k := binary.Uvarint(runs)
+					require.Zero(t, count&1, "bit-packed runs are not written")
+					for j := 0; j < int(count>>1); j++ {
+						levels = append(levels, runs[k])
+					}
+					runs = runs[k+1:]
+				}
+			} else {
+				levels = bytes.Repeat([]byte{1}, n)
+			}
+			require.Len(t, levels, n)
+
+			for _, level := range levels {
+				if level == 0 {
+					columns[i] = append(columns[i], nil)
+					continue
+				}
+				switch element[1].(int64) {
+				case physicalInt32:
+					columns[i] = append(columns[i], int32(binary.LittleEndian.Uint32(body)))
+					body = body[4:]
+				case physicalInt64:
+					columns[i] = append(columns[i], int64(binary.LittleEndian.Uint64(body)))
+					body = body[8:]
+				case physicalByteArray:
+					size := binary.LittleEndian.Uint32(body)
+					columns[i] = append(columns[i], string(body[4:4+size]))
+					body = body[4+size:]
+				}
+			}
+			require.Empty(t, body)
+		}
+	}
+	return footer, columns
+}
+
+func TestWriter(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	for _, codec := range []Codec{Uncompressed, Gzip} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, []Column{
+			{Name: "id", Type: String},
+			{Name: "timestamp", Type: Timestamp},
+			{Name: "port", Type: Int32},
+			{Name: "bytes", Type: Int64},
+			{Name: "flags", Type: String, Optional: true},
+		}).WithCompression(codec).WithRowGroupSize(2)
+
+		require.NoError(t, w.Write("a", start, 443, int64(1)<<40, "SYN"))
+		require.NoError(t, w.Write("b", start.Add(time.Second), int32(53), 2, nil))
+		assert.NotZero(t, buf.Len(), "a full row group is written")
+		require.NoError(t, w.Write("c", start.Add(2*time.Second), 80, 3, nil))
+		require.NoError(t, w.Close())
+
+		footer, columns := readFile(t, buf.Bytes())
+		assert.Equal(t, int64(3), footer[3])
+		assert.Len(t, footer[4], 2)
+		schema := footer[2].([]any)
+		assert.Equal(t, int64(5), schema[0].(map[int16]any)[5])
+		assert.Equal(t, []byte("timestamp"), schema[2].(map[int16]any)[4])
+		assert.Equal(t, int64(convertedTimestampMicros), schema[2].(map[int16]any)[6])
+
+		assert.Equal(t, []any{"a", "b", "c"}, columns[0])
+		assert.Equal(t, []any{start.UnixMicro(), start.Add(time.Second).UnixMicro(), start.Add(2 * time.Second).UnixMicro()}, columns[1])
+		assert.Equal(t, []any{int32(443), int32(53), int32(80)}, columns[2])
+		assert.Equal(t, []any{int64(1) << 40, int64(2), int64(3)}, columns[3])
+		assert.Equal(t, []any{"SYN", nil, nil}, columns[4])
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewWriter(&buf, []Column{{Name: "id", Type: String}}).Close())
+	footer, columns := readFile(t, buf.Bytes())
+	assert.Equal(t, int64(0), footer[3])
+	assert.Empty(t, columns[0])
+}
+
+func TestWriter_InvalidValues(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, []Column{{Name: "id", Type: String}, {Name: "port", Type: Int32}})
+
+	assert.ErrorIs(t, w.Write("a"), ErrValue)
+	assert.ErrorIs(t, w.Write(nil, 1), ErrValue)
+	assert.ErrorIs(t, w.Write("a", "443"), ErrValue)
+	assert.ErrorIs(t, w.Write("a", 1<<40), ErrValue)
+
+	// Rejected rows are not written
+	require.NoError(t, w.Write("a", 1))
+	require.NoError(t, w.Close())
+	_, columns := readFile(t, buf.Bytes())
+	assert.Equal(t, []any{"a"}, columns[0])
+	assert.Equal(t, []any{int32(1)}, columns[1])
+}
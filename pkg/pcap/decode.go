@@ -0,0 +1,219 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// LinkType identifies the link layer header of captured frames
+type LinkType uint32
+
+// Supported link types
+const (
+	LinkTypeNull     LinkType = 0
+	LinkTypeEthernet LinkType = 1
+	LinkTypeRaw      LinkType = 101
+	LinkTypeLinuxSLL LinkType = 113
+	LinkTypeIPv4     LinkType = 228
+	LinkTypeIPv6     LinkType = 229
+)
+
+// Errors returned by Decode
+var (
+	ErrUnsupported = errors.New("unsupported packet")
+	ErrTruncated   = errors.New("truncated packet")
+)
+
+// EtherTypes and IP protocol numbers
+const (
+	etherTypeIPv4  = 0x0800
+	etherTypeIPv6  = 0x86dd
+	etherTypeVLAN  = 0x8100
+	etherTypeQinQ  = 0x88a8
+	protocolICMP   = 1
+	protocolTCP    = 6
+	protocolUDP    = 17
+	protocolICMPv6 = 58
+)
+
+// tcpFlags are the names of the TCP flags, lowest bit first
+var tcpFlags = []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG"}
+
+// Decode converts a captured frame into a packet. Port is the destination
+// port, unless only the source port is a well-known one, so that both
+// directions of a connection are attributed to the service. Size is the
+// length of the IP packet and Payload holds the raw transport payload.
+func Decode(record *Record) (*models.Packet, error) {
+	data, etherType, err := network(record.LinkType, record.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var source, destination netip.Addr
+	var protocol, ttl, size int
+	switch etherType {
+	case etherTypeIPv4:
+		if len(data) < 20 || data[0]>>4 != 4 {
+			return nil, fmt.Errorf("%w: IPv4 header", ErrTruncated)
+		}
+		headerLength := int(data[0]&0x0f) * 4
+		if headerLength < 20 || len(data) < headerLength {
+			return nil, fmt.Errorf("%w: IPv4 header", ErrTruncated)
+		}
+		if offset := binary.BigEndian.Uint16(data[6:]) & 0x1fff; offset != 0 {
+			return nil, fmt.Errorf("%w: IPv4 fragment", ErrUnsupported)
+		}
+		size = int(binary.BigEndian.Uint16(data[2:]))
+		ttl = int(data[8])
+		protocol = int(data[9])
+		source = netip.AddrFrom4([4]byte(data[12:16]))
+		destination = netip.AddrFrom4([4]byte(data[16:20]))
+		data = data[headerLength:]
+	case etherTypeIPv6:
+		if len(data) < 40 || data[0]>>4 != 6 {
+			return nil, fmt.Errorf("%w: IPv6 header", ErrTruncated)
+		}
+		size = 40 + int(binary.BigEndian.Uint16(data[4:]))
+		ttl = int(data[7])
+		source = netip.AddrFrom16([16]byte(data[8:24]))
+		destination = netip.AddrFrom16([16]byte(data[24:40]))
+		protocol, data, err = skipExtensions(data[6], data[40:])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: EtherType 0x%04x", ErrUnsupported, etherType)
+	}
+
+	packet := models.NewPacket(source.String(), destination.String(), "", 0, size)
+	packet.Timestamp = record.Timestamp
+	packet.TTL = ttl
+	packet.Flags = ""
+
+	switch protocol {
+	case protocolTCP:
+		if len(data) < 20 {
+			return nil, fmt.Errorf("%w: TCP header", ErrTruncated)
+		}
+		offset := int(data[12]>>4) * 4
+		if offset < 20 || len(data) < offset {
+			return nil, fmt.Errorf("%w: TCP header", ErrTruncated)
+		}
+		packet.Protocol = "TCP"
+		packet.Port = servicePort(binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:]))
+		var flags []string
+		for i, name := range tcpFlags {
+			if data[13]&(1<<i) != 0 {
+				flags = append(flags, name)
+			}
+		}
+		packet.Flags = strings.Join(flags, ",")
+		packet.Payload = string(data[offset:])
+	case protocolUDP:
+		if len(data) < 8 {
+			return nil, fmt.Errorf("%w: UDP header", ErrTruncated)
+		}
+		packet.Protocol = "UDP"
+		packet.Port = servicePort(binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:]))
+		packet.Payload = string(data[8:])
+	case protocolICMP, protocolICMPv6:
+		packet.Protocol = "ICMP"
+	default:
+		return nil, fmt.Errorf("%w: IP protocol %d", ErrUnsupported, protocol)
+	}
+
+	// Captures truncated to the snapshot length still report the size
+	// of the packet on the wire
+	if packet.Size == 0 {
+		packet.Size = record.Length
+	}
+	return packet, nil
+}
+
+// network strips the link layer header of a frame and returns the network
+// layer data with its EtherType
+func network(linkType LinkType, data []byte) ([]byte, uint16, error) {
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return nil, 0, fmt.Errorf("%w: Ethernet header", ErrTruncated)
+		}
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(data) < 4 {
+				return nil, 0, fmt.Errorf("%w: VLAN tag", ErrTruncated)
+			}
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		return data, etherType, nil
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, 0, fmt.Errorf("%w: Linux cooked header", ErrTruncated)
+		}
+		return data[16:], binary.BigEndian.Uint16(data[14:]), nil
+	case LinkTypeNull:
+		// The address family is in the byte order of the capturing host
+		if len(data) < 4 {
+			return nil, 0, fmt.Errorf("%w: loopback header", ErrTruncated)
+		}
+		return ipVersion(data[4:])
+	case LinkTypeRaw, LinkTypeIPv4, LinkTypeIPv6:
+		return ipVersion(data)
+	default:
+		return nil, 0, fmt.Errorf("%w: link type %d", ErrUnsupported, linkType)
+	}
+}
+
+// ipVersion returns the EtherType matching the version of a bare IP packet
+func ipVersion(data []byte) ([]byte, uint16, error) {
+	if len(data) == 0 {
+		return nil, 0, fmt.Errorf("%w: IP header", ErrTruncated)
+	}
+	switch data[0] >> 4 {
+	case 4:
+		return data, etherTypeIPv4, nil
+	case 6:
+		return data, etherTypeIPv6, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: IP version %d", ErrUnsupported, data[0]>>4)
+	}
+}
+
+// skipExtensions skips the IPv6 extension headers and returns the upper
+// layer protocol with its data
+func skipExtensions(next byte, data []byte) (int, []byte, error) {
+	for {
+		switch next {
+		case 0, 43, 60: // Hop-by-hop, routing and destination options
+			if len(data) < 8 || len(data) < 8+int(data[1])*8 {
+				return 0, nil, fmt.Errorf("%w: IPv6 extension header", ErrTruncated)
+			}
+			next, data = data[0], data[8+int(data[1])*8:]
+		case 44: // Fragment
+			if len(data) < 8 {
+				return 0, nil, fmt.Errorf("%w: IPv6 fragment header", ErrTruncated)
+			}
+			if binary.BigEndian.Uint16(data[2:])&0xfff8 != 0 {
+				return 0, nil, fmt.Errorf("%w: IPv6 fragment", ErrUnsupported)
+			}
+			next, data = data[0], data[8:]
+		default:
+			return int(next), data, nil
+		}
+	}
+}
+
+// servicePort returns the port identifying the service of a connection
+func servicePort(source, destination uint16) int {
+	if source < 1024 && destination >= 1024 {
+		return int(source)
+	}
+	return int(destination)
+}
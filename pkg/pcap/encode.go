@@ -0,0 +1,215 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"net/netip"
+	"strings"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// tcpFlagBits maps the TCP flag names accepted by Encode to their bits
+var tcpFlagBits = map[string]byte{
+	"FIN": 0x01, "SYN": 0x02, "RST": 0x04, "PSH": 0x08,
+	"ACK": 0x10, "URG": 0x20, "ECE": 0x40, "CWR": 0x80,
+}
+
+// Encode synthesizes the Ethernet frame of a packet that was not captured
+// from the wire, such as a simulated one, so that it can be written to a
+// capture file. The frame is sent from SourceIP to Port on DestinationIP,
+// from an ephemeral port shared by the packets of the same flow, with
+// the TTL and the comma separated TCP Flags of the packet, and carries
+// Payload. When Size exceeds the synthesized IP packet, the frame is
+// recorded as truncated to its headers and payload, like a capture with
+// a snapshot length. MAC addresses are locally administered ones derived
+// from the IP addresses.
+func Encode(packet *models.Packet) (*Record, error) {
+	source, err := netip.ParseAddr(packet.SourceIP)
+	if err != nil {
+		return nil, fmt.Errorf("%w: source address %q", ErrUnsupported, packet.SourceIP)
+	}
+	destination, err := netip.ParseAddr(packet.DestinationIP)
+	if err != nil {
+		return nil, fmt.Errorf("%w: destination address %q", ErrUnsupported, packet.DestinationIP)
+	}
+	source, destination = source.Unmap(), destination.Unmap()
+	if source.Is4() != destination.Is4() {
+		return nil, fmt.Errorf("%w: %s and %s are of different IP versions", ErrUnsupported, source, destination)
+	}
+	if packet.Port < 0 || packet.Port > 0xffff {
+		return nil, fmt.Errorf("%w: port %d", ErrUnsupported, packet.Port)
+	}
+
+	var protocol byte
+	var transport []byte
+	payload := []byte(packet.Payload)
+	switch strings.ToUpper(packet.Protocol) {
+	case "TCP", "HTTP", "HTTPS":
+		protocol = protocolTCP
+		transport = make([]byte, 20)
+		binary.BigEndian.PutUint16(transport, SourcePort(packet))
+		binary.BigEndian.PutUint16(transport[2:], uint16(packet.Port))
+		transport[12] = 5 << 4
+		transport[13] = TCPFlags(packet.Flags)
+		binary.BigEndian.PutUint16(transport[14:], 0xffff) // window
+	case "UDP":
+		protocol = protocolUDP
+		transport = make([]byte, 8)
+		binary.BigEndian.PutUint16(transport, SourcePort(packet))
+		binary.BigEndian.PutUint16(transport[2:], uint16(packet.Port))
+	case "ICMP":
+		protocol = protocolICMP
+		transport = []byte{8, 0, 0, 0, 0, 0, 0, 0} // echo request
+		if source.Is6() {
+			protocol = protocolICMPv6
+			transport[0] = 128
+		}
+	default:
+		return nil, fmt.Errorf("%w: protocol %q", ErrUnsupported, packet.Protocol)
+	}
+
+	// The IP length fields bound the size of the packet
+	ipHeader, maxSize := 20, 0xffff
+	if source.Is6() {
+		ipHeader, maxSize = 40, 0xffff+40
+	}
+	if limit := maxSize - ipHeader - len(transport); len(payload) > limit {
+		payload = payload[:limit]
+	}
+	captured := ipHeader + len(transport) + len(payload)
+	size := max(captured, min(packet.Size, maxSize))
+
+	// Transport lengths and checksums cover the whole packet, so the
+	// checksum is only computed when no byte is missing
+	segment := append(transport, payload...)
+	checksumOffset := 2
+	switch protocol {
+	case protocolTCP:
+		checksumOffset = 16
+	case protocolUDP:
+		checksumOffset = 6
+		binary.BigEndian.PutUint16(segment[4:], uint16(size-ipHeader))
+	}
+	if size == captured {
+		var sum uint32
+		if protocol != protocolICMP {
+			sum = pseudoHeaderSum(source, destination, protocol, len(segment))
+		}
+		checksum := finishChecksum(sum + onesSum(segment))
+		if protocol == protocolUDP && checksum == 0 {
+			checksum = 0xffff
+		}
+		binary.BigEndian.PutUint16(segment[checksumOffset:], checksum)
+	}
+
+	ttl := packet.TTL
+	if ttl <= 0 || ttl > 0xff {
+		ttl = 64
+	}
+	var ip []byte
+	var etherType uint16
+	if source.Is4() {
+		etherType = etherTypeIPv4
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(size))
+		ip[6] = 0x40 // don't fragment
+		ip[8] = byte(ttl)
+		ip[9] = protocol
+		source4, destination4 := source.As4(), destination.As4()
+		copy(ip[12:], source4[:])
+		copy(ip[16:], destination4[:])
+		binary.BigEndian.PutUint16(ip[10:], finishChecksum(onesSum(ip)))
+	} else {
+		etherType = etherTypeIPv6
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(size-ipHeader))
+		ip[6] = protocol
+		ip[7] = byte(ttl)
+		source16, destination16 := source.As16(), destination.As16()
+		copy(ip[8:], source16[:])
+		copy(ip[24:], destination16[:])
+	}
+
+	frame := make([]byte, 14, 14+captured)
+	copy(frame, macAddress(destination))
+	copy(frame[6:], macAddress(source))
+	binary.BigEndian.PutUint16(frame[12:], etherType)
+	frame = append(append(frame, ip...), segment...)
+
+	return &Record{
+		Timestamp: packet.Timestamp,
+		LinkType:  LinkTypeEthernet,
+		Data:      frame,
+		Length:    14 + size,
+	}, nil
+}
+
+// SourcePort returns the source port synthesized for a packet, which does
+// not record it: a port in the dynamic range, the same for every packet of
+// a flow
+func SourcePort(packet *models.Packet) uint16 {
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%s|%s|%s|%d", packet.Protocol, packet.SourceIP, packet.DestinationIP, packet.Port)
+	return uint16(49152 + hash.Sum32()%16384)
+}
+
+// TCPFlags returns the bits of comma or space separated TCP flag names.
+// Unknown names are ignored.
+func TCPFlags(flags string) byte {
+	var bits byte
+	for _, name := range strings.FieldsFunc(strings.ToUpper(flags), func(r rune) bool {
+		return r == ',' || r == '|' || r == ' '
+	}) {
+		bits |= tcpFlagBits[name]
+	}
+	return bits
+}
+
+// macAddress derives a locally administered MAC address from the last
+// four bytes of an IP address
+func macAddress(addr netip.Addr) []byte {
+	bytes := addr.As16()
+	return append([]byte{0x02, 0x00}, bytes[12:]...)
+}
+
+// pseudoHeaderSum returns the ones' complement sum of the pseudo header of
+// a transport checksum
+func pseudoHeaderSum(source, destination netip.Addr, protocol byte, length int) uint32 {
+	var header []byte
+	if source.Is4() {
+		source4, destination4 := source.As4(), destination.As4()
+		header = append(append(header, source4[:]...), destination4[:]...)
+		header = append(header, 0, protocol)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	} else {
+		source16, destination16 := source.As16(), destination.As16()
+		header = append(append(header, source16[:]...), destination16[:]...)
+		header = binary.BigEndian.AppendUint32(header, uint32(length))
+		header = append(header, 0, 0, 0, protocol)
+	}
+	return onesSum(header)
+}
+
+// onesSum returns the 32-bit sum of the 16-bit words of data
+func onesSum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
+
+// finishChecksum folds a sum into the ones' complement Internet checksum
+func finishChecksum(sum uint32) uint16 {
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
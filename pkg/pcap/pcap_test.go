@@ -0,0 +1,272 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tcpFrame returns an Ethernet frame of an IPv4 TCP segment
+func tcpFrame(source, destination [4]byte, sourcePort, destinationPort uint16, flags byte, payload string) []byte {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp, sourcePort)
+	binary.BigEndian.PutUint16(tcp[2:], destinationPort)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+	ip[8] = 64
+	ip[9] = protocolTCP
+	copy(ip[12:], source[:])
+	copy(ip[16:], destination[:])
+
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:], etherTypeIPv4)
+	return append(append(frame, ip...), tcp...)
+}
+
+// classicFile returns a little-endian microsecond pcap file of frames
+func classicFile(start time.Time, frames ...[]byte) []byte {
+	var buf bytes.Buffer
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header, magicMicroseconds)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], 65535)
+	binary.LittleEndian.PutUint32(header[20:], uint32(LinkTypeEthernet))
+	buf.Write(header)
+
+	for i, frame := range frames {
+		t := start.Add(time.Duration(i) * time.Millisecond)
+		record := make([]byte, 16)
+		binary.LittleEndian.PutUint32(record, uint32(t.Unix()))
+		binary.LittleEndian.PutUint32(record[4:], uint32(t.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(record[8:], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(record[12:], uint32(len(frame)))
+		buf.Write(record)
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
+
+// block returns a big-endian pcapng block
+func block(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	data := binary.BigEndian.AppendUint32(nil, blockType)
+	data = binary.BigEndian.AppendUint32(data, uint32(12+len(body)))
+	data = append(data, body...)
+	return binary.BigEndian.AppendUint32(data, uint32(12+len(body)))
+}
+
+func TestReader_Classic(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	file := classicFile(start,
+		tcpFrame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, 51000, 443, 0x02, ""),
+		tcpFrame([4]byte{10, 0, 0, 2}, [4]byte{10, 0, 0, 1}, 443, 51000, 0x12, "hello"),
+	)
+	require.True(t, IsCapture(file))
+
+	reader, err := NewReader(bytes.NewReader(file))
+	require.NoError(t, err)
+
+	record, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, start, record.Timestamp)
+	assert.Equal(t, LinkTypeEthernet, record.LinkType)
+	packet, err := Decode(record)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", packet.SourceIP)
+	assert.Equal(t, "10.0.0.2", packet.DestinationIP)
+	assert.Equal(t, "TCP", packet.Protocol)
+	assert.Equal(t, 443, packet.Port)
+	assert.Equal(t, 40, packet.Size)
+	assert.Equal(t, 64, packet.TTL)
+	assert.Equal(t, "SYN", packet.Flags)
+	assert.Equal(t, start, packet.Timestamp)
+
+	// Replies are attributed to the service port
+	record, err = reader.Next()
+	require.NoError(t, err)
+	packet, err = Decode(record)
+	require.NoError(t, err)
+	assert.Equal(t, 443, packet.Port)
+	assert.Equal(t, "SYN,ACK", packet.Flags)
+	assert.Equal(t, "hello", packet.Payload)
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_Pcapng(t *testing.T) {
+	// Section header, then an interface with nanosecond timestamps
+	section := binary.BigEndian.AppendUint32(nil, magicByteOrder)
+	section = append(section, 0, 1, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	iface := []byte{0, byte(LinkTypeEthernet), 0, 0, 0, 0, 0xff, 0xff}
+	iface = append(iface, 0, optionTimeResolution, 0, 1, 9, 0, 0, 0, 0, 0, 0, 0)
+
+	// An enhanced packet with a comment
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	frame := tcpFrame([4]byte{192, 168, 1, 10}, [4]byte{192, 168, 1, 20}, 40000, 22, 0x18, "SSH-2.0-OpenSSH_9.6\r\n")
+	units := uint64(timestamp.UnixNano())
+	packet := binary.BigEndian.AppendUint32(nil, 0)
+	packet = binary.BigEndian.AppendUint32(packet, uint32(units>>32))
+	packet = binary.BigEndian.AppendUint32(packet, uint32(units))
+	packet = binary.BigEndian.AppendUint32(packet, uint32(len(frame)))
+	packet = binary.BigEndian.AppendUint32(packet, uint32(len(frame)))
+	packet = append(packet, frame...)
+	for len(packet)%4 != 0 {
+		packet = append(packet, 0)
+	}
+	packet = append(packet, 0, optionComment, 0, 8)
+	packet = append(packet, "packet_1"...)
+	packet = append(packet, 0, 0, 0, 0)
+
+	var file []byte
+	file = append(file, block(blockSectionHeader, section)...)
+	file = append(file, block(blockInterface, iface)...)
+	file = append(file, block(0x00000005, []byte{1, 2, 3, 4})...) // skipped statistics block
+	file = append(file, block(blockEnhancedPacket, packet)...)
+	require.True(t, IsCapture(file))
+
+	reader, err := NewReader(bytes.NewReader(file))
+	require.NoError(t, err)
+	record, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, timestamp, record.Timestamp)
+	assert.Equal(t, []string{"packet_1"}, record.Comments)
+
+	decoded, err := Decode(record)
+	require.NoError(t, err)
+	assert.Equal(t, 22, decoded.Port)
+	assert.Equal(t, "PSH,ACK", decoded.Flags)
+	assert.Equal(t, "SSH-2.0-OpenSSH_9.6\r\n", decoded.Payload)
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestNewReader_InvalidFormat(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte(`{"packets":[]}`)))
+	assert.ErrorIs(t, err, ErrFormat)
+	assert.False(t, IsCapture([]byte("GET / HTTP/1.1")))
+}
+
+func TestDecode(t *testing.T) {
+	// IPv6 UDP with a hop-by-hop extension header
+	ipv6 := make([]byte, 40)
+	ipv6[0] = 0x60
+	binary.BigEndian.PutUint16(ipv6[4:], 8+12)
+	ipv6[6] = 0
+	ipv6[7] = 255
+	ipv6[23] = 1
+	ipv6[39] = 2
+	ipv6 = append(ipv6, protocolUDP, 0, 0, 0, 0, 0, 0, 0)
+	ipv6 = append(ipv6, 0xd4, 0x31, 0, 53, 0, 12, 0, 0, 'q', 'u', 'e', 'r')
+
+	packet, err := Decode(&Record{LinkType: LinkTypeRaw, Data: ipv6})
+	require.NoError(t, err)
+	assert.Equal(t, "::1", packet.SourceIP)
+	assert.Equal(t, "::2", packet.DestinationIP)
+	assert.Equal(t, "UDP", packet.Protocol)
+	assert.Equal(t, 53, packet.Port)
+	assert.Equal(t, 60, packet.Size)
+	assert.Equal(t, "quer", packet.Payload)
+
+	// ARP is not an IP packet
+	arp := make([]byte, 42)
+	binary.BigEndian.PutUint16(arp[12:], 0x0806)
+	_, err = Decode(&Record{LinkType: LinkTypeEthernet, Data: arp})
+	assert.True(t, errors.Is(err, ErrUnsupported), err)
+
+	_, err = Decode(&Record{LinkType: LinkTypeEthernet, Data: tcpFrame([4]byte{}, [4]byte{}, 1, 2, 0, "")[:30]})
+	assert.ErrorIs(t, err, ErrTruncated)
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	packets := []models.Packet{
+		{ID: "p1", Timestamp: timestamp, Protocol: "HTTPS", SourceIP: "10.0.0.1", DestinationIP: "10.0.0.2", Port: 443, Size: 45, TTL: 57, Flags: "PSH,ACK", Payload: "hello"},
+		// Larger than its payload: recorded as truncated
+		{ID: "p2", Timestamp: timestamp.Add(time.Second), Protocol: "UDP", SourceIP: "fd00::1", DestinationIP: "fd00::2", Port: 53, Size: 1200, Payload: "query"},
+		{ID: "p3", Timestamp: timestamp.Add(2 * time.Second), Protocol: "ICMP", SourceIP: "10.0.0.1", DestinationIP: "10.0.0.3", Size: 28},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, LinkTypeEthernet)
+	for i := range packets {
+		record, err := Encode(&packets[i])
+		require.NoError(t, err)
+		record.Comments = []string{"packet_id: " + packets[i].ID}
+		require.NoError(t, w.Write(record))
+	}
+	require.NoError(t, w.Close())
+
+	reader, err := NewReader(&buf)
+	require.NoError(t, err)
+	for _, want := range packets {
+		record, err := reader.Next()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"packet_id: " + want.ID}, record.Comments)
+		assert.Equal(t, want.Timestamp, record.Timestamp)
+
+		got, err := Decode(record)
+		require.NoError(t, err)
+		assert.Equal(t, want.SourceIP, got.SourceIP)
+		assert.Equal(t, want.DestinationIP, got.DestinationIP)
+		assert.Equal(t, want.Port, got.Port)
+		assert.Equal(t, want.Size, got.Size)
+		assert.Equal(t, want.Payload, got.Payload)
+		if want.Protocol == "HTTPS" {
+			assert.Equal(t, "TCP", got.Protocol)
+			assert.Equal(t, want.TTL, got.TTL)
+			assert.Equal(t, want.Flags, got.Flags)
+		}
+
+		// Header checksums are valid, and so is the checksum of complete
+		// transport segments
+		if got.SourceIP == "10.0.0.1" {
+			ip := record.Data[14:34]
+			assert.Zero(t, finishChecksum(onesSum(ip)))
+			segment := record.Data[34:]
+			sum := onesSum(segment)
+			if got.Protocol == "TCP" {
+				source, destination := ip[12:16], ip[16:20]
+				sum += onesSum(append(append(append([]byte{}, source...), destination...), 0, protocolTCP, 0, byte(len(segment))))
+			}
+			assert.Zero(t, finishChecksum(sum))
+		}
+	}
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+
+	// An empty capture is a valid file
+	buf.Reset()
+	require.NoError(t, NewWriter(&buf, LinkTypeEthernet).Close())
+	reader, err = NewReader(&buf)
+	require.NoError(t, err)
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestEncode_Invalid(t *testing.T) {
+	for _, packet := range []models.Packet{
+		{Protocol: "TCP", SourceIP: "10.0.0.1", DestinationIP: "not an address"},
+		{Protocol: "TCP", SourceIP: "10.0.0.1", DestinationIP: "::1"},
+		{Protocol: "SCTP", SourceIP: "10.0.0.1", DestinationIP: "10.0.0.2"},
+	} {
+		_, err := Encode(&packet)
+		assert.ErrorIs(t, err, ErrUnsupported, packet)
+	}
+}
@@ -0,0 +1,315 @@
+// Package pcap reads and decodes packet capture files in the classic pcap
+// and the pcapng formats, and writes packets as pcapng files.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"time"
+)
+
+// ErrFormat is returned for input that is not a supported capture file
+var ErrFormat = errors.New("not a pcap or pcapng file")
+
+// maxRecordSize bounds the blocks and records read from a file, so that a
+// corrupt length cannot exhaust memory
+const maxRecordSize = 16 << 20
+
+// Magic numbers of the supported formats
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	magicNanoseconds  = 0xa1b23c4d
+	magicByteOrder    = 0x1a2b3c4d
+)
+
+// pcapng block types
+const (
+	blockSectionHeader   = 0x0a0d0d0a
+	blockInterface       = 0x00000001
+	blockSimplePacket    = 0x00000003
+	blockEnhancedPacket  = 0x00000006
+	optionEnd            = 0
+	optionComment        = 1
+	optionTimeResolution = 9
+)
+
+// Record is a captured frame
+type Record struct {
+	Timestamp time.Time
+	LinkType  LinkType
+
+	// Data holds the captured bytes, which may be shorter than Length
+	// when the capture was truncated to the snapshot length
+	Data   []byte
+	Length int
+
+	// Comments are the pcapng comments of the frame
+	Comments []string
+}
+
+// Reader reads the records of a capture file
+type Reader struct {
+	r    *bufio.Reader
+	next func() (*Record, error)
+
+	// Classic pcap state
+	order       binary.ByteOrder
+	nanoseconds bool
+	linkType    LinkType
+
+	// pcapng state
+	interfaces []pcapngInterface
+}
+
+// pcapngInterface is an interface described by a pcapng section
+type pcapngInterface struct {
+	linkType LinkType
+	// units is the number of timestamp units per second
+	units uint64
+}
+
+// IsCapture reports whether header, the first bytes of a file, starts a
+// pcap or pcapng file
+func IsCapture(header []byte) bool {
+	if len(header) < 4 {
+		return false
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header) {
+		case magicMicroseconds, magicNanoseconds, blockSectionHeader:
+			return true
+		}
+	}
+	return false
+}
+
+// NewReader reads the file header of r and returns a reader of its records
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReaderSize(r, 64<<10)}
+	magic, err := reader.r.Peek(4)
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(magic) {
+		case magicMicroseconds, magicNanoseconds:
+			reader.order = order
+			reader.next = reader.nextClassic
+			return reader, reader.readClassicHeader()
+		case blockSectionHeader:
+			reader.next = reader.nextBlock
+			return reader, nil
+		}
+	}
+	return nil, ErrFormat
+}
+
+// Next returns the next record, or io.EOF at the end of the file
+func (r *Reader) Next() (*Record, error) {
+	return r.next()
+}
+
+// readClassicHeader reads the global header of a classic pcap file
+func (r *Reader) readClassicHeader() error {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	r.nanoseconds = r.order.Uint32(header) == magicNanoseconds
+	// The upper bits of the link type field hold FCS information
+	r.linkType = LinkType(r.order.Uint32(header[20:]) & 0x0fffffff)
+	return nil
+}
+
+// nextClassic reads a record of a classic pcap file
+func (r *Reader) nextClassic() (*Record, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated record header: %w", err)
+		}
+		return nil, err
+	}
+
+	seconds := int64(r.order.Uint32(header))
+	fraction := int64(r.order.Uint32(header[4:]))
+	if !r.nanoseconds {
+		fraction *= 1000
+	}
+	captured := r.order.Uint32(header[8:])
+	if captured > maxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes exceeds the %d bytes limit", captured, maxRecordSize)
+	}
+
+	data := make([]byte, captured)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, fmt.Errorf("truncated record: %w", err)
+	}
+	return &Record{
+		Timestamp: time.Unix(seconds, fraction).UTC(),
+		LinkType:  r.linkType,
+		Data:      data,
+		Length:    int(r.order.Uint32(header[12:])),
+	}, nil
+}
+
+// nextBlock reads pcapng blocks until it finds a packet
+func (r *Reader) nextBlock() (*Record, error) {
+	for {
+		blockType, body, err := r.readBlock()
+		if err != nil {
+			return nil, err
+		}
+
+		switch blockType {
+		case blockSectionHeader:
+			// A new section redefines its interfaces
+			r.interfaces = nil
+		case blockInterface:
+			if len(body) < 8 {
+				return nil, errors.New("truncated interface description block")
+			}
+			iface := pcapngInterface{linkType: LinkType(r.order.Uint16(body)), units: 1000000}
+			for _, option := range r.options(body[8:]) {
+				if option.code == optionTimeResolution && len(option.value) == 1 {
+					iface.units = timeUnits(option.value[0])
+				}
+			}
+			r.interfaces = append(r.interfaces, iface)
+		case blockEnhancedPacket:
+			return r.enhancedPacket(body)
+		case blockSimplePacket:
+			if len(body) < 4 || len(r.interfaces) == 0 {
+				return nil, errors.New("invalid simple packet block")
+			}
+			length := r.order.Uint32(body)
+			data := body[4:]
+			if int(length) < len(data) {
+				data = data[:length]
+			}
+			return &Record{LinkType: r.interfaces[0].linkType, Data: data, Length: int(length)}, nil
+		}
+	}
+}
+
+// enhancedPacket decodes the body of an enhanced packet block
+func (r *Reader) enhancedPacket(body []byte) (*Record, error) {
+	if len(body) < 20 {
+		return nil, errors.New("truncated enhanced packet block")
+	}
+	id := r.order.Uint32(body)
+	if int(id) >= len(r.interfaces) {
+		return nil, fmt.Errorf("packet of undeclared interface %d", id)
+	}
+	iface := r.interfaces[id]
+
+	captured := int(r.order.Uint32(body[12:]))
+	padded := (captured + 3) &^ 3
+	if 20+padded > len(body) {
+		return nil, errors.New("truncated enhanced packet block")
+	}
+	record := &Record{
+		Timestamp: timestamp(uint64(r.order.Uint32(body[4:]))<<32|uint64(r.order.Uint32(body[8:])), iface.units),
+		LinkType:  iface.linkType,
+		Data:      body[20 : 20+captured],
+		Length:    int(r.order.Uint32(body[16:])),
+	}
+	for _, option := range r.options(body[20+padded:]) {
+		if option.code == optionComment {
+			record.Comments = append(record.Comments, string(option.value))
+		}
+	}
+	return record, nil
+}
+
+// readBlock reads a pcapng block and returns its type and body. A section
+// header block sets the byte order of the blocks that follow.
+func (r *Reader) readBlock() (uint32, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated block header: %w", err)
+		}
+		return 0, nil, err
+	}
+
+	// The type of a section header reads the same in both byte orders
+	blockType := binary.LittleEndian.Uint32(header)
+	if blockType == blockSectionHeader {
+		magic, err := r.r.Peek(4)
+		if err != nil {
+			return 0, nil, fmt.Errorf("truncated section header block: %w", err)
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic) == magicByteOrder:
+			r.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic) == magicByteOrder:
+			r.order = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("%w: invalid byte-order magic", ErrFormat)
+		}
+	} else {
+		blockType = r.order.Uint32(header)
+	}
+
+	length := r.order.Uint32(header[4:])
+	if length < 12 || length%4 != 0 || length > maxRecordSize {
+		return 0, nil, fmt.Errorf("invalid block length %d", length)
+	}
+	block := make([]byte, length-8)
+	if _, err := io.ReadFull(r.r, block); err != nil {
+		return 0, nil, fmt.Errorf("truncated block: %w", err)
+	}
+	return blockType, block[:len(block)-4], nil
+}
+
+// option is a pcapng option
+type option struct {
+	code  uint16
+	value []byte
+}
+
+// options decodes the options that end a block body
+func (r *Reader) options(data []byte) []option {
+	var options []option
+	for len(data) >= 4 {
+		code := r.order.Uint16(data)
+		length := int(r.order.Uint16(data[2:]))
+		if code == optionEnd || 4+length > len(data) {
+			break
+		}
+		options = append(options, option{code: code, value: data[4 : 4+length]})
+		data = data[4+(length+3)&^3:]
+	}
+	return options
+}
+
+// timeUnits converts the if_tsresol option into units per second: a power
+// of ten, or of two when the high bit is set
+func timeUnits(resolution byte) uint64 {
+	exponent := uint64(resolution & 0x7f)
+	if resolution&0x80 != 0 {
+		if exponent > 63 {
+			exponent = 63
+		}
+		return 1 << exponent
+	}
+	units := uint64(1)
+	for i := uint64(0); i < exponent && i < 19; i++ {
+		units *= 10
+	}
+	return units
+}
+
+// timestamp converts a count of units per second since the epoch
+func timestamp(value, units uint64) time.Time {
+	seconds, remainder := value/units, value%units
+	hi, lo := bits.Mul64(remainder, uint64(time.Second))
+	nanoseconds, _ := bits.Div64(hi, lo, units)
+	return time.Unix(int64(seconds), int64(nanoseconds)).UTC()
+}
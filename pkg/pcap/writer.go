@@ -0,0 +1,130 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// pcapng options written by Writer
+const (
+	optionUserApplication = 4
+	nanosecondResolution  = 9
+)
+
+// Writer writes records to a pcapng file of a single interface. Frames
+// are timestamped with nanosecond precision and carry their comments.
+type Writer struct {
+	w           io.Writer
+	linkType    LinkType
+	application string
+	started     bool
+	err         error
+}
+
+// NewWriter creates a writer of frames of linkType to w. The file header
+// is written with the first record, or by Close for an empty file.
+func NewWriter(w io.Writer, linkType LinkType) *Writer {
+	return &Writer{w: w, linkType: linkType, application: "network-sniffer"}
+}
+
+// Write appends a record. Its link type must be the one of the writer.
+func (w *Writer) Write(record *Record) error {
+	if record.LinkType != w.linkType {
+		return fmt.Errorf("%w: link type %d in a file of link type %d", ErrUnsupported, record.LinkType, w.linkType)
+	}
+	if len(record.Data) > maxRecordSize {
+		return fmt.Errorf("%w: frame of %d bytes exceeds the %d bytes limit", ErrUnsupported, len(record.Data), maxRecordSize)
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	length := record.Length
+	if length < len(record.Data) {
+		length = len(record.Data)
+	}
+	units := uint64(record.Timestamp.UnixNano())
+	if record.Timestamp.IsZero() || record.Timestamp.UnixNano() < 0 {
+		units = 0
+	}
+
+	body := binary.LittleEndian.AppendUint32(nil, 0) // interface
+	body = binary.LittleEndian.AppendUint32(body, uint32(units>>32))
+	body = binary.LittleEndian.AppendUint32(body, uint32(units))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(record.Data)))
+	body = binary.LittleEndian.AppendUint32(body, uint32(min(length, math.MaxUint32)))
+	body = pad(append(body, record.Data...))
+	if len(record.Comments) > 0 {
+		for _, comment := range record.Comments {
+			body = appendOption(body, optionComment, []byte(comment))
+		}
+		body = appendOption(body, optionEnd, nil)
+	}
+	return w.block(blockEnhancedPacket, body)
+}
+
+// Close writes the file header when no record was written. It does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	return w.start()
+}
+
+// start writes the section header and interface description once
+func (w *Writer) start() error {
+	if w.started {
+		return w.err
+	}
+	w.started = true
+
+	section := binary.LittleEndian.AppendUint32(nil, magicByteOrder)
+	section = binary.LittleEndian.AppendUint16(section, 1) // major version
+	section = binary.LittleEndian.AppendUint16(section, 0) // minor version
+	section = binary.LittleEndian.AppendUint64(section, math.MaxUint64)
+	section = appendOption(section, optionUserApplication, []byte(w.application))
+	section = appendOption(section, optionEnd, nil)
+	if err := w.block(blockSectionHeader, section); err != nil {
+		return err
+	}
+
+	iface := binary.LittleEndian.AppendUint16(nil, uint16(w.linkType))
+	iface = binary.LittleEndian.AppendUint16(iface, 0)
+	iface = binary.LittleEndian.AppendUint32(iface, 0) // no snapshot length
+	iface = appendOption(iface, optionTimeResolution, []byte{nanosecondResolution})
+	iface = appendOption(iface, optionEnd, nil)
+	return w.block(blockInterface, iface)
+}
+
+// block writes a block around body, which is padded to 32 bits
+func (w *Writer) block(blockType uint32, body []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	length := uint32(12 + len(body))
+	data := binary.LittleEndian.AppendUint32(make([]byte, 0, length), blockType)
+	data = binary.LittleEndian.AppendUint32(data, length)
+	data = append(data, body...)
+	data = binary.LittleEndian.AppendUint32(data, length)
+	_, w.err = w.w.Write(data)
+	return w.err
+}
+
+// appendOption appends a pcapng option padded to 32 bits. Values beyond
+// the 64 KiB an option can hold are truncated.
+func appendOption(data []byte, code uint16, value []byte) []byte {
+	if len(value) > math.MaxUint16 {
+		value = value[:math.MaxUint16]
+	}
+	data = binary.LittleEndian.AppendUint16(data, code)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(value)))
+	return pad(append(data, value...))
+}
+
+// pad pads data with zeros to a multiple of 32 bits
+func pad(data []byte) []byte {
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
@@ -0,0 +1,86 @@
+package sketch
+
+import "fmt"
+
+// CountMin is a Count-Min sketch giving over-estimated frequencies of keys in
+// a fixed amount of memory
+type CountMin struct {
+	width  uint64
+	depth  int
+	counts [][]uint64
+}
+
+// NewCountMin creates a Count-Min sketch with depth rows of width counters
+func NewCountMin(width, depth int) *CountMin {
+	if width < 1 {
+		width = 1
+	}
+	if depth < 1 {
+		depth = 1
+	}
+
+	counts := make([][]uint64, depth)
+	for i := range counts {
+		counts[i] = make([]uint64, width)
+	}
+
+	return &CountMin{
+		width:  uint64(width),
+		depth:  depth,
+		counts: counts,
+	}
+}
+
+// Add increments the frequency of key by count
+func (c *CountMin) Add(key string, count uint64) {
+	h1, h2 := split(hash64(key))
+	for i := 0; i < c.depth; i++ {
+		c.counts[i][c.index(h1, h2, i)] += count
+	}
+}
+
+// Estimate returns the estimated frequency of key, which is never lower than
+// the true frequency
+func (c *CountMin) Estimate(key string) uint64 {
+	h1, h2 := split(hash64(key))
+	var estimate uint64
+	for i := 0; i < c.depth; i++ {
+		v := c.counts[i][c.index(h1, h2, i)]
+		if i == 0 || v < estimate {
+			estimate = v
+		}
+	}
+	return estimate
+}
+
+// Merge adds the counters of other into c. Both sketches must share dimensions.
+func (c *CountMin) Merge(other *CountMin) error {
+	if other.width != c.width || other.depth != c.depth {
+		return fmt.Errorf("count-min dimensions mismatch: %dx%d vs %dx%d", c.depth, c.width, other.depth, other.width)
+	}
+	for i := range c.counts {
+		for j := range c.counts[i] {
+			c.counts[i][j] += other.counts[i][j]
+		}
+	}
+	return nil
+}
+
+// Reset zeroes every counter
+func (c *CountMin) Reset() {
+	for i := range c.counts {
+		for j := range c.counts[i] {
+			c.counts[i][j] = 0
+		}
+	}
+}
+
+// index returns the column of row i using double hashing
+func (c *CountMin) index(h1, h2 uint64, i int) uint64 {
+	return (h1 + uint64(i)*h2) % c.width
+}
+
+// split derives two independent hashes from a single 64-bit hash
+func split(h uint64) (uint64, uint64) {
+	return h & 0xffffffff, (h >> 32) | 1
+}
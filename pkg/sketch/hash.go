@@ -0,0 +1,23 @@
+// Package sketch provides fixed-memory probabilistic data structures used to
+// summarise high-volume packet streams: Count-Min sketches for frequency
+// estimates, Space-Saving for heavy hitters and HyperLogLog for cardinality.
+package sketch
+
+import "hash/fnv"
+
+// hash64 returns a well-mixed 64-bit hash of key
+func hash64(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return mix64(h.Sum64())
+}
+
+// mix64 is the splitmix64 finalizer, used to spread FNV output across all bits
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
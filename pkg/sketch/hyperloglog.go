@@ -0,0 +1,93 @@
+package sketch
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// HyperLogLog estimates the number of distinct keys added to it
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+// NewHyperLogLog creates a HyperLogLog with 2^precision registers.
+// Precision is clamped to [4, 16]; the standard error is about 1.04/sqrt(2^precision).
+func NewHyperLogLog(precision uint8) *HyperLogLog {
+	if precision < 4 {
+		precision = 4
+	}
+	if precision > 16 {
+		precision = 16
+	}
+	return &HyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}
+}
+
+// Add records key
+func (h *HyperLogLog) Add(key string) {
+	x := hash64(key)
+	idx := x >> (64 - h.precision)
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1))) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Count returns the estimated number of distinct keys
+func (h *HyperLogLog) Count() uint64 {
+	m := float64(len(h.registers))
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha(m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+// Merge folds the registers of other into h. Both must share precision.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if other.precision != h.precision {
+		return fmt.Errorf("hyperloglog precision mismatch: %d vs %d", h.precision, other.precision)
+	}
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Reset clears every register
+func (h *HyperLogLog) Reset() {
+	for i := range h.registers {
+		h.registers[i] = 0
+	}
+}
+
+// alpha returns the bias correction constant for m registers
+func alpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/m)
+}
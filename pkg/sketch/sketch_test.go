@@ -0,0 +1,115 @@
+package sketch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountMin_NeverUnderestimates(t *testing.T) {
+	cm := NewCountMin(256, 4)
+	truth := make(map[string]uint64)
+
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprintf("10.0.%d.%d", i%7, i%251)
+		cm.Add(key, 3)
+		truth[key] += 3
+	}
+
+	for key, count := range truth {
+		assert.GreaterOrEqual(t, cm.Estimate(key), count, key)
+	}
+	assert.Equal(t, uint64(0), NewCountMin(256, 4).Estimate("missing"))
+}
+
+func TestCountMin_Merge(t *testing.T) {
+	a := NewCountMin(64, 3)
+	b := NewCountMin(64, 3)
+	a.Add("k", 2)
+	b.Add("k", 5)
+
+	require.NoError(t, a.Merge(b))
+	assert.GreaterOrEqual(t, a.Estimate("k"), uint64(7))
+
+	assert.Error(t, a.Merge(NewCountMin(32, 3)))
+
+	a.Reset()
+	assert.Equal(t, uint64(0), a.Estimate("k"))
+}
+
+func TestSpaceSaving_FindsHeavyHitters(t *testing.T) {
+	ss := NewSpaceSaving(20)
+
+	// Three heavy keys hidden in a long tail of unique keys
+	for i := 0; i < 2000; i++ {
+		ss.Add(fmt.Sprintf("tail-%d", i), 1)
+		if i%4 == 0 {
+			ss.Add("heavy-a", 1)
+		}
+		if i%5 == 0 {
+			ss.Add("heavy-b", 1)
+		}
+		if i%8 == 0 {
+			ss.Add("heavy-c", 1)
+		}
+	}
+
+	top := ss.Top(3)
+	require.Len(t, top, 3)
+	assert.Equal(t, "heavy-a", top[0].Key)
+	assert.Equal(t, "heavy-b", top[1].Key)
+	assert.Equal(t, "heavy-c", top[2].Key)
+	for _, c := range top {
+		assert.LessOrEqual(t, c.Error, c.Count)
+	}
+	assert.Equal(t, 20, ss.Len())
+}
+
+func TestSpaceSaving_Weighted(t *testing.T) {
+	ss := NewSpaceSaving(2)
+	ss.Add("small", 10)
+	ss.Add("big", 1500)
+	ss.Add("other", 20)
+
+	top := ss.Top(0)
+	require.Len(t, top, 2)
+	assert.Equal(t, "big", top[0].Key)
+	assert.Equal(t, uint64(1500), top[0].Count)
+	// "other" replaced "small" and inherited its count as error
+	assert.Equal(t, "other", top[1].Key)
+	assert.Equal(t, uint64(30), top[1].Count)
+	assert.Equal(t, uint64(10), top[1].Error)
+
+	ss.Reset()
+	assert.Equal(t, 0, ss.Len())
+}
+
+func TestHyperLogLog_Accuracy(t *testing.T) {
+	for _, n := range []int{10, 1000, 50000} {
+		h := NewHyperLogLog(12)
+		for i := 0; i < n; i++ {
+			key := fmt.Sprintf("192.168.%d.%d", i/256, i%256)
+			h.Add(key)
+			h.Add(key) // duplicates must not change the estimate
+		}
+		assert.InEpsilon(t, float64(n), float64(h.Count()), 0.05, "n=%d", n)
+	}
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a := NewHyperLogLog(10)
+	b := NewHyperLogLog(10)
+	for i := 0; i < 500; i++ {
+		a.Add(fmt.Sprintf("a-%d", i))
+		b.Add(fmt.Sprintf("b-%d", i))
+	}
+
+	require.NoError(t, a.Merge(b))
+	assert.InEpsilon(t, 1000.0, float64(a.Count()), 0.08)
+	assert.Error(t, a.Merge(NewHyperLogLog(12)))
+
+	a.Reset()
+	assert.Equal(t, uint64(0), a.Count())
+}
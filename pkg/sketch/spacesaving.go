@@ -0,0 +1,115 @@
+package sketch
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Counter is a heavy-hitter candidate tracked by a Space-Saving sketch.
+// Count over-estimates the true weight of Key by at most Error.
+type Counter struct {
+	Key   string
+	Count uint64
+	Error uint64
+}
+
+// SpaceSaving tracks the heaviest keys of a weighted stream using a fixed
+// number of counters
+type SpaceSaving struct {
+	capacity int
+	index    map[string]int
+	counters counterHeap
+}
+
+// NewSpaceSaving creates a Space-Saving sketch holding at most capacity counters
+func NewSpaceSaving(capacity int) *SpaceSaving {
+	if capacity < 1 {
+		capacity = 1
+	}
+	s := &SpaceSaving{capacity: capacity}
+	s.Reset()
+	return s
+}
+
+// Add increases the weight of key. When the sketch is full the lightest
+// counter is replaced and its count carried over as the error bound.
+func (s *SpaceSaving) Add(key string, weight uint64) {
+	if i, ok := s.index[key]; ok {
+		s.counters.items[i].Count += weight
+		heap.Fix(&s.counters, i)
+		return
+	}
+
+	if s.counters.Len() < s.capacity {
+		heap.Push(&s.counters, Counter{Key: key, Count: weight})
+		return
+	}
+
+	min := s.counters.items[0]
+	delete(s.index, min.Key)
+	s.counters.items[0] = Counter{Key: key, Count: min.Count + weight, Error: min.Count}
+	s.index[key] = 0
+	heap.Fix(&s.counters, 0)
+}
+
+// Top returns the n heaviest counters in descending order of count.
+// A non-positive n returns every counter.
+func (s *SpaceSaving) Top(n int) []Counter {
+	counters := make([]Counter, len(s.counters.items))
+	copy(counters, s.counters.items)
+	SortCounters(counters)
+	if n > 0 && len(counters) > n {
+		counters = counters[:n]
+	}
+	return counters
+}
+
+// Len returns the number of tracked counters
+func (s *SpaceSaving) Len() int {
+	return s.counters.Len()
+}
+
+// Reset drops every counter
+func (s *SpaceSaving) Reset() {
+	s.index = make(map[string]int, s.capacity)
+	s.counters = counterHeap{index: s.index}
+}
+
+// SortCounters orders counters by descending count, breaking ties by key
+func SortCounters(counters []Counter) {
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Count != counters[j].Count {
+			return counters[i].Count > counters[j].Count
+		}
+		return counters[i].Key < counters[j].Key
+	})
+}
+
+// counterHeap is a min-heap of counters that keeps a key to position index
+type counterHeap struct {
+	items []Counter
+	index map[string]int
+}
+
+func (h counterHeap) Len() int { return len(h.items) }
+
+func (h counterHeap) Less(i, j int) bool { return h.items[i].Count < h.items[j].Count }
+
+func (h counterHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.index[h.items[i].Key] = i
+	h.index[h.items[j].Key] = j
+}
+
+func (h *counterHeap) Push(x any) {
+	c := x.(Counter)
+	h.index[c.Key] = len(h.items)
+	h.items = append(h.items, c)
+}
+
+func (h *counterHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, last.Key)
+	return last
+}
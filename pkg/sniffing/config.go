@@ -0,0 +1,232 @@
+package sniffing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+)
+
+// Rate profiles controlling how many packets are captured per tick
+const (
+	// RateConstant captures one packet per tick
+	RateConstant = "constant"
+	// RateBursty mostly captures one packet per tick, with occasional
+	// bursts of up to 20
+	RateBursty = "bursty"
+	// RateDiurnal follows the time of day, from one packet per tick at
+	// midnight to five at noon
+	RateDiurnal = "diurnal"
+)
+
+// Bounds of the configurable capture parameters
+const (
+	MinInterval  = 10 * time.Millisecond
+	MaxInterval  = time.Hour
+	MaxAddresses = 10000
+	MaxPorts     = 10000
+)
+
+// Protocols are the protocols the sniffer can generate
+var Protocols = []string{"TCP", "UDP", "ICMP", "HTTP", "HTTPS"}
+
+// ErrInvalidConfig is returned for capture parameters that fail validation
+var ErrInvalidConfig = errors.New("invalid sniffer configuration")
+
+// DefaultConfig returns the capture parameters used until the sniffer is
+// reconfigured
+func DefaultConfig(interval time.Duration) models.SnifferConfig {
+	return models.SnifferConfig{
+		Interval:    models.Duration(interval),
+		RateProfile: RateConstant,
+		ProtocolWeights: map[string]float64{
+			"TCP": 1, "UDP": 1, "HTTP": 1, "HTTPS": 1,
+		},
+		Addresses: []string{
+			"192.168.1.1", "192.168.1.100", "192.168.1.101", "192.168.1.102",
+			"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4",
+			"172.16.0.1", "172.16.0.2", "172.16.0.3",
+			"8.8.8.8", "1.1.1.1", "208.67.222.222", // DNS servers
+			"142.250.190.78", "151.101.1.69", "104.16.124.96", // Google, Reddit, Cloudflare
+		},
+		Ports: []int{
+			80, 443, 22, 21, 25, 53, 110, 143, 993, 995, // Common ports
+			8080, 8443, 3000, 5000, 8000, 9000, // Development ports
+		},
+	}
+}
+
+// ValidateConfig checks capture parameters before they are applied
+func ValidateConfig(config models.SnifferConfig) error {
+	interval := time.Duration(config.Interval)
+	if interval < MinInterval || interval > MaxInterval {
+		return fmt.Errorf("%w: interval must be between %s and %s", ErrInvalidConfig, MinInterval, MaxInterval)
+	}
+
+	switch config.RateProfile {
+	case RateConstant, RateBursty, RateDiurnal:
+	default:
+		return fmt.Errorf("%w: rate_profile must be one of %s, %s, %s", ErrInvalidConfig, RateConstant, RateBursty, RateDiurnal)
+	}
+
+	total := 0.0
+	for protocol, weight := range config.ProtocolWeights {
+		if !supportedProtocol(protocol) {
+			return fmt.Errorf("%w: unsupported protocol %q", ErrInvalidConfig, protocol)
+		}
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return fmt.Errorf("%w: weight of %s must be a non-negative number", ErrInvalidConfig, protocol)
+		}
+		total += weight
+	}
+	if total <= 0 {
+		return fmt.Errorf("%w: protocol_weights must give at least one protocol a positive weight", ErrInvalidConfig)
+	}
+
+	if len(config.Addresses) > MaxAddresses {
+		return fmt.Errorf("%w: at most %d addresses are allowed", ErrInvalidConfig, MaxAddresses)
+	}
+	distinct := make(map[string]bool, len(config.Addresses))
+	for _, address := range config.Addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return fmt.Errorf("%w: invalid address %q", ErrInvalidConfig, address)
+		}
+		distinct[ip.String()] = true
+	}
+	if len(distinct) < 2 {
+		return fmt.Errorf("%w: addresses must hold at least two distinct addresses", ErrInvalidConfig)
+	}
+
+	if len(config.Ports) == 0 || len(config.Ports) > MaxPorts {
+		return fmt.Errorf("%w: ports must hold between 1 and %d ports", ErrInvalidConfig, MaxPorts)
+	}
+	for _, port := range config.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("%w: invalid port %d", ErrInvalidConfig, port)
+		}
+	}
+
+	if config.ScanProbability < 0 || config.ScanProbability > 1 {
+		return fmt.Errorf("%w: scan_probability must be between 0 and 1", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// LoadConfig reads capture parameters saved by SaveConfig. It returns nil
+// when the file does not exist.
+func LoadConfig(path string) (*models.SnifferConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sniffer configuration: %w", err)
+	}
+
+	var config models.SnifferConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse sniffer configuration: %w", err)
+	}
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// SaveConfig writes capture parameters to path, replacing the file
+// atomically
+func SaveConfig(path string, config models.SnifferConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save sniffer configuration: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save sniffer configuration: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save sniffer configuration: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save sniffer configuration: %w", err)
+	}
+	return nil
+}
+
+// protocolPicker draws protocols according to their weights
+type protocolPicker struct {
+	protocols  []string
+	cumulative []float64
+}
+
+// newProtocolPicker builds a picker from validated weights
+func newProtocolPicker(weights map[string]float64) protocolPicker {
+	protocols := make([]string, 0, len(weights))
+	for protocol, weight := range weights {
+		if weight > 0 {
+			protocols = append(protocols, protocol)
+		}
+	}
+	sort.Strings(protocols)
+
+	cumulative := make([]float64, len(protocols))
+	total := 0.0
+	for i, protocol := range protocols {
+		total += weights[protocol]
+		cumulative[i] = total
+	}
+	return protocolPicker{protocols: protocols, cumulative: cumulative}
+}
+
+// pick returns a random protocol
+func (p protocolPicker) pick() string {
+	r := rand.Float64() * p.cumulative[len(p.cumulative)-1]
+	i := sort.SearchFloat64s(p.cumulative, r)
+	if i >= len(p.protocols) {
+		i = len(p.protocols) - 1
+	}
+	return p.protocols[i]
+}
+
+// packetsPerTick returns how many packets a rate profile captures at now
+func packetsPerTick(profile string, now time.Time) int {
+	switch profile {
+	case RateBursty:
+		if rand.Float64() < 0.1 {
+			return 5 + rand.Intn(16)
+		}
+		return 1
+	case RateDiurnal:
+		hour := float64(now.Hour()) + float64(now.Minute())/60
+		level := (1 - math.Cos(2*math.Pi*hour/24)) / 2
+		return 1 + int(math.Round(4*level))
+	default:
+		return 1
+	}
+}
+
+// supportedProtocol reports whether the sniffer can generate protocol
+func supportedProtocol(protocol string) bool {
+	for _, p := range Protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
@@ -2,12 +2,22 @@ package sniffing
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"math/rand"
+	"sync"
 	"time"
 
+	"github.com/cryptonextsecurity/network-sniffer/internal/logging"
+	"github.com/cryptonextsecurity/network-sniffer/internal/metrics"
 	"github.com/cryptonextsecurity/network-sniffer/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/cryptonextsecurity/network-sniffer/pkg/sniffing")
+
 // Sniffer defines the interface for packet sniffing
 type Sniffer interface {
 	// Start begins the sniffing process
@@ -20,15 +30,42 @@ type Sniffer interface {
 	IsRunning() bool
 }
 
+// Configurable is implemented by sniffers whose capture parameters can
+// change while they run
+type Configurable interface {
+	Config() models.SnifferConfig
+	Configure(config models.SnifferConfig) error
+}
+
+// Reasons reported by a sniffer that is not capturing
+const (
+	StopReasonNotStarted = "not started"
+	StopReasonRequested  = "stop requested"
+)
+
+// Status describes the capture state of a sniffer
+type Status struct {
+	Running     bool
+	Interval    time.Duration
+	StartedAt   time.Time
+	LastCapture time.Time
+	StopReason  string
+}
+
 // PacketSniffer implements the Sniffer interface with simulated packet capture
 type PacketSniffer struct {
-	storage     Storage
-	interval    time.Duration
-	isRunning   bool
-	stopChan    chan struct{}
-	commonIPs   []string
-	commonPorts []int
-	protocols   []string
+	storage      Storage
+	mutex        sync.Mutex
+	config       models.SnifferConfig
+	protocols    protocolPicker
+	reconfigured chan struct{}
+	isRunning    bool
+	stopChan     chan struct{}
+	startedAt    time.Time
+	lastCapture  time.Time
+	stopReason   string
+	scans        *ScanGenerator
+	logger       *slog.Logger
 }
 
 // Storage defines the interface for packet storage
@@ -38,47 +75,128 @@ type Storage interface {
 
 // NewPacketSniffer creates a new packet sniffer instance
 func NewPacketSniffer(storage Storage, interval time.Duration) *PacketSniffer {
+	config := DefaultConfig(interval)
 	return &PacketSniffer{
-		storage:  storage,
-		interval: interval,
-		stopChan: make(chan struct{}),
-		commonIPs: []string{
-			"192.168.1.1", "192.168.1.100", "192.168.1.101", "192.168.1.102",
-			"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4",
-			"172.16.0.1", "172.16.0.2", "172.16.0.3",
-			"8.8.8.8", "1.1.1.1", "208.67.222.222", // DNS servers
-			"142.250.190.78", "151.101.1.69", "104.16.124.96", // Google, Reddit, Cloudflare
-		},
-		commonPorts: []int{
-			80, 443, 22, 21, 25, 53, 110, 143, 993, 995, // Common ports
-			8080, 8443, 3000, 5000, 8000, 9000, // Development ports
-		},
-		protocols: []string{"TCP", "UDP", "HTTP", "HTTPS"},
+		storage:      storage,
+		config:       config,
+		protocols:    newProtocolPicker(config.ProtocolWeights),
+		reconfigured: make(chan struct{}, 1),
+		stopReason:   StopReasonNotStarted,
+		scans:        NewScanGenerator(10 * time.Millisecond),
+		logger:       slog.Default(),
 	}
 }
 
-// Start begins the sniffing process
+// SetLogger sets the logger receiving lifecycle changes and dropped
+// packets. A nil logger selects the default logger.
+func (s *PacketSniffer) SetLogger(logger *slog.Logger) {
+	s.logger = logging.OrDefault(logger)
+}
+
+// SetScanProbability sets the chance, per tick, of also emitting a simulated
+// port scan or host sweep. Zero disables scan simulation.
+func (s *PacketSniffer) SetScanProbability(probability float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.config.ScanProbability = probability
+}
+
+// Config returns the current capture parameters
+func (s *PacketSniffer) Config() models.SnifferConfig {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return copyConfig(s.config)
+}
+
+// Configure validates and applies new capture parameters. A running
+// sniffer picks them up from its next tick, without restarting.
+func (s *PacketSniffer) Configure(config models.SnifferConfig) error {
+	if err := ValidateConfig(config); err != nil {
+		return err
+	}
+	config = copyConfig(config)
+
+	s.mutex.Lock()
+	s.config = config
+	s.protocols = newProtocolPicker(config.ProtocolWeights)
+	s.mutex.Unlock()
+
+	// Let a running capture loop reset its ticker
+	select {
+	case s.reconfigured <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// snapshot returns the capture parameters to use for one tick
+func (s *PacketSniffer) snapshot() (models.SnifferConfig, protocolPicker) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.config, s.protocols
+}
+
+// copyConfig deep copies capture parameters so callers cannot change them
+// behind the sniffer's back
+func copyConfig(config models.SnifferConfig) models.SnifferConfig {
+	weights := make(map[string]float64, len(config.ProtocolWeights))
+	for protocol, weight := range config.ProtocolWeights {
+		weights[protocol] = weight
+	}
+	config.ProtocolWeights = weights
+	config.Addresses = append([]string(nil), config.Addresses...)
+	config.Ports = append([]int(nil), config.Ports...)
+	return config
+}
+
+// Start begins the sniffing process. Capture runs until Stop is called or
+// ctx is cancelled; a stopped sniffer can be started again.
 func (s *PacketSniffer) Start(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	if s.isRunning {
 		return nil
 	}
 
+	stop := make(chan struct{})
+	s.stopChan = stop
 	s.isRunning = true
+	s.startedAt = time.Now()
+	s.stopReason = ""
+	interval := time.Duration(s.config.Interval)
+	metrics.SnifferRunning.Set(1)
+	s.logger.InfoContext(ctx, "Packet sniffing started", "interval", interval.String(), "rate_profile", s.config.RateProfile)
 
 	go func() {
-		ticker := time.NewTicker(s.interval)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
-				s.isRunning = false
+				s.stopped(stop, ctx.Err().Error())
+				s.logger.Info("Packet sniffing stopped", "reason", ctx.Err().Error())
 				return
-			case <-s.stopChan:
-				s.isRunning = false
+			case <-stop:
 				return
-			case <-ticker.C:
-				s.generateAndStorePacket(ctx)
+			case <-s.reconfigured:
+				config, _ := s.snapshot()
+				ticker.Reset(time.Duration(config.Interval))
+			case now := <-ticker.C:
+				config, _ := s.snapshot()
+				for i := packetsPerTick(config.RateProfile, now); i > 0; i-- {
+					s.generateAndStorePacket(ctx)
+				}
+				if config.ScanProbability > 0 && rand.Float64() < config.ScanProbability {
+					s.generateAndStoreScan(ctx)
+				}
+				s.mutex.Lock()
+				s.lastCapture = time.Now()
+				s.mutex.Unlock()
 			}
 		}
 	}()
@@ -88,47 +206,132 @@ func (s *PacketSniffer) Start(ctx context.Context) error {
 
 // Stop stops the sniffing process
 func (s *PacketSniffer) Stop(ctx context.Context) error {
+	s.mutex.Lock()
 	if !s.isRunning {
+		s.mutex.Unlock()
 		return nil
 	}
+	stop := s.stopChan
+	s.mutex.Unlock()
 
-	close(s.stopChan)
-	s.isRunning = false
+	close(stop)
+	s.stopped(stop, StopReasonRequested)
+	s.logger.InfoContext(ctx, "Packet sniffing stopped", "reason", StopReasonRequested)
 	return nil
 }
 
+// stopped records the end of the capture run owning stop
+func (s *PacketSniffer) stopped(stop chan struct{}, reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopChan != stop || !s.isRunning {
+		return
+	}
+	s.isRunning = false
+	s.stopReason = reason
+	metrics.SnifferRunning.Set(0)
+}
+
 // IsRunning returns true if sniffing is active
 func (s *PacketSniffer) IsRunning() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	return s.isRunning
 }
 
+// Status returns the capture state of the sniffer
+func (s *PacketSniffer) Status() Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return Status{
+		Running:     s.isRunning,
+		Interval:    time.Duration(s.config.Interval),
+		StartedAt:   s.startedAt,
+		LastCapture: s.lastCapture,
+		StopReason:  s.stopReason,
+	}
+}
+
 // generateAndStorePacket creates a simulated packet and stores it
 func (s *PacketSniffer) generateAndStorePacket(ctx context.Context) {
 	packet := s.generateRandomPacket()
+	metrics.PacketsGenerated.WithLabelValues(packet.Protocol).Inc()
+
+	ctx, span := tracer.Start(ctx, "sniffer.capture", trace.WithNewRoot(), trace.WithAttributes(
+		attribute.String("packet.id", packet.ID),
+		attribute.String("packet.protocol", packet.Protocol),
+	))
+	defer span.End()
 
 	if err := s.storage.Store(ctx, packet); err != nil {
-		// In a real application, we might log this error
-		// For now, we'll just ignore it to keep the simulation running
-		_ = err
+		s.drop(ctx, packet, err)
+	}
+}
+
+// generateAndStoreScan simulates a scan burst from a random source
+func (s *PacketSniffer) generateAndStoreScan(ctx context.Context) {
+	packets := s.generateRandomScan(time.Now())
+	ctx, span := tracer.Start(ctx, "sniffer.scan", trace.WithNewRoot(), trace.WithAttributes(
+		attribute.Int("scan.packets", len(packets)),
+	))
+	defer span.End()
+
+	for _, packet := range packets {
+		metrics.PacketsGenerated.WithLabelValues(packet.Protocol).Inc()
+		if err := s.storage.Store(ctx, packet); err != nil {
+			s.drop(ctx, packet, err)
+		}
+	}
+}
+
+// drop accounts for a packet storage refused. Capture keeps running.
+func (s *PacketSniffer) drop(ctx context.Context, packet *models.Packet, err error) {
+	metrics.PacketsDropped.WithLabelValues(packet.Protocol, "store_error").Inc()
+	s.logger.WarnContext(ctx, "Dropped packet",
+		"packet_id", packet.ID,
+		"protocol", packet.Protocol,
+		"source_ip", packet.SourceIP,
+		"destination_ip", packet.DestinationIP,
+		"error", err.Error(),
+	)
+}
+
+// generateRandomScan creates the packets of a random scan kind
+func (s *PacketSniffer) generateRandomScan(start time.Time) []*models.Packet {
+	config, _ := s.snapshot()
+	source, target := randomPair(config.Addresses)
+	firstPort := rand.Intn(1000) + 1
+	ports := PortRange(firstPort, firstPort+49)
+
+	switch rand.Intn(3) {
+	case 0:
+		return s.scans.Vertical(source, target, ports, start)
+	case 1:
+		targets := make([]string, 0, 50)
+		for i := 1; i <= 50; i++ {
+			targets = append(targets, fmt.Sprintf("10.1.0.%d", i))
+		}
+		return s.scans.Horizontal(source, targets, config.Ports[rand.Intn(len(config.Ports))], start)
+	default:
+		return s.scans.HalfOpen(source, target, ports, start)
 	}
 }
 
 // generateRandomPacket creates a realistic packet with random data
 func (s *PacketSniffer) generateRandomPacket() *models.Packet {
-	// Generate random source and destination IPs
-	sourceIP := s.commonIPs[rand.Intn(len(s.commonIPs))]
-	destIP := s.commonIPs[rand.Intn(len(s.commonIPs))]
+	config, protocols := s.snapshot()
 
-	// Avoid same source and destination
-	for destIP == sourceIP {
-		destIP = s.commonIPs[rand.Intn(len(s.commonIPs))]
-	}
+	// Generate distinct random source and destination IPs
+	sourceIP, destIP := randomPair(config.Addresses)
 
 	// Generate random port
-	port := s.commonPorts[rand.Intn(len(s.commonPorts))]
+	port := config.Ports[rand.Intn(len(config.Ports))]
 
-	// Generate random protocol
-	protocol := s.protocols[rand.Intn(len(s.protocols))]
+	// Generate a protocol according to the configured mix
+	protocol := protocols.pick()
 
 	// Generate random packet size (64-1500 bytes)
 	size := rand.Intn(1436) + 64
@@ -141,8 +344,10 @@ func (s *PacketSniffer) generateRandomPacket() *models.Packet {
 		packet.TTL = rand.Intn(64) + 32
 	}
 
+	// Established connections mostly acknowledge; bare SYNs are left to
+	// the scan generator, as the scan detector takes them for probes
 	if rand.Float32() < 0.2 {
-		flags := []string{"SYN", "ACK", "FIN", "RST", "PSH", "URG"}
+		flags := []string{"PSH,ACK", "FIN,ACK", "RST,ACK", "SYN,ACK", "URG,ACK"}
 		packet.Flags = flags[rand.Intn(len(flags))]
 	}
 
@@ -155,7 +360,19 @@ func (s *PacketSniffer) generateRandomPacket() *models.Packet {
 			"DELETE /item/123 HTTP/1.1",
 		}
 		packet.Payload = payloads[rand.Intn(len(payloads))]
+		packet.Flags = "PSH,ACK"
 	}
 
 	return packet
 }
+
+// randomPair returns two distinct addresses of a pool holding at least two
+// distinct addresses
+func randomPair(addresses []string) (string, string) {
+	source := addresses[rand.Intn(len(addresses))]
+	destination := addresses[rand.Intn(len(addresses))]
+	for destination == source {
+		destination = addresses[rand.Intn(len(addresses))]
+	}
+	return source, destination
+}
@@ -1,10 +1,15 @@
 package sniffing
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/cryptonextsecurity/network-sniffer/internal/detection"
 	"github.com/cryptonextsecurity/network-sniffer/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,6 +25,13 @@ func (m *MockStorage) Store(ctx context.Context, packet *models.Packet) error {
 	return nil
 }
 
+// FailingStorage rejects every packet
+type FailingStorage struct{}
+
+func (FailingStorage) Store(ctx context.Context, packet *models.Packet) error {
+	return errors.New("storage unavailable")
+}
+
 func TestPacketSniffer_Start(t *testing.T) {
 	mockStorage := &MockStorage{}
 	sniffer := NewPacketSniffer(mockStorage, 100*time.Millisecond)
@@ -154,9 +166,176 @@ func TestGenerateRandomPacket(t *testing.T) {
 	assert.Contains(t, validProtocols, packet.Protocol)
 
 	// Verify IP addresses are valid
-	assert.Contains(t, sniffer.commonIPs, packet.SourceIP)
-	assert.Contains(t, sniffer.commonIPs, packet.DestinationIP)
+	assert.Contains(t, sniffer.Config().Addresses, packet.SourceIP)
+	assert.Contains(t, sniffer.Config().Addresses, packet.DestinationIP)
 
 	// Verify port is from common ports
-	assert.Contains(t, sniffer.commonPorts, packet.Port)
+	assert.Contains(t, sniffer.Config().Ports, packet.Port)
+}
+
+func TestPacketSniffer_DefaultTrafficIsNotAScan(t *testing.T) {
+	sniffer := NewPacketSniffer(&MockStorage{}, MinInterval)
+	findings := detection.NewFindingStore(100)
+	detector := detection.NewScanDetector(detection.DefaultScanConfig(), findings)
+
+	// Two detection windows of packets at the fastest capture rate
+	start := time.Now()
+	for i := 0; i < int(2*time.Minute/MinInterval); i++ {
+		packet := sniffer.generateRandomPacket()
+		packet.Timestamp = start.Add(time.Duration(i) * MinInterval)
+		detector.OnStore(packet)
+	}
+
+	assert.Empty(t, findings.List(&models.FindingFilter{Type: detection.FindingSYNScan}))
+}
+
+func TestScanGenerator(t *testing.T) {
+	start := time.Now()
+	generator := NewScanGenerator(100 * time.Millisecond)
+
+	vertical := generator.Vertical("10.0.0.66", "10.0.0.1", PortRange(20, 29), start)
+	require.Len(t, vertical, 20)
+	assert.Equal(t, "SYN", vertical[0].Flags)
+	assert.Equal(t, "ACK", vertical[1].Flags)
+	assert.Equal(t, 29, vertical[19].Port)
+	assert.Equal(t, start.Add(900*time.Millisecond+50*time.Millisecond), vertical[19].Timestamp)
+
+	halfOpen := generator.HalfOpen("10.0.0.66", "10.0.0.1", PortRange(1, 8), start)
+	for _, p := range halfOpen {
+		assert.NotEqual(t, "ACK", p.Flags, "half-open scans never complete handshakes")
+	}
+
+	ids := make(map[string]bool)
+	for _, p := range append(vertical, halfOpen...) {
+		assert.False(t, ids[p.ID], "duplicate packet ID %s", p.ID)
+		ids[p.ID] = true
+	}
+}
+
+func TestPacketSniffer_ScanSimulation(t *testing.T) {
+	mockStorage := &MockStorage{}
+	sniffer := NewPacketSniffer(mockStorage, 1*time.Second)
+
+	packets := sniffer.generateRandomScan(time.Now())
+	assert.GreaterOrEqual(t, len(packets), 50)
+	for _, p := range packets {
+		assert.NotEqual(t, p.SourceIP, p.DestinationIP)
+		assert.Equal(t, "TCP", p.Protocol)
+	}
+}
+
+func TestPacketSniffer_LogsDroppedPackets(t *testing.T) {
+	var out bytes.Buffer
+	sniffer := NewPacketSniffer(FailingStorage{}, time.Second)
+	sniffer.SetLogger(slog.New(slog.NewTextHandler(&out, nil)))
+
+	sniffer.generateAndStorePacket(context.Background())
+
+	assert.Contains(t, out.String(), `msg="Dropped packet"`)
+	assert.Contains(t, out.String(), `error="storage unavailable"`)
+}
+
+func TestPacketSniffer_RestartAndStatus(t *testing.T) {
+	mockStorage := &MockStorage{}
+	sniffer := NewPacketSniffer(mockStorage, 20*time.Millisecond)
+	ctx := context.Background()
+
+	status := sniffer.Status()
+	assert.False(t, status.Running)
+	assert.Equal(t, StopReasonNotStarted, status.StopReason)
+
+	require.NoError(t, sniffer.Start(ctx))
+	require.NoError(t, sniffer.Stop(ctx))
+	assert.Equal(t, StopReasonRequested, sniffer.Status().StopReason)
+
+	// A stopped sniffer captures again once restarted
+	require.NoError(t, sniffer.Start(ctx))
+	time.Sleep(70 * time.Millisecond)
+	status = sniffer.Status()
+	assert.True(t, status.Running)
+	assert.Empty(t, status.StopReason)
+	assert.False(t, status.LastCapture.IsZero())
+	require.NoError(t, sniffer.Stop(ctx))
+}
+
+func TestPacketSniffer_Configure(t *testing.T) {
+	mockStorage := &MockStorage{}
+	sniffer := NewPacketSniffer(mockStorage, time.Hour)
+	ctx := context.Background()
+
+	require.NoError(t, sniffer.Start(ctx))
+	defer sniffer.Stop(ctx)
+
+	config := sniffer.Config()
+	config.Interval = models.Duration(20 * time.Millisecond)
+	config.ProtocolWeights = map[string]float64{"UDP": 1, "TCP": 0}
+	config.Addresses = []string{"10.9.0.1", "10.9.0.2"}
+	config.Ports = []int{5353}
+	require.NoError(t, sniffer.Configure(config))
+
+	// The running capture switches from an hourly to a 20ms interval
+	time.Sleep(150 * time.Millisecond)
+	sniffer.Stop(ctx)
+	require.NotEmpty(t, mockStorage.packets)
+	for _, packet := range mockStorage.packets {
+		assert.Equal(t, "UDP", packet.Protocol)
+		assert.Contains(t, config.Addresses, packet.SourceIP)
+		assert.Equal(t, 5353, packet.Port)
+	}
+
+	// Changing the returned config does not change the sniffer
+	config.Ports[0] = 1
+	assert.Equal(t, []int{5353}, sniffer.Config().Ports)
+}
+
+func TestValidateConfig(t *testing.T) {
+	valid := DefaultConfig(time.Second)
+	require.NoError(t, ValidateConfig(valid))
+
+	tests := map[string]func(c *models.SnifferConfig){
+		"interval too short": func(c *models.SnifferConfig) { c.Interval = models.Duration(time.Millisecond) },
+		"unknown profile":    func(c *models.SnifferConfig) { c.RateProfile = "sometimes" },
+		"unknown protocol":   func(c *models.SnifferConfig) { c.ProtocolWeights = map[string]float64{"SCTP": 1} },
+		"negative weight":    func(c *models.SnifferConfig) { c.ProtocolWeights = map[string]float64{"TCP": -1, "UDP": 2} },
+		"zero weights":       func(c *models.SnifferConfig) { c.ProtocolWeights = map[string]float64{"TCP": 0} },
+		"invalid address":    func(c *models.SnifferConfig) { c.Addresses = []string{"10.0.0.1", "10.0.0.300"} },
+		"single address":     func(c *models.SnifferConfig) { c.Addresses = []string{"10.0.0.1", "10.0.0.1"} },
+		"no ports":           func(c *models.SnifferConfig) { c.Ports = nil },
+		"invalid port":       func(c *models.SnifferConfig) { c.Ports = []int{0} },
+		"scan probability":   func(c *models.SnifferConfig) { c.ScanProbability = 1.5 },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := copyConfig(valid)
+			mutate(&config)
+			assert.ErrorIs(t, ValidateConfig(config), ErrInvalidConfig)
+		})
+	}
+}
+
+func TestSaveAndLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sniffing.json")
+
+	loaded, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Nil(t, loaded)
+
+	config := DefaultConfig(2 * time.Second)
+	config.RateProfile = RateDiurnal
+	require.NoError(t, SaveConfig(path, config))
+
+	loaded, err = LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, config, *loaded)
+}
+
+func TestPacketsPerTick(t *testing.T) {
+	midnight := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 1, packetsPerTick(RateConstant, midnight))
+	assert.Equal(t, 1, packetsPerTick(RateDiurnal, midnight))
+	assert.Equal(t, 5, packetsPerTick(RateDiurnal, midnight.Add(12*time.Hour)))
+	for i := 0; i < 100; i++ {
+		n := packetsPerTick(RateBursty, midnight)
+		assert.True(t, n == 1 || (n >= 5 && n <= 20), n)
+	}
 }
@@ -0,0 +1,670 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: sniffer/v1/sniffer.proto
+
+package snifferv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SnifferService_ListPackets_FullMethodName      = "/sniffer.v1.SnifferService/ListPackets"
+	SnifferService_GetPacket_FullMethodName        = "/sniffer.v1.SnifferService/GetPacket"
+	SnifferService_DeletePacket_FullMethodName     = "/sniffer.v1.SnifferService/DeletePacket"
+	SnifferService_ClearPackets_FullMethodName     = "/sniffer.v1.SnifferService/ClearPackets"
+	SnifferService_StartSniffing_FullMethodName    = "/sniffer.v1.SnifferService/StartSniffing"
+	SnifferService_StopSniffing_FullMethodName     = "/sniffer.v1.SnifferService/StopSniffing"
+	SnifferService_GetSnifferStatus_FullMethodName = "/sniffer.v1.SnifferService/GetSnifferStatus"
+	SnifferService_GetStats_FullMethodName         = "/sniffer.v1.SnifferService/GetStats"
+	SnifferService_Subscribe_FullMethodName        = "/sniffer.v1.SnifferService/Subscribe"
+)
+
+// SnifferServiceClient is the client API for SnifferService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SnifferServiceClient interface {
+	// ListPackets returns the stored packets matching a filter, oldest first.
+	ListPackets(ctx context.Context, in *ListPacketsRequest, opts ...grpc.CallOption) (*ListPacketsResponse, error)
+	// GetPacket returns a packet by ID, or fails with NOT_FOUND.
+	GetPacket(ctx context.Context, in *GetPacketRequest, opts ...grpc.CallOption) (*Packet, error)
+	// DeletePacket removes a packet by ID. Requires the admin role.
+	DeletePacket(ctx context.Context, in *DeletePacketRequest, opts ...grpc.CallOption) (*DeletePacketResponse, error)
+	// ClearPackets removes every stored packet. Requires the admin role.
+	ClearPackets(ctx context.Context, in *ClearPacketsRequest, opts ...grpc.CallOption) (*ClearPacketsResponse, error)
+	// StartSniffing starts capture. Requires the analyst role.
+	StartSniffing(ctx context.Context, in *StartSniffingRequest, opts ...grpc.CallOption) (*StartSniffingResponse, error)
+	// StopSniffing stops capture. Requires the analyst role.
+	StopSniffing(ctx context.Context, in *StopSniffingRequest, opts ...grpc.CallOption) (*StopSniffingResponse, error)
+	// GetSnifferStatus reports whether capture is running.
+	GetSnifferStatus(ctx context.Context, in *GetSnifferStatusRequest, opts ...grpc.CallOption) (*SnifferStatus, error)
+	// GetStats returns storage statistics and rolling traffic summaries.
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error)
+	// Subscribe streams the live packets matching a filter as they are
+	// stored, until the client cancels the call or the server shuts down.
+	// Packets of uploaded captures are not streamed.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (SnifferService_SubscribeClient, error)
+}
+
+type snifferServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSnifferServiceClient(cc grpc.ClientConnInterface) SnifferServiceClient {
+	return &snifferServiceClient{cc}
+}
+
+func (c *snifferServiceClient) ListPackets(ctx context.Context, in *ListPacketsRequest, opts ...grpc.CallOption) (*ListPacketsResponse, error) {
+	out := new(ListPacketsResponse)
+	err := c.cc.Invoke(ctx, SnifferService_ListPackets_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snifferServiceClient) GetPacket(ctx context.Context, in *GetPacketRequest, opts ...grpc.CallOption) (*Packet, error) {
+	out := new(Packet)
+	err := c.cc.Invoke(ctx, SnifferService_GetPacket_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snifferServiceClient) DeletePacket(ctx context.Context, in *DeletePacketRequest, opts ...grpc.CallOption) (*DeletePacketResponse, error) {
+	out := new(DeletePacketResponse)
+	err := c.cc.Invoke(ctx, SnifferService_DeletePacket_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snifferServiceClient) ClearPackets(ctx context.Context, in *ClearPacketsRequest, opts ...grpc.CallOption) (*ClearPacketsResponse, error) {
+	out := new(ClearPacketsResponse)
+	err := c.cc.Invoke(ctx, SnifferService_ClearPackets_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snifferServiceClient) StartSniffing(ctx context.Context, in *StartSniffingRequest, opts ...grpc.CallOption) (*StartSniffingResponse, error) {
+	out := new(StartSniffingResponse)
+	err := c.cc.Invoke(ctx, SnifferService_StartSniffing_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snifferServiceClient) StopSniffing(ctx context.Context, in *StopSniffingRequest, opts ...grpc.CallOption) (*StopSniffingResponse, error) {
+	out := new(StopSniffingResponse)
+	err := c.cc.Invoke(ctx, SnifferService_StopSniffing_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snifferServiceClient) GetSnifferStatus(ctx context.Context, in *GetSnifferStatusRequest, opts ...grpc.CallOption) (*SnifferStatus, error) {
+	out := new(SnifferStatus)
+	err := c.cc.Invoke(ctx, SnifferService_GetSnifferStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snifferServiceClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*Stats, error) {
+	out := new(Stats)
+	err := c.cc.Invoke(ctx, SnifferService_GetStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snifferServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (SnifferService_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SnifferService_ServiceDesc.Streams[0], SnifferService_Subscribe_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &snifferServiceSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SnifferService_SubscribeClient interface {
+	Recv() (*SubscribeResponse, error)
+	grpc.ClientStream
+}
+
+type snifferServiceSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *snifferServiceSubscribeClient) Recv() (*SubscribeResponse, error) {
+	m := new(SubscribeResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SnifferServiceServer is the server API for SnifferService service.
+// All implementations must embed UnimplementedSnifferServiceServer
+// for forward compatibility
+type SnifferServiceServer interface {
+	// ListPackets returns the stored packets matching a filter, oldest first.
+	ListPackets(context.Context, *ListPacketsRequest) (*ListPacketsResponse, error)
+	// GetPacket returns a packet by ID, or fails with NOT_FOUND.
+	GetPacket(context.Context, *GetPacketRequest) (*Packet, error)
+	// DeletePacket removes a packet by ID. Requires the admin role.
+	DeletePacket(context.Context, *DeletePacketRequest) (*DeletePacketResponse, error)
+	// ClearPackets removes every stored packet. Requires the admin role.
+	ClearPackets(context.Context, *ClearPacketsRequest) (*ClearPacketsResponse, error)
+	// StartSniffing starts capture. Requires the analyst role.
+	StartSniffing(context.Context, *StartSniffingRequest) (*StartSniffingResponse, error)
+	// StopSniffing stops capture. Requires the analyst role.
+	StopSniffing(context.Context, *StopSniffingRequest) (*StopSniffingResponse, error)
+	// GetSnifferStatus reports whether capture is running.
+	GetSnifferStatus(context.Context, *GetSnifferStatusRequest) (*SnifferStatus, error)
+	// GetStats returns storage statistics and rolling traffic summaries.
+	GetStats(context.Context, *GetStatsRequest) (*Stats, error)
+	// Subscribe streams the live packets matching a filter as they are
+	// stored, until the client cancels the call or the server shuts down.
+	// Packets of uploaded captures are not streamed.
+	Subscribe(*SubscribeRequest, SnifferService_SubscribeServer) error
+	mustEmbedUnimplementedSnifferServiceServer()
+}
+
+// UnimplementedSnifferServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSnifferServiceServer struct {
+}
+
+func (UnimplementedSnifferServiceServer) ListPackets(context.Context, *ListPacketsRequest) (*ListPacketsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPackets not implemented")
+}
+func (UnimplementedSnifferServiceServer) GetPacket(context.Context, *GetPacketRequest) (*Packet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPacket not implemented")
+}
+func (UnimplementedSnifferServiceServer) DeletePacket(context.Context, *DeletePacketRequest) (*DeletePacketResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePacket not implemented")
+}
+func (UnimplementedSnifferServiceServer) ClearPackets(context.Context, *ClearPacketsRequest) (*ClearPacketsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClearPackets not implemented")
+}
+func (UnimplementedSnifferServiceServer) StartSniffing(context.Context, *StartSniffingRequest) (*StartSniffingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartSniffing not implemented")
+}
+func (UnimplementedSnifferServiceServer) StopSniffing(context.Context, *StopSniffingRequest) (*StopSniffingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopSniffing not implemented")
+}
+func (UnimplementedSnifferServiceServer) GetSnifferStatus(context.Context, *GetSnifferStatusRequest) (*SnifferStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSnifferStatus not implemented")
+}
+func (UnimplementedSnifferServiceServer) GetStats(context.Context, *GetStatsRequest) (*Stats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedSnifferServiceServer) Subscribe(*SubscribeRequest, SnifferService_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedSnifferServiceServer) mustEmbedUnimplementedSnifferServiceServer() {}
+
+// UnsafeSnifferServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SnifferServiceServer will
+// result in compilation errors.
+type UnsafeSnifferServiceServer interface {
+	mustEmbedUnimplementedSnifferServiceServer()
+}
+
+func RegisterSnifferServiceServer(s grpc.ServiceRegistrar, srv SnifferServiceServer) {
+	s.RegisterService(&SnifferService_ServiceDesc, srv)
+}
+
+func _SnifferService_ListPackets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPacketsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnifferServiceServer).ListPackets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnifferService_ListPackets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnifferServiceServer).ListPackets(ctx, req.(*ListPacketsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnifferService_GetPacket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPacketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnifferServiceServer).GetPacket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnifferService_GetPacket_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnifferServiceServer).GetPacket(ctx, req.(*GetPacketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnifferService_DeletePacket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePacketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnifferServiceServer).DeletePacket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnifferService_DeletePacket_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnifferServiceServer).DeletePacket(ctx, req.(*DeletePacketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnifferService_ClearPackets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClearPacketsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnifferServiceServer).ClearPackets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnifferService_ClearPackets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnifferServiceServer).ClearPackets(ctx, req.(*ClearPacketsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnifferService_StartSniffing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartSniffingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnifferServiceServer).StartSniffing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnifferService_StartSniffing_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnifferServiceServer).StartSniffing(ctx, req.(*StartSniffingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnifferService_StopSniffing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopSniffingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnifferServiceServer).StopSniffing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnifferService_StopSniffing_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnifferServiceServer).StopSniffing(ctx, req.(*StopSniffingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnifferService_GetSnifferStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSnifferStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnifferServiceServer).GetSnifferStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnifferService_GetSnifferStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnifferServiceServer).GetSnifferStatus(ctx, req.(*GetSnifferStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnifferService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnifferServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnifferService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnifferServiceServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnifferService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SnifferServiceServer).Subscribe(m, &snifferServiceSubscribeServer{stream})
+}
+
+type SnifferService_SubscribeServer interface {
+	Send(*SubscribeResponse) error
+	grpc.ServerStream
+}
+
+type snifferServiceSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *snifferServiceSubscribeServer) Send(m *SubscribeResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// SnifferService_ServiceDesc is the grpc.ServiceDesc for SnifferService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SnifferService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sniffer.v1.SnifferService",
+	HandlerType: (*SnifferServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListPackets",
+			Handler:    _SnifferService_ListPackets_Handler,
+		},
+		{
+			MethodName: "GetPacket",
+			Handler:    _SnifferService_GetPacket_Handler,
+		},
+		{
+			MethodName: "DeletePacket",
+			Handler:    _SnifferService_DeletePacket_Handler,
+		},
+		{
+			MethodName: "ClearPackets",
+			Handler:    _SnifferService_ClearPackets_Handler,
+		},
+		{
+			MethodName: "StartSniffing",
+			Handler:    _SnifferService_StartSniffing_Handler,
+		},
+		{
+			MethodName: "StopSniffing",
+			Handler:    _SnifferService_StopSniffing_Handler,
+		},
+		{
+			MethodName: "GetSnifferStatus",
+			Handler:    _SnifferService_GetSnifferStatus_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _SnifferService_GetStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _SnifferService_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sniffer/v1/sniffer.proto",
+}
+
+const (
+	CollectorService_RegisterAgent_FullMethodName  = "/sniffer.v1.CollectorService/RegisterAgent"
+	CollectorService_ForwardPackets_FullMethodName = "/sniffer.v1.CollectorService/ForwardPackets"
+	CollectorService_ListAgents_FullMethodName     = "/sniffer.v1.CollectorService/ListAgents"
+)
+
+// CollectorServiceClient is the client API for CollectorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CollectorServiceClient interface {
+	// RegisterAgent announces an agent, or refreshes the details of a
+	// registered one. Agents register every time they connect.
+	RegisterAgent(ctx context.Context, in *RegisterAgentRequest, opts ...grpc.CallOption) (*Agent, error)
+	// ForwardPackets streams batches of packets captured by a registered
+	// agent. The packets are stored as live traffic tagged with the agent ID,
+	// under new IDs. Batches are acknowledged in order once stored; a batch
+	// whose sequence number was already acknowledged is acknowledged again
+	// without being stored, so agents can resend the batches of a broken
+	// stream.
+	ForwardPackets(ctx context.Context, opts ...grpc.CallOption) (CollectorService_ForwardPacketsClient, error)
+	// ListAgents returns the registered agents. Requires the viewer role.
+	ListAgents(ctx context.Context, in *ListAgentsRequest, opts ...grpc.CallOption) (*ListAgentsResponse, error)
+}
+
+type collectorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCollectorServiceClient(cc grpc.ClientConnInterface) CollectorServiceClient {
+	return &collectorServiceClient{cc}
+}
+
+func (c *collectorServiceClient) RegisterAgent(ctx context.Context, in *RegisterAgentRequest, opts ...grpc.CallOption) (*Agent, error) {
+	out := new(Agent)
+	err := c.cc.Invoke(ctx, CollectorService_RegisterAgent_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *collectorServiceClient) ForwardPackets(ctx context.Context, opts ...grpc.CallOption) (CollectorService_ForwardPacketsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CollectorService_ServiceDesc.Streams[0], CollectorService_ForwardPackets_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &collectorServiceForwardPacketsClient{stream}
+	return x, nil
+}
+
+type CollectorService_ForwardPacketsClient interface {
+	Send(*ForwardPacketsRequest) error
+	Recv() (*ForwardPacketsResponse, error)
+	grpc.ClientStream
+}
+
+type collectorServiceForwardPacketsClient struct {
+	grpc.ClientStream
+}
+
+func (x *collectorServiceForwardPacketsClient) Send(m *ForwardPacketsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *collectorServiceForwardPacketsClient) Recv() (*ForwardPacketsResponse, error) {
+	m := new(ForwardPacketsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *collectorServiceClient) ListAgents(ctx context.Context, in *ListAgentsRequest, opts ...grpc.CallOption) (*ListAgentsResponse, error) {
+	out := new(ListAgentsResponse)
+	err := c.cc.Invoke(ctx, CollectorService_ListAgents_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CollectorServiceServer is the server API for CollectorService service.
+// All implementations must embed UnimplementedCollectorServiceServer
+// for forward compatibility
+type CollectorServiceServer interface {
+	// RegisterAgent announces an agent, or refreshes the details of a
+	// registered one. Agents register every time they connect.
+	RegisterAgent(context.Context, *RegisterAgentRequest) (*Agent, error)
+	// ForwardPackets streams batches of packets captured by a registered
+	// agent. The packets are stored as live traffic tagged with the agent ID,
+	// under new IDs. Batches are acknowledged in order once stored; a batch
+	// whose sequence number was already acknowledged is acknowledged again
+	// without being stored, so agents can resend the batches of a broken
+	// stream.
+	ForwardPackets(CollectorService_ForwardPacketsServer) error
+	// ListAgents returns the registered agents. Requires the viewer role.
+	ListAgents(context.Context, *ListAgentsRequest) (*ListAgentsResponse, error)
+	mustEmbedUnimplementedCollectorServiceServer()
+}
+
+// UnimplementedCollectorServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCollectorServiceServer struct {
+}
+
+func (UnimplementedCollectorServiceServer) RegisterAgent(context.Context, *RegisterAgentRequest) (*Agent, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterAgent not implemented")
+}
+func (UnimplementedCollectorServiceServer) ForwardPackets(CollectorService_ForwardPacketsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ForwardPackets not implemented")
+}
+func (UnimplementedCollectorServiceServer) ListAgents(context.Context, *ListAgentsRequest) (*ListAgentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAgents not implemented")
+}
+func (UnimplementedCollectorServiceServer) mustEmbedUnimplementedCollectorServiceServer() {}
+
+// UnsafeCollectorServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CollectorServiceServer will
+// result in compilation errors.
+type UnsafeCollectorServiceServer interface {
+	mustEmbedUnimplementedCollectorServiceServer()
+}
+
+func RegisterCollectorServiceServer(s grpc.ServiceRegistrar, srv CollectorServiceServer) {
+	s.RegisterService(&CollectorService_ServiceDesc, srv)
+}
+
+func _CollectorService_RegisterAgent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterAgentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollectorServiceServer).RegisterAgent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CollectorService_RegisterAgent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollectorServiceServer).RegisterAgent(ctx, req.(*RegisterAgentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CollectorService_ForwardPackets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CollectorServiceServer).ForwardPackets(&collectorServiceForwardPacketsServer{stream})
+}
+
+type CollectorService_ForwardPacketsServer interface {
+	Send(*ForwardPacketsResponse) error
+	Recv() (*ForwardPacketsRequest, error)
+	grpc.ServerStream
+}
+
+type collectorServiceForwardPacketsServer struct {
+	grpc.ServerStream
+}
+
+func (x *collectorServiceForwardPacketsServer) Send(m *ForwardPacketsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *collectorServiceForwardPacketsServer) Recv() (*ForwardPacketsRequest, error) {
+	m := new(ForwardPacketsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CollectorService_ListAgents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAgentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CollectorServiceServer).ListAgents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CollectorService_ListAgents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CollectorServiceServer).ListAgents(ctx, req.(*ListAgentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CollectorService_ServiceDesc is the grpc.ServiceDesc for CollectorService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CollectorService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sniffer.v1.CollectorService",
+	HandlerType: (*CollectorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RegisterAgent",
+			Handler:    _CollectorService_RegisterAgent_Handler,
+		},
+		{
+			MethodName: "ListAgents",
+			Handler:    _CollectorService_ListAgents_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ForwardPackets",
+			Handler:       _CollectorService_ForwardPackets_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "sniffer/v1/sniffer.proto",
+}